@@ -0,0 +1,229 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Role tells whether a pod belongs to the revision being rolled out or the one being replaced
+type Role string
+
+const (
+	Old Role = "old"
+	New Role = "new"
+)
+
+// PodSample is one observation of a pod taking part in a rollout
+type PodSample struct {
+	Name     string
+	Role     Role
+	Revision string
+	Ready    bool
+	Restarts int32
+}
+
+// Signals are the health signals aggregated over the pods of one revision
+type Signals struct {
+	Revision       string             `json:"revision"`
+	Pods           int                `json:"pods"`
+	Restarts       int32              `json:"restarts"`
+	ReadinessFlaps int                `json:"readinessFlaps"`
+	NotReady       int                `json:"notReady"`
+	Metrics        map[string]float64 `json:"metrics,omitempty"`
+}
+
+// Target identifies the workload under analysis, it is handed to metric hooks
+type Target struct {
+	Namespace string
+	Workload  string
+	Role      Role
+	Revision  string
+}
+
+// Metric is an optional hook returning an application health value for one revision, lower is healthier
+type Metric interface {
+	Name() string
+	Value(ctx context.Context, target Target) (float64, error)
+}
+
+// Criteria are the tolerated differences between the new and the old revision
+type Criteria struct {
+	// MaxRestartsPerPodDelta is how many more restarts per pod the new revision may have
+	MaxRestartsPerPodDelta float64
+	// MaxFlapsPerPodDelta is how many more readiness flaps per pod the new revision may have
+	MaxFlapsPerPodDelta float64
+	// MaxMetricIncrease is the tolerated relative increase of each metric, e.g. 0.1 for 10%
+	MaxMetricIncrease float64
+}
+
+// DefaultCriteria tolerates one extra restart or flap per pod and a 10% metric regression
+func DefaultCriteria() Criteria {
+	return Criteria{
+		MaxRestartsPerPodDelta: 1,
+		MaxFlapsPerPodDelta:    1,
+		MaxMetricIncrease:      0.1,
+	}
+}
+
+// Check is the outcome of comparing one signal between the revisions
+type Check struct {
+	Name   string  `json:"name"`
+	Old    float64 `json:"old"`
+	New    float64 `json:"new"`
+	Passed bool    `json:"passed"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// Record is the pass/fail analysis of a rollout, meant to be consumed by deploy pipelines
+type Record struct {
+	Namespace string    `json:"namespace"`
+	Workload  string    `json:"workload"`
+	Time      time.Time `json:"time"`
+	Old       Signals   `json:"old"`
+	New       Signals   `json:"new"`
+	Checks    []Check   `json:"checks"`
+	Passed    bool      `json:"passed"`
+}
+
+type podHistory struct {
+	role     Role
+	revision string
+	ready    bool
+	flaps    int
+	restarts int32
+}
+
+// Collector accumulates pod samples over the course of a rollout
+type Collector struct {
+	mu   sync.Mutex
+	pods map[string]*podHistory
+}
+
+// NewCollector creates an empty Collector
+func NewCollector() *Collector {
+	return &Collector{pods: make(map[string]*podHistory)}
+}
+
+// Observe records a pod sample, counting a flap whenever a pod that was Ready stops being Ready
+func (c *Collector) Observe(s PodSample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h, ok := c.pods[s.Name]
+	if !ok {
+		c.pods[s.Name] = &podHistory{role: s.Role, revision: s.Revision, ready: s.Ready, restarts: s.Restarts}
+		return
+	}
+	if h.ready && !s.Ready {
+		h.flaps++
+	}
+	h.ready = s.Ready
+	if s.Restarts > h.restarts {
+		h.restarts = s.Restarts
+	}
+}
+
+// Signals aggregates the samples of all pods with the given role
+func (c *Collector) Signals(role Role) Signals {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var s Signals
+	for _, h := range c.pods {
+		if h.role != role {
+			continue
+		}
+		s.Pods++
+		s.Restarts += h.restarts
+		s.ReadinessFlaps += h.flaps
+		if !h.ready {
+			s.NotReady++
+		}
+		if s.Revision == "" {
+			s.Revision = h.revision
+		}
+	}
+	return s
+}
+
+// Analyze compares the new revision against the old one and produces a pass/fail record
+func Analyze(ctx context.Context, c *Collector, namespace, workload string, criteria Criteria, metrics []Metric) *Record {
+	r := &Record{
+		Namespace: namespace,
+		Workload:  workload,
+		Time:      time.Now().UTC(),
+		Old:       c.Signals(Old),
+		New:       c.Signals(New),
+	}
+
+	r.Checks = append(r.Checks,
+		perPodCheck("restarts", float64(r.Old.Restarts), r.Old.Pods, float64(r.New.Restarts), r.New.Pods, criteria.MaxRestartsPerPodDelta),
+		perPodCheck("readinessFlaps", float64(r.Old.ReadinessFlaps), r.Old.Pods, float64(r.New.ReadinessFlaps), r.New.Pods, criteria.MaxFlapsPerPodDelta),
+		Check{
+			Name:   "newNotReady",
+			New:    float64(r.New.NotReady),
+			Passed: r.New.NotReady == 0,
+		},
+	)
+
+	for _, m := range metrics {
+		r.Checks = append(r.Checks, metricCheck(ctx, m, r, criteria.MaxMetricIncrease))
+	}
+
+	r.Passed = true
+	for _, check := range r.Checks {
+		r.Passed = r.Passed && check.Passed
+	}
+	return r
+}
+
+// perPodCheck compares a per-pod average, allowing the new revision to exceed the old by maxDelta
+func perPodCheck(name string, oldTotal float64, oldPods int, newTotal float64, newPods int, maxDelta float64) Check {
+	check := Check{Name: name, Old: perPod(oldTotal, oldPods), New: perPod(newTotal, newPods)}
+	check.Passed = check.New <= check.Old+maxDelta
+	if !check.Passed {
+		check.Detail = fmt.Sprintf("%.2f per pod exceeds the old revision's %.2f by more than %.2f", check.New, check.Old, maxDelta)
+	}
+	return check
+}
+
+// metricCheck evaluates a metric hook for both revisions, allowing a relative increase of maxIncrease
+func metricCheck(ctx context.Context, m Metric, r *Record, maxIncrease float64) Check {
+	check := Check{Name: m.Name()}
+
+	oldValue, err := m.Value(ctx, Target{Namespace: r.Namespace, Workload: r.Workload, Role: Old, Revision: r.Old.Revision})
+	if err != nil {
+		check.Detail = fmt.Sprintf("querying old revision: %v", err)
+		return check
+	}
+	newValue, err := m.Value(ctx, Target{Namespace: r.Namespace, Workload: r.Workload, Role: New, Revision: r.New.Revision})
+	if err != nil {
+		check.Detail = fmt.Sprintf("querying new revision: %v", err)
+		return check
+	}
+
+	setMetric(&r.Old, m.Name(), oldValue)
+	setMetric(&r.New, m.Name(), newValue)
+	check.Old, check.New = oldValue, newValue
+	check.Passed = newValue <= oldValue*(1+maxIncrease)
+	if !check.Passed {
+		check.Detail = fmt.Sprintf("%g is more than %.0f%% above the old revision's %g", newValue, maxIncrease*100, oldValue)
+	}
+	return check
+}
+
+func setMetric(s *Signals, name string, value float64) {
+	if s.Metrics == nil {
+		s.Metrics = make(map[string]float64)
+	}
+	s.Metrics[name] = value
+}
+
+func perPod(total float64, pods int) float64 {
+	if pods == 0 {
+		return 0
+	}
+	return total / float64(pods)
+}
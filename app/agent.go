@@ -0,0 +1,776 @@
+package app
+
+import (
+	"adv-go/auth"
+	"adv-go/config"
+	"adv-go/election"
+	"adv-go/features"
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/signing"
+	"adv-go/sink"
+	"adv-go/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	clientset kubernetes.Interface
+	// wg tracks the goroutines of the current leadership term, see goLeader
+	wg  sync.WaitGroup
+	out sink.Sink
+	// elector runs the leader election in the cluster, nil when running locally
+	elector election.Elector
+	// apiAuth authenticates the clients of the metrics server and the pod status stream
+	apiAuth *auth.Authenticator
+)
+
+var (
+	cfg = config.Default()
+	// configPath is the YAML config file given with --config
+	configPath string
+)
+
+// openSinks opens the configured sinks that records are written to, each receiving its selected fields
+func openSinks() (sink.Sink, error) {
+	projections := make(map[string]sink.Projection, len(cfg.Sinks.Fields))
+	for name, f := range cfg.Sinks.Fields {
+		projections[name] = sink.Projection{Include: f.Include, Exclude: f.Exclude}
+	}
+	return sink.Open(cfg.Sinks.Names, sink.Options{
+		FilePath: cfg.Sinks.File.Path,
+		FileRotation: sink.Rotation{
+			MaxSize:    cfg.Sinks.File.MaxSizeMB * 1024 * 1024,
+			Interval:   cfg.Sinks.File.RotateInterval.Duration,
+			MaxBackups: cfg.Sinks.File.MaxBackups,
+			MaxAge:     cfg.Sinks.File.MaxAge.Duration,
+		},
+		HTTP: sink.HTTPOptions{
+			URL:            cfg.Sinks.HTTP.URL,
+			Gzip:           cfg.Sinks.HTTP.Gzip,
+			Delta:          cfg.Sinks.HTTP.Delta,
+			ResyncInterval: cfg.Sinks.HTTP.ResyncInterval.Duration,
+		},
+		SyslogAddr: cfg.Sinks.Syslog.Addr,
+		SyslogTag:  cfg.Sinks.Syslog.Tag,
+		Archive: sink.ArchiveOptions{
+			URL:      cfg.Sinks.Archive.URL,
+			Dir:      cfg.Sinks.Archive.Dir,
+			Interval: cfg.Sinks.Archive.UploadInterval.Duration,
+			MaxSize:  cfg.Sinks.Archive.MaxSizeMB * 1024 * 1024,
+		},
+		Projections: projections,
+		IDs:         cfg.Sinks.IDs,
+		Journal:     cfg.Sinks.Journal,
+		JournalMax:  cfg.Sinks.JournalMaxRecords,
+		QueueSize:   cfg.Sinks.QueueSize,
+		Buffers:     sinkBuffers(),
+		Pipeline:    pipelineStages(cfg.Sinks.Pipeline),
+		Delivered:   recordSelfTestDelivery,
+	})
+}
+
+// runAgent serves the API, opens the sinks and logs the cluster state, leading through leader election when in a cluster
+func runAgent(clientset kubernetes.Interface, isInCluster bool) {
+	encoder, err := model.NewEncoder(cfg.RecordFormat)
+	if err != nil {
+		agentLog.Fatal("Invalid record format", "err", err)
+	}
+	recordEncoder = encoder
+	if cfg.Template != "" {
+		tmpl, err := compilePodTemplate(cfg.Template)
+		if err != nil {
+			agentLog.Fatal("Invalid pod record template", "err", err)
+		}
+		recordEncoder = templateEncoder{tmpl}
+	}
+	apiAuth, err = auth.New(auth.Options{
+		TokenFile:    cfg.APIAuth.TokenFile,
+		CertFile:     cfg.APIAuth.TLSCert,
+		KeyFile:      cfg.APIAuth.TLSKey,
+		ClientCAFile: cfg.APIAuth.ClientCA,
+		PublicPaths:  cfg.APIAuth.PublicPaths,
+	})
+	if err != nil {
+		agentLog.Fatal("Failed to set up API authentication", "err", err)
+	}
+	if cfg.MetricsAddr != "" {
+		registerAPI(clientset)
+		registerHealth(clientset)
+		if cfg.Dashboard {
+			registerDashboard()
+		}
+		metrics.Serve(cfg.MetricsAddr, apiAuth)
+	}
+
+	// Trace the observation pipeline so alerts can link to how they were produced
+	if cfg.Tracing.Endpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio, cfg.Tracing.LinkTemplate)
+		if err != nil {
+			agentLog.Fatal("Failed to set up tracing", "err", err)
+		}
+		defer shutdown(context.Background())
+	}
+
+	out, err = openSinks()
+	if err != nil {
+		agentLog.Fatal("Failed to open sinks", "err", err)
+	}
+	defer out.Close()
+	if err := openLeaderAudit(); err != nil {
+		agentLog.Fatal("Failed to open the leadership audit log", "err", err)
+	}
+	defer closeLeaderAudit()
+
+	// Attach findings about unhealthy pods to the pods as Kubernetes Events
+	if cfg.EmitEvents && !cfg.ReadOnly {
+		broadcaster := startEventRecorder(clientset)
+		defer broadcaster.Shutdown()
+	}
+
+	setupNotifier()
+	if cfg.Notify.SilencesFile != "" {
+		if fileSilences, err = readSilencesFile(cfg.Notify.SilencesFile); err != nil {
+			agentLog.Fatal("Failed to load the silences file", "file", cfg.Notify.SilencesFile, "err", err)
+		}
+	}
+	if cfg.SigningKey != "" {
+		if bundleSigner, err = signing.LoadSigner(cfg.SigningKey); err != nil {
+			agentLog.Fatal("Failed to load signing key", "err", err)
+		}
+	}
+	restarts = model.NewRestartTracker(cfg.RestartAlertWindow.Duration)
+	remediator = newRemediator()
+	churn = model.NewChurnTracker(cfg.ChurnAlertWindow.Duration)
+	if cfg.History.DSN != "" {
+		startHistory()
+	}
+
+	if cfg.GRPCAddr != "" || cfg.Dashboard {
+		startPodStream()
+	}
+	// Every replica caches the pods, so followers answer the read API too
+	startReadCache(clientset)
+	if cfg.TUI {
+		if err := startTUI(); err != nil {
+			agentLog.Fatal("Failed to start the terminal UI", "err", err)
+		}
+	}
+
+	if cfg.HeartbeatInterval.Duration > 0 {
+		go runHeartbeat(cfg.HeartbeatInterval.Duration)
+	}
+
+	// Start leader election if in a Kubernetes cluster, otherwise directly log pod statuses
+	if electsLeader(isInCluster) {
+		startLeaderElection(clientset)
+	} else {
+		if sharding() {
+			agentLog.Warn("Not electing a leader, monitoring every namespace instead of a shard", "shards", cfg.Lease.Shards)
+		}
+		if hashingPods() {
+			agentLog.Warn("Not electing a leader, monitoring every pod instead of a share")
+		}
+		if cfg.ReadOnly {
+			agentLog.Info("Read-only mode, skipping leader election and event emission.")
+		} else {
+			agentLog.Info("Running locally, skipping leader election.")
+		}
+		ctx := lead(context.Background())
+		startPodWatch(ctx, clientset)
+		startHistoryCompaction(ctx)
+		goLeader(func() { runReportingLoop(ctx, clientset) })
+	}
+
+	// Block the program so it doesn’t exit immediately. Useful to test leadership
+	select {}
+}
+
+// leaseNamespace returns the configured lock namespace, defaulting to the namespace the agent runs in
+func leaseNamespace() string {
+	if cfg.Lease.Namespace != "" {
+		return cfg.Lease.Namespace
+	}
+	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		if ns := strings.TrimSpace(string(data)); ns != "" {
+			return ns
+		}
+	}
+	return "default"
+}
+
+// Function to check if the app is running inside a Kubernetes cluster
+func isRunningInCluster() bool {
+	_, err := rest.InClusterConfig()
+	return err == nil
+}
+
+// runReportingLoop re-logs the cluster state every interval until ctx is cancelled
+func runReportingLoop(ctx context.Context, clientset kubernetes.Interface) {
+	if cfg.Interval.Duration <= 0 {
+		reportClusterStatus(ctx, clientset)
+		return
+	}
+
+	ticker := time.NewTicker(cfg.Interval.Duration)
+	defer ticker.Stop()
+	startPassClock(ctx)
+	for {
+		reportClusterStatus(ctx, clientset)
+		passFinished(ctx)
+		select {
+		case <-ctx.Done():
+			collectorLog.Info("Stopping periodic reporting.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportClusterStatus runs a single reporting pass over pods, nodes and events, stopping between steps once
+// ctx is cancelled
+func reportClusterStatus(ctx context.Context, clientset kubernetes.Interface) {
+	ctx, span := tracing.Start(ctx, "reporting pass")
+	defer span.End()
+	refreshSilences(ctx)
+
+	steps := []struct {
+		name string
+		run  func(ctx context.Context)
+	}{
+		{"pods", func(ctx context.Context) { logPodStatus(ctx, clientset) }},
+		{"nodes", func(ctx context.Context) {
+			if ownsClusterWork() {
+				logNodeStatus(ctx, clientset)
+			}
+		}},
+		{"clock skew", func(ctx context.Context) {
+			if ownsClusterWork() {
+				checkClockSkew(ctx, clientset)
+			}
+		}},
+		{"reachability", func(ctx context.Context) {
+			if len(cfg.ReachabilityTargets) > 0 && ownsClusterWork() {
+				checkServiceReachability(ctx, clientset)
+			}
+		}},
+		{"jobs", func(ctx context.Context) {
+			if features.Enabled(features.JobTracking) && ownsNamespaceWork() {
+				logJobStatus(ctx, clientset)
+			}
+		}},
+		{"events", func(ctx context.Context) {
+			if ownsNamespaceWork() {
+				logEventStatus(ctx, clientset)
+			}
+		}},
+		{"autoscaler", func(ctx context.Context) {
+			if features.Enabled(features.AutoscalerCorrelation) {
+				logAutoscalerActivity(ctx, clientset)
+			}
+		}},
+		{"promql alerts", func(ctx context.Context) {
+			if ownsClusterWork() {
+				evaluatePromQLAlerts(ctx)
+			}
+		}},
+	}
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			collectorLog.Info("Reporting pass cancelled")
+			span.AddEvent("leadership lost")
+			return
+		}
+		stepCtx, stepSpan := tracing.Start(ctx, "report "+step.name)
+		step.run(stepCtx)
+		stepSpan.End()
+	}
+}
+
+// logPodStatus retrieves the pod statuses and logs them, dropping the records still in flight once ctx is cancelled
+func logPodStatus(ctx context.Context, clientset kubernetes.Interface) {
+	pods, err := getAllPods(ctx, clientset)
+	if err != nil {
+		// Skip this pass rather than exiting, the next one lists again
+		collectorLog.Error("Error listing pods", "err", err)
+		return
+	}
+
+	nodes := loadNodeIndex(ctx, clientset)
+	logOSMismatches(pods.Items, nodes)
+
+	logWorkloadStatus(ctx, clientset, pods.Items)
+	if features.Enabled(features.VolumeReporting) {
+		logVolumeStatus(ctx, clientset, pods.Items)
+	}
+	detectUnschedulablePods(ctx, clientset, pods.Items)
+	if features.Enabled(features.PodRemediation) {
+		remediatePods(ctx, clientset, pods.Items)
+	}
+	if features.Enabled(features.DaemonSetCoverage) {
+		checkDaemonSetCoverage(ctx, clientset, nodes)
+	}
+
+	enrich := loadEnrichment(ctx, clientset, nodes)
+	usage := loadPodUsage()
+	podChannel := make(chan *v1.Pod)
+	statusChannel := make(chan podRecord, cfg.BatchSize)
+
+	// Format pod statuses on a bounded pool of workers
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var pass sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		pass.Add(1)
+		go func() {
+			defer pass.Done()
+			logPodInfo(podChannel, nodes, enrich, usage, statusChannel)
+		}()
+	}
+
+	go func() {
+		defer close(podChannel)
+		for i := range pods.Items {
+			select {
+			case podChannel <- &pods.Items[i]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Wait for all workers to finish
+	go func() {
+		pass.Wait()
+		close(statusChannel)
+	}()
+
+	// Collect the results from the channel, print them and write them to the sinks in batches
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("pods", len(pods.Items)))
+	all := writeAllPodRecords()
+	transitions := cfg.PodRecordMode == "transitions"
+	// Records of pods that changed are shed after those restating an unchanged pod
+	changed := make([][]byte, 0, cfg.BatchSize)
+	restated := make([][]byte, 0, cfg.BatchSize)
+	for record := range statusChannel {
+		if !cfg.PodRecords || cfg.Summary || ctx.Err() != nil || (!all && record.transition == nil) {
+			continue
+		}
+		status := record.status
+		collectorLog.Info(status, record.fields...)
+		if record.transition != nil {
+			changed = append(changed, []byte(status))
+		} else {
+			restated = append(restated, []byte(status))
+		}
+		if len(changed)+len(restated) >= cfg.BatchSize {
+			writeBatch(ctx, sink.PriorityTransition, changed)
+			writeBatch(ctx, sink.PriorityHeartbeat, restated)
+			changed, restated = changed[:0], restated[:0]
+		}
+	}
+	if ctx.Err() != nil {
+		collectorLog.Info("Leadership lost, dropping pod records", "count", len(changed)+len(restated))
+		return
+	}
+
+	seen := make(map[types.UID]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		seen[pod.UID] = true
+	}
+	for _, t := range podStates.Forget(seen) {
+		if cfg.PodRecords && !cfg.Summary && transitions {
+			status := formatPodDeletion(t)
+			collectorLog.Info(status, "namespace", t.Namespace, "pod", t.Name, "phase", "Deleted")
+			changed = append(changed, []byte(status))
+		}
+	}
+	writeBatch(ctx, sink.PriorityTransition, changed)
+	writeBatch(ctx, sink.PriorityHeartbeat, restated)
+	if cfg.Summary {
+		logNamespaceSummary(ctx, pods.Items)
+	}
+	if cfg.StatusReports {
+		writeStatusReports(ctx, pods.Items)
+	}
+	resolveGonePodAlerts(pods.Items)
+	restarts.Forget(seen)
+}
+
+// sinkBuffers converts the buffer of each configured sink for the sink package
+func sinkBuffers() map[string]sink.BufferOptions {
+	buffers := make(map[string]sink.BufferOptions, len(cfg.Sinks.Names))
+	for _, name := range cfg.Sinks.Names {
+		b := cfg.Sinks.BufferFor(name)
+		buffers[name] = sink.BufferOptions{
+			Size:          b.Size,
+			BatchSize:     b.BatchSize,
+			FlushInterval: b.FlushInterval.Duration,
+			Policy:        b.Policy,
+		}
+	}
+	return buffers
+}
+
+// pipelineStages converts the configured pipeline stages for the sink package
+func pipelineStages(stages []config.StageConfig) []sink.StageSpec {
+	specs := make([]sink.StageSpec, len(stages))
+	for i, st := range stages {
+		specs[i] = sink.StageSpec{
+			Name:     st.Name,
+			Type:     st.Type,
+			Match:    st.Match,
+			Keep:     st.Keep,
+			Set:      st.Set,
+			Fields:   st.Fields,
+			Patterns: st.Patterns,
+			Sinks:    st.Sinks,
+		}
+	}
+	return specs
+}
+
+// writeBatch writes the records to the configured sinks in one go, with the priority they are shed by when the sinks
+// fall behind
+func writeBatch(ctx context.Context, priority sink.Priority, batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	_, span := tracing.Start(ctx, "write batch", attribute.Int("records", len(batch)), attribute.String("priority", priority.String()))
+	defer span.End()
+	if err := sink.WritePriority(out, priority, batch...); err != nil {
+		tracing.Fail(span, err)
+		collectorLog.Error("Error writing to sink", "err", err)
+		return
+	}
+	collectorLog.Info("Logged pod statuses", "count", len(batch))
+}
+
+// getAllPods returns the pods this replica owns in the monitored namespaces from the pod store, or lists them one
+// page at a time while the store is not synced
+func getAllPods(ctx context.Context, clientset kubernetes.Interface) (*v1.PodList, error) {
+	// The pod watches cover the same namespaces and selectors, so once synced their store replaces the list calls
+	if pods := cachedPods(); pods != nil {
+		collectorLog.Debug("Read pods from the pod store", "count", len(pods))
+		return &v1.PodList{Items: ownedPods(pods)}, nil
+	}
+	namespaces := monitoredNamespaces()
+	if cfg.ListConcurrency > 0 {
+		return listNamespacesConcurrently(ctx, clientset, namespaces)
+	}
+	pods := &v1.PodList{}
+	for _, namespace := range namespaces {
+		items, err := listPodPages(ctx, clientset, namespace)
+		if err != nil {
+			return nil, err
+		}
+		pods.Items = append(pods.Items, ownedPods(keptPods(items))...)
+	}
+	return pods, nil
+}
+
+// listNamespacesConcurrently lists the pods of each namespace on --list-concurrency workers, expanding all
+// namespaces into the cluster's namespaces first. The pods are merged in namespace order, the first error fails the
+// whole list
+func listNamespacesConcurrently(ctx context.Context, clientset kubernetes.Interface, namespaces []string) (*v1.PodList, error) {
+	if len(namespaces) == 1 && namespaces[0] == metav1.NamespaceAll {
+		list, err := retryCall(ctx, "list namespaces", func(ctx context.Context) (*v1.NamespaceList, error) {
+			return clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			return nil, err
+		}
+		namespaces = make([]string, 0, len(list.Items))
+		for _, ns := range list.Items {
+			if !ignoredNamespace(ns.Name) {
+				namespaces = append(namespaces, ns.Name)
+			}
+		}
+		sort.Strings(namespaces)
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([][]v1.Pod, len(namespaces))
+	errs := make([]error, len(namespaces))
+	next := make(chan int)
+	var workers sync.WaitGroup
+	for w := 0; w < min(cfg.ListConcurrency, len(namespaces)); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range next {
+				if results[i], errs[i] = listPodPages(ctx, clientset, namespaces[i]); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	start := time.Now()
+feed:
+	for i := range namespaces {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	workers.Wait()
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, fmt.Errorf("error listing the pods of namespace %s: %w", namespaces[i], err)
+		}
+	}
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	pods := &v1.PodList{}
+	for _, items := range results {
+		pods.Items = append(pods.Items, ownedPods(keptPods(items))...)
+	}
+	collectorLog.Debug("Listed pods per namespace", "namespaces", len(namespaces), "workers", cfg.ListConcurrency,
+		"count", len(pods.Items), "took", format.Duration(time.Since(start)))
+	return pods, nil
+}
+
+// listPodPages pages through the pods of a namespace using Continue tokens
+func listPodPages(ctx context.Context, clientset kubernetes.Interface, namespace string) ([]v1.Pod, error) {
+	opts := podListOptions()
+	opts.Limit = cfg.PageSize
+
+	var items []v1.Pod
+	for {
+		list, err := retryCall(ctx, "list pods", func(ctx context.Context) (*v1.PodList, error) {
+			return clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		})
+		if apierrors.IsResourceExpired(err) && opts.Continue != "" {
+			// The continue token outlived the apiserver's compaction window, start over
+			collectorLog.Info("Pod list continue token expired, restarting the list", "namespace", namespace)
+			opts.Continue = ""
+			items = items[:0]
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, list.Items...)
+		collectorLog.Debug("Listed pods", "namespace", namespace, "count", len(list.Items), "total", len(items))
+		if list.Continue == "" {
+			return items, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+// monitoredNamespaces returns the namespaces of the held shard when sharding, otherwise the configured namespaces,
+// or all namespaces when none are set
+func monitoredNamespaces() []string {
+	if namespaces, ok := shardNamespaces(); ok {
+		return namespaces
+	}
+	if selectingNamespaces() {
+		return selectedNamespaceList()
+	}
+	if len(cfg.Namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return cfg.Namespaces
+}
+
+// namespaceMonitored reports whether the namespace is one of the monitored namespaces
+func namespaceMonitored(namespace string) bool {
+	if namespaces, ok := shardNamespaces(); ok {
+		for _, ns := range namespaces {
+			if ns == namespace {
+				return true
+			}
+		}
+		return false
+	}
+	if selectingNamespaces() {
+		return namespaceSelected(namespace)
+	}
+	if len(cfg.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range cfg.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// podListOptions returns the list options carrying the configured pod selectors
+func podListOptions() metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: cfg.LabelSelector,
+		FieldSelector: cfg.FieldSelector,
+	}
+}
+
+// recordEncoder renders the pod records, chosen by --record-format or --template
+var recordEncoder model.Encoder = model.TextEncoder{}
+
+// podRecord is an encoded pod status and the fields identifying the pod in the log
+type podRecord struct {
+	status string
+	fields []any
+	// transition is set when the pod's state changed since the previous pass
+	transition *model.StateTransition
+}
+
+// logPodInfo encodes the status of each pod received until the pod channel is closed
+func logPodInfo(podChannel <-chan *v1.Pod, nodes nodeIndex, enrich *enrichment, usage podUsage, statusChannel chan<- podRecord) {
+	transitions := cfg.PodRecordMode == "transitions"
+	for pod := range podChannel {
+		// Create an instance of the Pod struct from the model package
+		podModel := model.NewPod(pod)
+
+		// What the record carries besides the pod, in the order the text format appends it
+		podModel.AddRecordField("Health", describeHealth(podModel))
+		if node, ok := nodes[podModel.NodeName()]; ok && node.VirtualKind() != "" {
+			podModel.AddRecordField("Virtual Node", node.VirtualKind())
+		}
+		nodeState := nodes.disruptions(podModel.NodeName())
+		if len(nodeState) > 0 {
+			podModel.AddRecordList("Node State", nodeState)
+		}
+		if pulls := describePullFailures(pod); len(pulls) > 0 {
+			podModel.AddRecordList("Image Pull", pulls)
+		}
+		usage.addFields(podModel)
+		if fields := enrich.fields(podModel); len(fields) > 0 {
+			podModel.AddRecordList("Enrichment", fields)
+		}
+		t, changed := podStates.Observe(podModel)
+		if transitions && changed {
+			podModel.AddRecordField("Transition", transitionStates(t))
+		}
+
+		if reason, message, ok := podModel.Problem(); ok {
+			recordPodProblem(pod, reason, message)
+		}
+		notifyBadPhase(pod, podModel)
+		checkRestarts(podModel)
+
+		status, err := recordEncoder.Encode(podModel)
+		if err != nil {
+			collectorLog.Error("Error encoding pod record", append(modelPodFields(podModel), "err", err)...)
+			continue
+		}
+		record := podRecord{status: string(status), fields: modelPodFields(podModel)}
+		if len(nodeState) > 0 {
+			record.fields = append(record.fields, "node_state", strings.Join(nodeState, ","))
+		}
+		if changed {
+			record.transition = &t
+		}
+		// Send the status to the status channel
+		statusChannel <- record
+	}
+}
+
+// startLeaderElection campaigns for the Lease and runs the watches and reporting loop while leading, campaigning
+// again after every term
+func startLeaderElection(clientset kubernetes.Interface) {
+	var err error
+	elector, err = newElector(clientset)
+	if err != nil {
+		electionLog.Fatal("Failed to set up leader election", "err", err)
+	}
+
+	for {
+		// releaseLeadership cancels the campaign, which gives up the lock held
+		ctx, cancel := context.WithCancelCause(context.Background())
+		campaignMu.Lock()
+		campaignCancel = cancel
+		campaignMu.Unlock()
+		elector.Run(ctx, func(ctx context.Context) {
+			if sharded, ok := elector.(*election.ShardedElector); ok {
+				if shard, ok := sharded.Shard(); ok {
+					claimShard(ctx, clientset, shard)
+				}
+			}
+			// Start logging pod status only when this instance is the leader
+			electionLog.Info("I am the leader, starting to log pod statuses.")
+			// The term's context is cancelled when leadership is lost, which stops the watches and the loop
+			ctx = lead(ctx)
+			startPodWatch(ctx, clientset)
+			startHistoryCompaction(ctx)
+			goLeader(func() { runReportingLoop(ctx, clientset) })
+		}, func() {
+			electionLog.Info("Lost leadership, stopping pod status logging.")
+			resign(cfg.Lease.RenewDeadline.Duration)
+			releaseShard()
+		})
+		// Run returns once leadership is lost, whether this replica released the lock or failed to renew it, and
+		// the replica campaigns again either way so it cannot end up a standby that never takes over
+		released := ctx.Err() != nil
+		cancel(nil)
+		// Standing by for a lease duration leaves the lock to another replica
+		if released {
+			electionLog.Info("Released leadership, campaigning again later", "after", cfg.Lease.Duration.Duration.String())
+		} else {
+			electionLog.Warn("Lost leadership, campaigning again later", "after", cfg.Lease.Duration.Duration.String())
+		}
+		time.Sleep(cfg.Lease.Duration.Duration)
+	}
+}
+
+// loadKubeConfig loads the Kubernetes configuration based on the environment: the in-cluster config unless a
+// kubeconfig context is asked for, otherwise the kubeconfig. --apiserver and --token override either
+func loadKubeConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err == nil && cfg.Context == "" {
+		agentLog.Info("Using in-cluster config")
+		if cfg.APIServer != "" {
+			config.Host = cfg.APIServer
+		}
+		if cfg.Token != "" {
+			// The mounted token file would replace the given token on refresh
+			config.BearerToken, config.BearerTokenFile = cfg.Token, ""
+		}
+	} else {
+		// Use local kubeconfig for development. A missing kubeconfig is fine when --apiserver says where to connect
+		agentLog.Info("Using local kubeconfig", "path", cfg.Kubeconfig, "context", cfg.Context)
+		rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: cfg.Kubeconfig}
+		if cfg.APIServer != "" {
+			rules = &clientcmd.ClientConfigLoadingRules{Precedence: []string{cfg.Kubeconfig}}
+		}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Context}
+		overrides.ClusterInfo.Server = cfg.APIServer
+		overrides.AuthInfo.Token = cfg.Token
+		if config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig(); err != nil {
+			return nil, err
+		}
+	}
+	configureRateLimits(config)
+	// Every apiserver request, including the informers' list and watch calls, is traced as a client span
+	config.Wrap(tracing.Transport)
+	return config, nil
+}
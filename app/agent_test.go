@@ -0,0 +1,252 @@
+package app
+
+import (
+	"adv-go/config"
+	"adv-go/model"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// recordingSink keeps the records written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (s *recordingSink) Write(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, string(record))
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func (s *recordingSink) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.records)
+}
+
+// testAgent points the agent's globals at a default config, a recording sink and fresh trackers, restoring them
+// when the test ends
+func testAgent(t *testing.T, configure func(c *config.Config)) *recordingSink {
+	t.Helper()
+	savedCfg, savedOut, savedRestarts, savedStates := cfg, out, restarts, podStates
+	t.Cleanup(func() { cfg, out, restarts, podStates = savedCfg, savedOut, savedRestarts, savedStates })
+
+	cfg = config.Default()
+	cfg.RetryBackoff = metav1.Duration{}
+	if configure != nil {
+		configure(&cfg)
+	}
+	recorded := &recordingSink{}
+	out = recorded
+	restarts = model.NewRestartTracker(cfg.RestartAlertWindow.Duration)
+	podStates = model.NewStateTracker()
+	return recorded
+}
+
+func testPod(namespace, name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(namespace + "/" + name)},
+		Spec:       v1.PodSpec{NodeName: "node-a", Containers: []v1.Container{{Name: "app", Image: "app:1"}}},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{{Name: "app", Image: "app:1", Ready: true, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}},
+		},
+	}
+}
+
+// pagedPods makes the fake clientset serve pod lists in pages of the requested limit, which it ignores itself,
+// and records each list call's options
+func pagedPods(client *fake.Clientset, calls *[]metav1.ListOptions) {
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := action.(k8stesting.ListActionImpl)
+		opts := list.ListOptions
+		*calls = append(*calls, opts)
+		obj, err := client.Tracker().List(v1.SchemeGroupVersion.WithResource("pods"), v1.SchemeGroupVersion.WithKind("Pod"), list.Namespace)
+		if err != nil {
+			return true, nil, err
+		}
+		items := obj.(*v1.PodList).Items
+		slices.SortFunc(items, func(a, b v1.Pod) int { return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name) })
+		start := 0
+		if opts.Continue != "" {
+			start, _ = strconv.Atoi(opts.Continue)
+		}
+		end := len(items)
+		page := &v1.PodList{}
+		if opts.Limit > 0 && start+int(opts.Limit) < end {
+			end = start + int(opts.Limit)
+			page.Continue = strconv.Itoa(end)
+		}
+		page.Items = items[start:end]
+		return true, page, nil
+	})
+}
+
+func TestListPodPages(t *testing.T) {
+	testAgent(t, func(c *config.Config) { c.PageSize = 2 })
+	client := fake.NewSimpleClientset()
+	for i := 0; i < 5; i++ {
+		client.Tracker().Add(testPod("shop", fmt.Sprintf("web-%d", i)))
+	}
+	var calls []metav1.ListOptions
+	pagedPods(client, &calls)
+
+	pods, err := listPodPages(context.Background(), client, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	if want := []string{"web-0", "web-1", "web-2", "web-3", "web-4"}; !slices.Equal(names, want) {
+		t.Errorf("pods = %v, want %v", names, want)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("listed %d pages, want 3", len(calls))
+	}
+	for i, want := range []string{"", "2", "4"} {
+		if calls[i].Limit != 2 || calls[i].Continue != want {
+			t.Errorf("page %d listed with limit %d continue %q, want limit 2 continue %q", i, calls[i].Limit, calls[i].Continue, want)
+		}
+	}
+}
+
+func TestListPodPagesExpiredContinue(t *testing.T) {
+	testAgent(t, func(c *config.Config) { c.PageSize = 2 })
+	client := fake.NewSimpleClientset()
+	for i := 0; i < 3; i++ {
+		client.Tracker().Add(testPod("shop", fmt.Sprintf("web-%d", i)))
+	}
+	var calls []metav1.ListOptions
+	pagedPods(client, &calls)
+	// The first continue token has expired, the list starts over
+	expired := false
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.ListActionImpl).ListOptions.Continue == "" || expired {
+			return false, nil, nil
+		}
+		expired = true
+		return true, nil, apierrors.NewResourceExpired("continue token expired")
+	})
+
+	pods, err := listPodPages(context.Background(), client, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 3 {
+		t.Errorf("listed %d pods after the restart, want each of the 3 once", len(pods))
+	}
+	if !expired {
+		t.Error("the expired continue token was never served")
+	}
+}
+
+func TestGetAllPods(t *testing.T) {
+	for _, concurrency := range []int{0, 2} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			testAgent(t, func(c *config.Config) {
+				c.PageSize = 1
+				c.ListConcurrency = concurrency
+				c.Namespaces = []string{"shop", "payments"}
+				c.IgnoreNamespaces = []string{"payments"}
+			})
+			client := fake.NewSimpleClientset(testPod("shop", "web-0"), testPod("shop", "web-1"), testPod("payments", "api-0"), testPod("other", "db-0"))
+			var calls []metav1.ListOptions
+			pagedPods(client, &calls)
+
+			pods, err := getAllPods(context.Background(), client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, pod := range pods.Items {
+				names = append(names, pod.Namespace+"/"+pod.Name)
+			}
+			// Unmonitored namespaces are not listed and ignored ones are dropped
+			if want := []string{"shop/web-0", "shop/web-1"}; !slices.Equal(names, want) {
+				t.Errorf("pods = %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+func TestGetAllPodsListError(t *testing.T) {
+	testAgent(t, func(c *config.Config) { c.MaxRetries = 0 })
+	client := fake.NewSimpleClientset(testPod("shop", "web-0"))
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", fmt.Errorf("no access"))
+	})
+	if _, err := getAllPods(context.Background(), client); !apierrors.IsForbidden(err) {
+		t.Errorf("getAllPods error = %v, want the forbidden list error", err)
+	}
+}
+
+func TestLogPodStatus(t *testing.T) {
+	recorded := testAgent(t, func(c *config.Config) { c.PodRecordMode = "transitions" })
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{v1.LabelOSStable: "linux"}},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}},
+	}
+	client := fake.NewSimpleClientset(node, testPod("shop", "web-0"), testPod("payments", "api-0"))
+
+	// The first pass records every pod as it first sees it
+	logPodStatus(context.Background(), client)
+	records := recorded.written()
+	if len(records) != 2 {
+		t.Fatalf("first pass wrote %d records, want one per pod: %q", len(records), records)
+	}
+	for _, want := range []string{"Pod Name: api-0", "Pod Name: web-0"} {
+		if !slices.ContainsFunc(records, func(r string) bool { return strings.HasPrefix(r, want) }) {
+			t.Errorf("no record starts with %q: %q", want, records)
+		}
+	}
+
+	// An unchanged pass writes nothing new in the transitions mode
+	logPodStatus(context.Background(), client)
+	if got := recorded.written(); len(got) != len(records) {
+		t.Fatalf("unchanged pass wrote %q", got[len(records):])
+	}
+
+	// A deleted pod gets its deletion record
+	if err := client.CoreV1().Pods("payments").Delete(context.Background(), "api-0", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	logPodStatus(context.Background(), client)
+	got := recorded.written()[len(records):]
+	if len(got) != 1 || !strings.HasPrefix(got[0], "Pod Name: api-0, Namespace: payments, Phase: Deleted") {
+		t.Errorf("pass after the deletion wrote %q, want the deletion record of payments/api-0", got)
+	}
+}
+
+func TestLogPodStatusListError(t *testing.T) {
+	recorded := testAgent(t, func(c *config.Config) { c.MaxRetries = 0 })
+	client := fake.NewSimpleClientset(testPod("shop", "web-0"))
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("apiserver is down")
+	})
+
+	// The pass is skipped rather than writing partial records
+	logPodStatus(context.Background(), client)
+	if got := recorded.written(); len(got) != 0 {
+		t.Errorf("failed pass wrote %q", got)
+	}
+}
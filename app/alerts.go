@@ -0,0 +1,354 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// firingAlerts are the alerts firing by key and silences the unexpired silences. With a history store they are
+// loaded from it when leading starts, so a restart or failover does not notify about every active alert again
+var (
+	firingAlerts = make(map[string]history.Alert)
+	silences     []history.Silence
+	alertsMu     sync.Mutex
+)
+
+// badPhaseKey is the key of a pod's bad phase alert
+func badPhaseKey(namespace, pod string) string {
+	return "bad-phase/" + namespace + "/" + pod
+}
+
+// loadAlertState replaces the alert state with the one recorded in the history store
+func loadAlertState(ctx context.Context) {
+	if historyStore == nil {
+		return
+	}
+	alerts, err := historyStore.Alerts(ctx, time.Time{})
+	if err != nil {
+		alertsLog.Error("Error loading alert state", "err", err)
+		return
+	}
+	active, err := historyStore.Silences(ctx, time.Now())
+	if err != nil {
+		alertsLog.Error("Error loading alert silences", "err", err)
+		return
+	}
+
+	alertsMu.Lock()
+	firingAlerts = make(map[string]history.Alert, len(alerts))
+	for _, a := range alerts {
+		firingAlerts[a.Key] = a
+	}
+	silences = active
+	alertsMu.Unlock()
+	alertsLog.Info("Loaded alert state", "firing", len(alerts), "silences", len(active))
+}
+
+// refreshSilences picks up the silences added or removed with the alerts command or in the silences file since the
+// previous pass
+func refreshSilences(ctx context.Context) {
+	loadSilencesFile()
+	if historyStore == nil {
+		return
+	}
+	active, err := historyStore.Silences(ctx, time.Now())
+	if err != nil {
+		alertsLog.Error("Error loading alert silences", "err", err)
+		return
+	}
+	alertsMu.Lock()
+	silences = active
+	alertsMu.Unlock()
+}
+
+// fireAlert marks the alert firing. It reports whether it fired, which it does not while the alert already fires
+// for the same reason, and whether a silence mutes its notification
+func fireAlert(a history.Alert) (fired, silenced bool) {
+	alertsMu.Lock()
+	if previous, ok := firingAlerts[a.Key]; ok && previous.Reason == a.Reason {
+		alertsMu.Unlock()
+		return false, false
+	}
+	a.State = history.AlertFiring
+	firingAlerts[a.Key] = a
+	var silence history.Silence
+	now := time.Now()
+	for _, s := range silences {
+		if s.ExpiresAt.After(now) && s.Matches(a.Key) {
+			silence, silenced = s, true
+			break
+		}
+	}
+	alertsMu.Unlock()
+
+	if historyStore != nil {
+		queueHistory("alert "+a.Key, func(ctx context.Context) error {
+			return historyStore.FireAlert(ctx, a)
+		})
+	}
+	if silenced {
+		alertsLog.Info("Alert silenced, not notifying", "alert", a.Key, "reason", a.Reason, "silence", silence.ID,
+			"comment", silence.Comment, "until", format.Time(silence.ExpiresAt))
+	}
+	return true, silenced
+}
+
+// resolveAlert marks the alert resolved when it is firing
+func resolveAlert(key string) {
+	alertsMu.Lock()
+	a, ok := firingAlerts[key]
+	delete(firingAlerts, key)
+	alertsMu.Unlock()
+	if !ok {
+		return
+	}
+	alertsLog.Info("Alert resolved", "alert", key, "reason", a.Reason, "firing", format.Age(time.Since(a.FiredAt)))
+	if historyStore != nil {
+		at := time.Now()
+		queueHistory("resolution of alert "+key, func(ctx context.Context) error {
+			return historyStore.ResolveAlert(ctx, key, at)
+		})
+	}
+}
+
+// resolveGonePodAlerts resolves the bad phase alerts of pods that were not seen in the latest listing. The alerts
+// are loaded from the shared history store, so those of pods another replica reports are left alone: with pods
+// hashed across replicas none are resolved, as a gone pod cannot be hashed, and with namespace shards only those in
+// the held shard's namespaces are
+func resolveGonePodAlerts(pods []v1.Pod) {
+	if hashingPods() {
+		return
+	}
+	var owned map[string]bool
+	if sharding() {
+		namespaces, ok := shardNamespaces()
+		if !ok {
+			return
+		}
+		owned = make(map[string]bool, len(namespaces))
+		for _, ns := range namespaces {
+			owned[ns] = true
+		}
+	}
+	seen := make(map[string]bool, len(pods))
+	for i := range pods {
+		seen[badPhaseKey(pods[i].Namespace, pods[i].Name)] = true
+	}
+	var gone []string
+	alertsMu.Lock()
+	for key := range firingAlerts {
+		rest, isPod := strings.CutPrefix(key, "bad-phase/")
+		if !isPod || seen[key] {
+			continue
+		}
+		if namespace, _, _ := strings.Cut(rest, "/"); owned != nil && !owned[namespace] {
+			continue
+		}
+		gone = append(gone, key)
+	}
+	alertsMu.Unlock()
+	for _, key := range gone {
+		resolveAlert(key)
+	}
+}
+
+// serveAlerts returns the firing alerts, those resolved within ?since= (default 24h) and the active silences
+func serveAlerts(w http.ResponseWriter, r *http.Request) {
+	since := 24 * time.Hour
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	alerts, err := historyStore.Alerts(r.Context(), time.Now().Add(-since))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	active, err := historyStore.Silences(r.Context(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if alerts == nil {
+		alerts = []history.Alert{}
+	}
+	if active == nil {
+		active = []history.Silence{}
+	}
+	writeJSON(w, map[string]any{"alerts": alerts, "silences": active})
+}
+
+// newAlertsCommand creates the alerts command and its subcommands
+func newAlertsCommand() *cobra.Command {
+	var since time.Duration
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "List the firing alerts and silences recorded in the history store",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAlertStore(func(store *history.Store) int { return runListAlerts(store, since) })
+		},
+	}
+	cmd.Flags().DurationVar(&since, "since", 0, "also list the alerts resolved within this duration")
+
+	var by string
+	ack := &cobra.Command{
+		Use:   "ack <key>",
+		Short: "Acknowledge a firing alert, the acknowledgment lasts until the alert resolves or fires for another reason",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAlertStore(func(store *history.Store) int {
+				err := store.AcknowledgeAlert(context.Background(), args[0], by, time.Now())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error acknowledging %s: %v\n", args[0], err)
+					return 1
+				}
+				fmt.Printf("Acknowledged %s as %s\n", args[0], by)
+				return 0
+			})
+		},
+	}
+	ack.Flags().StringVar(&by, "by", currentUser(), "who acknowledges the alert")
+
+	var duration time.Duration
+	var comment, createdBy string
+	silence := &cobra.Command{
+		Use:   "silence <matcher>",
+		Short: `Mute the notifications of the alerts whose key matches a glob, e.g. "bad-phase/shop/*"`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := path.Match(args[0], ""); err != nil {
+				return fmt.Errorf("invalid matcher %q: %w", args[0], err)
+			}
+			if duration <= 0 {
+				return errors.New("--for must be positive")
+			}
+			return withAlertStore(func(store *history.Store) int {
+				now := time.Now()
+				s := history.Silence{ID: string(uuid.NewUUID()), Matcher: args[0], Comment: comment, CreatedBy: createdBy,
+					CreatedAt: now, ExpiresAt: now.Add(duration)}
+				if err := store.AddSilence(context.Background(), s); err != nil {
+					fmt.Fprintf(os.Stderr, "error adding silence: %v\n", err)
+					return 1
+				}
+				fmt.Printf("Silenced %s until %s, ID %s\n", s.Matcher, format.Time(s.ExpiresAt), s.ID)
+				return 0
+			})
+		},
+	}
+	silence.Flags().DurationVar(&duration, "for", 2*time.Hour, "how long the silence lasts")
+	silence.Flags().StringVar(&comment, "comment", "", "why the alerts are silenced")
+	silence.Flags().StringVar(&createdBy, "by", currentUser(), "who silences the alerts")
+
+	unsilence := &cobra.Command{
+		Use:   "unsilence <id>",
+		Short: "Remove a silence before it expires",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAlertStore(func(store *history.Store) int {
+				err := store.DeleteSilence(context.Background(), args[0])
+				if errors.Is(err, sql.ErrNoRows) {
+					fmt.Fprintf(os.Stderr, "no silence with ID %s\n", args[0])
+					return 1
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error removing silence: %v\n", err)
+					return 1
+				}
+				fmt.Printf("Removed silence %s\n", args[0])
+				return 0
+			})
+		},
+	}
+
+	cmd.AddCommand(ack, silence, unsilence)
+	return cmd
+}
+
+// withAlertStore opens the history store for an alerts subcommand
+func withAlertStore(run func(store *history.Store) int) error {
+	if cfg.History.DSN == "" {
+		return errors.New("alerts requires --history-dsn")
+	}
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to open history store: %w", err)
+	}
+	defer store.Close()
+	return exitCode(run(store))
+}
+
+// runListAlerts prints the alerts and the active silences as tables
+func runListAlerts(store *history.Store, since time.Duration) int {
+	var from time.Time
+	if since > 0 {
+		from = time.Now().Add(-since)
+	}
+	alerts, err := store.Alerts(context.Background(), from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading alerts: %v\n", err)
+		return 1
+	}
+	active, err := store.Silences(context.Background(), time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading silences: %v\n", err)
+		return 1
+	}
+
+	if len(alerts) == 0 {
+		fmt.Println("No firing alerts")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tSTATE\tREASON\tFIRED\tACKNOWLEDGED\tDETAIL")
+		for _, a := range alerts {
+			state := a.State
+			if a.State == history.AlertResolved {
+				state += " " + format.Time(a.ResolvedAt)
+			}
+			acked := "-"
+			if a.AcknowledgedBy != "" {
+				acked = a.AcknowledgedBy + " " + format.Time(a.AcknowledgedAt)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.Key, state, a.Reason, format.Time(a.FiredAt), acked, a.Detail)
+		}
+		w.Flush()
+	}
+	if len(active) > 0 {
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SILENCE\tMATCHER\tUNTIL\tBY\tCOMMENT")
+		for _, s := range active {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, s.Matcher, format.Time(s.ExpiresAt), s.CreatedBy, s.Comment)
+		}
+		w.Flush()
+	}
+	return 0
+}
+
+// currentUser names who runs the command, for acknowledgments and silences
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/store"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podStore holds the pods of the pod watches, reset with every leadership term
+var podStore = store.New()
+
+// podSnapshot is the API representation of a pod's current status
+type podSnapshot struct {
+	Namespace    string                       `json:"namespace"`
+	Name         string                       `json:"name"`
+	Node         string                       `json:"node"`
+	Phase        string                       `json:"phase"`
+	Health       string                       `json:"health"`
+	Ready        int                          `json:"ready"`
+	Containers   int                          `json:"containers"`
+	Restarts     int32                        `json:"restarts"`
+	Waiting      []string                     `json:"waiting,omitempty"`
+	Terminations []model.ContainerTermination `json:"lastTerminations,omitempty"`
+	// SpecHash is shared by the pods created from the same template, see model.Pod.SpecHash
+	SpecHash string `json:"specHash"`
+}
+
+// registerAPI adds the query API to the metrics server
+func registerAPI(clientset kubernetes.Interface) {
+	metrics.Handle("GET /api/v1/pods", http.HandlerFunc(servePods))
+	metrics.Handle("GET /api/v1/pods/{namespace}", http.HandlerFunc(servePods))
+	metrics.Handle("GET /api/v1/nodes/{name}/pods", http.HandlerFunc(serveNodePods))
+	metrics.Handle("GET /api/v1/pods/{namespace}/{name}/timeline", podTimelineHandler(clientset))
+	metrics.Handle("GET /api/v1/selftest/{name}", http.HandlerFunc(serveSelfTest))
+	metrics.Handle("GET /api/v1/images", http.HandlerFunc(serveImages))
+	metrics.Handle("GET /api/v1/topology", topologyHandler(clientset))
+}
+
+// cachedPods returns the pods of the monitored namespaces in the pod store ordered by namespace and name, nil until
+// the pod watches synced
+func cachedPods() []v1.Pod {
+	if !podStore.HasSynced() {
+		return nil
+	}
+	stored := monitoredPods(podStore.List())
+	pods := make([]v1.Pod, 0, len(stored))
+	for _, p := range stored {
+		pods = append(pods, *p.Object())
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
+}
+
+// monitoredPods leaves out the stored pods of the namespaces not monitored. The store holds the pods of every shard,
+// and of every namespace with a namespace selector
+func monitoredPods(pods []*model.Pod) []*model.Pod {
+	kept := make([]*model.Pod, 0, len(pods))
+	for _, p := range pods {
+		if namespaceMonitored(p.Namespace()) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// servePods returns the cached status of the watched pods, optionally limited to one namespace
+func servePods(w http.ResponseWriter, r *http.Request) {
+	if namespace := r.PathValue("namespace"); namespace != "" {
+		writePodSnapshots(w, monitoredPods(podStore.ByNamespace(namespace)))
+	} else {
+		writePodSnapshots(w, monitoredPods(podStore.List()))
+	}
+}
+
+// serveNodePods returns the cached status of the watched pods scheduled to a node
+func serveNodePods(w http.ResponseWriter, r *http.Request) {
+	writePodSnapshots(w, monitoredPods(podStore.ByNode(r.PathValue("name"))))
+}
+
+// writePodSnapshots writes the pods as sorted snapshots, or 503 while the pod store is not synced
+func writePodSnapshots(w http.ResponseWriter, pods []*model.Pod) {
+	if !podStore.HasSynced() {
+		http.Error(w, "pod cache not synced yet", http.StatusServiceUnavailable)
+		return
+	}
+	snapshots := make([]podSnapshot, 0, len(pods))
+	for _, pod := range pods {
+		snapshots = append(snapshots, newPodSnapshot(pod))
+	}
+	sortPodSnapshots(snapshots)
+	writeJSON(w, snapshots)
+}
+
+// newPodSnapshot captures the pod's current status. Waiting reasons and terminations are sorted because the
+// kubelet does not guarantee the order of container statuses
+func newPodSnapshot(pod *model.Pod) podSnapshot {
+	ready, total := pod.ReadyContainers()
+	waiting := pod.WaitingReasons()
+	sort.Strings(waiting)
+	terminations := pod.LastTerminations()
+	health, _ := podHealth(pod)
+	sort.SliceStable(terminations, func(i, j int) bool { return terminations[i].Container < terminations[j].Container })
+	return podSnapshot{
+		Namespace:    pod.Namespace(),
+		Name:         pod.Name(),
+		Node:         pod.NodeName(),
+		Phase:        string(pod.Phase()),
+		Health:       health,
+		Ready:        ready,
+		Containers:   total,
+		Restarts:     pod.RestartCount(),
+		Waiting:      waiting,
+		Terminations: terminations,
+		SpecHash:     pod.SpecHash(),
+	}
+}
+
+// writeJSON encodes the value as the JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		apiLog.Error("Error writing API response", "err", err)
+	}
+}
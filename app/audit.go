@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Checks of the audit, the keys of a namespace's counts
+const (
+	auditPrivileged  = "privileged"
+	auditRoot        = "root"
+	auditHostNetwork = "host-network"
+	auditHostPID     = "host-pid"
+	auditNoLimits    = "no-limits"
+)
+
+// auditFinding is one pod or container failing a check
+type auditFinding struct {
+	Pod string `json:"pod"`
+	// Container is empty for the checks of the pod spec, hostNetwork and hostPID
+	Container string `json:"container,omitempty"`
+	Check     string `json:"check"`
+	Detail    string `json:"detail"`
+}
+
+// namespaceAudit is the audit of one namespace's pods
+type namespaceAudit struct {
+	Namespace string `json:"namespace"`
+	Pods      int    `json:"pods"`
+	// Counts are the findings by check
+	Counts   map[string]int `json:"counts"`
+	Findings []auditFinding `json:"findings"`
+}
+
+// newAuditCommand creates the audit command
+func newAuditCommand() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report the pods running privileged, as root, on the host network or PID namespace, or without limits",
+		Long: `Report the pods running privileged, as root, on the host network or PID namespace, or without limits.
+
+Lists the monitored pods once and reports per namespace the containers that are privileged, run as root or may
+(runAsNonRoot is not set and no runAsUser other than 0 is), or lack a CPU or memory limit, and the pods sharing the
+host's network or PID namespace. Exits 1 when anything is found.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runAudit(clientset, asJSON))
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+	return cmd
+}
+
+// runAudit audits the monitored pods and prints the report, returning 1 when anything was found
+func runAudit(clientset kubernetes.Interface, asJSON bool) int {
+	pods, err := getAllPods(context.Background(), clientset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing pods: %v\n", err)
+		return 1
+	}
+	report := auditPods(pods.Items)
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing the report: %v\n", err)
+			return 1
+		}
+	} else {
+		printAudit(report)
+	}
+	for _, ns := range report {
+		if len(ns.Findings) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// auditPods audits the pods by namespace, ordered by namespace
+func auditPods(pods []v1.Pod) []namespaceAudit {
+	byNamespace := make(map[string]*namespaceAudit)
+	var report []namespaceAudit
+	for i := range pods {
+		pod := &pods[i]
+		ns, ok := byNamespace[pod.Namespace]
+		if !ok {
+			ns = &namespaceAudit{Namespace: pod.Namespace, Counts: make(map[string]int), Findings: []auditFinding{}}
+			byNamespace[pod.Namespace] = ns
+		}
+		ns.Pods++
+		for _, f := range auditPod(pod) {
+			ns.Counts[f.Check]++
+			ns.Findings = append(ns.Findings, f)
+		}
+	}
+	for _, ns := range byNamespace {
+		report = append(report, *ns)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Namespace < report[j].Namespace })
+	return report
+}
+
+// auditPod checks the pod spec and each of its containers, init containers included
+func auditPod(pod *v1.Pod) []auditFinding {
+	var findings []auditFinding
+	if pod.Spec.HostNetwork {
+		findings = append(findings, auditFinding{Pod: pod.Name, Check: auditHostNetwork, Detail: "hostNetwork: true"})
+	}
+	if pod.Spec.HostPID {
+		findings = append(findings, auditFinding{Pod: pod.Name, Check: auditHostPID, Detail: "hostPID: true"})
+	}
+	containers := append(append([]v1.Container(nil), pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		finding := func(check, detail string) {
+			findings = append(findings, auditFinding{Pod: pod.Name, Container: c.Name, Check: check, Detail: detail})
+		}
+		if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+			finding(auditPrivileged, "privileged: true")
+		}
+		if detail, ok := runsAsRoot(pod.Spec.SecurityContext, c.SecurityContext); ok {
+			finding(auditRoot, detail)
+		}
+		var missing []string
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			if _, ok := c.Resources.Limits[name]; !ok {
+				missing = append(missing, string(name))
+			}
+		}
+		if len(missing) > 0 {
+			finding(auditNoLimits, "no "+strings.Join(missing, " or ")+" limit")
+		}
+	}
+	return findings
+}
+
+// runsAsRoot reports whether a container runs as root, or may because nothing keeps the image's user from being
+// root. The container's settings override the pod's
+func runsAsRoot(pod *v1.PodSecurityContext, container *v1.SecurityContext) (string, bool) {
+	var user *int64
+	var nonRoot *bool
+	if pod != nil {
+		user, nonRoot = pod.RunAsUser, pod.RunAsNonRoot
+	}
+	if container != nil {
+		if container.RunAsUser != nil {
+			user = container.RunAsUser
+		}
+		if container.RunAsNonRoot != nil {
+			nonRoot = container.RunAsNonRoot
+		}
+	}
+	switch {
+	case user != nil && *user == 0:
+		return "runAsUser: 0", true
+	case user != nil || (nonRoot != nil && *nonRoot):
+		return "", false
+	default:
+		return "runAsNonRoot not set, the image's user may be root", true
+	}
+}
+
+// printAudit prints a summary line per namespace followed by its findings
+func printAudit(report []namespaceAudit) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, ns := range report {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		checks := make([]string, 0, len(ns.Counts))
+		for check, n := range ns.Counts {
+			checks = append(checks, fmt.Sprintf("%s %d", check, n))
+		}
+		sort.Strings(checks)
+		summary := "no findings"
+		if len(checks) > 0 {
+			summary = strings.Join(checks, ", ")
+		}
+		fmt.Fprintf(w, "Namespace %s: %d pods, %s\n", ns.Namespace, ns.Pods, summary)
+		if len(ns.Findings) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, "POD\tCONTAINER\tCHECK\tDETAIL")
+		for _, f := range ns.Findings {
+			container := f.Container
+			if container == "" {
+				container = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Pod, container, f.Check, f.Detail)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"adv-go/features"
+	"adv-go/format"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// autoscalerSources are the event source components of the supported node autoscalers
+var autoscalerSources = []string{"karpenter", "cluster-autoscaler"}
+
+// autoscalerWindow is how far apart an autoscaler action and a pod disruption may be to correlate them
+const autoscalerWindow = 10 * time.Minute
+
+// autoscalerAction is the latest autoscaler event seen for a node or pod
+type autoscalerAction struct {
+	Source string
+	Reason string
+	Note   string
+	At     time.Time
+}
+
+// podDisruption is a deleted pod waiting to be matched against autoscaler activity
+type podDisruption struct {
+	Namespace string
+	Name      string
+	Node      string
+	At        time.Time
+}
+
+// autoscalerActions holds the latest action per "Kind/namespace/name", disruptions the unmatched pod deletions
+var (
+	autoscalerActions = make(map[string]autoscalerAction)
+	disruptions       []podDisruption
+	autoscalerMu      sync.Mutex
+)
+
+// logAutoscalerActivity logs new autoscaler events and explains pod disruptions caused by them
+func logAutoscalerActivity(ctx context.Context, clientset kubernetes.Interface) {
+	for _, source := range autoscalerSources {
+		events, err := retryCall(ctx, "list "+source+" events", func(ctx context.Context) (*v1.EventList, error) {
+			return clientset.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+				FieldSelector: "source=" + source,
+			})
+		})
+		if err != nil {
+			eventsLog.Error("Error listing autoscaler events", "source", source, "err", err)
+			continue
+		}
+		for i := range events.Items {
+			if events.Items[i].Source.Component != source {
+				// Fake clientsets, as used by the demo, ignore the field selector
+				continue
+			}
+			observeAutoscalerEvent(source, model.NewEventFromCoreV1(&events.Items[i]))
+		}
+	}
+	correlateDisruptions()
+}
+
+// observeAutoscalerEvent remembers the event as the latest action on its object, logging it when it is new
+func observeAutoscalerEvent(source string, ev *model.Event) {
+	if ev.Regarding.Kind == "Pod" && !namespaceMonitored(ev.Regarding.Namespace) {
+		return
+	}
+	key := actionKey(ev.Regarding.Kind, ev.Regarding.Namespace, ev.Regarding.Name)
+
+	autoscalerMu.Lock()
+	previous, ok := autoscalerActions[key]
+	fresh := !ok || ev.LastObserved.After(previous.At)
+	if fresh {
+		autoscalerActions[key] = autoscalerAction{Source: source, Reason: ev.Reason, Note: ev.Note, At: ev.LastObserved}
+	}
+	autoscalerMu.Unlock()
+	// Node actions are remembered on every shard to explain its pod deletions but logged by one
+	if !fresh || (ev.Regarding.Kind != "Pod" && !ownsClusterWork()) {
+		return
+	}
+
+	status := fmt.Sprintf("Autoscaler: %s %s %s/%s: %s", source, ev.Reason, ev.Regarding.Kind, ev.Regarding.Name, ev.Note)
+	if err := sink.WritePriority(out, sink.PriorityEnrichment, []byte(status)); err != nil {
+		eventsLog.Error("Error writing to sink", "err", err)
+	}
+	eventsLog.Info(status, "source", source, "kind", ev.Regarding.Kind, "namespace", ev.Regarding.Namespace, "name", ev.Regarding.Name)
+}
+
+// recordPodDisruption queues a deleted pod to be explained by autoscaler activity
+func recordPodDisruption(pod *v1.Pod) {
+	if pod.Spec.NodeName == "" || !features.Enabled(features.AutoscalerCorrelation) {
+		return
+	}
+	autoscalerMu.Lock()
+	defer autoscalerMu.Unlock()
+	disruptions = append(disruptions, podDisruption{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		At:        time.Now(),
+	})
+}
+
+// correlateDisruptions logs the pod deletions explained by an autoscaler action on the pod or its node
+func correlateDisruptions() {
+	autoscalerMu.Lock()
+	var explained []string
+	pending := disruptions[:0]
+	for _, d := range disruptions {
+		action, ok := autoscalerActions[actionKey("Pod", d.Namespace, d.Name)]
+		if !ok || !withinWindow(action.At, d.At) {
+			action, ok = autoscalerActions[actionKey("Node", "", d.Node)]
+		}
+		switch {
+		case ok && withinWindow(action.At, d.At):
+			explained = append(explained, fmt.Sprintf("Disruption: Pod %s/%s left node %s, caused by %s %s %s before: %s",
+				d.Namespace, d.Name, d.Node, action.Source, action.Reason, format.Age(d.At.Sub(action.At)), action.Note))
+		case time.Since(d.At) < autoscalerWindow:
+			// The autoscaler event may not have been listed yet
+			pending = append(pending, d)
+		}
+	}
+	disruptions = pending
+
+	for key, action := range autoscalerActions {
+		if time.Since(action.At) > 2*autoscalerWindow {
+			delete(autoscalerActions, key)
+		}
+	}
+	autoscalerMu.Unlock()
+
+	for _, status := range explained {
+		if err := sink.WritePriority(out, sink.PriorityEnrichment, []byte(status)); err != nil {
+			eventsLog.Error("Error writing to sink", "err", err)
+		}
+		eventsLog.Info(status)
+	}
+}
+
+// withinWindow reports whether the action happened close enough to the disruption to have caused it
+func withinWindow(action, disruption time.Time) bool {
+	d := disruption.Sub(action)
+	return d > -time.Minute && d < autoscalerWindow
+}
+
+// actionKey identifies the object an autoscaler event is about
+func actionKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"adv-go/history"
+	"adv-go/sink"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// availabilityWindows are the rolling windows availability is reported over
+var availabilityWindows = []struct {
+	name   string
+	window time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// replicaCounts remembers the last recorded ready and desired replicas per workload so only changes are stored
+var (
+	replicaCounts   = make(map[string][2]int32)
+	replicaCountsMu sync.Mutex
+)
+
+// recordWorkloadReplicas queues a replica sample when the workload's ready or desired count changed
+func recordWorkloadReplicas(namespace, workload string, ready, desired int32) {
+	if historyStore == nil {
+		return
+	}
+	key := namespace + "/" + workload
+	replicaCountsMu.Lock()
+	changed := replicaCounts[key] != [2]int32{ready, desired}
+	_, seen := replicaCounts[key]
+	replicaCounts[key] = [2]int32{ready, desired}
+	replicaCountsMu.Unlock()
+	if seen && !changed {
+		return
+	}
+	r := history.ReplicaSample{Namespace: namespace, Workload: workload, Ready: ready, Desired: desired, At: time.Now()}
+	queueHistory("replicas of "+key, func(ctx context.Context) error {
+		return historyStore.RecordReplicas(ctx, r)
+	})
+}
+
+// lastAvailabilityDigest is when the availability digest was last written, zero until the first pass
+var lastAvailabilityDigest time.Time
+
+// writeAvailabilityDigest writes one record per workload with its availability once every --availability-digest
+func writeAvailabilityDigest(ctx context.Context) {
+	interval := cfg.History.AvailabilityDigest.Duration
+	if historyStore == nil || interval <= 0 {
+		return
+	}
+	if lastAvailabilityDigest.IsZero() {
+		// The first digest covers a full interval of this replica's observations
+		lastAvailabilityDigest = time.Now()
+		return
+	}
+	if time.Since(lastAvailabilityDigest) < interval {
+		return
+	}
+	lastAvailabilityDigest = time.Now()
+
+	availability, err := computeWorkloadAvailability(ctx, historyStore, time.Now())
+	if err != nil {
+		historyLog.Error("Error computing workload availability", "err", err)
+		return
+	}
+	batch := make([][]byte, 0, len(availability))
+	for _, a := range availability {
+		status := fmt.Sprintf("Availability: %s/%s", a.Namespace, a.Workload)
+		for _, w := range availabilityWindows {
+			if p, ok := a.Availability[w.name]; ok {
+				status += fmt.Sprintf(", %s: %.2f%% (down %s)", w.name, p, a.Downtime[w.name])
+			}
+		}
+		collectorLog.Info(status, "namespace", a.Namespace, "workload", a.Workload)
+		batch = append(batch, []byte(status))
+	}
+	writeBatch(ctx, sink.PriorityEnrichment, batch)
+}
+
+// workloadAvailability is a workload's availability in each of the windows
+type workloadAvailability struct {
+	Namespace string `json:"namespace"`
+	Workload  string `json:"workload"`
+	// Availability maps window names to the available percentage, windows without observations are left out
+	Availability map[string]float64 `json:"availability"`
+	Downtime     map[string]string  `json:"downtime"`
+}
+
+// computeWorkloadAvailability reads the replica samples of the longest window and computes every window from them
+func computeWorkloadAvailability(ctx context.Context, store *history.Store, now time.Time) ([]workloadAvailability, error) {
+	longest := availabilityWindows[len(availabilityWindows)-1].window
+	samples, err := store.ReplicaSamples(ctx, now.Add(-longest))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []workloadAvailability
+	index := make(map[string]int)
+	for _, w := range availabilityWindows {
+		for _, a := range history.ComputeAvailability(samples, now.Add(-w.window), now) {
+			key := a.Namespace + "/" + a.Workload
+			i, ok := index[key]
+			if !ok {
+				i = len(result)
+				index[key] = i
+				result = append(result, workloadAvailability{Namespace: a.Namespace, Workload: a.Workload,
+					Availability: make(map[string]float64), Downtime: make(map[string]string)})
+			}
+			if a.Observed > 0 {
+				result[i].Availability[w.name] = a.Percent
+				result[i].Downtime[w.name] = a.Downtime.Round(time.Second).String()
+			}
+		}
+	}
+	return result, nil
+}
+
+// serveAvailability returns the availability of every workload over the rolling windows as JSON
+func serveAvailability(w http.ResponseWriter, r *http.Request) {
+	availability, err := computeWorkloadAvailability(r.Context(), historyStore, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if availability == nil {
+		availability = []workloadAvailability{}
+	}
+	writeJSON(w, availability)
+}
+
+// newAvailabilityCommand creates the availability command
+func newAvailabilityCommand() *cobra.Command {
+	var namespace string
+	cmd := &cobra.Command{
+		Use:   "availability",
+		Short: "Print the availability of every workload over the last 24 hours, 7 days and 30 days",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.History.DSN == "" {
+				return errors.New("availability requires --history-dsn")
+			}
+			return exitCode(runAvailability(namespace))
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only report the workloads of this namespace")
+	return cmd
+}
+
+// runAvailability prints the availability report as a table
+func runAvailability(namespace string) int {
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening history store: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	all, err := computeWorkloadAvailability(context.Background(), store, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading replica history: %v\n", err)
+		return 1
+	}
+	var availability []workloadAvailability
+	for _, a := range all {
+		if namespace == "" || a.Namespace == namespace {
+			availability = append(availability, a)
+		}
+	}
+	if len(availability) == 0 {
+		fmt.Println("No workload replicas recorded")
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "NAMESPACE\tWORKLOAD")
+	for _, w := range availabilityWindows {
+		fmt.Fprintf(tw, "\t%s", w.name)
+	}
+	fmt.Fprintln(tw, "\tDOWNTIME (30d)")
+	for _, a := range availability {
+		fmt.Fprintf(tw, "%s\t%s", a.Namespace, a.Workload)
+		for _, w := range availabilityWindows {
+			if p, ok := a.Availability[w.name]; ok {
+				fmt.Fprintf(tw, "\t%.2f%%", p)
+			} else {
+				fmt.Fprint(tw, "\t-")
+			}
+		}
+		downtime := a.Downtime[availabilityWindows[len(availabilityWindows)-1].name]
+		if downtime == "" {
+			downtime = "-"
+		}
+		fmt.Fprintf(tw, "\t%s\n", downtime)
+	}
+	tw.Flush()
+	return 0
+}
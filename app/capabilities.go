@@ -0,0 +1,64 @@
+package app
+
+import (
+	"strings"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// clusterInfo is the apiserver version and which optional APIs it serves, discovered once when connecting
+type clusterInfo struct {
+	// Version is the apiserver's git version, e.g. v1.29.3, "unknown" when discovery failed
+	Version string
+	// EventsV1 reports whether events.k8s.io/v1 is served, otherwise only core/v1 events are read
+	EventsV1 bool
+	// EndpointSlices reports whether discovery.k8s.io/v1 is served (Kubernetes 1.21 and later)
+	EndpointSlices bool
+	// Metrics reports whether metrics-server's metrics.k8s.io/v1beta1 is served
+	Metrics bool
+}
+
+// cluster is the discovered cluster, it assumes every API is served until detectCluster ran
+var cluster = clusterInfo{Version: "unknown", EventsV1: true, EndpointSlices: true, Metrics: true}
+
+// detectCluster asks the apiserver for its version and API groups. When discovery fails every API is assumed
+// to be served, the collectors then fall back on their own when a call fails
+func detectCluster(clientset kubernetes.Interface) clusterInfo {
+	info := clusterInfo{Version: "unknown", EventsV1: true, EndpointSlices: true, Metrics: true}
+	if v, err := clientset.Discovery().ServerVersion(); err != nil {
+		agentLog.Warn("Could not read the apiserver version", "err", err)
+	} else {
+		info.Version = v.GitVersion
+	}
+
+	groups, err := clientset.Discovery().ServerGroups()
+	if err != nil {
+		agentLog.Warn("Could not discover the served API groups, assuming all are available", "err", err)
+		return info
+	}
+	served := make(map[string]bool)
+	for _, g := range groups.Groups {
+		for _, v := range g.Versions {
+			served[v.GroupVersion] = true
+		}
+	}
+	info.EventsV1 = served[eventsv1.SchemeGroupVersion.String()]
+	info.EndpointSlices = served[discoveryv1.SchemeGroupVersion.String()]
+	info.Metrics = served[metricsv1beta1.SchemeGroupVersion.String()]
+
+	var missing []string
+	if !info.EventsV1 {
+		missing = append(missing, "events.k8s.io/v1 (reading core/v1 events)")
+	}
+	if !info.EndpointSlices {
+		missing = append(missing, "discovery.k8s.io/v1 (incident bundles skip endpoint slices)")
+	}
+	if !info.Metrics && cfg.WithMetrics {
+		missing = append(missing, "metrics.k8s.io/v1beta1 (disabling --with-metrics)")
+	}
+	agentLog.Info("Connected to cluster", "version", info.Version, "groups", len(groups.Groups), "unavailable", strings.Join(missing, ", "))
+	return info
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/sink"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// churn counts pod creations and deletions per namespace so runaway CronJobs and crash looping controllers raise an alert
+var churn *model.ChurnTracker
+
+// observePodChurn counts a created or deleted pod and alerts when its namespace churns more than the threshold
+// within the churn alert window
+func observePodChurn(obj *v1.Pod, created bool) {
+	pod := model.NewPod(obj)
+	workload := pod.Workload()
+	event := "deleted"
+	if created {
+		event = "created"
+	}
+	// Bare pods are counted under their kind alone, their names would make a label per pod
+	label := workload
+	if strings.HasPrefix(label, "Pod/") {
+		label = "Pod"
+	}
+	metrics.PodChurn.WithLabelValues(pod.Namespace(), label, event).Inc()
+
+	if cfg.ChurnAlertThreshold <= 0 {
+		return
+	}
+	c := churn.Observe(pod.Namespace(), workload, created, time.Now())
+	if c.Total() <= cfg.ChurnAlertThreshold {
+		return
+	}
+	churn.Reset(pod.Namespace())
+	metrics.PodChurnAlerts.WithLabelValues(pod.Namespace()).Inc()
+
+	alert := fmt.Sprintf("ALERT: namespace %s churned %d pods within %s (%d created, %d deleted), most by %s (%d)",
+		pod.Namespace(), c.Total(), format.Age(cfg.ChurnAlertWindow.Duration), c.Created, c.Deleted, c.TopWorkload, c.TopCount)
+	if err := sink.WritePriority(out, sink.PriorityAlert, []byte(alert)); err != nil {
+		alertsLog.Error("Error writing to sink", "err", err)
+	}
+	alertsLog.Warn(alert, "namespace", pod.Namespace(), "workload", c.TopWorkload, "created", c.Created, "deleted", c.Deleted)
+}
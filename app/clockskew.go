@@ -0,0 +1,107 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/model"
+	"context"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// nodeLeaseNamespace holds the heartbeat leases renewed by each kubelet
+const nodeLeaseNamespace = "kube-node-lease"
+
+// checkClockSkew compares node heartbeats and kubelet event times against the agent clock
+func checkClockSkew(ctx context.Context, clientset kubernetes.Interface) {
+	now := time.Now()
+	skews, err := leaseClockSkews(ctx, clientset, now)
+	if err != nil {
+		collectorLog.Error("Error listing node leases", "err", err)
+		return
+	}
+
+	// Events reported by a kubelet carry the node's clock, anything from the future means it is ahead
+	events, err := getAllEvents(ctx, clientset)
+	if err != nil {
+		collectorLog.Error("Error listing events", "err", err)
+	}
+	for _, ev := range events {
+		if _, isNode := skews[ev.Host]; !isNode {
+			continue
+		}
+		if ahead := ev.LastObserved.Sub(now); ahead > skews[ev.Host] {
+			skews[ev.Host] = ahead
+		}
+	}
+
+	for node, skew := range skews {
+		metrics.NodeClockSkew.WithLabelValues(node).Set(skew.Seconds())
+		if exceedsSkew(skew) {
+			collectorLog.Warn("Node clock is off, certificate validation and log correlation may break",
+				"node", node, "skew", format.Duration(skew))
+		}
+	}
+}
+
+// leaseClockSkews estimates each Ready node's clock offset from the renew time of its heartbeat lease
+func leaseClockSkews(ctx context.Context, clientset kubernetes.Interface, now time.Time) (map[string]time.Duration, error) {
+	nodes, err := getAllNodes(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := retryCall(ctx, "list node leases", func(ctx context.Context) (*coordinationv1.LeaseList, error) {
+		return clientset.CoordinationV1().Leases(nodeLeaseNamespace).List(ctx, metav1.ListOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ready := make(map[string]bool, len(nodes.Items))
+	skews := make(map[string]time.Duration, len(nodes.Items))
+	for i := range nodes.Items {
+		node := model.NewNode(&nodes.Items[i])
+		// A virtual kubelet provider renews leases on its own schedule, not with a node clock
+		if node.VirtualKind() == "virtual-kubelet" {
+			continue
+		}
+		ready[node.Name()] = node.IsReady()
+		skews[node.Name()] = 0
+	}
+
+	for _, lease := range leases.Items {
+		if lease.Spec.RenewTime == nil || !ready[lease.Name] {
+			continue
+		}
+		offset := lease.Spec.RenewTime.Sub(now)
+
+		// The node controller judges readiness by when it sees renewals, not by their timestamps,
+		// so a Ready node whose renewals look older than a renew interval has a clock that is behind
+		renewInterval := 10 * time.Second
+		if lease.Spec.LeaseDurationSeconds != nil {
+			renewInterval = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second / 4
+		}
+		switch {
+		case offset < -renewInterval:
+			offset += renewInterval
+		case offset < 0:
+			offset = 0
+		}
+		skews[lease.Name] = offset
+	}
+	return skews, nil
+}
+
+// exceedsSkew reports whether the absolute skew is above the configured threshold
+func exceedsSkew(skew time.Duration) bool {
+	if cfg.ClockSkewThreshold.Duration <= 0 {
+		return false
+	}
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew > cfg.ClockSkewThreshold.Duration
+}
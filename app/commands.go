@@ -0,0 +1,356 @@
+package app
+
+import (
+	"adv-go/config"
+	"adv-go/election"
+	"adv-go/features"
+	"adv-go/format"
+	"adv-go/logging"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// version is the release the binary was built from, set with -ldflags "-X adv-go/app.version=..."
+var version = "dev"
+
+// exitError ends the command with a specific exit code, the command has already reported why
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitCode turns the exit code of a run function into the error a cobra command returns
+func exitCode(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return exitError{code: code}
+}
+
+// Execute runs the pod-logger command line and returns the process exit code
+func Execute() int {
+	err := newRootCommand().Execute()
+	var exit exitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exit):
+		return exit.code
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+}
+
+// newRootCommand creates the pod-logger command, which runs the agent when no subcommand is given. The agent
+// settings are persistent flags so every subcommand loads the same config
+func newRootCommand() *cobra.Command {
+	agentFlags := flag.NewFlagSet("pod-logger", flag.ContinueOnError)
+	config.BindFlags(agentFlags, &cfg)
+	agentFlags.StringVar(&configPath, "config", "", "path to a YAML config file, flags override its values")
+
+	root := &cobra.Command{
+		Use:           "pod-logger",
+		Short:         "Log and alert on the state of the pods in a Kubernetes cluster",
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// config.Load only sees the flags set on the Go flag set, so replay the ones given on the command line
+			var err error
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				if agentFlags.Lookup(f.Name) != nil && err == nil {
+					err = agentFlags.Set(f.Name, f.Value.String())
+				}
+			})
+			if err != nil {
+				return err
+			}
+			if err := config.Load(configPath, &cfg, agentFlags); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if err := logging.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+				return fmt.Errorf("invalid logging config: %w", err)
+			}
+			if err := format.SetTimezone(cfg.Timezone); err != nil {
+				return fmt.Errorf("invalid timezone: %w", err)
+			}
+			if err := features.Set(cfg.FeatureGates); err != nil {
+				return fmt.Errorf("invalid feature gates: %w", err)
+			}
+			if err := setHealthRules(cfg.HealthRules); err != nil {
+				return fmt.Errorf("invalid health rules: %w", err)
+			}
+			if err := setExclusions(cfg.IgnoreLabels); err != nil {
+				return fmt.Errorf("invalid exclusions: %w", err)
+			}
+			return nil
+		},
+		RunE: runAgentCommand,
+	}
+	root.PersistentFlags().AddGoFlagSet(agentFlags)
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "run",
+			Short: "Run the agent, the default when no command is given",
+			Args:  cobra.NoArgs,
+			RunE:  runAgentCommand,
+		},
+		newSnapshotCommand(),
+		newDiffCommand(),
+		newExportCommand(),
+		newHistoryCommand(),
+		newReplayCommand(),
+		newAvailabilityCommand(),
+		newAlertsCommand(),
+		newMigrateCommand(),
+		newRolloutCommand(),
+		newInspectCommand(),
+		newAuditCommand(),
+		newImagesCommand(),
+		newTopologyCommand(),
+		newRulesCommand(),
+		newKeygenCommand(),
+		newVerifyCommand(),
+		newLeaderStatusCommand(),
+		newReachabilityCommand(),
+		newSelfTestCommand(),
+		newFeaturesCommand(),
+		newVersionCommand(),
+	)
+	for _, newCommand := range optionalCommands {
+		root.AddCommand(newCommand())
+	}
+	return root
+}
+
+// optionalCommands are added by the files of commands a build tag can leave out of the binary
+var optionalCommands []func() *cobra.Command
+
+// connect creates the clients of the configured cluster
+func connect() error {
+	restConfig, err := loadKubeConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load Kubernetes config: %w", err)
+	}
+	if clientset, err = kubernetes.NewForConfig(restConfig); err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	if metadataClient, err = metadata.NewForConfig(restConfig); err != nil {
+		return fmt.Errorf("failed to create metadata client: %w", err)
+	}
+	cluster = detectCluster(clientset)
+	if selectingNamespaces() {
+		if err := loadSelectedNamespaces(context.Background(), clientset); err != nil {
+			return fmt.Errorf("failed to list the namespaces of the namespace selector: %w", err)
+		}
+	}
+	if cfg.WithMetrics && !cluster.Metrics {
+		cfg.WithMetrics = false
+	}
+	if cfg.WithMetrics {
+		if podMetricsClient, err = metricsclientset.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("failed to create metrics client: %w", err)
+		}
+	}
+	if cfg.StatusReports {
+		if dynamicClient, err = dynamic.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("failed to create dynamic client: %w", err)
+		}
+	}
+	return nil
+}
+
+// runAgentCommand connects to the cluster and runs the agent until the process is stopped
+func runAgentCommand(cmd *cobra.Command, args []string) error {
+	if err := connect(); err != nil {
+		return err
+	}
+	// Report precisely which permissions are missing rather than failing later with a bare Forbidden
+	if cfg.CheckPermissions {
+		return exitCode(runPermissionReport(clientset))
+	}
+	// Determine if we're running inside a Kubernetes cluster
+	isInCluster := isRunningInCluster()
+	logMissingPermissions(clientset, isInCluster)
+	runAgent(clientset, isInCluster)
+	return nil
+}
+
+// newVersionCommand creates the version command
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version the binary was built from",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("pod-logger %s\n", version)
+			fmt.Printf("go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+			if info, ok := debug.ReadBuildInfo(); ok {
+				for _, s := range info.Settings {
+					if s.Key == "-tags" || s.Key == "vcs.revision" || s.Key == "vcs.time" || s.Key == "vcs.modified" {
+						fmt.Printf("%s: %s\n", s.Key, s.Value)
+					}
+				}
+			}
+		},
+	}
+}
+
+// newFeaturesCommand creates the features command
+func newFeaturesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "features",
+		Short: "List the feature gates and whether they are enabled with the loaded config",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FEATURE\tSTAGE\tDEFAULT\tENABLED\tDESCRIPTION")
+			for _, f := range features.List() {
+				fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\n", f.Name, f.Stage, f.Default, f.Enabled, f.Description)
+			}
+			w.Flush()
+		},
+	}
+}
+
+// newLeaderStatusCommand creates the leader-status command
+func newLeaderStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "leader-status",
+		Short: "Print which replica holds the leader election lock, each shard lock or the member Leases, exiting 1 when one is not held",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runLeaderStatus(clientset))
+		},
+	}
+}
+
+// runLeaderStatus reads the leader election lock, every shard lock with --lease-shards or the member Leases with
+// --lease-hash-pods, and reports the holders
+func runLeaderStatus(clientset kubernetes.Interface) int {
+	if hashingPods() {
+		return printMembers(clientset, electionConfig())
+	}
+	if !sharding() {
+		return printLockStatus(clientset, electionConfig())
+	}
+	code := 0
+	for shard := 0; shard < cfg.Lease.Shards; shard++ {
+		if shard > 0 {
+			fmt.Println()
+		}
+		if printLockStatus(clientset, election.ShardConfig(electionConfig(), shard)) != 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// printMembers lists the replicas the pods are hashed across and when their member Leases expire, 1 when none is live
+func printMembers(clientset kubernetes.Interface, config election.Config) int {
+	members, err := election.ReadMembers(context.Background(), clientset, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading the member Leases of %s/%s: %v\n", config.Namespace, config.Name, err)
+		return 1
+	}
+	fmt.Printf("Members of:  %s/%s\n", config.Namespace, config.Name)
+	live := 0
+	for _, m := range members {
+		state := "expires " + format.Time(m.Expires)
+		if time.Now().After(m.Expires) {
+			state = "expired " + format.Time(m.Expires)
+		} else {
+			live++
+		}
+		if !m.Heartbeat.IsZero() {
+			state += fmt.Sprintf(", last pass %s ago", time.Since(m.Heartbeat).Round(time.Second))
+		}
+		fmt.Printf("  %s renewed %s ago, %s\n", m.Identity, time.Since(m.Renewed).Round(time.Second), state)
+	}
+	fmt.Printf("Live:        %d of %d\n", live, len(members))
+	if live == 0 {
+		return 1
+	}
+	return 0
+}
+
+// printLockStatus reads a leader election lock and reports its holder and heartbeat, followed by the transitions
+// annotated on its Lease
+func printLockStatus(clientset kubernetes.Interface, config election.Config) int {
+	record, err := election.ReadRecord(context.Background(), clientset, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s lock %s/%s: %v\n", config.LockType, config.Namespace, config.Name, err)
+		return 1
+	}
+
+	fmt.Printf("Lock:        %s/%s (%s)\n", config.Namespace, config.Name, config.LockType)
+	code := printLockHolder(record)
+	heartbeat, err := election.ReadHeartbeat(context.Background(), clientset, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading the heartbeat of %s/%s: %v\n", config.Namespace, config.Name, err)
+		return 1
+	}
+	if !heartbeat.IsZero() {
+		fmt.Printf("Last pass:   %s (%s ago)\n", format.Time(heartbeat), time.Since(heartbeat).Round(time.Second))
+	}
+	transitions, err := election.ReadTransitions(context.Background(), clientset, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading the transitions of %s/%s: %v\n", config.Namespace, config.Name, err)
+		return 1
+	}
+	if len(transitions) > 0 {
+		fmt.Println("History:")
+	}
+	for _, t := range transitions {
+		fmt.Printf("  %s %s %s: %s\n", format.Time(t.At), t.Identity, t.Event, t.Reason)
+	}
+	return code
+}
+
+// printLockHolder reports the holder of a lock record and when its lease expires, 1 when no replica holds it
+func printLockHolder(record *resourcelock.LeaderElectionRecord) int {
+	if record.HolderIdentity == "" {
+		fmt.Println("Leader:      none")
+		return 1
+	}
+	fmt.Printf("Leader:      %s\n", record.HolderIdentity)
+	if !record.AcquireTime.IsZero() {
+		fmt.Printf("Acquired:    %s\n", format.Time(record.AcquireTime.Time))
+	}
+	fmt.Printf("Transitions: %d\n", record.LeaderTransitions)
+	if record.RenewTime.IsZero() || record.LeaseDurationSeconds == 0 {
+		return 0
+	}
+	renewed := record.RenewTime.Time
+	expires := renewed.Add(time.Duration(record.LeaseDurationSeconds) * time.Second)
+	fmt.Printf("Renewed:     %s (%s ago)\n", format.Time(renewed), time.Since(renewed).Round(time.Second))
+	if time.Now().After(expires) {
+		fmt.Printf("Expired:     %s, no replica is leading\n", format.Time(expires))
+		return 1
+	}
+	fmt.Printf("Expires:     %s\n", format.Time(expires))
+	return 0
+}
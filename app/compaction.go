@@ -0,0 +1,83 @@
+package app
+
+import (
+	"adv-go/history"
+	"adv-go/metrics"
+	"context"
+	"net/http"
+	"time"
+)
+
+// startHistoryCompaction compacts the history store every --compaction-interval until the term ends, when
+// --retention is set. Only the replica doing the cluster-scoped work compacts, the others share its database
+func startHistoryCompaction(ctx context.Context) {
+	if historyStore == nil || cfg.History.Retention.Duration <= 0 {
+		return
+	}
+	goLeader(func() {
+		ticker := time.NewTicker(cfg.History.CompactionInterval.Duration)
+		defer ticker.Stop()
+		for {
+			if ownsClusterWork() {
+				compactHistory(ctx)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// compactHistory runs one compaction and counts what it rolled up, logging it when there was anything
+func compactHistory(ctx context.Context) {
+	start := time.Now()
+	retention := history.Retention{
+		Raw:    cfg.History.Retention.Duration,
+		Hourly: cfg.History.HourlyRetention.Duration,
+		Daily:  cfg.History.DailyRetention.Duration,
+	}
+	c, err := historyStore.Compact(ctx, retention, start)
+	if err != nil {
+		if ctx.Err() == nil {
+			historyLog.Error("Error compacting history", "err", err)
+		}
+		return
+	}
+	metrics.HistoryCompacted.WithLabelValues("transitions").Add(float64(c.Transitions))
+	metrics.HistoryCompacted.WithLabelValues("events").Add(float64(c.Events))
+	metrics.HistoryCompacted.WithLabelValues("lineage").Add(float64(c.Lineage))
+	metrics.HistoryCompacted.WithLabelValues("hourly").Add(float64(c.Hourly))
+	metrics.HistoryCompacted.WithLabelValues("daily").Add(float64(c.Daily))
+	log := historyLog.Info
+	if c == (history.Compaction{}) {
+		log = historyLog.Debug
+	}
+	log("Compacted history", "transitions", c.Transitions, "events", c.Events, "lineage", c.Lineage,
+		"hourly", c.Hourly, "daily", c.Daily, "took", time.Since(start).Round(time.Millisecond).String())
+}
+
+// serveRollups returns the hourly and daily counts of the buckets within ?since= (30 days by default), optionally
+// of one ?namespace=
+func serveRollups(w http.ResponseWriter, r *http.Request) {
+	since := 30 * 24 * time.Hour
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+
+	rollups, err := historyStore.Rollups(r.Context(), r.URL.Query().Get("namespace"), time.Now().Add(-since))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rollups == nil {
+		rollups = []history.Rollup{}
+	}
+	writeJSON(w, rollups)
+}
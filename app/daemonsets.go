@@ -0,0 +1,256 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// coverageGap is a node a DaemonSet targets without a ready pod of it
+type coverageGap struct {
+	since   time.Time
+	alerted bool
+}
+
+// coverageGaps are the gaps seen by "namespace/daemonset/node", kept across passes to time them against the grace
+var (
+	coverageGaps = make(map[string]*coverageGap)
+	coverageMu   sync.Mutex
+)
+
+// resetDaemonSetCoverage forgets the gaps seen, a new leader times them from its first pass
+func resetDaemonSetCoverage() {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	coverageGaps = make(map[string]*coverageGap)
+}
+
+// checkDaemonSetCoverage logs one line per DaemonSet with the nodes its node selector and affinity target that have
+// no ready pod of it, and alerts once a node went without one for longer than --daemonset-coverage-grace
+func checkDaemonSetCoverage(ctx context.Context, clientset kubernetes.Interface, nodes nodeIndex) {
+	metrics.DaemonSetMissingNodes.Reset()
+	if len(nodes) == 0 {
+		return
+	}
+	now := time.Now()
+	current := make(map[string]string)
+	checked := make(map[string]bool)
+	// Gaps are only forgotten for the DaemonSets checked, unless every one could be
+	complete := true
+	var records []string
+	for _, namespace := range monitoredNamespaces() {
+		daemonSets, err := retryCall(ctx, "list daemon sets", func(ctx context.Context) (*appsv1.DaemonSetList, error) {
+			return clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing daemon sets", "namespace", namespace, "err", err)
+			complete = false
+			continue
+		}
+		for i := range daemonSets.Items {
+			ds := model.NewDaemonSet(&daemonSets.Items[i])
+			if ignoredNamespace(ds.Namespace()) || !ownsWorkload("DaemonSet/"+ds.Namespace()+"/"+ds.Name()) {
+				continue
+			}
+			pods, err := daemonPods(ctx, clientset, ds.Object())
+			if err != nil {
+				collectorLog.Error("Error listing daemon set pods", "namespace", ds.Namespace(), "daemonset", ds.Name(), "err", err)
+				complete = false
+				continue
+			}
+			checked[ds.Namespace()+"/"+ds.Name()] = true
+			gaps := make(map[string]string)
+			targeted, ready := 0, 0
+			for _, name := range sortedKeys(nodes) {
+				node := nodes[name]
+				// Fargate and virtual-kubelet nodes run no daemons
+				if node.VirtualKind() != "" || !ds.Targets(node) {
+					continue
+				}
+				targeted++
+				if reason, covered := nodeCoverage(ds, node, pods[name]); covered {
+					ready++
+				} else {
+					gaps[name] = reason
+					current[ds.Namespace()+"/"+ds.Name()+"/"+name] = reason
+				}
+			}
+			records = append(records, trackCoverage(ds, targeted, ready, gaps, now))
+		}
+	}
+
+	for _, status := range records {
+		collectorLog.Info(status)
+	}
+	batch := make([][]byte, len(records))
+	for i, status := range records {
+		batch[i] = []byte(status)
+	}
+	writeBatch(ctx, sink.PriorityHeartbeat, batch)
+	forgetCoverageGaps(current, checked, complete, nodes)
+}
+
+// ownsWorkload reports whether this replica checks the workload: always unless hashing pods, then the replica the
+// workload hashes to
+func ownsWorkload(key string) bool {
+	members, ok := podMembers()
+	return !ok || members.Owns(key)
+}
+
+// daemonPods lists the pods the DaemonSet controls by the node they run on or, while Pending, are bound to
+func daemonPods(ctx context.Context, clientset kubernetes.Interface, ds *appsv1.DaemonSet) (map[string][]*v1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	list, err := retryCall(ctx, "list daemon set pods", func(ctx context.Context) (*v1.PodList, error) {
+		return clientset.CoreV1().Pods(ds.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	byNode := make(map[string][]*v1.Pod)
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if !metav1.IsControlledBy(pod, ds) {
+			continue
+		}
+		if node := daemonPodNode(pod); node != "" {
+			byNode[node] = append(byNode[node], pod)
+		}
+	}
+	for _, pods := range byNode {
+		sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	}
+	return byNode, nil
+}
+
+// daemonPodNode is the node a daemon pod runs on, or the one the controller bound it to with a metadata.name node
+// affinity while it is not scheduled yet
+func daemonPodNode(pod *v1.Pod) string {
+	if pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil || pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, req := range term.MatchFields {
+			if req.Key == "metadata.name" && req.Operator == v1.NodeSelectorOpIn && len(req.Values) == 1 {
+				return req.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// nodeCoverage reports whether one of the DaemonSet's pods on the node is ready, or else why none is: the pod
+// cannot be scheduled or is not ready, a taint keeps the pods off the node, or the node is disrupted
+func nodeCoverage(ds *model.DaemonSet, node *model.Node, pods []*v1.Pod) (string, bool) {
+	for _, pod := range pods {
+		if c := podCondition(pod, v1.PodReady); c != nil && c.Status == v1.ConditionTrue {
+			return "", true
+		}
+	}
+	if len(pods) > 0 {
+		return daemonPodProblem(pods[0]), false
+	}
+	if taint, ok := ds.BlockingTaint(node); ok {
+		return "taint " + taint.ToString() + " not tolerated", false
+	}
+	if disruptions := node.Disruptions(); len(disruptions) > 0 {
+		return "no pod, node " + strings.Join(disruptions, ","), false
+	}
+	return "no pod created", false
+}
+
+// daemonPodProblem explains why a daemon pod is not ready, with the scheduler's diagnosis while it has no node
+func daemonPodProblem(pod *v1.Pod) string {
+	if pod.Spec.NodeName == "" {
+		if c := podCondition(pod, v1.PodScheduled); c != nil && c.Status == v1.ConditionFalse && c.Message != "" {
+			return fmt.Sprintf("pod %s cannot be scheduled: %s", pod.Name, model.DiagnoseScheduling(c.Message))
+		}
+		return "pod " + pod.Name + " not scheduled yet"
+	}
+	if reasons := model.NewPod(pod).WaitingReasons(); len(reasons) > 0 {
+		return fmt.Sprintf("pod %s %s: %s", pod.Name, pod.Status.Phase, strings.Join(reasons, ","))
+	}
+	return fmt.Sprintf("pod %s %s, not ready", pod.Name, pod.Status.Phase)
+}
+
+// trackCoverage times the DaemonSet's gaps by node, alerting on those past the grace, and renders its line, e.g.
+// "DaemonSet: kube-system/fluent-bit, Healthy: false, Nodes: 12, Ready: 11, Missing: [ip-10-0-3-7: taint dedicated=gpu:NoSchedule not tolerated]"
+func trackCoverage(ds *model.DaemonSet, targeted, ready int, gaps map[string]string, now time.Time) string {
+	var missing []string
+	coverageMu.Lock()
+	for node, reason := range gaps {
+		key := ds.Namespace() + "/" + ds.Name() + "/" + node
+		gap := coverageGaps[key]
+		if gap == nil {
+			gap = &coverageGap{since: now}
+			coverageGaps[key] = gap
+		}
+		missingFor := now.Sub(gap.since)
+		if missingFor < cfg.DaemonSetCoverageGrace.Duration {
+			continue
+		}
+		missing = append(missing, node+": "+reason)
+		if !gap.alerted {
+			gap.alerted = true
+			alert := fmt.Sprintf("ALERT: DaemonSet %s/%s has no ready pod on node %s for %s: %s",
+				ds.Namespace(), ds.Name(), node, format.Age(missingFor), reason)
+			if err := sink.WritePriority(out, sink.PriorityAlert, []byte(alert)); err != nil {
+				alertsLog.Error("Error writing to sink", "err", err)
+			}
+			alertsLog.Warn(alert, "namespace", ds.Namespace(), "daemonset", ds.Name(), "node", node, "reason", reason)
+		}
+	}
+	coverageMu.Unlock()
+
+	sort.Strings(missing)
+	metrics.DaemonSetMissingNodes.WithLabelValues(ds.Namespace(), ds.Name()).Set(float64(len(missing)))
+	return fmt.Sprintf("DaemonSet: %s/%s, Healthy: %t, Nodes: %d, Ready: %d, Missing: [%s]",
+		ds.Namespace(), ds.Name(), len(missing) == 0, targeted, ready, strings.Join(missing, ", "))
+}
+
+// forgetCoverageGaps drops the gaps not seen in this pass, writing a record for the alerted ones that closed on a
+// node still there. After a failed listing the gaps of the DaemonSets not checked are kept
+func forgetCoverageGaps(current map[string]string, checked map[string]bool, complete bool, nodes nodeIndex) {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	for key, gap := range coverageGaps {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		namespace, rest, _ := strings.Cut(key, "/")
+		name, node, _ := strings.Cut(rest, "/")
+		if !checked[namespace+"/"+name] {
+			// Deleted, or not checked this pass
+			if complete {
+				delete(coverageGaps, key)
+			}
+			continue
+		}
+		delete(coverageGaps, key)
+		if _, ok := nodes[node]; !ok || !gap.alerted {
+			continue
+		}
+		status := fmt.Sprintf("DaemonSet coverage restored: %s/%s on node %s after %s", namespace, name, node, format.Age(time.Since(gap.since)))
+		if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+			alertsLog.Error("Error writing to sink", "err", err)
+		}
+		alertsLog.Info(status, "namespace", namespace, "daemonset", name, "node", node)
+	}
+}
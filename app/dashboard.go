@@ -0,0 +1,167 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"adv-go/podstream"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//go:embed web/dashboard.html
+var dashboardPage []byte
+
+const (
+	// dashboardRecent is how many of the latest transitions a newly opened dashboard starts with
+	dashboardRecent = 50
+	// dashboardInterval is how often the dashboard's pod counts and leadership state are refreshed
+	dashboardInterval = 5 * time.Second
+)
+
+// recentTransitions are the latest pod transitions, oldest first, for dashboards opened later
+var (
+	recentTransitions []podstream.Update
+	recentMu          sync.Mutex
+)
+
+// dashboardSummary is the dashboard's view of the pod store and the leadership
+type dashboardSummary struct {
+	Leader string `json:"leader"`
+	// Synced is false while the pod store is not synced, the counts are empty then
+	Synced bool           `json:"synced"`
+	Pods   int            `json:"pods"`
+	Phases map[string]int `json:"phases"`
+	// Health counts the pods by the class the health rules give them
+	Health map[string]int `json:"health"`
+}
+
+// dashboardTransition is a pod transition as sent to the dashboard
+type dashboardTransition struct {
+	Namespace     string    `json:"namespace"`
+	Pod           string    `json:"pod"`
+	Node          string    `json:"node,omitempty"`
+	PreviousState string    `json:"previousState,omitempty"`
+	State         string    `json:"state"`
+	ObservedAt    time.Time `json:"observedAt"`
+}
+
+// registerDashboard adds the dashboard and its event stream to the metrics server
+func registerDashboard() {
+	metrics.Handle("GET /dashboard", http.RedirectHandler("/dashboard/", http.StatusMovedPermanently))
+	metrics.Handle("GET /dashboard/{$}", http.HandlerFunc(serveDashboard))
+	metrics.Handle("GET /dashboard/events", http.HandlerFunc(serveDashboardEvents))
+}
+
+// serveDashboard returns the dashboard page
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
+
+// serveDashboardEvents streams server-sent events to the dashboard: the recent transitions, then every new one once
+// the pod cache synced, and a summary every dashboardInterval
+func serveDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	recentMu.Lock()
+	recent := append([]podstream.Update(nil), recentTransitions...)
+	recentMu.Unlock()
+	for _, u := range recent {
+		writeDashboardEvent(w, "transition", newDashboardTransition(u))
+	}
+	writeDashboardEvent(w, "summary", summarizeDashboard())
+	flusher.Flush()
+
+	// Until the pod cache synced only summaries are streamed, the transitions are subscribed to once it did
+	var sub *podstream.Subscription
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+	tick := time.NewTicker(dashboardInterval)
+	defer tick.Stop()
+	for {
+		if sub == nil && podStream != nil {
+			sub, _ = podStream.Subscribe("")
+		}
+		var updates <-chan podstream.Update
+		var done <-chan struct{}
+		if sub != nil {
+			updates, done = sub.Updates(), sub.Done()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case u := <-updates:
+			writeDashboardEvent(w, "transition", newDashboardTransition(u))
+		case <-done:
+			sub = nil
+			writeDashboardEvent(w, "summary", summarizeDashboard())
+		case <-tick.C:
+			writeDashboardEvent(w, "summary", summarizeDashboard())
+		}
+		flusher.Flush()
+	}
+}
+
+// writeDashboardEvent writes a server-sent event with the value as JSON data
+func writeDashboardEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		apiLog.Error("Error encoding dashboard event", "event", event, "err", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// summarizeDashboard counts the stored pods by phase and health and describes the leadership as the health check does
+func summarizeDashboard() dashboardSummary {
+	summary := dashboardSummary{
+		Leader: leadershipCheck().info,
+		Synced: podStore.HasSynced(),
+		Phases: make(map[string]int),
+		Health: make(map[string]int),
+	}
+	if !summary.Synced {
+		return summary
+	}
+	for _, pod := range monitoredPods(podStore.List()) {
+		summary.Phases[string(pod.Phase())]++
+		health, _ := podHealth(pod)
+		summary.Health[health]++
+		summary.Pods++
+	}
+	return summary
+}
+
+// rememberTransition keeps the transition for dashboards opened later
+func rememberTransition(u podstream.Update) {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	recentTransitions = append(recentTransitions, u)
+	if len(recentTransitions) > dashboardRecent {
+		recentTransitions = recentTransitions[len(recentTransitions)-dashboardRecent:]
+	}
+}
+
+// newDashboardTransition converts a pod status update for the dashboard
+func newDashboardTransition(u podstream.Update) dashboardTransition {
+	return dashboardTransition{
+		Namespace:     u.Namespace,
+		Pod:           u.Pod,
+		Node:          u.Node,
+		PreviousState: u.PreviousState,
+		State:         u.State,
+		ObservedAt:    u.ObservedAt,
+	}
+}
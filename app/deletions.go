@@ -0,0 +1,111 @@
+package app
+
+import (
+	"adv-go/history"
+	"adv-go/metrics"
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Reasons a pod was deleted for, derived from its final state
+const (
+	deletionEvicted          = "Evicted"
+	deletionPreempted        = "Preempted"
+	deletionScaledDown       = "ScaledDown"
+	deletionOOMKilled        = "OOMKilled"
+	deletionGarbageCollected = "GarbageCollected"
+	deletionCompleted        = "Completed"
+	deletionUnknown          = "Unknown"
+)
+
+// disruptionReasons maps the reasons of the DisruptionTarget condition to deletion reasons
+var disruptionReasons = map[string]string{
+	"EvictionByEvictionAPI":     deletionEvicted,
+	"TerminationByKubelet":      deletionEvicted,
+	"DeletionByTaintManager":    deletionEvicted,
+	"PreemptionByScheduler":     deletionPreempted,
+	"PreemptionByKubeScheduler": deletionPreempted,
+	"DeletionByPodGC":           deletionGarbageCollected,
+}
+
+// recordPodDeletion writes why a pod the watch saw deleted went away, to the sinks and the history
+func recordPodDeletion(clientset kubernetes.Interface, pod *v1.Pod) {
+	reason, detail := deletionReason(clientset, pod)
+	metrics.PodDeletions.WithLabelValues(pod.Namespace, reason).Inc()
+	writePodEvent(pod.Namespace, pod.Name, "Deleted", reason+": "+detail)
+	recordPodEvent(pod.Namespace, pod.Name, history.PodDeletion, reason+": "+detail)
+}
+
+// deletionReason classifies the deletion of a pod from its last known state. Evictions and preemptions leave a
+// DisruptionTarget condition or the status reason behind, a scale-down a SuccessfulDelete event on the owner
+func deletionReason(clientset kubernetes.Interface, pod *v1.Pod) (string, string) {
+	if c := podCondition(pod, v1.DisruptionTarget); c != nil && c.Status == v1.ConditionTrue {
+		if reason, ok := disruptionReasons[c.Reason]; ok {
+			return reason, describeDeletion(c.Reason, c.Message)
+		}
+	}
+	switch pod.Status.Reason {
+	case "Evicted", "Terminated", "Shutdown":
+		return deletionEvicted, describeDeletion(pod.Status.Reason, pod.Status.Message)
+	case "Preempting":
+		return deletionPreempted, describeDeletion(pod.Status.Reason, pod.Status.Message)
+	}
+	if ref := metav1.GetControllerOfNoCopy(pod); ref != nil && deletedByOwner(clientset, pod, ref) {
+		return deletionScaledDown, fmt.Sprintf("deleted by %s/%s", ref.Kind, ref.Name)
+	}
+	if container, t := oomKilledContainer(pod); t != nil {
+		return deletionOOMKilled, fmt.Sprintf("container %s was last killed for exceeding its memory limit (exit code %d)", container, t.ExitCode)
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return deletionCompleted, "deleted after it " + strings.ToLower(string(pod.Status.Phase))
+	}
+	return deletionUnknown, "no eviction, preemption or owner scale-down found, deleted through the API, e.g. by kubectl delete"
+}
+
+// describeDeletion joins a reason and its message, the message is often empty
+func describeDeletion(reason, message string) string {
+	if message == "" {
+		return reason
+	}
+	return reason + ", " + message
+}
+
+// deletedByOwner tells whether the pod's controller recorded deleting it, which the ReplicaSet, StatefulSet and
+// DaemonSet controllers do with a SuccessfulDelete event naming the pod
+func deletedByOwner(clientset kubernetes.Interface, pod *v1.Pod, ref *metav1.OwnerReference) bool {
+	events, err := retryCall(context.Background(), "list owner events", func(ctx context.Context) (*v1.EventList, error) {
+		return clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: "involvedObject.kind=" + ref.Kind + ",involvedObject.name=" + ref.Name + ",reason=SuccessfulDelete",
+		})
+	})
+	if err != nil {
+		watchLog.Error("Error listing owner events", append(podFields(pod), "err", err)...)
+		return false
+	}
+	for _, ev := range events.Items {
+		// "Deleted pod: web-1" from a ReplicaSet, "delete Pod web-1 in StatefulSet web successful" from a StatefulSet
+		for _, word := range strings.Fields(ev.Message) {
+			if word == pod.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oomKilledContainer returns the first container whose current or last termination was an OOM kill
+func oomKilledContainer(pod *v1.Pod) (string, *v1.ContainerStateTerminated) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		for _, t := range []*v1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+			if t != nil && t.Reason == "OOMKilled" {
+				return cs.Name, t
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,428 @@
+//go:build !minimal && !nodemo
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubeversion "k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// demoNodes are the nodes of the synthetic cluster, one per zone
+var demoNodes = []string{"ip-10-0-1-11", "ip-10-0-2-12", "ip-10-0-3-13"}
+
+// demoStep is one failure scenario played against the synthetic cluster
+type demoStep struct {
+	description string
+	run         func(ctx context.Context, client kubernetes.Interface) error
+}
+
+func init() {
+	optionalCommands = append(optionalCommands, newDemoCommand)
+}
+
+// newDemoCommand creates the demo command
+func newDemoCommand() *cobra.Command {
+	var step time.Duration
+	cmd := &cobra.Command{
+		Use:   "demo",
+		Short: "Run the agent against a synthetic cluster that plays failure scenarios",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDemo(step)
+		},
+	}
+	cmd.Flags().DurationVar(&step, "step", 20*time.Second, "time between failure scenarios")
+	return cmd
+}
+
+// runDemo runs the agent against a fake clientset holding a synthetic cluster and plays failure scenarios on it
+func runDemo(step time.Duration) {
+	if cfg.WithMetrics {
+		fmt.Fprintln(os.Stderr, "--with-metrics is not supported by the demo cluster, ignoring it")
+		cfg.WithMetrics = false
+	}
+
+	client := fake.NewSimpleClientset(demoObjects()...)
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &kubeversion.Info{GitVersion: "v1.30.2-demo", Platform: "linux/amd64"}
+	clientset = client
+	cluster = detectCluster(client)
+	agentLog.Info("Demo cluster ready, pods and nodes are synthetic", "nodes", len(demoNodes), "step", step.String(), "api", cfg.MetricsAddr)
+
+	go playDemo(context.Background(), client, step)
+	runAgent(client, false)
+}
+
+// playDemo runs the scenarios one step apart
+func playDemo(ctx context.Context, client kubernetes.Interface, step time.Duration) {
+	for _, s := range demoSteps() {
+		time.Sleep(step)
+		agentLog.Info("Demo: " + s.description)
+		if err := s.run(ctx, client); err != nil {
+			agentLog.Error("Demo step failed", "step", s.description, "err", err)
+		}
+	}
+	agentLog.Info("Demo: all scenarios played, the cluster stays in its final state")
+}
+
+// demoSteps are the failure scenarios in the order they are played
+func demoSteps() []demoStep {
+	return []demoStep{
+		{"payments/api-5f7c9-x2k4q is OOMKilled", func(ctx context.Context, client kubernetes.Interface) error {
+			return updateDemoPod(ctx, client, "payments", "api-5f7c9-x2k4q", func(pod *v1.Pod) {
+				cs := &pod.Status.ContainerStatuses[0]
+				cs.RestartCount++
+				cs.LastTerminationState = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					Reason: "OOMKilled", ExitCode: 137, FinishedAt: metav1.Now(),
+				}}
+			})
+		}},
+		{"shop/checkout-7b6d4-9wz8m keeps crashing", func(ctx context.Context, client kubernetes.Interface) error {
+			if err := updateDemoPod(ctx, client, "shop", "checkout-7b6d4-9wz8m", func(pod *v1.Pod) {
+				pod.Status.ContainerStatuses[0].RestartCount += 4
+			}); err != nil {
+				return err
+			}
+			return createDemoEvent(ctx, client, "shop", "checkout-7b6d4-9wz8m", "BackOff", "Back-off restarting failed container checkout")
+		}},
+		{"karpenter disrupts node ip-10-0-3-13 and shop/cart-6c8f5-q7r2d moves", func(ctx context.Context, client kubernetes.Interface) error {
+			ev := &v1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: "ip-10-0-3-13.disrupting", Namespace: metav1.NamespaceDefault, UID: "demo-event-disrupting"},
+				InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "ip-10-0-3-13"},
+				Type:           v1.EventTypeNormal,
+				Reason:         "DisruptionTerminating",
+				Message:        "Disrupting Node: Underutilized",
+				Source:         v1.EventSource{Component: "karpenter"},
+				FirstTimestamp: metav1.Now(),
+				LastTimestamp:  metav1.Now(),
+				Count:          1,
+			}
+			if _, err := client.CoreV1().Events(ev.Namespace).Create(ctx, ev, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			if err := client.CoreV1().Pods("shop").Delete(ctx, "cart-6c8f5-q7r2d", metav1.DeleteOptions{}); err != nil {
+				return err
+			}
+			successor := demoPod("shop", "cart-6c8f5-m3n8t", "ip-10-0-1-11", "cart-6c8f5", "cart", v1.PodRunning, true)
+			successor.CreationTimestamp = metav1.Now()
+			_, err := client.CoreV1().Pods("shop").Create(ctx, successor, metav1.CreateOptions{})
+			return err
+		}},
+		{"node ip-10-0-3-13 stops reporting Ready", func(ctx context.Context, client kubernetes.Interface) error {
+			return setDemoNodeReady(ctx, client, "ip-10-0-3-13", v1.ConditionUnknown, "NodeStatusUnknown")
+		}},
+		{"batch/report-28391 fails", func(ctx context.Context, client kubernetes.Interface) error {
+			return updateDemoPod(ctx, client, "batch", "report-28391", func(pod *v1.Pod) {
+				pod.Status.Phase = v1.PodFailed
+				pod.Status.ContainerStatuses[0].State = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					Reason: "Error", ExitCode: 2, FinishedAt: metav1.Now(),
+				}}
+				pod.Status.ContainerStatuses[0].Ready = false
+			})
+		}},
+		{"node ip-10-0-3-13 recovers", func(ctx context.Context, client kubernetes.Interface) error {
+			return setDemoNodeReady(ctx, client, "ip-10-0-3-13", v1.ConditionTrue, "KubeletReady")
+		}},
+		{"shop/cart starts rolling out 2.5.0", func(ctx context.Context, client kubernetes.Interface) error {
+			pod := demoPod("shop", "cart-9d2e1-w4x7z", "ip-10-0-3-13", "cart-9d2e1", "cart", v1.PodPending, false)
+			pod.CreationTimestamp = metav1.Now()
+			pod.Spec.Containers[0].Image = "registry.example.com/shop/cart:2.5.0"
+			pod.Status.ContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}
+			_, err := client.CoreV1().Pods("shop").Create(ctx, pod, metav1.CreateOptions{})
+			return err
+		}},
+	}
+}
+
+// demoObjects builds the synthetic cluster: three nodes, a few deployments with healthy and failing pods, and their events
+func demoObjects() []runtime.Object {
+	objects := []runtime.Object{
+		demoNamespace("shop", "storefront"),
+		demoNamespace("payments", "payments"),
+		demoNamespace("batch", "data"),
+	}
+	for i, name := range demoNodes {
+		objects = append(objects, demoNode(name, fmt.Sprintf("us-east-1%c", 'a'+i)))
+	}
+
+	objects = append(objects, demoDeployment("shop", "cart", "cart-6c8f5", 3, 3)...)
+	objects = append(objects,
+		demoPod("shop", "cart-6c8f5-h4j9k", "ip-10-0-1-11", "cart-6c8f5", "cart", v1.PodRunning, true),
+		demoPod("shop", "cart-6c8f5-p2l6v", "ip-10-0-2-12", "cart-6c8f5", "cart", v1.PodRunning, true),
+		demoPod("shop", "cart-6c8f5-q7r2d", "ip-10-0-3-13", "cart-6c8f5", "cart", v1.PodRunning, true),
+	)
+
+	objects = append(objects, demoDeployment("shop", "checkout", "checkout-7b6d4", 2, 1)...)
+	crashing := demoPod("shop", "checkout-7b6d4-9wz8m", "ip-10-0-2-12", "checkout-7b6d4", "checkout", v1.PodRunning, false)
+	crashing.Status.ContainerStatuses[0].RestartCount = 7
+	crashing.Status.ContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{
+		Reason: "CrashLoopBackOff", Message: "back-off 5m0s restarting failed container=checkout",
+	}}
+	crashing.Status.ContainerStatuses[0].LastTerminationState = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+		Reason: "Error", ExitCode: 1, FinishedAt: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+	}}
+	objects = append(objects,
+		demoPod("shop", "checkout-7b6d4-b5c1f", "ip-10-0-1-11", "checkout-7b6d4", "checkout", v1.PodRunning, true),
+		crashing,
+	)
+
+	objects = append(objects, demoDeployment("shop", "frontend", "frontend-84d2a", 1, 0)...)
+	pulling := demoPod("shop", "frontend-84d2a-t6y3u", "ip-10-0-3-13", "frontend-84d2a", "frontend", v1.PodPending, false)
+	pulling.Status.ContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{
+		Reason: "ImagePullBackOff", Message: `Back-off pulling image "registry.example.com/shop/frontend:2.4.1"`,
+	}}
+	objects = append(objects, pulling)
+
+	objects = append(objects, demoDeployment("payments", "api", "api-5f7c9", 2, 2)...)
+	objects = append(objects,
+		demoPod("payments", "api-5f7c9-x2k4q", "ip-10-0-1-11", "api-5f7c9", "api", v1.PodRunning, true),
+		demoPod("payments", "api-5f7c9-r8e1w", "ip-10-0-2-12", "api-5f7c9", "api", v1.PodRunning, true),
+	)
+
+	objects = append(objects, demoDeployment("payments", "worker", "worker-9a3e7", 1, 0)...)
+	pending := demoPod("payments", "worker-9a3e7-k2m5n", "", "worker-9a3e7", "worker", v1.PodPending, false)
+	pending.Status.ContainerStatuses = nil
+	pending.Status.Conditions = []v1.PodCondition{{
+		Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: "Unschedulable",
+		Message: "0/3 nodes are available: 3 Insufficient memory.", LastTransitionTime: metav1.Now(),
+	}}
+	objects = append(objects, pending)
+
+	job := demoPod("batch", "report-28391", "ip-10-0-2-12", "", "report", v1.PodRunning, true)
+	demoMountClaim(job, "report-data")
+	archive := demoPod("batch", "archive-0", "ip-10-0-1-11", "", "archive", v1.PodPending, false)
+	archive.Status.ContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}
+	demoMountClaim(archive, "archive-data")
+	objects = append(objects, job, archive)
+	objects = append(objects, demoClaims()...)
+
+	objects = append(objects,
+		demoEvent("payments", "worker-9a3e7-k2m5n", "FailedScheduling", "0/3 nodes are available: 3 Insufficient memory."),
+		demoEvent("shop", "frontend-84d2a-t6y3u", "Failed", `Failed to pull image "registry.example.com/shop/frontend:2.4.1": not found`),
+	)
+	return objects
+}
+
+// demoNamespace creates a namespace owned by the team
+func demoNamespace(name, team string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: name, UID: types.UID("demo-ns-" + name), Labels: map[string]string{"team": team},
+	}}
+}
+
+// demoNode creates a Ready linux node in the zone
+func demoNode(name, zone string) *v1.Node {
+	capacity := v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("16Gi"), v1.ResourcePods: resource.MustParse("58")}
+	allocatable := v1.ResourceList{v1.ResourceCPU: resource.MustParse("3920m"), v1.ResourceMemory: resource.MustParse("15Gi"), v1.ResourcePods: resource.MustParse("58")}
+	now := metav1.Now()
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name, UID: types.UID("demo-node-" + name), CreationTimestamp: metav1.NewTime(now.Add(-72 * time.Hour)),
+			Labels: map[string]string{
+				v1.LabelOSStable:             "linux",
+				v1.LabelInstanceTypeStable:   "m5.xlarge",
+				v1.LabelTopologyZone:         zone,
+				"karpenter.sh/capacity-type": "on-demand",
+			},
+		},
+		Status: v1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: allocatable,
+			NodeInfo:    v1.NodeSystemInfo{OperatingSystem: "linux", KubeletVersion: "v1.31.1"},
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue, Reason: "KubeletReady", LastTransitionTime: now, LastHeartbeatTime: now},
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse, LastTransitionTime: now, LastHeartbeatTime: now},
+				{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse, LastTransitionTime: now, LastHeartbeatTime: now},
+				{Type: v1.NodePIDPressure, Status: v1.ConditionFalse, LastTransitionTime: now, LastHeartbeatTime: now},
+			},
+		},
+	}
+}
+
+// demoDeployment creates a deployment and the replica set it controls with the given replica counts
+func demoDeployment(namespace, name, replicaSet string, desired, ready int32) []runtime.Object {
+	controller := true
+	labels := map[string]string{"app": name}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID("demo-deploy-" + name), Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &desired,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas: desired, ReadyReplicas: ready, AvailableReplicas: ready, UpdatedReplicas: desired,
+		},
+	}
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: replicaSet, Namespace: namespace, UID: types.UID("demo-rs-" + replicaSet), Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "Deployment", Name: name, UID: deployment.UID, Controller: &controller,
+			}},
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &desired, Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		Status: appsv1.ReplicaSetStatus{Replicas: desired, ReadyReplicas: ready, AvailableReplicas: ready},
+	}
+	return []runtime.Object{deployment, rs}
+}
+
+// demoPod creates a pod with one container, owned by the replica set when one is given
+func demoPod(namespace, name, node, replicaSet, app string, phase v1.PodPhase, ready bool) *v1.Pod {
+	created := metav1.NewTime(time.Now().Add(-3 * time.Hour))
+	// The kubelet's latest status write, so the agent's watch lag starts out small
+	written := metav1.Now()
+	readyStatus := v1.ConditionFalse
+	if ready {
+		readyStatus = v1.ConditionTrue
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: namespace, UID: types.UID("demo-pod-" + name),
+			CreationTimestamp: created, Labels: map[string]string{"app": app},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, Time: &written}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Name:  app,
+				Image: "registry.example.com/" + namespace + "/" + app + ":2.4.1",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m"), v1.ResourceMemory: resource.MustParse("256Mi")},
+					Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m"), v1.ResourceMemory: resource.MustParse("512Mi")},
+				},
+			}},
+		},
+		Status: v1.PodStatus{
+			Phase:     phase,
+			StartTime: &created,
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodScheduled, Status: v1.ConditionTrue, LastTransitionTime: created},
+				{Type: v1.PodReady, Status: readyStatus, LastTransitionTime: created},
+			},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:  app,
+				Ready: ready,
+				Image: "registry.example.com/" + namespace + "/" + app + ":2.4.1",
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: created}},
+			}},
+		},
+	}
+	if replicaSet != "" {
+		// Deployment ReplicaSets are named <deployment>-<pod-template-hash>
+		pod.Labels["pod-template-hash"] = replicaSet[strings.LastIndex(replicaSet, "-")+1:]
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet, UID: types.UID("demo-rs-" + replicaSet), Controller: &controller,
+		}}
+	}
+	return pod
+}
+
+// demoMountClaim mounts the PersistentVolumeClaim as the pod's data volume
+func demoMountClaim(pod *v1.Pod, claim string) {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+		Name:         "data",
+		VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+	})
+}
+
+// demoClaims creates a claim bound to its volume and one left Pending on a storage class that does not exist
+func demoClaims() []runtime.Object {
+	storageClass, missingClass := "gp3", "gp3-encrypted"
+	requests := v1.ResourceList{v1.ResourceStorage: resource.MustParse("20Gi")}
+	bound := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "report-data", Namespace: "batch", UID: "demo-pvc-report-data"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass, VolumeName: "pvc-demo-report-data",
+			Resources: v1.VolumeResourceRequirements{Requests: requests},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound, Capacity: requests},
+	}
+	volume := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-demo-report-data", UID: "demo-pv-report-data"},
+		Spec: v1.PersistentVolumeSpec{
+			StorageClassName: storageClass, Capacity: requests,
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	}
+	pending := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "archive-data", Namespace: "batch", UID: "demo-pvc-archive-data"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &missingClass,
+			Resources:        v1.VolumeResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("100Gi")}},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+	return []runtime.Object{bound, volume, pending}
+}
+
+// demoEvent creates a Warning event about the pod
+func demoEvent(namespace, pod, reason, message string) *v1.Event {
+	now := metav1.Now()
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: fmt.Sprintf("%s.%s", pod, reason), Namespace: namespace, UID: types.UID("demo-event-" + pod + "-" + reason)},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod, UID: types.UID("demo-pod-" + pod)},
+		Type:           v1.EventTypeWarning,
+		Reason:         reason,
+		Message:        message,
+		Source:         v1.EventSource{Component: "kubelet"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
+
+// createDemoEvent records a new Warning event about the pod
+func createDemoEvent(ctx context.Context, client kubernetes.Interface, namespace, pod, reason, message string) error {
+	ev := demoEvent(namespace, pod, reason, message)
+	ev.Name += fmt.Sprintf(".%d", time.Now().Unix())
+	ev.UID += types.UID(fmt.Sprintf("-%d", time.Now().Unix()))
+	_, err := client.CoreV1().Events(namespace).Create(ctx, ev, metav1.CreateOptions{})
+	return err
+}
+
+// updateDemoPod applies the change to the pod
+func updateDemoPod(ctx context.Context, client kubernetes.Interface, namespace, name string, change func(*v1.Pod)) error {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	change(pod)
+	// Updates need a fresh timestamp for the watch lag to reflect the change
+	now := metav1.Now()
+	pod.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, Time: &now}}
+	_, err = client.CoreV1().Pods(namespace).Update(ctx, pod, metav1.UpdateOptions{})
+	return err
+}
+
+// setDemoNodeReady sets the node's Ready condition
+func setDemoNodeReady(ctx context.Context, client kubernetes.Interface, name string, status v1.ConditionStatus, reason string) error {
+	node, err := client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == v1.NodeReady {
+			node.Status.Conditions[i].Status = status
+			node.Status.Conditions[i].Reason = reason
+			node.Status.Conditions[i].LastTransitionTime = metav1.Now()
+		}
+	}
+	_, err = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// historyPrefix marks a diff argument as a point in time of the history store rather than a snapshot file
+const historyPrefix = "history:"
+
+// diffState is the phase and node of every pod at one point in time, by namespace/name
+type diffState struct {
+	source string
+	taken  time.Time
+	pods   map[string]diffPod
+}
+
+// diffPod is what a diff compares of a pod
+type diffPod struct {
+	phase string
+	node  string
+}
+
+// newDiffCommand creates the diff command
+func newDiffCommand() *cobra.Command {
+	var namespace string
+	cmd := &cobra.Command{
+		Use:   "diff <before> <after>",
+		Short: "Compare two snapshots and list the pods added, removed or in another phase, exiting 1 when they differ",
+		Long: "Each side is a JSON file written by snapshot --json, or history:<time> for the pods the history store " +
+			"knew at that time, where <time> is RFC 3339, a duration ago such as 2h, or now. The history only knows the " +
+			"pods the agent saw created or change phase or node.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exitCode(runDiff(args[0], args[1], namespace))
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only compare the pods of this namespace")
+	return cmd
+}
+
+// runDiff loads both sides and prints how the pods differ, 1 when they do
+func runDiff(before, after, namespace string) int {
+	var store *history.Store
+	if strings.HasPrefix(before, historyPrefix) || strings.HasPrefix(after, historyPrefix) {
+		if cfg.History.DSN == "" {
+			fmt.Fprintln(os.Stderr, "comparing with history:<time> requires --history-dsn")
+			return 2
+		}
+		var err error
+		if store, err = history.Open(cfg.History.DSN); err != nil {
+			fmt.Fprintf(os.Stderr, "error opening history store: %v\n", err)
+			return 2
+		}
+		defer store.Close()
+	}
+	var states [2]*diffState
+	for i, arg := range []string{before, after} {
+		state, err := loadDiffState(context.Background(), store, arg, namespace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading %s: %v\n", arg, err)
+			return 2
+		}
+		states[i] = state
+	}
+	a, b := states[0], states[1]
+	fmt.Printf("Comparing %s (%s) with %s (%s)\n", a.source, format.Time(a.taken), b.source, format.Time(b.taken))
+
+	keys := make(map[string]bool, len(a.pods)+len(b.pods))
+	for key := range a.pods {
+		keys[key] = true
+	}
+	for key := range b.pods {
+		keys[key] = true
+	}
+	added, removed, changed := 0, 0, 0
+	for _, key := range sortedKeys(keys) {
+		old, wasThere := a.pods[key]
+		pod, isThere := b.pods[key]
+		switch {
+		case !wasThere:
+			fmt.Printf("+ %s %s on %s\n", key, pod.phase, orNone(pod.node))
+			added++
+		case !isThere:
+			fmt.Printf("- %s %s on %s\n", key, old.phase, orNone(old.node))
+			removed++
+		case old.phase != pod.phase:
+			fmt.Printf("~ %s %s -> %s\n", key, old.phase, pod.phase)
+			changed++
+		}
+	}
+	fmt.Printf("%d added, %d removed, %d changed phase\n", added, removed, changed)
+	if added+removed+changed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadDiffState reads the pods of a snapshot file or of the history at a point in time
+func loadDiffState(ctx context.Context, store *history.Store, arg, namespace string) (*diffState, error) {
+	state := &diffState{source: arg, pods: make(map[string]diffPod)}
+	if at, ok := strings.CutPrefix(arg, historyPrefix); ok {
+		taken, err := parseHistoryTime(at)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", arg, err)
+		}
+		pods, err := store.PodsAt(ctx, taken)
+		if err != nil {
+			return nil, err
+		}
+		state.taken = taken
+		for _, t := range pods {
+			if namespace == "" || t.Namespace == namespace {
+				state.pods[t.Namespace+"/"+t.Pod] = diffPod{phase: t.Phase, node: t.Node}
+			}
+		}
+		return state, nil
+	}
+
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		return nil, err
+	}
+	var snap clusterSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("not a snapshot written by snapshot --json: %w", err)
+	}
+	state.taken = snap.Taken
+	for _, pod := range snap.Pods {
+		if namespace == "" || pod.Namespace == namespace {
+			state.pods[pod.Namespace+"/"+pod.Name] = diffPod{phase: pod.Phase, node: pod.Node}
+		}
+	}
+	return state, nil
+}
+
+// parseHistoryTime parses a point in the history, such as of a history:<time> argument: RFC 3339, a duration ago or
+// now
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "now" {
+		return time.Now(), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("expected an RFC 3339 time, a duration ago such as 2h, or now")
+}
+
+// orNone renders an empty node name as none
+func orNone(node string) string {
+	if node == "" {
+		return "none"
+	}
+	return node
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointPropagationTimeout is how long a Ready pod is waited for in EndpointSlices, pods not backing any Service
+// are forgotten after it
+const endpointPropagationTimeout = 5 * time.Minute
+
+// endpointProbeTimeout bounds how long a propagated endpoint is dialed before giving up on it
+const endpointProbeTimeout = 30 * time.Second
+
+// readyPod is a pod that became Ready while watching, waiting to appear in the EndpointSlices of its Services
+type readyPod struct {
+	readyAt time.Time
+	// services are the Services the pod was already measured for
+	services map[string]bool
+	probed   bool
+}
+
+// readyPods holds the pods that recently became Ready by UID
+var (
+	readyPods   = make(map[types.UID]*readyPod)
+	readyPodsMu sync.Mutex
+)
+
+// observePodReady starts the propagation clock of a pod that just turned Ready
+func observePodReady(old, pod *v1.Pod) {
+	if !isPodReady(pod) || isPodReady(old) {
+		return
+	}
+	now := time.Now()
+	readyPodsMu.Lock()
+	defer readyPodsMu.Unlock()
+	for uid, p := range readyPods {
+		if now.Sub(p.readyAt) > endpointPropagationTimeout {
+			delete(readyPods, uid)
+		}
+	}
+	// The informer's own observation time is used rather than the condition's, which only has second precision
+	readyPods[pod.UID] = &readyPod{readyAt: now, services: make(map[string]bool)}
+}
+
+// isPodReady reports whether the pod's Ready condition is True
+func isPodReady(pod *v1.Pod) bool {
+	c := podCondition(pod, v1.PodReady)
+	return c != nil && c.Status == v1.ConditionTrue
+}
+
+// watchEndpointSlices starts an EndpointSlice informer for a single namespace, or all namespaces when empty,
+// measuring how long pods that became Ready take to show up as ready endpoints
+func watchEndpointSlices(ctx context.Context, clientset kubernetes.Interface, namespace string) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace))
+	informer := factory.Discovery().V1().EndpointSlices().Informer()
+	addSyncCheck("endpointslices/"+namespace, informer.HasSynced)
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if slice, ok := obj.(*discoveryv1.EndpointSlice); ok && informer.HasSynced() && isWarm() && namespaceMonitored(slice.Namespace) {
+				observeEndpointSlice(ctx, slice)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if slice, ok := obj.(*discoveryv1.EndpointSlice); ok && isWarm() && namespaceMonitored(slice.Namespace) {
+				observeEndpointSlice(ctx, slice)
+			}
+		},
+	})
+
+	factory.Start(ctx.Done())
+	stopInformers(ctx, factory)
+}
+
+// observeEndpointSlice records the propagation delay of every recently Ready pod that is now a ready endpoint of
+// the slice's Service, and starts probing it when --endpoint-probe is set
+func observeEndpointSlice(ctx context.Context, slice *discoveryv1.EndpointSlice) {
+	service := slice.Namespace + "/" + slice.Labels[discoveryv1.LabelServiceName]
+	now := time.Now()
+
+	readyPodsMu.Lock()
+	defer readyPodsMu.Unlock()
+	for _, ep := range slice.Endpoints {
+		if ep.TargetRef == nil || ep.TargetRef.Kind != "Pod" || len(ep.Addresses) == 0 {
+			continue
+		}
+		// A nil Ready condition means ready, as in the EndpointSlice API
+		if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+			continue
+		}
+		p, ok := readyPods[ep.TargetRef.UID]
+		if !ok || p.services[service] {
+			continue
+		}
+		p.services[service] = true
+		delay := now.Sub(p.readyAt)
+		metrics.EndpointPropagation.WithLabelValues("endpointslice").Observe(delay.Seconds())
+		watchLog.Debug("Pod endpoint propagated", "namespace", slice.Namespace, "pod", ep.TargetRef.Name,
+			"service", service, "delay", format.Duration(delay))
+
+		if cfg.EndpointProbe && !p.probed {
+			if port, ok := endpointTCPPort(slice); ok {
+				p.probed = true
+				addr := net.JoinHostPort(ep.Addresses[0], strconv.Itoa(int(port)))
+				readyAt := p.readyAt
+				goLeader(func() { probeEndpoint(ctx, addr, readyAt) })
+			}
+		}
+	}
+}
+
+// endpointTCPPort returns the first TCP port of the slice
+func endpointTCPPort(slice *discoveryv1.EndpointSlice) (int32, bool) {
+	for _, p := range slice.Ports {
+		if p.Port != nil && (p.Protocol == nil || *p.Protocol == v1.ProtocolTCP) {
+			return *p.Port, true
+		}
+	}
+	return 0, false
+}
+
+// probeEndpoint dials the endpoint until it accepts a connection and records the delay since the pod became Ready
+func probeEndpoint(ctx context.Context, addr string, readyAt time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, endpointProbeTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	for {
+		dialCtx, dialCancel := context.WithTimeout(ctx, time.Second)
+		conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+		dialCancel()
+		if err == nil {
+			conn.Close()
+			metrics.EndpointPropagation.WithLabelValues("reachable").Observe(time.Since(readyAt).Seconds())
+			return
+		}
+		select {
+		case <-ctx.Done():
+			watchLog.Debug("Endpoint not reachable", "addr", addr, "timeout", endpointProbeTimeout.String(), "err", err)
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
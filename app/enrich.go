@@ -0,0 +1,81 @@
+package app
+
+import (
+	"adv-go/model"
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// enrichment holds the configured node and namespace metadata for one reporting pass
+type enrichment struct {
+	nodes      map[string]map[string]string
+	namespaces map[string]map[string]string
+}
+
+// loadEnrichment fetches the node and namespace metadata selected in the config, or returns nil when nothing is selected
+func loadEnrichment(ctx context.Context, clientset kubernetes.Interface, nodes nodeIndex) *enrichment {
+	e := cfg.Enrichment
+	if len(e.NodeLabels) == 0 && len(e.NamespaceLabels) == 0 && len(e.NamespaceAnnotations) == 0 {
+		return nil
+	}
+	enrich := &enrichment{
+		nodes:      make(map[string]map[string]string),
+		namespaces: make(map[string]map[string]string),
+	}
+
+	for name, node := range nodes {
+		enrich.nodes[name] = pick("node:", node.Labels(), e.NodeLabels, nil)
+	}
+
+	if len(e.NamespaceLabels) > 0 || len(e.NamespaceAnnotations) > 0 {
+		namespaces, err := retryCall(ctx, "list namespaces", func(ctx context.Context) (*v1.NamespaceList, error) {
+			return clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing namespaces for enrichment", "err", err)
+		} else {
+			for _, ns := range namespaces.Items {
+				fields := pick("namespace:", ns.Labels, e.NamespaceLabels, nil)
+				enrich.namespaces[ns.Name] = pick("namespace:", ns.Annotations, e.NamespaceAnnotations, fields)
+			}
+		}
+	}
+	return enrich
+}
+
+// fields returns the enrichment of a pod as key=value pairs in the order the keys are configured
+func (e *enrichment) fields(pod *model.Pod) []string {
+	if e == nil {
+		return nil
+	}
+	var fields []string
+	for _, key := range cfg.Enrichment.NodeLabels {
+		if v, ok := e.nodes[pod.NodeName()]["node:"+key]; ok {
+			fields = append(fields, "node:"+key+"="+v)
+		}
+	}
+	for _, keys := range [][]string{cfg.Enrichment.NamespaceLabels, cfg.Enrichment.NamespaceAnnotations} {
+		for _, key := range keys {
+			if v, ok := e.namespaces[pod.Namespace()]["namespace:"+key]; ok {
+				fields = append(fields, "namespace:"+key+"="+v)
+			}
+		}
+	}
+	return fields
+}
+
+// pick copies the selected keys present in values into into, prefixing them
+func pick(prefix string, values map[string]string, keys []string, into map[string]string) map[string]string {
+	if into == nil {
+		into = make(map[string]string, len(keys))
+	}
+	for _, key := range keys {
+		if v, ok := values[key]; ok {
+			into[prefix+key] = v
+		}
+	}
+	return into
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// seenEvents tracks the last logged series count per event UID so repeated occurrences are logged once
+var (
+	seenEvents   = make(map[string]int32)
+	seenEventsMu sync.Mutex
+)
+
+// logEventStatus retrieves cluster events and logs the ones not seen before
+func logEventStatus(ctx context.Context, clientset kubernetes.Interface) {
+	events, err := getAllEvents(ctx, clientset)
+	if err != nil {
+		eventsLog.Error("Error listing events", "err", err)
+		return
+	}
+
+	for _, ev := range dedupEvents(events) {
+		status := fmt.Sprintf("Event: %s/%s %s %s (x%d): %s",
+			ev.Namespace, ev.Regarding.Name, ev.Type, ev.Reason, ev.Count, ev.Note)
+		if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+			eventsLog.Error("Error writing to sink", "err", err)
+			continue
+		}
+		eventsLog.Info(status, "namespace", ev.Namespace, "kind", ev.Regarding.Kind, "name", ev.Regarding.Name, "reason", ev.Reason)
+	}
+}
+
+// watchPodEvents streams Warning events about pods in a namespace as they happen, interleaving them with the pod records
+func watchPodEvents(ctx context.Context, clientset kubernetes.Interface, namespace string) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = "involvedObject.kind=Pod,type=" + v1.EventTypeWarning
+		}),
+	)
+	eventInformer := factory.Core().V1().Events().Informer()
+	addSyncCheck("events/"+namespace, eventInformer.HasSynced)
+
+	logEvent := func(obj interface{}) {
+		e, ok := obj.(*v1.Event)
+		if !ok || !eventInformer.HasSynced() || !isWarm() {
+			// Events from the initial list are reported by the next reporting pass
+			return
+		}
+		if e.Type != v1.EventTypeWarning || e.InvolvedObject.Kind != "Pod" {
+			// Fake clientsets, as used by the demo, ignore the field selector
+			return
+		}
+		if ignoredNamespace(e.InvolvedObject.Namespace) || !namespaceMonitored(e.InvolvedObject.Namespace) {
+			return
+		}
+		ev := model.NewEventFromCoreV1(e)
+		if !markEventSeen(ev) {
+			return
+		}
+		writePodEvent(ev.Namespace, ev.Regarding.Name, ev.Reason, fmt.Sprintf("%s (x%d): %s", ev.Type, ev.Count, ev.Note))
+	}
+	eventInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    logEvent,
+		UpdateFunc: func(_, obj interface{}) { logEvent(obj) },
+	})
+
+	factory.Start(ctx.Done())
+	stopInformers(ctx, factory)
+}
+
+// observeOOMKills reports containers that were OOMKilled since the previous version of the pod, the kubelet emits no Event for them
+func observeOOMKills(old, pod *v1.Pod) {
+	previous := make(map[string]int32, len(old.Status.ContainerStatuses))
+	for _, cs := range old.Status.ContainerStatuses {
+		previous[cs.Name] = cs.RestartCount
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		t := cs.LastTerminationState.Terminated
+		if cs.RestartCount <= previous[cs.Name] || t == nil || t.Reason != "OOMKilled" {
+			continue
+		}
+		writePodEvent(pod.Namespace, pod.Name, "OOMKilled", fmt.Sprintf("container %s was killed for exceeding its memory limit (exit code %d)", cs.Name, t.ExitCode))
+	}
+}
+
+// writePodEvent writes a "Pod Event" record about a single pod
+func writePodEvent(namespace, pod, reason, detail string) {
+	status := fmt.Sprintf("Pod Event: %s/%s %s %s", namespace, pod, reason, detail)
+	if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+		eventsLog.Error("Error writing to sink", "err", err)
+	}
+	eventsLog.Info(status, "namespace", namespace, "pod", pod, "reason", reason)
+}
+
+// getAllEvents fetches events in the monitored namespaces, preferring events.k8s.io/v1 and falling back to core/v1
+func getAllEvents(ctx context.Context, clientset kubernetes.Interface) ([]*model.Event, error) {
+	useEventsV1 := cluster.EventsV1
+
+	var events []*model.Event
+	for _, namespace := range monitoredNamespaces() {
+		if useEventsV1 {
+			list, err := clientset.EventsV1().Events(namespace).List(ctx, metav1.ListOptions{})
+			if err == nil {
+				for i := range list.Items {
+					events = append(events, model.NewEventFromEventsV1(&list.Items[i]))
+				}
+				continue
+			}
+			eventsLog.Info("Falling back to core/v1 events", "err", err)
+			useEventsV1 = false
+		}
+
+		list, err := retryCall(ctx, "list events", func(ctx context.Context) (*v1.EventList, error) {
+			return clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			events = append(events, model.NewEventFromCoreV1(&list.Items[i]))
+		}
+	}
+	return events, nil
+}
+
+// markEventSeen remembers a streamed event so the reporting pass does not log it again, false when it was already logged
+func markEventSeen(ev *model.Event) bool {
+	seenEventsMu.Lock()
+	defer seenEventsMu.Unlock()
+	if count, ok := seenEvents[ev.UID]; ok && count >= ev.Count {
+		return false
+	}
+	seenEvents[ev.UID] = ev.Count
+	return true
+}
+
+// dedupEvents drops events whose series count has not changed since they were last logged
+func dedupEvents(events []*model.Event) []*model.Event {
+	seenEventsMu.Lock()
+	defer seenEventsMu.Unlock()
+
+	fresh := make([]*model.Event, 0, len(events))
+	current := make(map[string]bool, len(events))
+	for _, ev := range events {
+		current[ev.UID] = true
+		if count, ok := seenEvents[ev.UID]; ok && count >= ev.Count {
+			continue
+		}
+		seenEvents[ev.UID] = ev.Count
+		fresh = append(fresh, ev)
+	}
+
+	// Forget events that have expired from the apiserver
+	for uid := range seenEvents {
+		if !current[uid] {
+			delete(seenEvents, uid)
+		}
+	}
+	return fresh
+}
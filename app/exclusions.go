@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ignoredLabels are the selectors of --ignore-labels, a pod matching any of them is ignored
+var ignoredLabels []labels.Selector
+
+// setExclusions parses the label selectors of the ignored pods
+func setExclusions(selectors []string) error {
+	parsed := make([]labels.Selector, 0, len(selectors))
+	for _, s := range selectors {
+		selector, err := labels.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid ignored label selector %q: %w", s, err)
+		}
+		parsed = append(parsed, selector)
+	}
+	ignoredLabels = parsed
+	return nil
+}
+
+// ignoredNamespace reports whether the pods of the namespace are ignored
+func ignoredNamespace(namespace string) bool {
+	return slices.Contains(cfg.IgnoreNamespaces, namespace)
+}
+
+// ignoredPod reports whether the pod is in an ignored namespace or matches an ignored label selector
+func ignoredPod(pod *v1.Pod) bool {
+	if ignoredNamespace(pod.Namespace) {
+		return true
+	}
+	for _, selector := range ignoredLabels {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// keptPods filters out the ignored pods
+func keptPods(pods []v1.Pod) []v1.Pod {
+	if len(cfg.IgnoreNamespaces) == 0 && len(ignoredLabels) == 0 {
+		return pods
+	}
+	return slices.DeleteFunc(pods, func(pod v1.Pod) bool { return ignoredPod(&pod) })
+}
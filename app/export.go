@@ -0,0 +1,165 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// historyRow is a recorded pod status transition in an export
+type historyRow struct {
+	ObservedAt time.Time `parquet:"observed_at,timestamp(millisecond)"`
+	Namespace  string    `parquet:"namespace"`
+	Pod        string    `parquet:"pod"`
+	Node       string    `parquet:"node"`
+	Phase      string    `parquet:"phase"`
+}
+
+// snapshotRow is a pod of the current snapshot in an export
+type snapshotRow struct {
+	TakenAt    time.Time `parquet:"taken_at,timestamp(millisecond)"`
+	Namespace  string    `parquet:"namespace"`
+	Pod        string    `parquet:"pod"`
+	Node       string    `parquet:"node"`
+	Phase      string    `parquet:"phase"`
+	Ready      int32     `parquet:"ready"`
+	Containers int32     `parquet:"containers"`
+	Restarts   int32     `parquet:"restarts"`
+	Waiting    string    `parquet:"waiting"`
+	// ClusterVersion is the apiserver version the snapshot was taken from
+	ClusterVersion string `parquet:"cluster_version"`
+}
+
+// exportOptions select what is exported and where to
+type exportOptions struct {
+	source, format, outPath string
+	since                   time.Duration
+}
+
+// newExportCommand creates the export command
+func newExportCommand() *cobra.Command {
+	var opts exportOptions
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump the recorded status history, or the current pods, to CSV or Parquet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.format == "" {
+				opts.format = "csv"
+				if strings.HasSuffix(opts.outPath, ".parquet") {
+					opts.format = "parquet"
+				}
+			}
+			if opts.format != "csv" && opts.format != "parquet" {
+				return fmt.Errorf("unknown export format %q, expected csv or parquet", opts.format)
+			}
+			if opts.source != "history" && opts.source != "snapshot" {
+				return fmt.Errorf("unknown export source %q, expected history or snapshot", opts.source)
+			}
+			return exitCode(runExport(opts))
+		},
+	}
+	cmd.Flags().StringVar(&opts.source, "source", "history", "what to export: history (needs --history-dsn) or snapshot (lists the cluster)")
+	cmd.Flags().StringVar(&opts.format, "format", "", "csv or parquet, by default taken from the --out extension")
+	cmd.Flags().StringVar(&opts.outPath, "out", "", "file to write")
+	cmd.Flags().DurationVar(&opts.since, "since", 24*time.Hour, "export history transitions observed within this duration")
+	cmd.MarkFlagRequired("out")
+	return cmd
+}
+
+// runExport dumps the recorded status history, or the current pods, to a CSV or Parquet file
+func runExport(opts exportOptions) int {
+	var err error
+	if opts.source == "history" {
+		err = exportHistory(opts.outPath, opts.format, time.Now().Add(-opts.since))
+	} else {
+		err = exportSnapshot(opts.outPath, opts.format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error exporting %s: %v\n", opts.source, err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\n", opts.outPath)
+	return 0
+}
+
+// exportHistory writes every transition recorded since the given time
+func exportHistory(path, exportFormat string, since time.Time) error {
+	if cfg.History.DSN == "" {
+		return fmt.Errorf("exporting history requires --history-dsn")
+	}
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	transitions, err := store.Transitions(context.Background(), since)
+	if err != nil {
+		return err
+	}
+	rows := make([]historyRow, len(transitions))
+	for i, t := range transitions {
+		rows[i] = historyRow{ObservedAt: t.At.UTC(), Namespace: t.Namespace, Pod: t.Pod, Node: t.Node, Phase: t.Phase}
+	}
+	if exportFormat == "parquet" {
+		return writeParquet(path, rows)
+	}
+
+	records := [][]string{{"observed_at", "namespace", "pod", "node", "phase"}}
+	for _, r := range rows {
+		records = append(records, []string{r.ObservedAt.In(format.Location()).Format(time.RFC3339Nano), r.Namespace, r.Pod, r.Node, r.Phase})
+	}
+	return writeCSVFile(path, records)
+}
+
+// exportSnapshot lists the cluster and writes one row per pod
+func exportSnapshot(path, exportFormat string) error {
+	if err := connect(); err != nil {
+		return err
+	}
+	snap, err := collectSnapshot(context.Background(), clientset)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]snapshotRow, len(snap.Pods))
+	for i, p := range snap.Pods {
+		rows[i] = snapshotRow{
+			TakenAt: snap.Taken, Namespace: p.Namespace, Pod: p.Name, Node: p.Node, Phase: p.Phase,
+			Ready: int32(p.Ready), Containers: int32(p.Containers), Restarts: p.Restarts, Waiting: strings.Join(p.Waiting, ";"), ClusterVersion: snap.ClusterVersion,
+		}
+	}
+	if exportFormat == "parquet" {
+		return writeParquet(path, rows)
+	}
+
+	records := [][]string{{"taken_at", "namespace", "pod", "node", "phase", "ready", "containers", "restarts", "waiting", "cluster_version"}}
+	for _, r := range rows {
+		records = append(records, []string{r.TakenAt.In(format.Location()).Format(time.RFC3339Nano), r.Namespace, r.Pod, r.Node, r.Phase,
+			strconv.Itoa(int(r.Ready)), strconv.Itoa(int(r.Containers)), strconv.Itoa(int(r.Restarts)), r.Waiting, r.ClusterVersion})
+	}
+	return writeCSVFile(path, records)
+}
+
+// writeCSVFile writes the records, header first, to the file
+func writeCSVFile(path string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.WriteAll(records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,10 @@
+//go:build minimal || noparquet
+
+package app
+
+import "errors"
+
+// writeParquet fails, the binary was built without Parquet support
+func writeParquet[T any](path string, rows []T) error {
+	return errors.New("parquet is not built into this binary, rebuild without -tags noparquet or minimal, or export --format=csv")
+}
@@ -0,0 +1,10 @@
+//go:build !minimal && !noparquet
+
+package app
+
+import "github.com/parquet-go/parquet-go"
+
+// writeParquet writes the rows to a Parquet file, the columns taken from their parquet tags
+func writeParquet[T any](path string, rows []T) error {
+	return parquet.WriteFile(path, rows)
+}
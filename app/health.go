@@ -0,0 +1,169 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// syncGrace is how long a leader's informers may take to sync before the agent is considered unhealthy
+const syncGrace = 2 * time.Minute
+
+// leadership and informer sync state reported by the probes
+var (
+	healthMu     sync.Mutex
+	leadingSince time.Time
+	syncChecks   = make(map[string]cache.InformerSynced)
+	// cacheChecks are the informers of the read cache, which run on every replica for the life of the process
+	cacheChecks = make(map[string]cache.InformerSynced)
+)
+
+// healthCheck is the outcome of a single probe check
+type healthCheck struct {
+	name string
+	ok   bool
+	info string
+}
+
+// registerHealth adds the liveness and readiness probes to the metrics server
+func registerHealth(clientset kubernetes.Interface) {
+	metrics.Handle("GET /healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks := []healthCheck{leadershipCheck(), syncCheck(true)}
+		if cfg.Lease.StallTimeout.Duration > 0 {
+			checks = append(checks, stallCheck())
+		}
+		writeHealth(w, checks)
+	}))
+	metrics.Handle("GET /readyz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks := []healthCheck{apiserverCheck(r.Context(), clientset), leadershipCheck(), syncCheck(false)}
+		writeHealth(w, checks)
+	}))
+}
+
+// startLeading marks this replica as running the watches and reporting loop
+func startLeading() {
+	resetJobTracking()
+	resetDaemonSetCoverage()
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	leadingSince = time.Now()
+}
+
+// stopLeading marks this replica as standby and forgets the caches of the stopped watches, the read cache stays
+func stopLeading() {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	leadingSince = time.Time{}
+	syncChecks = make(map[string]cache.InformerSynced)
+}
+
+// isLeading reports whether this replica runs the watches and reporting loop
+func isLeading() bool {
+	if elector != nil {
+		return elector.IsLeader()
+	}
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	return !leadingSince.IsZero()
+}
+
+// addSyncCheck reports the informer's sync state in the probes
+func addSyncCheck(name string, synced cache.InformerSynced) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	syncChecks[name] = synced
+}
+
+// addCacheCheck reports the sync state of an informer of the read cache in the probes
+func addCacheCheck(name string, synced cache.InformerSynced) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	cacheChecks["cache/"+name] = synced
+}
+
+// apiserverCheck verifies the apiserver answers its health endpoint
+func apiserverCheck(ctx context.Context, clientset kubernetes.Interface) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	client := clientset.Discovery().RESTClient()
+	if client == nil {
+		// Fake clientsets, as used by the demo, have no apiserver to probe
+		return healthCheck{name: "apiserver", ok: true}
+	}
+	if err := client.Get().AbsPath("/healthz").Do(ctx).Error(); err != nil {
+		return healthCheck{name: "apiserver", info: err.Error()}
+	}
+	return healthCheck{name: "apiserver", ok: true}
+}
+
+// leadershipCheck reports whether this replica is leading, standby replicas are healthy too
+func leadershipCheck() healthCheck {
+	identity := "local"
+	if elector != nil {
+		identity = elector.Identity()
+	}
+	if !isLeading() {
+		return healthCheck{name: "leader", ok: true, info: identity + " standby"}
+	}
+	if shard, ok := heldShardIndex(); ok {
+		identity += fmt.Sprintf(" shard %d/%d", shard, cfg.Lease.Shards)
+	}
+	if members, ok := podMembers(); ok {
+		identity += fmt.Sprintf(" one of %d replicas", len(members.Members()))
+	}
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	return healthCheck{name: "leader", ok: true, info: identity + " leading since " + format.Time(leadingSince)}
+}
+
+// syncCheck reports the informers that have not synced. For liveness they only fail once the grace period since the
+// term or, on standby, the agent started is over
+func syncCheck(liveness bool) healthCheck {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	var pending []string
+	for _, checks := range []map[string]cache.InformerSynced{cacheChecks, syncChecks} {
+		for name, synced := range checks {
+			if !synced() {
+				pending = append(pending, name)
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return healthCheck{name: "informers", ok: true, info: fmt.Sprintf("%d synced", len(cacheChecks)+len(syncChecks))}
+	}
+	sort.Strings(pending)
+	since := leadingSince
+	if since.IsZero() {
+		since = agentStarted
+	}
+	withinGrace := liveness && time.Since(since) < syncGrace
+	return healthCheck{name: "informers", ok: withinGrace, info: "not synced: " + strings.Join(pending, ",")}
+}
+
+// writeHealth writes one line per check, answering 503 when any of them failed
+func writeHealth(w http.ResponseWriter, checks []healthCheck) {
+	var b strings.Builder
+	status := http.StatusOK
+	for _, c := range checks {
+		mark := "+"
+		if !c.ok {
+			mark = "-"
+			status = http.StatusServiceUnavailable
+		}
+		fmt.Fprintf(&b, "[%s]%s %s\n", mark, c.name, c.info)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, b.String())
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"sync/atomic"
+	"time"
+)
+
+// lastPodLag is the most recent pod watch lag in nanoseconds
+var lastPodLag atomic.Int64
+
+// runHeartbeat logs a one line summary of the agent's state every interval
+func runHeartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		agentLog.Info("Heartbeat", heartbeatFields()...)
+	}
+}
+
+// heartbeatFields summarizes the watched pods, how many are unhealthy, leadership and watch lag
+func heartbeatFields() []any {
+	if !isLeading() {
+		return []any{"leader", false, "cluster", cluster.Version}
+	}
+
+	pods := 0
+	unhealthy, failed := 0, 0
+	for _, pod := range monitoredPods(podStore.List()) {
+		// The store holds the pods of every replica when hashing pods
+		if !ownsPod(pod.Object()) {
+			continue
+		}
+		pods++
+		switch health, _ := podHealth(pod); health {
+		case model.HealthFailed:
+			failed++
+			unhealthy++
+		case model.HealthDegraded:
+			unhealthy++
+		}
+	}
+	lag := format.Duration(time.Duration(lastPodLag.Load()))
+	return []any{"leader", true, "cluster", cluster.Version, "pods", pods, "unhealthy", unhealthy, "failed", failed, "lag", lag}
+}
+
+// podHealthy reports whether the health rules classify the pod as Healthy
+func podHealthy(pod *model.Pod) bool {
+	health, _ := podHealth(pod)
+	return health == model.HealthHealthy
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"adv-go/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+)
+
+// historyStore records pod status transitions, nil when no history DSN is configured
+var historyStore *history.Store
+
+// historyWrite is a queued write to the history store
+type historyWrite struct {
+	what  string
+	write func(ctx context.Context) error
+}
+
+// historyWrites queues writes so slow database writes never block the informer
+var historyWrites = make(chan historyWrite, 1024)
+
+// startHistory opens the history store, starts writing queued records to it and serves the node timeline API
+func startHistory() {
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		historyLog.Fatal("Error opening history store", "err", err)
+	}
+	historyStore = store
+	metrics.Handle("GET /api/v1/nodes/{name}/timeline", http.HandlerFunc(serveNodeTimeline))
+	metrics.Handle("GET /api/v1/availability", http.HandlerFunc(serveAvailability))
+	metrics.Handle("GET /api/v1/alerts", http.HandlerFunc(serveAlerts))
+	metrics.Handle("GET /api/v1/rollouts/markers", http.HandlerFunc(serveRolloutMarkers))
+	metrics.Handle("GET /api/v1/history/rollups", http.HandlerFunc(serveRollups))
+
+	go func() {
+		for w := range historyWrites {
+			if err := w.write(context.Background()); err != nil {
+				historyLog.Error("Error recording history", "what", w.what, "err", err)
+			}
+		}
+	}()
+}
+
+// recordPodTransition queues a transition when the pod's phase or node changed, old is nil for new pods
+func recordPodTransition(old, pod *v1.Pod, phase string) {
+	if historyStore == nil {
+		return
+	}
+	if old != nil && old.Status.Phase == pod.Status.Phase && old.Spec.NodeName == pod.Spec.NodeName {
+		return
+	}
+	t := history.Transition{
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Node:      pod.Spec.NodeName,
+		Phase:     phase,
+		At:        time.Now(),
+	}
+	queueHistory("transition of pod "+t.Namespace+"/"+t.Pod, func(ctx context.Context) error {
+		return historyStore.Record(ctx, t)
+	})
+}
+
+// recordPodEvent queues a restart or alert for the pod's timeline
+func recordPodEvent(namespace, pod, kind, detail string) {
+	if historyStore == nil {
+		return
+	}
+	e := history.PodEvent{Namespace: namespace, Pod: pod, Kind: kind, Detail: detail, At: time.Now()}
+	queueHistory(kind+" of pod "+namespace+"/"+pod, func(ctx context.Context) error {
+		return historyStore.RecordPodEvent(ctx, e)
+	})
+}
+
+// recordPodRestarts queues a restart for every container whose restart count went up
+func recordPodRestarts(old, pod *v1.Pod) {
+	if historyStore == nil {
+		return
+	}
+	previous := make(map[string]int32, len(old.Status.ContainerStatuses))
+	for _, cs := range old.Status.ContainerStatuses {
+		previous[cs.Name] = cs.RestartCount
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.RestartCount <= previous[cs.Name] {
+			continue
+		}
+		detail := fmt.Sprintf("container %s restarted (%d)", cs.Name, cs.RestartCount)
+		if t := cs.LastTerminationState.Terminated; t != nil {
+			detail += fmt.Sprintf(" after %s, exit code %d", t.Reason, t.ExitCode)
+		}
+		recordPodEvent(pod.Namespace, pod.Name, history.PodRestart, detail)
+	}
+}
+
+// queueHistory hands the write to the history writer, dropping it when the queue is full
+func queueHistory(what string, write func(ctx context.Context) error) {
+	select {
+	case historyWrites <- historyWrite{what: what, write: write}:
+	default:
+		historyLog.Info("History queue full, dropping write", "what", what)
+	}
+}
+
+// newHistoryCommand creates the history command
+func newHistoryCommand() *cobra.Command {
+	var namespace string
+	var since time.Duration
+	cmd := &cobra.Command{
+		Use:   "history <pod>",
+		Short: "Print the recorded transitions of a pod and the pods it replaced",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.History.DSN == "" {
+				return errors.New("history requires --history-dsn")
+			}
+			return exitCode(runHistory(namespace, args[0], since))
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "namespace of the pod")
+	cmd.Flags().DurationVar(&since, "since", 24*time.Hour, "show transitions observed within this duration")
+	return cmd
+}
+
+// runHistory prints the recorded transitions of a pod and the pods it replaced
+func runHistory(namespace, pod string, since time.Duration) int {
+
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening history store: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	pods, err := podLineage(ctx, store, namespace, pod)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error querying history: %v\n", err)
+		return 1
+	}
+
+	// Follow the workload replica back through the pods it replaced
+	var list []history.Transition
+	for _, name := range pods {
+		transitions, err := store.PodHistory(ctx, namespace, name, time.Now().Add(-since))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error querying history: %v\n", err)
+			return 1
+		}
+		list = append(list, transitions...)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].At.Before(list[j].At) })
+	for _, t := range list {
+		fmt.Printf("%s %s/%s Phase: %s, Node: %s\n", format.Time(t.At), t.Namespace, t.Pod, t.Phase, t.Node)
+	}
+	return 0
+}
@@ -0,0 +1,255 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// pullFailureReasons are the waiting reasons of containers whose image cannot be pulled
+var pullFailureReasons = map[string]bool{
+	"ImagePullBackOff":  true,
+	"ErrImagePull":      true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// imageRef is a container image reference split into its parts
+type imageRef struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	// Tag is latest when the reference names neither a tag nor a digest
+	Tag    string `json:"tag,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// parseImageRef splits an image reference the way the container runtime resolves it: the first path component is
+// the registry when it looks like a host, otherwise the image is on Docker Hub
+func parseImageRef(image string) imageRef {
+	var ref imageRef
+	rest := image
+	if name, digest, ok := strings.Cut(rest, "@"); ok {
+		rest, ref.Digest = name, digest
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, ref.Tag = rest[:i], rest[i+1:]
+	}
+	if host, path, ok := strings.Cut(rest, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		ref.Registry, ref.Repository = host, path
+	} else {
+		ref.Registry, ref.Repository = "docker.io", rest
+		if !ok {
+			ref.Repository = "library/" + rest
+		}
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref
+}
+
+// imageUsage is where one image runs
+type imageUsage struct {
+	Image string `json:"image"`
+	imageRef
+	Pods       int      `json:"pods"`
+	Namespaces []string `json:"namespaces"`
+	Nodes      []string `json:"nodes"`
+}
+
+// pullFailure is a container whose image cannot be pulled
+type pullFailure struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Node      string `json:"node,omitempty"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	imageRef
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+}
+
+// imageReport is the image inventory of a set of pods
+type imageReport struct {
+	Images       []imageUsage  `json:"images"`
+	PullFailures []pullFailure `json:"pullFailures"`
+}
+
+// inventoryImages collects the images of the pods' containers, init and ephemeral containers included, and the
+// containers waiting on a failed pull
+func inventoryImages(pods []v1.Pod) imageReport {
+	type sets struct {
+		pods              int
+		namespaces, nodes map[string]bool
+	}
+	usage := make(map[string]*sets)
+	report := imageReport{Images: []imageUsage{}, PullFailures: []pullFailure{}}
+	for i := range pods {
+		pod := &pods[i]
+		images := make(map[string]bool)
+		for _, c := range pod.Spec.InitContainers {
+			images[c.Image] = true
+		}
+		for _, c := range pod.Spec.Containers {
+			images[c.Image] = true
+		}
+		for _, c := range pod.Spec.EphemeralContainers {
+			images[c.Image] = true
+		}
+		for image := range images {
+			u, ok := usage[image]
+			if !ok {
+				u = &sets{namespaces: make(map[string]bool), nodes: make(map[string]bool)}
+				usage[image] = u
+			}
+			u.pods++
+			u.namespaces[pod.Namespace] = true
+			if pod.Spec.NodeName != "" {
+				u.nodes[pod.Spec.NodeName] = true
+			}
+		}
+
+		report.PullFailures = append(report.PullFailures, podPullFailures(pod)...)
+	}
+
+	for image, u := range usage {
+		report.Images = append(report.Images, imageUsage{
+			Image: image, imageRef: parseImageRef(image), Pods: u.pods,
+			Namespaces: sortedKeys(u.namespaces), Nodes: sortedKeys(u.nodes),
+		})
+	}
+	sort.Slice(report.Images, func(i, j int) bool { return report.Images[i].Image < report.Images[j].Image })
+	sort.Slice(report.PullFailures, func(i, j int) bool {
+		a, b := report.PullFailures[i], report.PullFailures[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+	return report
+}
+
+// podPullFailures returns the containers of the pod, init containers included, waiting on a failed image pull
+func podPullFailures(pod *v1.Pod) []pullFailure {
+	var failures []pullFailure
+	statuses := append(append([]v1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if s.State.Waiting == nil || !pullFailureReasons[s.State.Waiting.Reason] {
+			continue
+		}
+		failures = append(failures, pullFailure{
+			Namespace: pod.Namespace, Pod: pod.Name, Node: pod.Spec.NodeName, Container: s.Name,
+			Image: s.Image, imageRef: parseImageRef(s.Image),
+			Reason: s.State.Waiting.Reason, Message: s.State.Waiting.Message,
+		})
+	}
+	return failures
+}
+
+// describePullFailures renders the pod's failed pulls for its record as container=image, e.g.
+// frontend=registry.example.com/shop/frontend:2.4.1
+func describePullFailures(pod *v1.Pod) []string {
+	var described []string
+	for _, f := range podPullFailures(pod) {
+		described = append(described, f.Container+"="+f.Image)
+	}
+	return described
+}
+
+// serveImages returns the image inventory of the cached pods, optionally of one ?namespace= or ?node=
+func serveImages(w http.ResponseWriter, r *http.Request) {
+	pods := cachedPods()
+	if pods == nil {
+		http.Error(w, "pod cache not synced yet", http.StatusServiceUnavailable)
+		return
+	}
+	namespace, node := r.URL.Query().Get("namespace"), r.URL.Query().Get("node")
+	kept := pods[:0]
+	for _, pod := range pods {
+		if (namespace == "" || pod.Namespace == namespace) && (node == "" || pod.Spec.NodeName == node) {
+			kept = append(kept, pod)
+		}
+	}
+	writeJSON(w, inventoryImages(kept))
+}
+
+// newImagesCommand creates the images command
+func newImagesCommand() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "List the images running in the monitored namespaces and the containers failing to pull theirs",
+		Long: `List the images running in the monitored namespaces and the containers failing to pull theirs.
+
+Each image is listed with its registry, the number of pods running it and the namespaces and nodes they are on,
+for instance to find what still pulls from a registry being migrated away from. Exits 1 when an image cannot be
+pulled.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runImages(clientset, asJSON))
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the inventory as JSON")
+	return cmd
+}
+
+// runImages lists the monitored pods once and prints their image inventory, returning 1 when a pull fails
+func runImages(clientset kubernetes.Interface, asJSON bool) int {
+	pods, err := getAllPods(context.Background(), clientset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing pods: %v\n", err)
+		return 1
+	}
+	report := inventoryImages(pods.Items)
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing the inventory: %v\n", err)
+			return 1
+		}
+	} else {
+		printImages(report)
+	}
+	if len(report.PullFailures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printImages prints the images, then the pull failures when there are any
+func printImages(report imageReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tREGISTRY\tPODS\tNAMESPACES\tNODES")
+	for _, u := range report.Images {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\n", u.Image, u.Registry, u.Pods, strings.Join(u.Namespaces, ","), len(u.Nodes))
+	}
+	if len(report.PullFailures) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "POD\tCONTAINER\tREGISTRY\tREPOSITORY\tTAG\tREASON\tMESSAGE")
+		for _, f := range report.PullFailures {
+			tag := f.Tag
+			if f.Digest != "" {
+				tag = f.Digest
+			}
+			fmt.Fprintf(w, "%s/%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.Namespace, f.Pod, f.Container, f.Registry, f.Repository, tag,
+				f.Reason, f.Message)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/incident"
+	"adv-go/signing"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// incidentLogLines is how many of the last log lines of each container go into a bundle
+const incidentLogLines int64 = 200
+
+// incidentHTTP uploads incident bundles
+var incidentHTTP = &http.Client{Timeout: time.Minute}
+
+// bundleSigner signs incident bundles, nil when no signing key is configured
+var bundleSigner *signing.Signer
+
+// incidentsEnabled reports whether bundles are saved or uploaded
+func incidentsEnabled() bool {
+	return cfg.Incidents.Dir != "" || cfg.Incidents.UploadURL != ""
+}
+
+// captureIncident assembles an incident bundle for the pod and saves or uploads it, returning where it went
+func captureIncident(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod, reason string) string {
+	bundle := collectIncident(ctx, clientset, pod, reason)
+
+	var locations []string
+	if cfg.Incidents.Dir != "" {
+		path, err := bundle.Save(cfg.Incidents.Dir, bundleSigner)
+		if err != nil {
+			alertsLog.Error("Error saving incident bundle", append(podFields(pod), "bundle", bundle.Name, "err", err)...)
+		} else {
+			locations = append(locations, path)
+		}
+	}
+	if cfg.Incidents.UploadURL != "" {
+		url, err := bundle.Upload(ctx, incidentHTTP, strings.TrimSuffix(cfg.Incidents.UploadURL, "/"), bundleSigner)
+		if err != nil {
+			alertsLog.Error("Error uploading incident bundle", append(podFields(pod), "bundle", bundle.Name, "err", err)...)
+		} else {
+			locations = append(locations, url)
+		}
+	}
+	if len(locations) > 0 {
+		alertsLog.Info("Captured incident bundle", append(podFields(pod), "reason", reason, "locations", strings.Join(locations, ", "))...)
+	}
+	return strings.Join(locations, ", ")
+}
+
+// collectIncident gathers the pod spec, recent events, last logs, node conditions and service endpoints of the pod.
+// Collection errors are recorded in the bundle instead of aborting it, a partial bundle is still useful
+func collectIncident(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod, reason string) *incident.Bundle {
+	name := fmt.Sprintf("%s-%s-%s", pod.Namespace, pod.Name, time.Now().UTC().Format("20060102T150405Z"))
+	bundle := incident.NewBundle(name)
+	var problems []string
+	failed := func(what string, err error) {
+		problems = append(problems, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	bundle.Add("summary.txt", []byte(fmt.Sprintf("Pod: %s/%s\nNode: %s\nReason: %s\nCaptured: %s\n",
+		pod.Namespace, pod.Name, pod.Spec.NodeName, reason, format.Time(time.Now()))))
+
+	if spec, err := yaml.Marshal(pod); err != nil {
+		failed("pod", err)
+	} else {
+		bundle.Add("pod.yaml", spec)
+	}
+
+	events, err := clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Pod,involvedObject.name=" + pod.Name,
+	})
+	if err != nil {
+		failed("events", err)
+	} else {
+		var b strings.Builder
+		for i := range events.Items {
+			e := &events.Items[i]
+			fmt.Fprintf(&b, "%s %s %s (x%d): %s\n",
+				format.Time(e.LastTimestamp.Time), e.Type, e.Reason, e.Count, e.Message)
+		}
+		bundle.Add("events.txt", []byte(b.String()))
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		addContainerLogs(ctx, clientset, bundle, pod, cs.Name, false, failed)
+		if cs.RestartCount > 0 {
+			addContainerLogs(ctx, clientset, bundle, pod, cs.Name, true, failed)
+		}
+	}
+
+	if pod.Spec.NodeName != "" {
+		node, err := clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+		if err != nil {
+			failed("node", err)
+		} else if err := bundle.AddJSON("node-conditions.json", node.Status.Conditions); err != nil {
+			failed("node", err)
+		}
+	}
+
+	if err := addServiceEndpoints(ctx, clientset, bundle, pod); err != nil {
+		failed("endpoints", err)
+	}
+
+	if len(problems) > 0 {
+		bundle.Add("errors.txt", []byte(strings.Join(problems, "\n")+"\n"))
+	}
+	return bundle
+}
+
+// addContainerLogs adds the last log lines of the current or previous container instance
+func addContainerLogs(ctx context.Context, clientset kubernetes.Interface, bundle *incident.Bundle, pod *v1.Pod,
+	container string, previous bool, failed func(string, error)) {
+	tail := incidentLogLines
+	logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+		Container: container,
+		TailLines: &tail,
+		Previous:  previous,
+	}).DoRaw(ctx)
+
+	name := "logs/" + container + ".log"
+	if previous {
+		name = "logs/" + container + ".previous.log"
+	}
+	if err != nil {
+		failed(name, err)
+		return
+	}
+	bundle.Add(name, logs)
+}
+
+// addServiceEndpoints adds the endpoint slices of the services selecting the pod
+func addServiceEndpoints(ctx context.Context, clientset kubernetes.Interface, bundle *incident.Bundle, pod *v1.Pod) error {
+	services, err := clientset.CoreV1().Services(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var slices []discoveryv1.EndpointSlice
+	if !cluster.EndpointSlices {
+		return errors.New("discovery.k8s.io/v1 endpoint slices are not served by this cluster")
+	}
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 || !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		list, err := clientset.DiscoveryV1().EndpointSlices(pod.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: discoveryv1.LabelServiceName + "=" + svc.Name,
+		})
+		if err != nil {
+			return err
+		}
+		slices = append(slices, list.Items...)
+	}
+	return bundle.AddJSON("endpoints.json", slices)
+}
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// inspectOptions configure the inspection of a workload
+type inspectOptions struct {
+	namespace string
+	duration  time.Duration
+	interval  time.Duration
+	tail      int64
+}
+
+// inspection is what an inspection already printed, so each pass prints only what is new
+type inspection struct {
+	name     string
+	selector labels.Selector
+	started  time.Time
+	// pods, probes and events hold the last printed line per pod, probe and event
+	pods   map[string]string
+	probes map[string]string
+	events map[string]int32
+	// logs holds the timestamp of the last log line printed per pod/container
+	logs map[string]time.Time
+}
+
+// newInspectCommand creates the inspect command
+func newInspectCommand() *cobra.Command {
+	opts := inspectOptions{}
+	cmd := &cobra.Command{
+		Use:   "inspect <kind>/<name>",
+		Short: "Follow one workload's pods in detail for a while: state, probes, usage, events and log tails",
+		Long: "Polls the workload's pods every --interval for --for, printing what changed: pod states, probe results, " +
+			"CPU and memory usage when metrics-server is available, new events and new log lines. Only this command " +
+			"collects at that rate, the agent's own collection and verbosity are left alone.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Usage is part of the detail, connect drops it again when metrics-server is not served
+			cfg.WithMetrics = true
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runInspect(clientset, args[0], opts))
+		},
+	}
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "default", "namespace of the workload")
+	cmd.Flags().DurationVar(&opts.duration, "for", 10*time.Minute, "how long to inspect the workload")
+	cmd.Flags().DurationVar(&opts.interval, "interval", 5*time.Second, "how often to collect")
+	cmd.Flags().Int64Var(&opts.tail, "tail", 20, "log lines of each container printed on the first pass")
+	return cmd
+}
+
+// runInspect inspects a deploy, sts or ds until the duration is over
+func runInspect(clientset kubernetes.Interface, target string, opts inspectOptions) int {
+	kind, name, ok := strings.Cut(target, "/")
+	if !ok || name == "" {
+		fmt.Fprintf(os.Stderr, "invalid workload %q, expected <kind>/<name>\n", target)
+		return 2
+	}
+	check, err := rolloutChecker(kind)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if opts.interval <= 0 {
+		fmt.Fprintln(os.Stderr, "--interval must be positive")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.duration)
+	defer cancel()
+	state, err := check(ctx, clientset, opts.namespace, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s: %v\n", target, err)
+		return 1
+	}
+	selector, err := metav1.LabelSelectorAsSelector(state.selector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid selector of %s: %v\n", target, err)
+		return 1
+	}
+
+	in := &inspection{
+		name: name, selector: selector, started: time.Now(),
+		pods: make(map[string]string), probes: make(map[string]string),
+		events: make(map[string]int32), logs: make(map[string]time.Time),
+	}
+	fmt.Printf("Inspecting %s in %s every %s for %s\n", target, opts.namespace, opts.interval, format.Age(opts.duration))
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		in.pass(ctx, clientset, opts)
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Inspection of %s ended after %s\n", target, format.Age(opts.duration))
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
+
+// pass collects the workload's pods once and prints what changed since the previous pass
+func (in *inspection) pass(ctx context.Context, clientset kubernetes.Interface, opts inspectOptions) {
+	list, err := clientset.CoreV1().Pods(opts.namespace).List(ctx, metav1.ListOptions{LabelSelector: in.selector.String()})
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Error listing pods: %v\n", err)
+		}
+		return
+	}
+	pods := list.Items
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	usage := in.usage(ctx, opts.namespace)
+	now := format.Time(time.Now())
+
+	seen := make(map[string]bool, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		seen[pod.Name] = true
+		line := fmt.Sprintf("%s on %s%s", podProgress(pod, nil), pod.Spec.NodeName, usage.describe(model.NewPod(pod)))
+		if in.pods[pod.Name] != line {
+			fmt.Printf("%s pod %s: %s\n", now, pod.Name, line)
+			in.pods[pod.Name] = line
+		}
+		in.printProbes(pod)
+		in.printLogs(ctx, clientset, pod, opts.tail)
+	}
+	for name := range in.pods {
+		if !seen[name] {
+			fmt.Printf("%s pod %s: deleted\n", now, name)
+			delete(in.pods, name)
+		}
+	}
+	in.printEvents(ctx, clientset, opts.namespace, seen)
+}
+
+// usage reads the current usage of the namespace's pods, nil without metrics-server
+func (in *inspection) usage(ctx context.Context, namespace string) podUsage {
+	if podMetricsClient == nil {
+		return nil
+	}
+	list, err := podMetricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{LabelSelector: in.selector.String()})
+	if err != nil {
+		return nil
+	}
+	usage := make(podUsage, len(list.Items))
+	for _, pm := range list.Items {
+		total := v1.ResourceList{}
+		for _, c := range pm.Containers {
+			addResources(total, c.Usage)
+		}
+		usage[pm.Namespace+"/"+pm.Name] = total
+	}
+	return usage
+}
+
+// printProbes prints the probes of every container with their latest result when it changed
+func (in *inspection) printProbes(pod *v1.Pod) {
+	statuses := make(map[string]v1.ContainerStatus, len(pod.Status.ContainerStatuses))
+	for _, cs := range pod.Status.ContainerStatuses {
+		statuses[cs.Name] = cs
+	}
+	for _, c := range pod.Spec.Containers {
+		cs := statuses[c.Name]
+		// The kubelet reports no liveness result, failures show as Unhealthy events and restarts
+		probes := []struct {
+			kind   string
+			probe  *v1.Probe
+			result string
+		}{
+			{"startup", c.StartupProbe, passing(cs.Started != nil && *cs.Started)},
+			{"liveness", c.LivenessProbe, fmt.Sprintf("restarts %d", cs.RestartCount)},
+			{"readiness", c.ReadinessProbe, passing(cs.Ready)},
+		}
+		for _, p := range probes {
+			if p.probe == nil {
+				continue
+			}
+			line := fmt.Sprintf("%s %s every %ds: %s", p.kind, describeProbe(p.probe), max(p.probe.PeriodSeconds, 10), p.result)
+			key := pod.Name + "/" + c.Name + "/" + p.kind
+			if in.probes[key] != line {
+				fmt.Printf("  probe %s/%s %s\n", pod.Name, c.Name, line)
+				in.probes[key] = line
+			}
+		}
+	}
+}
+
+// passing renders a probe result
+func passing(passed bool) string {
+	if passed {
+		return "passing"
+	}
+	return "failing"
+}
+
+// describeProbe renders what a probe checks, like "http-get :8080/healthz" or "exec cat /tmp/ready"
+func describeProbe(p *v1.Probe) string {
+	switch {
+	case p.HTTPGet != nil:
+		return fmt.Sprintf("http-get :%s%s", p.HTTPGet.Port.String(), p.HTTPGet.Path)
+	case p.TCPSocket != nil:
+		return "tcp :" + p.TCPSocket.Port.String()
+	case p.GRPC != nil:
+		return fmt.Sprintf("grpc :%d", p.GRPC.Port)
+	case p.Exec != nil:
+		return "exec " + strings.Join(p.Exec.Command, " ")
+	}
+	return "unknown"
+}
+
+// printEvents prints the events of the workload and its pods that are new or repeated since the last pass. Events
+// up to 5 minutes older than the inspection are printed on the first pass
+func (in *inspection) printEvents(ctx context.Context, clientset kubernetes.Interface, namespace string, pods map[string]bool) {
+	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Error listing events: %v\n", err)
+		}
+		return
+	}
+	events := make([]*model.Event, 0, len(list.Items))
+	for i := range list.Items {
+		ev := model.NewEventFromCoreV1(&list.Items[i])
+		subject := ev.Regarding.Name
+		if !pods[subject] && subject != in.name && !strings.HasPrefix(subject, in.name+"-") {
+			continue
+		}
+		if ev.LastObserved.Before(in.started.Add(-5*time.Minute)) || in.events[ev.UID] == ev.Count {
+			continue
+		}
+		in.events[ev.UID] = ev.Count
+		events = append(events, ev)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].LastObserved.Before(events[j].LastObserved) })
+	for _, ev := range events {
+		fmt.Printf("  event %s %s %s %s/%s (x%d): %s\n", format.Time(ev.LastObserved), ev.Type, ev.Reason, ev.Regarding.Kind,
+			ev.Regarding.Name, ev.Count, ev.Note)
+	}
+}
+
+// printLogs prints the log lines the pod's running containers wrote since the last pass, the last tail lines on the
+// first one
+func (in *inspection) printLogs(ctx context.Context, clientset kubernetes.Interface, pod *v1.Pod, tail int64) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Running == nil {
+			continue
+		}
+		key := pod.Name + "/" + cs.Name
+		last, seen := in.logs[key]
+		opts := &v1.PodLogOptions{Container: cs.Name, Timestamps: true}
+		if seen {
+			// SinceTime has a precision of seconds, the lines up to the last one printed are skipped below
+			since := metav1.NewTime(last)
+			opts.SinceTime = &since
+		} else {
+			opts.TailLines = &tail
+		}
+		logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).DoRaw(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Error reading logs of %s: %v\n", key, err)
+			}
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(logs))
+		for scanner.Scan() {
+			stamp, line, _ := strings.Cut(scanner.Text(), " ")
+			at, err := time.Parse(time.RFC3339Nano, stamp)
+			if err != nil {
+				// Not timestamped, as from fake clients
+				at, line = time.Now(), scanner.Text()
+			}
+			if seen && !at.After(last) {
+				continue
+			}
+			fmt.Printf("  log %s: %s\n", key, line)
+			last, seen = at, true
+		}
+		if !seen {
+			last = time.Now()
+		}
+		in.logs[key] = last
+	}
+}
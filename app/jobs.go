@@ -0,0 +1,159 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// missedScheduleGrace is how late a CronJob may start a job before the schedule counts as missed, when the CronJob
+// sets no starting deadline
+const missedScheduleGrace = 2 * time.Minute
+
+// jobsReportedUntil is the end of the window the previous pass reported finished Jobs in, and missedSchedules the
+// latest missed time reported per CronJob. Both are reset when a leadership term starts
+var (
+	jobsReportedUntil time.Time
+	missedSchedules   = make(map[string]time.Time)
+	jobsMu            sync.Mutex
+)
+
+// resetJobTracking forgets what was reported, so a new leader reports the Jobs that finished during the last
+// interval rather than since its previous term
+func resetJobTracking() {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	jobsReportedUntil = time.Time{}
+	missedSchedules = make(map[string]time.Time)
+}
+
+// logJobStatus logs the Jobs that completed or failed since the previous pass, and the CronJobs that missed a
+// scheduled run
+func logJobStatus(ctx context.Context, clientset kubernetes.Interface) {
+	now := time.Now()
+	jobsMu.Lock()
+	from := jobsReportedUntil
+	if from.IsZero() && cfg.Interval.Duration > 0 {
+		from = now.Add(-cfg.Interval.Duration)
+	}
+	jobsMu.Unlock()
+
+	var records []string
+	listed := true
+	for _, namespace := range monitoredNamespaces() {
+		jobs, err := retryCall(ctx, "list jobs", func(ctx context.Context) (*batchv1.JobList, error) {
+			return clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing jobs", "namespace", namespace, "err", err)
+			listed = false
+			continue
+		}
+		records = append(records, finishedJobRecords(jobs.Items, from, now)...)
+
+		cronJobs, err := retryCall(ctx, "list cron jobs", func(ctx context.Context) (*batchv1.CronJobList, error) {
+			return clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing cron jobs", "namespace", namespace, "err", err)
+			continue
+		}
+		records = append(records, missedScheduleRecords(cronJobs.Items, now)...)
+	}
+	// A namespace that could not be listed is reported again with the next pass
+	if listed {
+		jobsMu.Lock()
+		jobsReportedUntil = now
+		jobsMu.Unlock()
+	}
+
+	batch := make([][]byte, len(records))
+	for i, status := range records {
+		batch[i] = []byte(status)
+	}
+	writeBatch(ctx, sink.PriorityTransition, batch)
+}
+
+// finishedJobRecords describes the jobs that finished after from and up to now, oldest first
+func finishedJobRecords(jobs []batchv1.Job, from, now time.Time) []string {
+	type finished struct {
+		at     time.Time
+		status string
+	}
+	var done []finished
+	for i := range jobs {
+		job := model.NewJob(&jobs[i])
+		outcome, reason, message, at := job.Outcome()
+		if outcome == "" || !at.After(from) || at.After(now) {
+			continue
+		}
+		active, succeeded, failed := job.Pods()
+		status := fmt.Sprintf("Job: %s/%s", job.Namespace(), job.Name())
+		if cronJob := job.CronJob(); cronJob != "" {
+			status += ", CronJob: " + cronJob
+		}
+		status += fmt.Sprintf(", Outcome: %s, Succeeded: %d, Failed: %d, Active: %d, Duration: %s", outcome, succeeded, failed,
+			active, format.Age(job.Duration(now)))
+		if outcome == model.JobFailed {
+			if reason == "BackoffLimitExceeded" {
+				status += fmt.Sprintf(", Reason: %s (backoff limit %d hit)", reason, job.BackoffLimit())
+			} else {
+				status += ", Reason: " + reason
+			}
+			status += ", Message: " + message
+			collectorLog.Warn(status, "namespace", job.Namespace(), "job", job.Name(), "reason", reason)
+		} else {
+			collectorLog.Info(status, "namespace", job.Namespace(), "job", job.Name())
+		}
+		metrics.JobOutcomes.WithLabelValues(job.Namespace(), outcome, reason).Inc()
+		done = append(done, finished{at, status})
+	}
+	sort.SliceStable(done, func(i, j int) bool { return done[i].at.Before(done[j].at) })
+	records := make([]string, len(done))
+	for i, d := range done {
+		records[i] = d.status
+	}
+	return records
+}
+
+// missedScheduleRecords describes the cron jobs whose next run after the last one started is overdue, once per
+// missed time
+func missedScheduleRecords(cronJobs []batchv1.CronJob, now time.Time) []string {
+	var records []string
+	for i := range cronJobs {
+		cj := model.NewCronJob(&cronJobs[i])
+		grace := missedScheduleGrace
+		if deadline := cj.StartingDeadline(); deadline > 0 {
+			grace = deadline
+		}
+		missed := cj.MissedSchedule(now, grace)
+		if missed.IsZero() {
+			continue
+		}
+		key := cj.Namespace() + "/" + cj.Name()
+		jobsMu.Lock()
+		reported := missedSchedules[key].Equal(missed)
+		missedSchedules[key] = missed
+		jobsMu.Unlock()
+		if reported {
+			continue
+		}
+
+		status := fmt.Sprintf("CronJob: %s, Missed Schedule: %s (%s late), Schedule: %s, Last Scheduled: %s, Active: %d",
+			key, format.Time(missed), format.Age(now.Sub(missed)), cj.Schedule(), format.Time(cj.LastScheduled()), cj.Active())
+		collectorLog.Warn(status, "namespace", cj.Namespace(), "cronjob", cj.Name())
+		metrics.CronJobMissedSchedules.WithLabelValues(cj.Namespace()).Inc()
+		records = append(records, status)
+	}
+	return records
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"adv-go/election"
+	"adv-go/tracing"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// leaderCancel cancels the context of the current leadership term, nil while standby
+var (
+	leaderCancel context.CancelFunc
+	leaderMu     sync.Mutex
+	// warm is set once the term's caches synced, see warmUp
+	warm atomic.Bool
+)
+
+// lead starts a leadership term and returns its context, which resign cancels. Everything that writes records
+// runs under it so a replica that lost the Lease stops writing before the next leader starts
+func lead(parent context.Context) context.Context {
+	_, span := tracing.Start(parent, "acquire leadership", attribute.String("identity", leaderIdentity()))
+	defer span.End()
+	ctx, cancel := context.WithCancel(parent)
+	leaderMu.Lock()
+	leaderCancel = cancel
+	leaderMu.Unlock()
+	startLeading()
+	return ctx
+}
+
+// warmUp is the second phase of taking over: it waits for the informers started for the term to sync and reconciles
+// the alert state and the workload specs with the store and the synced caches. Until it returns the informer handlers
+// emit nothing, so a failover does not replay the previous leader's records, contradict its alerts or mark rollouts
+// it already saw
+func warmUp(ctx context.Context) {
+	ctx, span := tracing.Start(ctx, "warm up leadership")
+	defer span.End()
+	start := time.Now()
+
+	healthMu.Lock()
+	checks := make(map[string]cache.InformerSynced, len(cacheChecks)+len(syncChecks))
+	for _, c := range []map[string]cache.InformerSynced{cacheChecks, syncChecks} {
+		for name, synced := range c {
+			checks[name] = synced
+		}
+	}
+	healthMu.Unlock()
+	synced := make([]cache.InformerSynced, 0, len(checks))
+	for _, s := range checks {
+		synced = append(synced, s)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, cfg.Lease.WarmupTimeout.Duration)
+	defer cancel()
+	if !cache.WaitForCacheSync(waitCtx.Done(), synced...) {
+		if ctx.Err() != nil {
+			return
+		}
+		var pending []string
+		for name, s := range checks {
+			if !s() {
+				pending = append(pending, name)
+			}
+		}
+		sort.Strings(pending)
+		span.SetAttributes(attribute.StringSlice("unsynced", pending))
+		electionLog.Warn("Informer caches did not sync, emitting anyway", "timeout", cfg.Lease.WarmupTimeout.Duration.String(),
+			"pending", strings.Join(pending, ","))
+	}
+
+	// Another replica may have fired or resolved alerts while this one was standby, and pods may have gone since
+	loadAlertState(ctx)
+	if pods := cachedPods(); pods != nil {
+		pods = ownedPods(pods)
+		resolveGonePodAlerts(pods)
+		seedWorkloadSpecs(pods)
+	}
+	warm.Store(true)
+	span.SetAttributes(attribute.Int("informers", len(checks)))
+	electionLog.Info("Caches synced, emitting records", "informers", len(checks), "took", time.Since(start).Round(time.Millisecond).String())
+}
+
+// isWarm reports whether the current leadership term finished warming up, the informer handlers emit only then
+func isWarm() bool {
+	return warm.Load()
+}
+
+// goLeader runs fn in a goroutine of the current leadership term, resign waits for it to return
+func goLeader(fn func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fn()
+	}()
+}
+
+// stopInformers shuts the factory down once the leadership term ends, waiting for its handlers to return
+func stopInformers(ctx context.Context, factory informers.SharedInformerFactory) {
+	goLeader(func() {
+		<-ctx.Done()
+		factory.Shutdown()
+	})
+}
+
+// resign ends the leadership term: it cancels the term's context and waits up to timeout for the reporting loop,
+// informers and goroutines started during it to drain
+func resign(timeout time.Duration) {
+	_, span := tracing.Start(context.Background(), "resign leadership", attribute.String("identity", leaderIdentity()))
+	defer span.End()
+	leaderMu.Lock()
+	if leaderCancel != nil {
+		leaderCancel()
+		leaderCancel = nil
+	}
+	leaderMu.Unlock()
+	warm.Store(false)
+	stopLeading()
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		span.SetAttributes(attribute.Bool("drained", true))
+		electionLog.Info("Stopped all leader work")
+	case <-time.After(timeout):
+		span.SetAttributes(attribute.Bool("drained", false))
+		electionLog.Warn("Leader work still running after resigning", "timeout", timeout.String())
+	}
+}
+
+// electsLeader reports whether the agent campaigns for the lock rather than leading right away: in a cluster, or
+// locally with --leader-elect-locally, and never in read-only mode
+func electsLeader(inCluster bool) bool {
+	return (inCluster || cfg.Lease.ElectLocally) && !cfg.ReadOnly
+}
+
+// electionConfig is the leader election lock described by the config
+func electionConfig() election.Config {
+	return election.Config{
+		Name:          cfg.Lease.Name,
+		Namespace:     leaseNamespace(),
+		LockType:      cfg.Lease.LockType,
+		Identity:      cfg.Lease.Identity,
+		LeaseDuration: cfg.Lease.Duration.Duration, // Duration of the leadership
+		RenewDeadline: cfg.Lease.RenewDeadline.Duration,
+		RetryPeriod:   cfg.Lease.RetryPeriod.Duration,
+		OnTransition:  auditTransition,
+		AnnotateLease: cfg.Lease.Annotate,
+	}
+}
+
+// leaderIdentity names this replica in leadership spans, local runs have no elector and are named "local"
+func leaderIdentity() string {
+	if elector == nil {
+		return "local"
+	}
+	return elector.Identity()
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"adv-go/election"
+	"adv-go/sink"
+	"encoding/json"
+	"sync"
+)
+
+// leaderAudit receives the leadership transitions with --lease-audit-log, nil when disabled
+var (
+	leaderAudit   sink.Sink
+	leaderAuditMu sync.Mutex
+)
+
+// openLeaderAudit opens the audit log of leadership transitions. It is never rotated or pruned, so the history of a
+// flapping leadership survives the incident
+func openLeaderAudit() error {
+	if cfg.Lease.AuditLog == "" {
+		return nil
+	}
+	f, err := sink.NewFile(cfg.Lease.AuditLog, sink.Rotation{})
+	if err != nil {
+		return err
+	}
+	leaderAuditMu.Lock()
+	leaderAudit = f
+	leaderAuditMu.Unlock()
+	return nil
+}
+
+// closeLeaderAudit closes the audit log
+func closeLeaderAudit() {
+	leaderAuditMu.Lock()
+	defer leaderAuditMu.Unlock()
+	if leaderAudit != nil {
+		leaderAudit.Close()
+		leaderAudit = nil
+	}
+}
+
+// auditTransition writes a leadership transition to the audit log as a JSON line
+func auditTransition(t election.Transition) {
+	electionLog.Debug("Leadership transition", "event", t.Event, "leader", t.Leader, "lock", t.Lock, "reason", t.Reason)
+	leaderAuditMu.Lock()
+	defer leaderAuditMu.Unlock()
+	if leaderAudit == nil {
+		return
+	}
+	line, err := json.Marshal(t)
+	if err != nil {
+		electionLog.Error("Error encoding leadership transition", "err", err)
+		return
+	}
+	if err := leaderAudit.Write(line); err != nil {
+		electionLog.Error("Error writing leadership transition to the audit log", "err", err)
+	}
+}
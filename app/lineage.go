@@ -0,0 +1,144 @@
+package app
+
+import (
+	"adv-go/history"
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// successorWindow is how long after a pod leaves its replacement may appear to be linked to it
+const successorWindow = 10 * time.Minute
+
+// maxPredecessors bounds how far back history queries follow a replica
+const maxPredecessors = 20
+
+// departedPod is a pod that left its workload and has not been replaced yet
+type departedPod struct {
+	name string
+	at   time.Time
+}
+
+// departures holds the departed pods per replica key waiting for a successor, departed when each UID was queued
+var (
+	departures  = make(map[string][]departedPod)
+	departed    = make(map[types.UID]time.Time)
+	departureMu sync.Mutex
+)
+
+// replicaKey identifies the workload replica a pod belongs to, pods of the same key replace each other.
+// ReplicaSet pods are interchangeable, DaemonSet pods replace the one on the same node, other pods have no key
+func replicaKey(pod *v1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		switch ref.Kind {
+		case "ReplicaSet":
+			return "ReplicaSet/" + pod.Namespace + "/" + ref.Name
+		case "DaemonSet":
+			return "DaemonSet/" + pod.Namespace + "/" + ref.Name + "/" + pod.Spec.NodeName
+		}
+	}
+	return ""
+}
+
+// observePodDeparture queues a pod that started terminating, failed or was deleted as a predecessor for its replacement
+func observePodDeparture(old, pod *v1.Pod) {
+	if historyStore == nil {
+		return
+	}
+	leaving := old == nil ||
+		(old.DeletionTimestamp == nil && pod.DeletionTimestamp != nil) ||
+		(old.Status.Phase != v1.PodFailed && pod.Status.Phase == v1.PodFailed)
+	key := replicaKey(pod)
+	if !leaving || key == "" {
+		return
+	}
+
+	departureMu.Lock()
+	defer departureMu.Unlock()
+	if _, ok := departed[pod.UID]; ok {
+		return
+	}
+	departed[pod.UID] = time.Now()
+	departures[key] = append(departures[key], departedPod{name: pod.Name, at: time.Now()})
+	pruneDepartures()
+}
+
+// linkSuccessor records the oldest departed pod of the same replica as the new pod's predecessor
+func linkSuccessor(pod *v1.Pod) {
+	if historyStore == nil {
+		return
+	}
+	key := replicaKey(pod)
+	if key == "" {
+		return
+	}
+
+	departureMu.Lock()
+	pruneDepartures()
+	queue := departures[key]
+	if len(queue) == 0 {
+		departureMu.Unlock()
+		return
+	}
+	predecessor := queue[0]
+	departures[key] = queue[1:]
+	departureMu.Unlock()
+
+	l := history.Lineage{
+		Namespace:   pod.Namespace,
+		Pod:         pod.Name,
+		Workload:    key,
+		Predecessor: predecessor.name,
+		At:          time.Now(),
+	}
+	queueHistory("predecessor of pod "+pod.Namespace+"/"+pod.Name, func(ctx context.Context) error {
+		return historyStore.RecordLineage(ctx, l)
+	})
+}
+
+// pruneDepartures drops departed pods that were not replaced in time, the caller holds the lock
+func pruneDepartures() {
+	for key, queue := range departures {
+		fresh := queue[:0]
+		for _, d := range queue {
+			if time.Since(d.at) < successorWindow {
+				fresh = append(fresh, d)
+			}
+		}
+		if len(fresh) == 0 {
+			delete(departures, key)
+		} else {
+			departures[key] = fresh
+		}
+	}
+	// Keep departed UIDs a while longer so the final delete of an already matched pod is not queued again
+	for uid, at := range departed {
+		if time.Since(at) > 2*successorWindow {
+			delete(departed, uid)
+		}
+	}
+}
+
+// podLineage returns the pod followed by its recorded predecessors, newest first
+func podLineage(ctx context.Context, store *history.Store, namespace, pod string) ([]string, error) {
+	lineage := []string{pod}
+	seen := map[string]bool{pod: true}
+	for len(lineage) <= maxPredecessors {
+		predecessor, err := store.Predecessor(ctx, namespace, lineage[len(lineage)-1])
+		if err != nil {
+			return nil, err
+		}
+		if predecessor == "" || seen[predecessor] {
+			break
+		}
+		seen[predecessor] = true
+		lineage = append(lineage, predecessor)
+	}
+	return lineage, nil
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"adv-go/logging"
+	"adv-go/model"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Component loggers, their levels are set with --log-level (e.g. info,collector=debug) and their format with --log-format
+var (
+	// agentLog covers startup and the heartbeat
+	agentLog = logging.For("agent")
+	// collectorLog covers the periodic pod, node and workload status passes
+	collectorLog = logging.For("collector")
+	// watchLog covers the pod and node informers
+	watchLog = logging.For("watch")
+	// eventsLog covers cluster and autoscaler events
+	eventsLog = logging.For("events")
+	// alertsLog covers alerts, notifications, incident bundles and remediation
+	alertsLog = logging.For("alerts")
+	// historyLog covers the history store
+	historyLog = logging.For("history")
+	// apiLog covers the HTTP API
+	apiLog = logging.For("api")
+	// electionLog covers leadership
+	electionLog = logging.For("election")
+)
+
+// podFields returns the namespace, pod, node, phase and owner fields every log line about a pod carries
+func podFields(pod *v1.Pod) []any {
+	return []any{"namespace", pod.Namespace, "pod", pod.Name, "node", pod.Spec.NodeName, "phase", string(pod.Status.Phase),
+		"owner", describeOwner(model.NewPod(pod))}
+}
+
+// modelPodFields returns the same fields as podFields for a pod model
+func modelPodFields(pod *model.Pod) []any {
+	health, _ := podHealth(pod)
+	return []any{"namespace", pod.Namespace(), "pod", pod.Name(), "node", pod.NodeName(), "phase", string(pod.Phase()),
+		"owner", describeOwner(pod), "health", health}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"adv-go/history"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// legacyRecord matches the pod records of pod_status.log, both the original three field lines and the current
+// ones that append more fields
+var legacyRecord = regexp.MustCompile(`^Pod Name: ([^,]+), Node: ([^,]*), Phase: (\w+)`)
+
+// legacyPass is the pod records written by one reporting pass
+type legacyPass struct {
+	at      time.Time
+	records []history.Transition
+}
+
+// migrateOptions configure how legacy records are placed in namespaces and time
+type migrateOptions struct {
+	namespace string
+	dryRun    bool
+}
+
+// newMigrateCommand creates the migrate command
+func newMigrateCommand() *cobra.Command {
+	var opts migrateOptions
+	cmd := &cobra.Command{
+		Use:   "migrate <pod_status.log>...",
+		Short: "Import the pod records of legacy pod_status.log files into the history store",
+		Long: `Import the pod records of legacy pod_status.log files into the history store.
+
+The files carry no timestamps or namespaces. A new reporting pass starts whenever a pod repeats, the last pass of
+a file is dated at the file's modification time and earlier passes one --interval apart before it, so pass the
+interval the agent ran with. Only changes of a pod's phase or node are recorded, like the agent does.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.History.DSN == "" && !opts.dryRun {
+				return errors.New("migrate requires --history-dsn")
+			}
+			if cfg.Interval.Duration <= 0 {
+				return errors.New("migrate needs the reporting --interval the files were written with")
+			}
+			return exitCode(runMigrate(args, opts))
+		},
+	}
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "default", "namespace recorded for the pods, legacy records have none")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "parse the files and print what would be imported without writing")
+	return cmd
+}
+
+// runMigrate parses the files and records the pod transitions they contain
+func runMigrate(paths []string, opts migrateOptions) int {
+	var passes []legacyPass
+	for _, path := range paths {
+		filePasses, err := readLegacyLog(path, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", path, err)
+			return 1
+		}
+		passes = append(passes, filePasses...)
+	}
+	// Rotated files are migrated in the order they were written whatever order they are given in
+	sort.SliceStable(passes, func(i, j int) bool { return passes[i].at.Before(passes[j].at) })
+
+	var transitions []history.Transition
+	last := make(map[string]history.Transition)
+	for _, pass := range passes {
+		for _, t := range pass.records {
+			if prev, ok := last[t.Pod]; ok && prev.Phase == t.Phase && prev.Node == t.Node {
+				continue
+			}
+			last[t.Pod] = t
+			transitions = append(transitions, t)
+		}
+	}
+	if len(passes) > 0 {
+		fmt.Printf("Found %d passes from %s to %s, %d transitions of %d pods\n", len(passes),
+			passes[0].at.Format(time.RFC3339), passes[len(passes)-1].at.Format(time.RFC3339), len(transitions), len(last))
+	}
+	if opts.dryRun || len(transitions) == 0 {
+		return 0
+	}
+
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening history store: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+	for _, t := range transitions {
+		if err := store.Record(context.Background(), t); err != nil {
+			fmt.Fprintf(os.Stderr, "error recording transition of %s: %v\n", t.Pod, err)
+			return 1
+		}
+	}
+	fmt.Printf("Imported %d transitions into the history store\n", len(transitions))
+	return 0
+}
+
+// readLegacyLog splits a file into reporting passes and dates them backwards from the file's modification time
+func readLegacyLog(path string, opts migrateOptions) ([]legacyPass, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var passes []legacyPass
+	var current legacyPass
+	inPass := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := legacyRecord.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			// Workload rollups, node and event records describe no pod phase
+			continue
+		}
+		if inPass[m[1]] {
+			passes = append(passes, current)
+			current = legacyPass{}
+			inPass = make(map[string]bool)
+		}
+		inPass[m[1]] = true
+		current.records = append(current.records, history.Transition{Namespace: opts.namespace, Pod: m[1], Node: m[2], Phase: m[3]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(current.records) > 0 {
+		passes = append(passes, current)
+	}
+
+	end := info.ModTime()
+	for i := range passes {
+		at := end.Add(-time.Duration(len(passes)-1-i) * cfg.Interval.Duration)
+		passes[i].at = at
+		for j := range passes[i].records {
+			passes[i].records[j].At = at
+		}
+	}
+	return passes, nil
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// selectedNamespaces are the namespaces matching --namespace-selector. They are listed when connecting and kept
+// current by the read cache's Namespace watch
+var (
+	selectedNamespaces   = make(map[string]bool)
+	selectedNamespacesMu sync.RWMutex
+)
+
+// selectingNamespaces reports whether the monitored namespaces are chosen by --namespace-selector
+func selectingNamespaces() bool {
+	return cfg.NamespaceSelector != ""
+}
+
+// selectedNamespaceList returns the namespaces matching the selector, sorted
+func selectedNamespaceList() []string {
+	selectedNamespacesMu.RLock()
+	defer selectedNamespacesMu.RUnlock()
+	namespaces := make([]string, 0, len(selectedNamespaces))
+	for ns := range selectedNamespaces {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// namespaceSelected reports whether the namespace matches the selector
+func namespaceSelected(namespace string) bool {
+	selectedNamespacesMu.RLock()
+	defer selectedNamespacesMu.RUnlock()
+	return selectedNamespaces[namespace]
+}
+
+// setNamespaceSelected adds or removes a namespace of the selection, logging when that changes
+func setNamespaceSelected(namespace string, selected bool) {
+	selectedNamespacesMu.Lock()
+	changed := selectedNamespaces[namespace] != selected
+	if selected {
+		selectedNamespaces[namespace] = true
+	} else {
+		delete(selectedNamespaces, namespace)
+	}
+	selectedNamespacesMu.Unlock()
+	if !changed {
+		return
+	}
+	if selected {
+		watchLog.Info("Namespace matches the namespace selector, monitoring it", "namespace", namespace, "selector", cfg.NamespaceSelector)
+	} else {
+		watchLog.Info("Namespace no longer matches the namespace selector or was deleted, no longer monitoring it", "namespace", namespace)
+	}
+}
+
+// loadSelectedNamespaces lists the namespaces matching the selector once, for the commands that do not watch them
+func loadSelectedNamespaces(ctx context.Context, clientset kubernetes.Interface) error {
+	list, err := retryCall(ctx, "list namespaces", func(ctx context.Context) (*v1.NamespaceList, error) {
+		return clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: cfg.NamespaceSelector})
+	})
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool, len(list.Items))
+	for _, ns := range list.Items {
+		selected[ns.Name] = true
+	}
+	selectedNamespacesMu.Lock()
+	selectedNamespaces = selected
+	selectedNamespacesMu.Unlock()
+	return nil
+}
+
+// watchNamespaces keeps the selected namespaces current for the life of the process. A watch with a label selector
+// delivers a namespace whose labels stop matching as deleted, the labels are matched again all the same
+func watchNamespaces(clientset kubernetes.Interface) {
+	selector, err := labels.Parse(cfg.NamespaceSelector)
+	if err != nil {
+		watchLog.Error("Invalid namespace selector", "selector", cfg.NamespaceSelector, "err", err)
+		return
+	}
+	observe := func(obj interface{}) {
+		if ns, ok := obj.(*v1.Namespace); ok {
+			setNamespaceSelected(ns.Name, selector.Matches(labels.Set(ns.Labels)))
+		}
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = cfg.NamespaceSelector
+		}),
+	)
+	informer := factory.Core().V1().Namespaces().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    observe,
+		UpdateFunc: func(_, obj interface{}) { observe(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*v1.Namespace); ok {
+				setNamespaceSelected(ns.Name, false)
+			}
+		},
+	})
+	addCacheCheck("namespaces", informer.HasSynced)
+	factory.Start(nil)
+}
+
+// watchedNamespaces are the namespaces the informers of a leadership term watch: every namespace when selecting
+// them, since a namespace can match at any time and the handlers drop the objects of the others, otherwise the
+// monitored namespaces
+func watchedNamespaces() []string {
+	if selectingNamespaces() {
+		return []string{metav1.NamespaceAll}
+	}
+	return monitoredNamespaces()
+}
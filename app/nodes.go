@@ -0,0 +1,128 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// logNodeStatus retrieves the node statuses and logs them
+func logNodeStatus(ctx context.Context, clientset kubernetes.Interface) {
+	nodes, err := getAllNodes(ctx, clientset)
+	if err != nil {
+		collectorLog.Error("Error listing nodes", "err", err)
+		return
+	}
+
+	for i := range nodes.Items {
+		status := formatNodeStatus(model.NewNode(&nodes.Items[i]))
+		if err := sink.WritePriority(out, sink.PriorityHeartbeat, []byte(status)); err != nil {
+			collectorLog.Error("Error writing to sink", "err", err)
+			continue
+		}
+		collectorLog.Info(status, "node", nodes.Items[i].Name)
+	}
+}
+
+// nodeIndex maps node names to their models for one reporting pass
+type nodeIndex map[string]*model.Node
+
+// loadNodeIndex lists the nodes for a reporting pass, returning an empty index on error
+func loadNodeIndex(ctx context.Context, clientset kubernetes.Interface) nodeIndex {
+	index := make(nodeIndex)
+	nodes, err := getAllNodes(ctx, clientset)
+	if err != nil {
+		collectorLog.Error("Error listing nodes", "err", err)
+		return index
+	}
+	for i := range nodes.Items {
+		index[nodes.Items[i].Name] = model.NewNode(&nodes.Items[i])
+	}
+	return index
+}
+
+// disruptions describes the infrastructure problems of the node a pod runs on, so app failures can be told apart
+// from node failures. A node missing from a non-empty index was deleted under the pod
+func (index nodeIndex) disruptions(name string) []string {
+	if name == "" || len(index) == 0 {
+		return nil
+	}
+	node, ok := index[name]
+	if !ok {
+		return []string{"NodeDeleted"}
+	}
+	return node.Disruptions()
+}
+
+// getAllNodes fetches all nodes in the cluster
+func getAllNodes(ctx context.Context, clientset kubernetes.Interface) (*v1.NodeList, error) {
+	return retryCall(ctx, "list nodes", func(ctx context.Context) (*v1.NodeList, error) {
+		return clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	})
+}
+
+// formatNodeStatus renders a single node's health as a log line
+func formatNodeStatus(node *model.Node) string {
+	// Only report the pressure conditions that are not in their healthy state. Virtual nodes have no
+	// kubelet doing node-pressure eviction, so their pressure conditions carry no meaning
+	var problems []string
+	virtual := node.VirtualKind()
+	for _, c := range node.Conditions() {
+		if virtual == "" && c.Type != v1.NodeReady && c.Status != v1.ConditionFalse {
+			problems = append(problems, string(c.Type))
+		}
+	}
+
+	taints := make([]string, 0, len(node.Taints()))
+	for _, t := range node.Taints() {
+		taints = append(taints, fmt.Sprintf("%s:%s", t.Key, t.Effect))
+	}
+
+	capacity, allocatable := node.Capacity(), node.Allocatable()
+	status := fmt.Sprintf("Node Name: %s, OS: %s, Ready: %t, Conditions: [%s], CPU: %s/%s, Memory: %s/%s, Taints: [%s]",
+		node.Name(), node.OS(), node.IsReady(), strings.Join(problems, ","),
+		format.CPU(allocatable.Cpu().MilliValue()), format.CPU(capacity.Cpu().MilliValue()),
+		format.Bytes(allocatable.Memory().Value()), format.Bytes(capacity.Memory().Value()),
+		strings.Join(taints, ","))
+	if virtual != "" {
+		status += ", Virtual: " + virtual
+	}
+	return status
+}
+
+// logOSMismatches flags Pending pods that require an operating system no schedulable node runs. An empty index, as
+// when the nodes could not be listed, flags nothing
+func logOSMismatches(pods []v1.Pod, nodes nodeIndex) {
+	if len(nodes) == 0 {
+		return
+	}
+	schedulable := make(map[string]int)
+	for _, node := range nodes {
+		if node.IsSchedulable() {
+			schedulable[node.OS()]++
+		}
+	}
+
+	for i := range pods {
+		pod := model.NewPod(&pods[i])
+		if pod.Phase() != v1.PodPending || pod.IsScheduled() || pod.RequiredOS() == "" {
+			continue
+		}
+		if schedulable[pod.RequiredOS()] > 0 {
+			continue
+		}
+		status := fmt.Sprintf("Pod Name: %s/%s, OS Mismatch: requires %s but no schedulable %s nodes are available",
+			pod.Namespace(), pod.Name(), pod.RequiredOS(), pod.RequiredOS())
+		if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+			collectorLog.Error("Error writing to sink", "err", err)
+		}
+		collectorLog.Info(status, modelPodFields(pod)...)
+	}
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"adv-go/history"
+	"adv-go/model"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nodeLister serves the watched nodes, nil until the node timeline is started
+var nodeLister corelisters.NodeLister
+
+// drainedNodes remembers the cordoned nodes already recorded as drained until they are uncordoned
+var (
+	drainedNodes   = make(map[string]bool)
+	drainedNodesMu sync.Mutex
+)
+
+// startNodeTimeline watches nodes and records their lifecycle in the history store
+func startNodeTimeline(ctx context.Context, clientset kubernetes.Interface) {
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	nodeInformer := factory.Core().V1().Nodes().Informer()
+
+	nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			// Nodes delivered during the initial list registered before the agent started
+			if node, ok := obj.(*v1.Node); ok && nodeInformer.HasSynced() && isWarm() {
+				recordNodeEvent(node.Name, history.NodeRegistered, "")
+			}
+		},
+		UpdateFunc: func(oldObj, obj interface{}) {
+			old, okOld := oldObj.(*v1.Node)
+			node, ok := obj.(*v1.Node)
+			if okOld && ok && isWarm() {
+				observeNodeChange(model.NewNode(old), model.NewNode(node))
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if node, ok := obj.(*v1.Node); ok && isWarm() {
+				forgetDrained(node.Name)
+				recordNodeEvent(node.Name, history.NodeDeleted, "")
+			}
+		},
+	})
+
+	nodeLister = factory.Core().V1().Nodes().Lister()
+	addSyncCheck("nodes", nodeInformer.HasSynced)
+	factory.Start(ctx.Done())
+	stopInformers(ctx, factory)
+}
+
+// observeNodeChange records cordon and readiness changes between two versions of a node
+func observeNodeChange(old, node *model.Node) {
+	if !old.IsCordoned() && node.IsCordoned() {
+		recordNodeEvent(node.Name(), history.NodeCordoned, "")
+	}
+	if old.IsCordoned() && !node.IsCordoned() {
+		forgetDrained(node.Name())
+		recordNodeEvent(node.Name(), history.NodeUncordoned, "")
+	}
+	if old.IsReady() && !node.IsReady() {
+		recordNodeEvent(node.Name(), history.NodeNotReady, readyReason(node))
+	}
+	if !old.IsReady() && node.IsReady() {
+		recordNodeEvent(node.Name(), history.NodeReady, "")
+	}
+}
+
+// readyReason returns the reason and message of the node's Ready condition
+func readyReason(node *model.Node) string {
+	for _, c := range node.Conditions() {
+		if c.Type == v1.NodeReady {
+			return c.Reason + ": " + c.Message
+		}
+	}
+	return ""
+}
+
+// checkNodeDrained records a cordoned node as drained once only DaemonSet and static pods remain on it
+func checkNodeDrained(clientset kubernetes.Interface, nodeName string) {
+	if nodeLister == nil || nodeName == "" {
+		return
+	}
+	node, err := nodeLister.Get(nodeName)
+	if err != nil || !node.Spec.Unschedulable {
+		return
+	}
+	drainedNodesMu.Lock()
+	drained := drainedNodes[nodeName]
+	drainedNodesMu.Unlock()
+	if drained {
+		return
+	}
+
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		historyLog.Error("Error listing pods on node", "node", nodeName, "err", err)
+		return
+	}
+	for i := range pods.Items {
+		if !survivesDrain(&pods.Items[i]) {
+			return
+		}
+	}
+
+	drainedNodesMu.Lock()
+	drainedNodes[nodeName] = true
+	drainedNodesMu.Unlock()
+	recordNodeEvent(nodeName, history.NodeDrained, "")
+}
+
+// survivesDrain reports whether a pod is left in place or already leaving when a node is drained
+func survivesDrain(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return true
+	}
+	if _, mirror := pod.Annotations[v1.MirrorPodAnnotationKey]; mirror {
+		return true
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetDrained clears the drained state of a node
+func forgetDrained(nodeName string) {
+	drainedNodesMu.Lock()
+	defer drainedNodesMu.Unlock()
+	delete(drainedNodes, nodeName)
+}
+
+// recordNodeEvent appends the event to the node's timeline
+func recordNodeEvent(node, event, detail string) {
+	e := history.NodeEvent{Node: node, Event: event, Detail: detail, At: time.Now()}
+	if err := historyStore.RecordNodeEvent(context.Background(), e); err != nil {
+		historyLog.Error("Error recording node event", "node", node, "event", event, "err", err)
+	}
+}
+
+// serveNodeTimeline returns a node's timeline as JSON, ?since= limits how far back it goes (default 7 days)
+func serveNodeTimeline(w http.ResponseWriter, r *http.Request) {
+	since := 7 * 24 * time.Hour
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+
+	timeline, err := historyStore.NodeTimeline(r.Context(), r.PathValue("name"), time.Now().Add(-since))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if timeline == nil {
+		timeline = []history.NodeEvent{}
+	}
+	writeJSON(w, timeline)
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/notify"
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// notifier delivers bad phase notifications, nil when no destination is configured
+var notifier notify.Notifier
+
+// setupNotifier creates the notifier for the configured destinations
+func setupNotifier() {
+	var notifiers notify.Multi
+	if cfg.Notify.SlackWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewSlack(cfg.Notify.SlackWebhookURL))
+	}
+	if cfg.Notify.WebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhook(cfg.Notify.WebhookURL))
+	}
+	if len(notifiers) > 0 {
+		notifier = notifiers
+	}
+}
+
+// notifiedAt is when each pod was last notified about, by pod UID and reason, for --notify-cooldown
+var (
+	notifiedAt   = make(map[notifiedKey]time.Time)
+	notifiedAtMu sync.Mutex
+)
+
+// notifiedKey identifies a pod's notifications about one reason. The UID tells a recreated pod of the same name
+// apart, so a StatefulSet pod is notified about again after it is replaced
+type notifiedKey struct {
+	uid    types.UID
+	reason string
+}
+
+// coolingDown reports when the pod was notified about the reason if that was within the cooldown, and otherwise
+// starts the cooldown. Cooldowns that ended are dropped on the way
+func coolingDown(uid types.UID, reason string) (time.Time, bool) {
+	cooldown := cfg.Notify.Cooldown.Duration
+	if cooldown <= 0 {
+		return time.Time{}, false
+	}
+	now := time.Now()
+	notifiedAtMu.Lock()
+	defer notifiedAtMu.Unlock()
+	for k, at := range notifiedAt {
+		if now.Sub(at) >= cooldown {
+			delete(notifiedAt, k)
+		}
+	}
+	key := notifiedKey{uid: uid, reason: reason}
+	if at, ok := notifiedAt[key]; ok {
+		return at, true
+	}
+	notifiedAt[key] = now
+	return time.Time{}, false
+}
+
+// notifyBadPhase records an alert, captures an incident bundle and sends a notification when a pod enters one of
+// the configured bad phases
+func notifyBadPhase(obj *v1.Pod, pod *model.Pod) {
+	if notifier == nil && historyStore == nil && !incidentsEnabled() {
+		return
+	}
+	reason, message := badPhase(pod)
+	key := badPhaseKey(pod.Namespace(), pod.Name())
+	if reason == "" {
+		resolveAlert(key)
+		return
+	}
+	fired, silenced := fireAlert(history.Alert{
+		Key:       key,
+		Namespace: pod.Namespace(),
+		Subject:   "Pod/" + pod.Name(),
+		Reason:    reason,
+		Detail:    message,
+		FiredAt:   time.Now(),
+	})
+	if !fired {
+		return
+	}
+	recordPodEvent(pod.Namespace(), pod.Name(), history.PodAlert, reason+": "+message)
+	if !silenced {
+		if s, ok := fileSilenceFor(obj); ok {
+			alertsLog.Info("Alert silenced by the silences file, not notifying", "alert", key, "reason", reason,
+				"namespace", s.Namespace, "selector", s.Selector, "comment", s.Comment, "until", format.Time(s.ExpiresAt))
+			silenced = true
+		}
+	}
+	if silenced {
+		metrics.NotificationsSuppressed.WithLabelValues("silenced").Inc()
+		return
+	}
+	if notifier == nil && !incidentsEnabled() {
+		return
+	}
+	if at, ok := coolingDown(obj.UID, reason); ok {
+		alertsLog.Info("Pod notified about the same reason within the cooldown, not notifying", "alert", key,
+			"reason", reason, "notified", format.Age(time.Since(at)))
+		metrics.NotificationsSuppressed.WithLabelValues("cooldown").Inc()
+		return
+	}
+
+	n := notify.Notification{
+		Namespace: pod.Namespace(),
+		Pod:       pod.Name(),
+		Node:      pod.NodeName(),
+		Phase:     string(pod.Phase()),
+		Reason:    reason,
+		Message:   message,
+		Time:      time.Now().UTC(),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if incidentsEnabled() {
+			n.Bundle = captureIncident(ctx, clientset, obj, reason)
+		}
+		if notifier == nil {
+			return
+		}
+		if err := notifier.Notify(ctx, n); err != nil {
+			alertsLog.Error("Error sending notification", "namespace", n.Namespace, "pod", n.Pod, "node", n.Node, "phase", n.Phase, "err", err)
+		}
+	}()
+}
+
+// badPhase returns the configured bad phase or problem reason the pod is in, or an empty reason
+func badPhase(pod *model.Pod) (string, string) {
+	reason, message, hasProblem := pod.Problem()
+	for _, phase := range cfg.Notify.Phases {
+		if hasProblem && phase == reason {
+			return reason, message
+		}
+		if phase == string(pod.Phase()) {
+			return phase, "pod " + pod.Name() + " is in phase " + phase
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"adv-go/model"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// metadataClient watches the metadata of pod owners, nil when connected to a fake cluster
+var metadataClient metadata.Interface
+
+// ownerResources are the controllers of pods that have controllers themselves, by kind
+var ownerResources = map[string]schema.GroupVersionResource{
+	"ReplicaSet": {Group: "apps", Version: "v1", Resource: "replicasets"},
+	"Job":        {Group: "batch", Version: "v1", Resource: "jobs"},
+}
+
+// ownerListers resolves pod owners from metadata informers of the ReplicaSets and Jobs in the monitored namespaces.
+// Only the metadata is cached, ownerReferences are all Owner needs
+type ownerListers map[string]map[string]cache.GenericLister
+
+// Controller returns the controller of a cached ReplicaSet or Job, see model.OwnerResolver
+func (o ownerListers) Controller(namespace, kind, name string) (string, string, bool) {
+	listers := o[namespace]
+	if listers == nil {
+		listers = o[metav1.NamespaceAll]
+	}
+	lister, ok := listers[kind]
+	if !ok {
+		return "", "", false
+	}
+	obj, err := lister.ByNamespace(namespace).Get(name)
+	if err != nil {
+		return "", "", false
+	}
+	meta, ok := obj.(*metav1.PartialObjectMetadata)
+	if !ok {
+		return "", "", false
+	}
+	ref := metav1.GetControllerOfNoCopy(meta)
+	if ref == nil {
+		return "", "", false
+	}
+	return ref.Kind, ref.Name, true
+}
+
+// describeOwner renders the pod's top-level owner as kind/name, e.g. Deployment/cart
+func describeOwner(pod *model.Pod) string {
+	return pod.OwnerKind() + "/" + pod.Owner()
+}
+
+// watchOwners starts the metadata informers pod owners are resolved with, for the read cache
+func watchOwners() {
+	if metadataClient == nil {
+		return
+	}
+	owners := make(ownerListers)
+	for _, namespace := range readCacheNamespaces() {
+		factory := metadatainformer.NewFilteredSharedInformerFactory(metadataClient, 0, namespace, nil)
+		owners[namespace] = make(map[string]cache.GenericLister, len(ownerResources))
+		for kind, gvr := range ownerResources {
+			informer := factory.ForResource(gvr)
+			owners[namespace][kind] = informer.Lister()
+			addCacheCheck("owners/"+gvr.Resource+"/"+namespace, informer.Informer().HasSynced)
+		}
+		factory.Start(nil)
+	}
+	model.SetOwnerResolver(owners)
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"adv-go/election"
+	"adv-go/features"
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// permission is an access the agent needs and the feature needing it
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	namespace   string
+	reason      string
+}
+
+// String renders the permission like "list pods in namespace shop" or "get leases.coordination.k8s.io in all namespaces"
+func (p permission) String() string {
+	resource := p.resource
+	if p.subresource != "" {
+		resource += "/" + p.subresource
+	}
+	if p.group != "" {
+		resource += "." + p.group
+	}
+	scope := "in all namespaces"
+	if p.namespace != "" {
+		scope = "in namespace " + p.namespace
+	}
+	if p.resource == "nodes" || p.resource == "persistentvolumes" {
+		scope = "(cluster scoped)"
+	}
+	return fmt.Sprintf("%s %s %s", p.verb, resource, scope)
+}
+
+// permissionResult is the outcome of checking one permission
+type permissionResult struct {
+	permission
+	allowed bool
+	detail  string
+}
+
+// requiredPermissions lists the accesses the agent needs with the current config
+func requiredPermissions(inCluster bool) []permission {
+	var perms []permission
+	add := func(verbs []string, group, resource, subresource, namespace, reason string) {
+		for _, verb := range verbs {
+			perms = append(perms, permission{verb, group, resource, subresource, namespace, reason})
+		}
+	}
+
+	add([]string{"list"}, "", "nodes", "", "", "report node status")
+	add([]string{"list"}, "", "persistentvolumes", "", "", "report the volumes bound to claims")
+	if cfg.History.DSN != "" {
+		add([]string{"watch"}, "", "nodes", "", "", "record the node timeline")
+	}
+	if selectingNamespaces() {
+		add([]string{"list", "watch"}, "", "namespaces", "", "", "follow the namespaces of --namespace-selector")
+	}
+	for _, ns := range watchedNamespaces() {
+		add([]string{"list", "watch"}, "", "pods", "", ns, "report and watch pod status")
+		add([]string{"list", "watch"}, "", "events", "", ns, "report cluster events and pod Warning events")
+		add([]string{"list"}, "apps", "deployments", "", ns, "roll pods up to their workloads")
+		add([]string{"list"}, "apps", "replicasets", "", ns, "roll pods up to their workloads")
+		add([]string{"list", "watch"}, "apps", "replicasets", "", ns, "resolve the top-level owners of pods")
+		add([]string{"list", "watch"}, "batch", "jobs", "", ns, "resolve the top-level owners of pods")
+		add([]string{"list"}, "", "persistentvolumeclaims", "", ns, "report volume claims and the pods waiting for them")
+		if features.Enabled(features.DaemonSetCoverage) {
+			add([]string{"list"}, "apps", "daemonsets", "", ns, "check the nodes DaemonSets cover (DaemonSetCoverage)")
+		}
+		if features.Enabled(features.JobTracking) {
+			add([]string{"list"}, "batch", "jobs", "", ns, "report finished Jobs (JobTracking)")
+			add([]string{"list"}, "batch", "cronjobs", "", ns, "report missed CronJob schedules (JobTracking)")
+		}
+		if incidentsEnabled() {
+			add([]string{"get"}, "", "pods", "log", ns, "capture container logs in incident bundles")
+			add([]string{"list"}, "", "services", "", ns, "capture service endpoints in incident bundles")
+			if cluster.EndpointSlices {
+				add([]string{"list"}, "discovery.k8s.io", "endpointslices", "", ns, "capture service endpoints in incident bundles")
+			}
+		}
+		if features.Enabled(features.EndpointLatency) && cluster.EndpointSlices {
+			add([]string{"list", "watch"}, "discovery.k8s.io", "endpointslices", "", ns, "measure endpoint propagation (EndpointLatency)")
+		}
+		if cfg.WithMetrics {
+			add([]string{"list"}, "metrics.k8s.io", "pods", "", ns, "read pod usage for --with-metrics")
+		}
+	}
+	reachabilityNamespaces := make(map[string]bool)
+	for _, target := range cfg.ReachabilityTargets {
+		ns, _, _ := strings.Cut(target, "/")
+		if reachabilityNamespaces[ns] {
+			continue
+		}
+		reachabilityNamespaces[ns] = true
+		add([]string{"get"}, "", "services", "", ns, "check Service reachability (--reachability-targets)")
+	}
+	if len(cfg.Enrichment.NamespaceLabels) > 0 || len(cfg.Enrichment.NamespaceAnnotations) > 0 {
+		add([]string{"list"}, "", "namespaces", "", "", "enrich records with namespace metadata")
+	} else if sharding() && len(cfg.Namespaces) == 0 {
+		add([]string{"list"}, "", "namespaces", "", "", "assign namespaces to shards (--lease-shards)")
+	} else if cfg.ListConcurrency > 0 && len(cfg.Namespaces) == 0 {
+		add([]string{"list"}, "", "namespaces", "", "", "list pods per namespace (--list-concurrency)")
+	}
+	if cfg.ReadOnly {
+		return perms
+	}
+	if cfg.EmitEvents {
+		add([]string{"create", "patch"}, "", "events", "", "", "emit Warning events about unhealthy pods (--emit-events)")
+	}
+	if features.Enabled(features.PodRemediation) {
+		for _, ns := range watchedNamespaces() {
+			add([]string{"delete"}, "", "pods", "", ns, "delete crash looping pods (PodRemediation)")
+		}
+	}
+	if cfg.StatusReports {
+		for _, ns := range monitoredNamespaces() {
+			add([]string{"create", "patch"}, "podlogger.io", "podstatusreports", "", ns, "apply PodStatusReports (--status-reports)")
+		}
+	}
+	if inCluster || cfg.Lease.ElectLocally {
+		lock := cfg.Lease.LockType
+		if lock == election.LockLeases || lock == election.LockConfigMapsLeases || lock == election.LockEndpointsLeases {
+			add([]string{"get", "create", "update"}, "coordination.k8s.io", "leases", "", leaseNamespace(), "leader election")
+		}
+		if cfg.Lease.HashPods {
+			add([]string{"list", "watch", "get", "create", "update", "delete"}, "coordination.k8s.io", "leases", "", leaseNamespace(),
+				"hash pods across replicas (--lease-hash-pods)")
+		}
+		if lock == election.LockConfigMaps || lock == election.LockConfigMapsLeases {
+			add([]string{"get", "create", "update"}, "", "configmaps", "", leaseNamespace(), "leader election ("+lock+" lock)")
+		}
+		if lock == election.LockEndpointsLeases {
+			add([]string{"get", "create", "update"}, "", "endpoints", "", leaseNamespace(), "leader election ("+lock+" lock)")
+		}
+	}
+	return perms
+}
+
+// checkPermissions asks the apiserver whether the agent's own identity holds each permission
+func checkPermissions(ctx context.Context, clientset kubernetes.Interface, perms []permission) []permissionResult {
+	results := make([]permissionResult, 0, len(perms))
+	for _, p := range perms {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   p.namespace,
+					Verb:        p.verb,
+					Group:       p.group,
+					Resource:    p.resource,
+					Subresource: p.subresource,
+				},
+			},
+		}
+		result := permissionResult{permission: p}
+		resp, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			result.detail = "check failed: " + err.Error()
+		} else {
+			result.allowed = resp.Status.Allowed
+			result.detail = strings.TrimSpace(resp.Status.Reason + " " + resp.Status.EvaluationError)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// runPermissionReport prints every required permission and whether it is held, returning 1 when any is missing
+func runPermissionReport(clientset kubernetes.Interface) int {
+	results := checkPermissions(context.Background(), clientset, requiredPermissions(isRunningInCluster()))
+	missing := 0
+	for _, r := range results {
+		mark := "[+]"
+		if !r.allowed {
+			mark = "[-]"
+			missing++
+		}
+		line := fmt.Sprintf("%s %s: needed to %s", mark, r.permission, r.reason)
+		if !r.allowed && r.detail != "" {
+			line += " (" + r.detail + ")"
+		}
+		fmt.Println(line)
+	}
+	if missing > 0 {
+		fmt.Printf("%d of %d permissions missing, see k8s-leader/clusterrole.yaml\n", missing, len(results))
+		return 1
+	}
+	fmt.Printf("all %d permissions granted\n", len(results))
+	return 0
+}
+
+// logMissingPermissions warns about each required permission the agent does not hold
+func logMissingPermissions(clientset kubernetes.Interface, inCluster bool) {
+	for _, r := range checkPermissions(context.Background(), clientset, requiredPermissions(inCluster)) {
+		if !r.allowed {
+			agentLog.Warn("Missing permission, run with --check-permissions for a full report",
+				"permission", r.permission.String(), "neededTo", r.reason, "detail", r.detail)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"adv-go/config"
+	"adv-go/model"
+	"time"
+)
+
+// healthRules classify every pod, the defaults unless healthRules are configured
+var healthRules = model.DefaultHealthRules()
+
+// setHealthRules replaces the default rules with the configured ones, when there are any
+func setHealthRules(configured []config.HealthRuleConfig) error {
+	if len(configured) == 0 {
+		return nil
+	}
+	rules := make([]model.HealthRule, len(configured))
+	for i, r := range configured {
+		rules[i] = model.HealthRule{
+			Name:        r.Name,
+			Health:      r.Health,
+			Phases:      r.Phases,
+			Conditions:  r.Conditions,
+			Reasons:     r.Reasons,
+			MinRestarts: r.MinRestarts,
+			MinAge:      r.MinAge.Duration,
+		}
+	}
+	if err := model.CheckHealthRules(rules); err != nil {
+		return err
+	}
+	healthRules = rules
+	return nil
+}
+
+// podHealth classifies the pod and returns the rule that decided, empty for Healthy pods no rule matched
+func podHealth(pod *model.Pod) (string, string) {
+	return model.ClassifyHealth(healthRules, pod, time.Now())
+}
+
+// describeHealth renders the pod's class with the deciding rule, e.g. "Degraded (not-ready)"
+func describeHealth(pod *model.Pod) string {
+	health, rule := podHealth(pod)
+	if rule == "" {
+		return health
+	}
+	return health + " (" + rule + ")"
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"adv-go/model"
+	"fmt"
+	"time"
+)
+
+// podStates remembers the last state of each pod so the transitions record mode only writes pods that changed
+var podStates = model.NewStateTracker()
+
+// lastFullRecords is when every pod's record was last written in the transitions record mode
+var lastFullRecords time.Time
+
+// writeAllPodRecords reports whether this pass writes a record for every pod rather than only the transitions
+func writeAllPodRecords() bool {
+	if cfg.PodRecordMode != "transitions" {
+		return true
+	}
+	interval := cfg.FullRecordInterval.Duration
+	if interval <= 0 || time.Since(lastFullRecords) < interval {
+		return false
+	}
+	lastFullRecords = time.Now()
+	return true
+}
+
+// describeTransition renders a pod's state change as a record suffix
+func describeTransition(t model.StateTransition) string {
+	return ", Transition: " + transitionStates(t)
+}
+
+// transitionStates renders a pod's state change as from -> to, New for pods created since the previous pass
+func transitionStates(t model.StateTransition) string {
+	if t.From == "" {
+		return "New -> " + t.To
+	}
+	return t.From + " -> " + t.To
+}
+
+// formatPodDeletion renders a pod that disappeared since the last pass as a record
+func formatPodDeletion(t model.StateTransition) string {
+	return fmt.Sprintf("Pod Name: %s, Namespace: %s, Phase: Deleted%s", t.Name, t.Namespace, describeTransition(t))
+}
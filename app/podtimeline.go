@@ -0,0 +1,107 @@
+package app
+
+import (
+	"adv-go/model"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// timelineEntry is one step in a pod's story
+type timelineEntry struct {
+	At     time.Time `json:"at"`
+	Pod    string    `json:"pod"`
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail"`
+}
+
+// podTimelineHandler returns the transitions, restarts, alerts and events of a pod and its predecessors as one ordered JSON list,
+// ?since= limits how far back it goes (default 24 hours)
+func podTimelineHandler(clientset kubernetes.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since := 24 * time.Hour
+		if s := r.URL.Query().Get("since"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			since = d
+		}
+
+		timeline, err := podTimeline(r.Context(), clientset, r.PathValue("namespace"), r.PathValue("name"), time.Now().Add(-since))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, timeline)
+	}
+}
+
+// podTimeline assembles the recorded history and the apiserver's events of a pod and its predecessors, oldest first
+func podTimeline(ctx context.Context, clientset kubernetes.Interface, namespace, pod string, since time.Time) ([]timelineEntry, error) {
+	timeline := []timelineEntry{}
+
+	pods := []string{pod}
+	if historyStore != nil {
+		var err error
+		if pods, err = podLineage(ctx, historyStore, namespace, pod); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range pods {
+		entries, err := podTimelineEntries(ctx, clientset, namespace, name, since)
+		if err != nil {
+			return nil, err
+		}
+		timeline = append(timeline, entries...)
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].At.Before(timeline[j].At) })
+	return timeline, nil
+}
+
+// podTimelineEntries returns the unordered timeline of a single pod
+func podTimelineEntries(ctx context.Context, clientset kubernetes.Interface, namespace, pod string, since time.Time) ([]timelineEntry, error) {
+	var timeline []timelineEntry
+	if historyStore != nil {
+		transitions, err := historyStore.PodHistory(ctx, namespace, pod, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range transitions {
+			timeline = append(timeline, timelineEntry{At: t.At, Pod: t.Pod, Kind: "Transition",
+				Detail: fmt.Sprintf("Phase: %s, Node: %s", t.Phase, t.Node)})
+		}
+
+		events, err := historyStore.PodEvents(ctx, namespace, pod, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			timeline = append(timeline, timelineEntry{At: e.At, Pod: e.Pod, Kind: e.Kind, Detail: e.Detail})
+		}
+	}
+
+	events, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Pod,involvedObject.name=" + pod,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range events.Items {
+		ev := model.NewEventFromCoreV1(&events.Items[i])
+		if ev.LastObserved.Before(since) {
+			continue
+		}
+		timeline = append(timeline, timelineEntry{At: ev.LastObserved, Pod: pod, Kind: "Event",
+			Detail: fmt.Sprintf("%s %s (x%d): %s", ev.Type, ev.Reason, ev.Count, ev.Note)})
+	}
+	return timeline, nil
+}
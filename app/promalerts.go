@@ -0,0 +1,41 @@
+package app
+
+import (
+	"adv-go/promql"
+	"adv-go/sink"
+	"adv-go/tracing"
+	"context"
+	"fmt"
+)
+
+// evaluatePromQLAlerts runs the configured PromQL alert rules and logs the ones above their threshold
+func evaluatePromQLAlerts(ctx context.Context) {
+	if len(cfg.Prometheus.Alerts) == 0 {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "evaluate promql alerts")
+	defer span.End()
+
+	client, err := promql.NewClient(cfg.Prometheus.URL)
+	if err != nil {
+		alertsLog.Error("Error creating Prometheus client", "err", err)
+		return
+	}
+
+	for _, rule := range cfg.Prometheus.Alerts {
+		value, err := client.Query(ctx, rule.Query)
+		if err != nil {
+			alertsLog.Error("Error evaluating alert", "alert", rule.Name, "err", err)
+			continue
+		}
+		if value <= rule.Threshold {
+			continue
+		}
+
+		status := tracing.Annotate(ctx, fmt.Sprintf("Alert: %s, Value: %g, Threshold: %g", rule.Name, value, rule.Threshold))
+		if err := sink.WritePriority(out, sink.PriorityAlert, []byte(status)); err != nil {
+			alertsLog.Error("Error writing to sink", "err", err)
+		}
+		alertsLog.Warn(status, "alert", rule.Name)
+	}
+}
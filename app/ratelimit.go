@@ -0,0 +1,71 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// configureRateLimits applies --kube-api-qps / --kube-api-burst to the client and puts the --list-watch-qps limiter
+// in front of its list and watch requests
+func configureRateLimits(config *rest.Config) {
+	config.QPS = float32(cfg.APIQPS)
+	config.Burst = cfg.APIBurst
+	if cfg.ListWatchQPS <= 0 {
+		return
+	}
+	limiter := flowcontrol.NewTokenBucketRateLimiter(float32(cfg.ListWatchQPS), max(cfg.ListWatchBurst, 1))
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return listWatchLimiter{next: rt, limiter: limiter}
+	})
+}
+
+// listWatchLimiter makes list and watch requests wait for its limiter, so the informers starting or relisting and
+// the reporting passes cannot use up the client's whole rate limit
+type listWatchLimiter struct {
+	next    http.RoundTripper
+	limiter flowcontrol.RateLimiter
+}
+
+func (l listWatchLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if verb := listOrWatch(req); verb != "" {
+		start := time.Now()
+		if err := l.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		metrics.ListWatchThrottle.WithLabelValues(verb).Observe(time.Since(start).Seconds())
+	}
+	return l.next.RoundTrip(req)
+}
+
+// listOrWatch returns "list" or "watch" for requests of a resource collection, "" for any other request. Paths are
+// /api/v1/[namespaces/<ns>/]<resource> for the core group and /apis/<group>/<version>/... for the others
+func listOrWatch(req *http.Request) string {
+	if req.Method != http.MethodGet {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return ""
+	}
+	// namespaces/<ns> is a namespace, namespaces/<ns>/<resource> a namespaced collection
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	if len(parts) != 1 || parts[0] == "" {
+		return ""
+	}
+	if req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1" {
+		return "watch"
+	}
+	return "list"
+}
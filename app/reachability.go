@@ -0,0 +1,258 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"adv-go/sink"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// reachabilityTimeout bounds each DNS lookup and connection attempt of the reachability check
+const reachabilityTimeout = 3 * time.Second
+
+// portCheck is the outcome of connecting to one port of a Service
+type portCheck struct {
+	Port   string
+	Result string
+}
+
+// serviceReachability is one row of the reachability matrix
+type serviceReachability struct {
+	Namespace string
+	Service   string
+	ClusterIP string
+	// DNS is "ok" when the Service name resolves to its ClusterIP, otherwise what went wrong
+	DNS   string
+	Ports []portCheck
+}
+
+// Reachable reports whether every check of the Service succeeded
+func (r serviceReachability) Reachable() bool {
+	if r.DNS != "ok" {
+		return false
+	}
+	for _, p := range r.Ports {
+		if p.Result != "ok" {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the row like "Reachability: shop/payments (10.96.0.12): dns ok, tcp/80 ok, tcp/443 refused"
+func (r serviceReachability) String() string {
+	checks := []string{"dns " + r.DNS}
+	for _, p := range r.Ports {
+		checks = append(checks, p.Port+" "+p.Result)
+	}
+	ip := r.ClusterIP
+	if ip == "" {
+		ip = "no ClusterIP"
+	}
+	return fmt.Sprintf("Reachability: %s/%s (%s): %s", r.Namespace, r.Service, ip, strings.Join(checks, ", "))
+}
+
+// checkServiceReachability resolves and connects to the configured Services and records the matrix, failures
+// with a Ready backend point at kube-proxy, the CNI or cluster DNS rather than the workload
+func checkServiceReachability(ctx context.Context, clientset kubernetes.Interface) {
+	for _, r := range probeServices(ctx, clientset, cfg.ReachabilityTargets) {
+		metrics.ServiceReachable.WithLabelValues(r.Namespace, r.Service, "dns").Set(boolGauge(r.DNS == "ok"))
+		for _, p := range r.Ports {
+			metrics.ServiceReachable.WithLabelValues(r.Namespace, r.Service, p.Port).Set(boolGauge(p.Result == "ok"))
+		}
+		status := r.String()
+		if err := sink.WritePriority(out, sink.PriorityHeartbeat, []byte(status)); err != nil {
+			collectorLog.Error("Error writing to sink", "err", err)
+		}
+		if r.Reachable() {
+			collectorLog.Info(status, "namespace", r.Namespace, "service", r.Service)
+		} else {
+			collectorLog.Warn(status, "namespace", r.Namespace, "service", r.Service)
+		}
+	}
+}
+
+// probeServices checks each "namespace/service" target in turn
+func probeServices(ctx context.Context, clientset kubernetes.Interface, targets []string) []serviceReachability {
+	rows := make([]serviceReachability, 0, len(targets))
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		namespace, name, _ := strings.Cut(target, "/")
+		rows = append(rows, probeService(ctx, clientset, namespace, name))
+	}
+	return rows
+}
+
+// probeService resolves the Service's cluster DNS name and connects to its ClusterIP on every TCP port
+func probeService(ctx context.Context, clientset kubernetes.Interface, namespace, name string) serviceReachability {
+	r := serviceReachability{Namespace: namespace, Service: name}
+	svc, err := retryCall(ctx, "get service", func(ctx context.Context) (*v1.Service, error) {
+		return clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	})
+	if apierrors.IsNotFound(err) {
+		r.DNS = "service not found"
+		return r
+	}
+	if err != nil {
+		r.DNS = "error getting service: " + err.Error()
+		return r
+	}
+	if svc.Spec.ClusterIP != v1.ClusterIPNone {
+		r.ClusterIP = svc.Spec.ClusterIP
+	}
+
+	host := fmt.Sprintf("%s.%s.svc.%s", name, namespace, cfg.ClusterDomain)
+	lookupCtx, cancel := context.WithTimeout(ctx, reachabilityTimeout)
+	addrs, err := net.DefaultResolver.LookupHost(lookupCtx, host)
+	cancel()
+	switch {
+	case err != nil:
+		r.DNS = describeDialError(err)
+	case r.ClusterIP != "" && !containsIP(addrs, svc.Spec.ClusterIPs):
+		r.DNS = "resolves to " + strings.Join(addrs, ",") + " instead of the ClusterIP"
+	default:
+		r.DNS = "ok"
+	}
+	if svc.Spec.Type == v1.ServiceTypeExternalName {
+		return r
+	}
+
+	// Headless Services have no virtual IP to program, connect to the first address the name resolves to
+	target := r.ClusterIP
+	if target == "" && len(addrs) > 0 {
+		target = addrs[0]
+	}
+	for _, port := range svc.Spec.Ports {
+		if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+			continue
+		}
+		check := portCheck{Port: "tcp/" + strconv.Itoa(int(port.Port))}
+		if target == "" {
+			check.Result = "no address"
+		} else {
+			check.Result = dialService(ctx, net.JoinHostPort(target, strconv.Itoa(int(port.Port))))
+		}
+		r.Ports = append(r.Ports, check)
+	}
+	return r
+}
+
+// dialService connects to addr and closes the connection right away, returning "ok" or why it failed
+func dialService(ctx context.Context, addr string) string {
+	dialer := net.Dialer{Timeout: reachabilityTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return describeDialError(err)
+	}
+	conn.Close()
+	return "ok"
+}
+
+// describeDialError shortens lookup and connection errors to what they mean for the matrix
+func describeDialError(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return "nxdomain"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	case errors.Is(err, context.DeadlineExceeded), os.IsTimeout(err):
+		return "timeout"
+	default:
+		return err.Error()
+	}
+}
+
+// containsIP reports whether any resolved address is one of the Service's ClusterIPs
+func containsIP(addrs, clusterIPs []string) bool {
+	for _, a := range addrs {
+		for _, ip := range clusterIPs {
+			if a == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// boolGauge is the gauge value of a check outcome
+func boolGauge(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// newReachabilityCommand creates the reachability command
+func newReachabilityCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reachability [namespace/service...]",
+		Short: "Resolve and connect to Services and print the reachability matrix, exiting 1 when any check fails",
+		Long: `Resolve and connect to Services and print the reachability matrix, exiting 1 when any check fails.
+
+The checks run from where the command runs, so run it in a pod to test the cluster network. Without arguments
+the --reachability-targets are checked.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets := cfg.ReachabilityTargets
+			if len(args) > 0 {
+				targets = args
+			}
+			if len(targets) == 0 {
+				return errors.New("no Services to check, pass namespace/service arguments or set --reachability-targets")
+			}
+			for _, target := range targets {
+				if ns, name, ok := strings.Cut(target, "/"); !ok || ns == "" || name == "" {
+					return fmt.Errorf("invalid target %q, expected namespace/service", target)
+				}
+			}
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(printReachability(probeServices(context.Background(), clientset, targets)))
+		},
+	}
+}
+
+// printReachability prints the matrix, one row per Service and one column per check
+func printReachability(rows []serviceReachability) int {
+	code := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tCLUSTER-IP\tDNS\tPORTS")
+	for _, r := range rows {
+		ip := r.ClusterIP
+		if ip == "" {
+			ip = "-"
+		}
+		ports := make([]string, 0, len(r.Ports))
+		for _, p := range r.Ports {
+			ports = append(ports, p.Port+" "+p.Result)
+		}
+		if len(ports) == 0 {
+			ports = append(ports, "-")
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\t%s\n", r.Namespace, r.Service, ip, r.DNS, strings.Join(ports, ", "))
+		if !r.Reachable() {
+			code = 1
+		}
+	}
+	w.Flush()
+	return code
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podInformers are the pod informers of the read cache by namespace. They run for the life of the process on every
+// replica, leading or not, so followers serve the read API and the pod stream from their own caches. A leadership
+// term only adds its handlers to them
+var podInformers = make(map[string]cache.SharedIndexInformer)
+
+// readCacheNamespaces are the namespaces the read cache watches: the configured ones, every namespace without any
+// or with a namespace selector. A shard's namespaces are a subset, the read cache of every replica covers all shards
+func readCacheNamespaces() []string {
+	if len(cfg.Namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return cfg.Namespaces
+}
+
+// startReadCache starts the pod and owner informers feeding the pod store and the pod stream, and has the stream
+// accept subscribers once they synced. It runs once, before the replica campaigns for the Lease
+func startReadCache(clientset kubernetes.Interface) {
+	if selectingNamespaces() {
+		watchNamespaces(clientset)
+	}
+	watchOwners()
+	var synced []cache.InformerSynced
+	for _, namespace := range readCacheNamespaces() {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = cfg.LabelSelector
+				opts.FieldSelector = cfg.FieldSelector
+			}),
+		)
+		podInformer := factory.Core().V1().Pods().Informer()
+		// Every pod is cached whole, also those of other replicas when hashing pods: ownership changes with each
+		// rebalance, so it is applied where the pods are reported rather than to the cache
+		podInformer.AddEventHandler(keptPodHandler(podStore.Handler(podInformer)))
+		podInformer.AddEventHandler(keptPodHandler(podStreamHandler(podInformer)))
+		addCacheCheck("pods/"+namespace, podInformer.HasSynced)
+		podInformers[namespace] = podInformer
+		synced = append(synced, podInformer.HasSynced)
+		factory.Start(nil)
+	}
+	if podStream != nil {
+		go func() {
+			cache.WaitForCacheSync(nil, synced...)
+			podStream.Accept()
+			watchLog.Info("Pod cache synced, serving the pod stream")
+		}()
+	}
+}
+
+// podStreamHandler streams the transitions of the cached pods of the monitored namespaces, on every replica. Adds
+// delivered during the initial list describe old state and are not streamed
+func podStreamHandler(informer cache.SharedIndexInformer) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok && informer.HasSynced() && namespaceMonitored(pod.Namespace) {
+				publishPodStatus(nil, pod)
+			}
+		},
+		UpdateFunc: func(oldObj, obj interface{}) {
+			old, ok := oldObj.(*v1.Pod)
+			pod, ok2 := obj.(*v1.Pod)
+			if ok && ok2 && namespaceMonitored(pod.Namespace) {
+				publishPodStatus(old, pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok && namespaceMonitored(pod.Namespace) {
+				publishPodStatus(pod, nil)
+			}
+		},
+	}
+}
+
+// leaderPodHandler adds the term's handler to every pod informer of the read cache, passing on the pods of the
+// monitored namespaces only, and removes it once the term ends
+func leaderPodHandler(ctx context.Context, handler cache.ResourceEventHandler) {
+	filtered := cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			pod, ok := obj.(*v1.Pod)
+			return ok && namespaceMonitored(pod.Namespace)
+		},
+		Handler: ownedPodHandler(handler),
+	}
+	for namespace, informer := range podInformers {
+		registration, err := informer.AddEventHandler(filtered)
+		if err != nil {
+			watchLog.Error("Failed to watch the cached pods", "namespace", namespace, "err", err)
+			continue
+		}
+		// The handler is synced once the informer replayed its cache to it
+		addSyncCheck("pods/"+namespace, registration.HasSynced)
+		goLeader(func() {
+			<-ctx.Done()
+			if err := informer.RemoveEventHandler(registration); err != nil {
+				watchLog.Error("Failed to stop watching the cached pods", "namespace", namespace, "err", err)
+			}
+		})
+	}
+}
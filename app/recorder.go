@@ -0,0 +1,29 @@
+package app
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// recorder emits Kubernetes Events for unhealthy pods, nil when event emission is disabled
+var recorder record.EventRecorder
+
+// startEventRecorder creates the recorder that attaches findings to pod objects
+func startEventRecorder(clientset kubernetes.Interface) record.EventBroadcaster {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	recorder = broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "pod-logger"})
+	return broadcaster
+}
+
+// recordPodProblem emits a Warning event on the pod so the finding shows up in kubectl describe
+func recordPodProblem(pod *v1.Pod, reason, message string) {
+	if recorder == nil {
+		return
+	}
+	// The broadcaster aggregates repeats of the same event, so emitting every pass only bumps its count
+	recorder.Event(pod, v1.EventTypeWarning, reason, message)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/remediation"
+	"adv-go/sink"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// remediator deletes crash looping pods within the remediation budgets. It outlives leadership terms, so a new term
+// of the same replica does not get a fresh budget
+var remediator *remediation.Executor
+
+// remediatedKinds are the controllers that replace a deleted pod
+var remediatedKinds = []string{"Deployment", "ReplicaSet", "StatefulSet", "DaemonSet"}
+
+// newRemediator creates the executor enforcing the configured budgets and circuit breaker
+func newRemediator() *remediation.Executor {
+	r := cfg.Remediation
+	return remediation.NewExecutor(remediation.Config{
+		Window:          r.Window.Duration,
+		GlobalBudget:    r.GlobalBudget,
+		NamespaceBudget: r.NamespaceBudget,
+		ErrorThreshold:  r.ErrorThreshold,
+		MinSamples:      r.MinSamples,
+		Cooldown:        r.Cooldown.Duration,
+	})
+}
+
+// remediablePod reports whether the pod is crash looping with at least --remediation-min-restarts restarts and is
+// managed by a controller that replaces it once deleted
+func remediablePod(pod *model.Pod) bool {
+	if pod.Object().DeletionTimestamp != nil || int(pod.RestartCount()) < cfg.Remediation.MinRestarts ||
+		!slices.Contains(pod.WaitingReasons(), "CrashLoopBackOff") {
+		return false
+	}
+	kind, _, _ := strings.Cut(pod.Workload(), "/")
+	return slices.Contains(remediatedKinds, kind)
+}
+
+// remediatePods deletes the pass's crash looping pods so their controllers replace them. Each deletion counts against
+// the global and namespace budgets, and once too many fail the circuit breaker halts them all for the cooldown
+func remediatePods(ctx context.Context, clientset kubernetes.Interface, pods []v1.Pod) {
+	if remediator == nil || cfg.ReadOnly {
+		return
+	}
+	for i := range pods {
+		pod := &pods[i]
+		p := model.NewPod(pod)
+		if !remediablePod(p) {
+			continue
+		}
+		err := remediator.Execute(ctx, remediation.Action{
+			Name:      "delete pod " + pod.Name,
+			Namespace: pod.Namespace,
+			Run: func(ctx context.Context) error {
+				if cfg.Remediation.DryRun {
+					return nil
+				}
+				// The UID precondition leaves a replacement of the same name alone
+				err := clientset.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{Preconditions: metav1.NewUIDPreconditions(string(pod.UID))})
+				if apierrors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			},
+		})
+		fields := podFields(pod)
+		switch {
+		case errors.Is(err, remediation.ErrCircuitOpen):
+			metrics.Remediations.WithLabelValues(pod.Namespace, "circuit-open").Inc()
+			alertsLog.Warn("Remediation halted after too many failed deletions, leaving the crash looping pods of this pass", fields...)
+			return
+		case errors.Is(err, remediation.ErrBudgetExceeded):
+			metrics.Remediations.WithLabelValues(pod.Namespace, "budget-exceeded").Inc()
+			alertsLog.Warn("Remediation budget exhausted, leaving crash looping pod", fields...)
+			continue
+		case err != nil:
+			metrics.Remediations.WithLabelValues(pod.Namespace, "failed").Inc()
+			alertsLog.Error("Error deleting crash looping pod", append(fields, "err", err)...)
+			continue
+		}
+
+		action, result := "deleted", "deleted"
+		if cfg.Remediation.DryRun {
+			action, result = "would delete", "dry-run"
+		}
+		metrics.Remediations.WithLabelValues(pod.Namespace, result).Inc()
+		record := fmt.Sprintf("Remediation: %s pod %s/%s of %s, crash looping after %d restarts",
+			action, pod.Namespace, pod.Name, p.Workload(), p.RestartCount())
+		if err := sink.WritePriority(out, sink.PriorityAlert, []byte(record)); err != nil {
+			alertsLog.Error("Error writing to sink", "err", err)
+		}
+		alertsLog.Warn(record, fields...)
+	}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"adv-go/config"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// crashLoopingPod is a pod of the ReplicaSet owner, none for a bare pod, waiting in CrashLoopBackOff
+func crashLoopingPod(namespace, name, owner string, restarts int32) *v1.Pod {
+	pod := testPod(namespace, name)
+	if owner != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner + "-5f7c9", Controller: &controller}}
+		pod.Labels = map[string]string{"pod-template-hash": "5f7c9"}
+	}
+	pod.Status.ContainerStatuses[0] = v1.ContainerStatus{
+		Name: "app", Image: "app:1", RestartCount: restarts,
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}
+	return pod
+}
+
+// testRemediator gives the test a fresh executor with the configured budgets
+func testRemediator(t *testing.T) {
+	saved := remediator
+	t.Cleanup(func() { remediator = saved })
+	remediator = newRemediator()
+}
+
+// remainingPods lists the namespace/name of the pods left in the fake cluster
+func remainingPods(t *testing.T, client *fake.Clientset) []string {
+	t.Helper()
+	list, err := client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pod := range list.Items {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestRemediatePods(t *testing.T) {
+	recorded := testAgent(t, func(c *config.Config) { c.Remediation.NamespaceBudget = 1 })
+	testRemediator(t)
+	pods := []*v1.Pod{
+		crashLoopingPod("shop", "checkout-5f7c9-a", "checkout", 7),
+		crashLoopingPod("shop", "checkout-5f7c9-b", "checkout", 7),
+		crashLoopingPod("shop", "cart-5f7c9-a", "cart", 2),
+		crashLoopingPod("shop", "debug", "", 9),
+		crashLoopingPod("payments", "api-5f7c9-a", "api", 5),
+		testPod("payments", "web-0"),
+	}
+	client := fake.NewSimpleClientset()
+	var items []v1.Pod
+	for _, pod := range pods {
+		client.Tracker().Add(pod)
+		items = append(items, *pod)
+	}
+
+	remediatePods(context.Background(), client, items)
+
+	// The shop budget covers one of its pods, pods with few restarts and bare pods are left alone
+	want := []string{"payments/web-0", "shop/cart-5f7c9-a", "shop/checkout-5f7c9-b", "shop/debug"}
+	if got := remainingPods(t, client); !slices.Equal(got, want) {
+		t.Errorf("pods left = %v, want %v", got, want)
+	}
+	records := recorded.written()
+	if len(records) != 2 || !strings.HasPrefix(records[0], "Remediation: deleted pod shop/checkout-5f7c9-a of Deployment/checkout") ||
+		!strings.HasPrefix(records[1], "Remediation: deleted pod payments/api-5f7c9-a") {
+		t.Errorf("records = %q, want one per deleted pod", records)
+	}
+}
+
+func TestRemediatePodsDryRun(t *testing.T) {
+	recorded := testAgent(t, func(c *config.Config) { c.Remediation.DryRun = true })
+	testRemediator(t)
+	pod := crashLoopingPod("shop", "checkout-5f7c9-a", "checkout", 7)
+	client := fake.NewSimpleClientset(pod)
+
+	remediatePods(context.Background(), client, []v1.Pod{*pod})
+	if got := remainingPods(t, client); len(got) != 1 {
+		t.Errorf("dry run deleted pods, left %v", got)
+	}
+	if records := recorded.written(); len(records) != 1 || !strings.HasPrefix(records[0], "Remediation: would delete pod shop/checkout-5f7c9-a") {
+		t.Errorf("records = %q, want the deletion that would be made", records)
+	}
+}
+
+func TestRemediatePodsReadOnly(t *testing.T) {
+	recorded := testAgent(t, func(c *config.Config) { c.ReadOnly = true })
+	testRemediator(t)
+	pod := crashLoopingPod("shop", "checkout-5f7c9-a", "checkout", 7)
+	client := fake.NewSimpleClientset(pod)
+
+	remediatePods(context.Background(), client, []v1.Pod{*pod})
+	if got := remainingPods(t, client); len(got) != 1 || len(recorded.written()) != 0 {
+		t.Errorf("read-only remediation left %v and wrote %q", got, recorded.written())
+	}
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// replayOptions select the recorded transitions to replay and how fast
+type replayOptions struct {
+	from, to  string
+	namespace string
+	pod       string
+	// speed divides the time between transitions, 0 writes them without waiting
+	speed float64
+}
+
+// replayedTransition is the JSON record of a replayed transition
+type replayedTransition struct {
+	Replayed   bool      `json:"replayed"`
+	ObservedAt time.Time `json:"observedAt"`
+	Namespace  string    `json:"namespace"`
+	Pod        string    `json:"pod"`
+	Node       string    `json:"node,omitempty"`
+	Phase      string    `json:"phase"`
+}
+
+// newReplayCommand creates the replay command
+func newReplayCommand() *cobra.Command {
+	opts := replayOptions{}
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Write the transitions recorded in the history store within a time range to the sinks again",
+		Long: `Write the transitions recorded in the history store within a time range to the sinks again.
+
+The transitions are written to the sinks selected with --sink in the order they were observed, --speed times faster
+than they happened, to reproduce an incident's timeline in a log pipeline or dashboard. --from and --to are RFC 3339
+times, durations ago such as 2h, or now.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.History.DSN == "" {
+				return errors.New("replay requires --history-dsn")
+			}
+			if opts.speed < 0 {
+				return errors.New("--speed must not be negative")
+			}
+			return exitCode(runReplay(opts))
+		},
+	}
+	cmd.Flags().StringVar(&opts.from, "from", "1h", "start of the range")
+	cmd.Flags().StringVar(&opts.to, "to", "now", "end of the range")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "replay only this namespace's pods")
+	cmd.Flags().StringVar(&opts.pod, "pod", "", "replay only this pod's transitions")
+	cmd.Flags().Float64Var(&opts.speed, "speed", 1, "how many times faster than observed to replay, 0 for as fast as the sinks take them")
+	return cmd
+}
+
+// runReplay writes the recorded transitions of the range to the sinks, pacing them by the time between them
+func runReplay(opts replayOptions) int {
+	from, err := parseHistoryTime(opts.from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --from: %v\n", err)
+		return 2
+	}
+	to, err := parseHistoryTime(opts.to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --to: %v\n", err)
+		return 2
+	}
+	if to.Before(from) {
+		fmt.Fprintln(os.Stderr, "--to is before --from")
+		return 2
+	}
+
+	store, err := history.Open(cfg.History.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening history store: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	recorded, err := store.TransitionsBetween(ctx, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error querying history: %v\n", err)
+		return 1
+	}
+	var transitions []history.Transition
+	for _, t := range recorded {
+		if (opts.namespace == "" || t.Namespace == opts.namespace) && (opts.pod == "" || t.Pod == opts.pod) {
+			transitions = append(transitions, t)
+		}
+	}
+
+	replayOut, err := openSinks()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening sinks: %v\n", err)
+		return 1
+	}
+	defer replayOut.Close()
+
+	fmt.Fprintf(os.Stderr, "Replaying %d transitions from %s to %s\n", len(transitions), format.Time(from), format.Time(to))
+	code := 0
+	for i, t := range transitions {
+		if i > 0 && opts.speed > 0 {
+			wait := time.Duration(float64(t.At.Sub(transitions[i-1].At)) / opts.speed)
+			select {
+			case <-ctx.Done():
+				fmt.Fprintf(os.Stderr, "Replay stopped after %d of %d transitions\n", i, len(transitions))
+				return 1
+			case <-time.After(wait):
+			}
+		}
+		record, err := replayRecord(t)
+		if err == nil {
+			err = replayOut.Write(record)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing the transition of %s/%s: %v\n", t.Namespace, t.Pod, err)
+			code = 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Replayed %d transitions\n", len(transitions))
+	return code
+}
+
+// replayRecord renders a transition as a JSON record with --record-format=json, as a text record otherwise
+func replayRecord(t history.Transition) ([]byte, error) {
+	if cfg.RecordFormat == "json" {
+		return json.Marshal(replayedTransition{
+			Replayed: true, ObservedAt: t.At.UTC(), Namespace: t.Namespace, Pod: t.Pod, Node: t.Node, Phase: t.Phase,
+		})
+	}
+	return []byte(fmt.Sprintf("Replayed Transition: %s %s/%s Phase: %s, Node: %s", format.Time(t.At), t.Namespace,
+		t.Pod, t.Phase, orNone(t.Node))), nil
+}
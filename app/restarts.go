@@ -0,0 +1,33 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/history"
+	"adv-go/model"
+	"adv-go/sink"
+	"fmt"
+	"time"
+)
+
+// restarts remembers previous restart counts so only bursts of restarts raise an alert
+var restarts *model.RestartTracker
+
+// checkRestarts alerts when the pod restarted more than the threshold within the restart alert window
+func checkRestarts(pod *model.Pod) {
+	if cfg.RestartAlertThreshold <= 0 {
+		return
+	}
+	delta := restarts.Observe(pod.UID(), pod.RestartCount(), time.Now())
+	if int(delta) <= cfg.RestartAlertThreshold {
+		return
+	}
+	restarts.Reset(pod.UID())
+
+	alert := fmt.Sprintf("ALERT: pod %s/%s restarted %d times within %s (total %d)",
+		pod.Namespace(), pod.Name(), delta, format.Age(cfg.RestartAlertWindow.Duration), pod.RestartCount())
+	if err := sink.WritePriority(out, sink.PriorityAlert, []byte(alert)); err != nil {
+		alertsLog.Error("Error writing to sink", "err", err)
+	}
+	alertsLog.Warn(alert, append(modelPodFields(pod), "restarts", delta)...)
+	recordPodEvent(pod.Namespace(), pod.Name(), history.PodAlert, alert)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"adv-go/tracing"
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// maxRetryBackoff caps the delay between two attempts
+const maxRetryBackoff = 30 * time.Second
+
+// retryCall runs the apiserver call, retrying transient failures with exponential backoff and jitter up to
+// --max-retries times before returning the last error. The call and its attempts are traced as one span
+func retryCall[T any](ctx context.Context, call string, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, span := tracing.Start(ctx, call)
+	defer span.End()
+
+	backoff := wait.Backoff{
+		Duration: cfg.RetryBackoff.Duration,
+		Factor:   2,
+		Jitter:   0.5,
+		Steps:    cfg.MaxRetries + 1,
+		Cap:      maxRetryBackoff,
+	}
+	for attempt := 0; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil || attempt >= cfg.MaxRetries || !retryable(err) {
+			span.SetAttributes(attribute.Int("attempts", attempt+1))
+			tracing.Fail(span, err)
+			return result, err
+		}
+		delay := backoff.Step()
+		metrics.APIRetries.WithLabelValues(call).Inc()
+		collectorLog.Warn("Apiserver call failed, retrying", "call", call, "attempt", attempt+1, "maxRetries", cfg.MaxRetries,
+			"backoff", delay.String(), "err", err)
+		time.Sleep(delay)
+	}
+}
+
+// retryable reports whether the error is a transient apiserver or network failure worth retrying
+func retryable(err error) bool {
+	switch {
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err):
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, net.ErrClosed)
+}
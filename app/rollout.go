@@ -0,0 +1,426 @@
+package app
+
+import (
+	"adv-go/analysis"
+	"adv-go/format"
+	"adv-go/model"
+	"adv-go/promql"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// rolloutState is a single observation of a workload rollout
+type rolloutState struct {
+	message  string
+	selector *metav1.LabelSelector
+	done     bool
+	// isNew reports whether a pod belongs to the revision being rolled out, nil when it cannot be told
+	isNew func(pod *v1.Pod) bool
+}
+
+// rolloutOptions configure how a rollout is followed and analyzed
+type rolloutOptions struct {
+	namespace      string
+	timeout        time.Duration
+	pollInterval   time.Duration
+	analyze        bool
+	analysisOutput string
+	criteria       analysis.Criteria
+	queries        metricQueries
+}
+
+// newRolloutCommand creates the rollout command and its watch subcommand
+func newRolloutCommand() *cobra.Command {
+	opts := rolloutOptions{criteria: analysis.DefaultCriteria()}
+	watch := &cobra.Command{
+		Use:   "watch <kind>/<name>",
+		Short: "Follow a workload's rollout until it completes, fails or times out",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runRolloutWatch(clientset, args[0], opts))
+		},
+	}
+	watch.Flags().StringVarP(&opts.namespace, "namespace", "n", "default", "namespace of the workload")
+	watch.Flags().DurationVar(&opts.timeout, "timeout", 0, "give up after this duration, 0 to wait until the rollout ends")
+	watch.Flags().DurationVar(&opts.pollInterval, "poll-interval", 2*time.Second, "how often to check the rollout")
+	watch.Flags().BoolVar(&opts.analyze, "analyze", false, "compare the health of the new revision against the old one and exit non-zero if it is worse")
+	watch.Flags().StringVar(&opts.analysisOutput, "analysis-output", "", "file to write the JSON analysis record to, stdout when empty")
+	watch.Flags().Float64Var(&opts.criteria.MaxRestartsPerPodDelta, "max-restart-delta", opts.criteria.MaxRestartsPerPodDelta, "extra restarts per pod tolerated in the new revision")
+	watch.Flags().Float64Var(&opts.criteria.MaxFlapsPerPodDelta, "max-flap-delta", opts.criteria.MaxFlapsPerPodDelta, "extra readiness flaps per pod tolerated in the new revision")
+	watch.Flags().Float64Var(&opts.criteria.MaxMetricIncrease, "max-metric-increase", opts.criteria.MaxMetricIncrease, "relative increase of each PromQL metric tolerated in the new revision")
+	watch.Flags().Var(&opts.queries, "metric", "name=PromQL query compared between revisions, may reference {{.Namespace}}, {{.Workload}}, {{.Role}} and {{.Revision}} (repeatable)")
+
+	cmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "Follow workload rollouts",
+	}
+	cmd.AddCommand(watch)
+	return cmd
+}
+
+// runRolloutWatch follows a workload's rollout until it completes, fails or times out
+func runRolloutWatch(clientset kubernetes.Interface, target string, opts rolloutOptions) int {
+	kind, name, ok := strings.Cut(target, "/")
+	if !ok || name == "" {
+		fmt.Fprintf(os.Stderr, "invalid workload %q, expected <kind>/<name>\n", target)
+		return 2
+	}
+	check, err := rolloutChecker(kind)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if opts.pollInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "--poll-interval must be positive")
+		return 2
+	}
+
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(opts.pollInterval)
+	defer ticker.Stop()
+
+	var collector *analysis.Collector
+	if opts.analyze {
+		collector = analysis.NewCollector()
+	}
+	analysisMetrics, err := opts.queries.metrics()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	// finish runs the analysis once the rollout ended, a failed analysis fails the command
+	finish := func(code int) int {
+		if collector == nil {
+			return code
+		}
+		record := analysis.Analyze(context.Background(), collector, opts.namespace, name, opts.criteria, analysisMetrics)
+		if err := writeAnalysis(record, opts.analysisOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing analysis: %v\n", err)
+			return 1
+		}
+		if code == 0 && !record.Passed {
+			fmt.Fprintf(os.Stderr, "analysis of %s failed\n", target)
+			return 1
+		}
+		return code
+	}
+
+	var lastMessage string
+	lastPods := make(map[string]string)
+	for {
+		state, err := check(ctx, clientset, opts.namespace, name)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return finish(rolloutTimedOut(target, opts.timeout, lastMessage))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rollout of %s failed: %v\n", target, err)
+			return finish(1)
+		}
+		if state.message != lastMessage {
+			fmt.Println(state.message)
+			lastMessage = state.message
+		}
+		reportPodProgress(ctx, clientset, opts.namespace, state, lastPods, collector)
+
+		if state.done {
+			fmt.Printf("rollout of %s complete\n", target)
+			return finish(0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return finish(rolloutTimedOut(target, opts.timeout, lastMessage))
+		case <-ticker.C:
+		}
+	}
+}
+
+// metricQueries collects the repeatable --metric name=query flag
+type metricQueries []string
+
+func (m *metricQueries) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *metricQueries) Type() string {
+	return "name=query"
+}
+
+func (m *metricQueries) Set(value string) error {
+	if name, query, ok := strings.Cut(value, "="); !ok || name == "" || query == "" {
+		return fmt.Errorf("expected name=query, got %q", value)
+	}
+	*m = append(*m, value)
+	return nil
+}
+
+// metrics turns the queries into analysis hooks against the configured Prometheus
+func (m metricQueries) metrics() ([]analysis.Metric, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	if cfg.Prometheus.URL == "" {
+		return nil, fmt.Errorf("--metric requires --prometheus-url")
+	}
+	client, err := promql.NewClient(cfg.Prometheus.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]analysis.Metric, 0, len(m))
+	for _, q := range m {
+		name, query, _ := strings.Cut(q, "=")
+		metric, err := promql.NewAnalysisMetric(client, name, query)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// writeAnalysis writes the analysis record as JSON to the given file, or stdout when empty
+func writeAnalysis(record *analysis.Record, path string) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// rolloutTimedOut reports the last known progress of a rollout that did not finish in time
+func rolloutTimedOut(target string, timeout time.Duration, lastMessage string) int {
+	fmt.Fprintf(os.Stderr, "rollout of %s timed out after %s: %s\n", target, format.Age(timeout), lastMessage)
+	return 1
+}
+
+// rolloutChecker returns the status function for a workload kind, accepting kubectl style short names
+func rolloutChecker(kind string) (func(context.Context, kubernetes.Interface, string, string) (*rolloutState, error), error) {
+	switch strings.ToLower(kind) {
+	case "deploy", "deployment", "deployments":
+		return deploymentRollout, nil
+	case "sts", "statefulset", "statefulsets":
+		return statefulSetRollout, nil
+	case "ds", "daemonset", "daemonsets":
+		return daemonSetRollout, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %q, expected deploy, sts or ds", kind)
+	}
+}
+
+// deploymentRollout reports a deployment's rollout progress the same way kubectl rollout status does
+func deploymentRollout(ctx context.Context, clientset kubernetes.Interface, namespace, name string) (*rolloutState, error) {
+	d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	state := &rolloutState{selector: d.Spec.Selector, isNew: newReplicaSetPods(ctx, clientset, d)}
+
+	if d.Generation > d.Status.ObservedGeneration {
+		state.message = "Waiting for deployment spec update to be observed..."
+		return state, nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return nil, fmt.Errorf("deployment %q exceeded its progress deadline: %s", name, c.Message)
+		}
+	}
+
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.UpdatedReplicas < desired:
+		state.message = fmt.Sprintf("Waiting for rollout to finish: %d out of %d new replicas have been updated...", d.Status.UpdatedReplicas, desired)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		state.message = fmt.Sprintf("Waiting for rollout to finish: %d old replicas are pending termination...", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		state.message = fmt.Sprintf("Waiting for rollout to finish: %d of %d updated replicas are available...", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	default:
+		state.message = fmt.Sprintf("deployment %q successfully rolled out", name)
+		state.done = true
+	}
+	return state, nil
+}
+
+// newReplicaSetPods matches pods of the deployment's current revision by their pod-template-hash
+func newReplicaSetPods(ctx context.Context, clientset kubernetes.Interface, d *appsv1.Deployment) func(*v1.Pod) bool {
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil
+	}
+	replicaSets, err := clientset.AppsV1().ReplicaSets(d.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil
+	}
+
+	const revisionAnnotation = "deployment.kubernetes.io/revision"
+	for _, rs := range replicaSets.Items {
+		if !metav1.IsControlledBy(&rs, d) || rs.Annotations[revisionAnnotation] != d.Annotations[revisionAnnotation] {
+			continue
+		}
+		hash := rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		return func(pod *v1.Pod) bool {
+			return pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey] == hash
+		}
+	}
+	return nil
+}
+
+// statefulSetRollout reports a statefulset's rolling update progress
+func statefulSetRollout(ctx context.Context, clientset kubernetes.Interface, namespace, name string) (*rolloutState, error) {
+	s, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if s.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return nil, fmt.Errorf("statefulset %q uses the %s strategy, only RollingUpdate can be watched", name, s.Spec.UpdateStrategy.Type)
+	}
+
+	updateRevision := s.Status.UpdateRevision
+	state := &rolloutState{
+		selector: s.Spec.Selector,
+		isNew: func(pod *v1.Pod) bool {
+			return pod.Labels[appsv1.StatefulSetRevisionLabel] == updateRevision
+		},
+	}
+
+	desired := int32(1)
+	if s.Spec.Replicas != nil {
+		desired = *s.Spec.Replicas
+	}
+	switch {
+	case s.Status.ObservedGeneration == 0 || s.Generation > s.Status.ObservedGeneration:
+		state.message = "Waiting for statefulset spec update to be observed..."
+	case s.Status.ReadyReplicas < desired:
+		state.message = fmt.Sprintf("Waiting for %d pods to be ready...", desired-s.Status.ReadyReplicas)
+	case s.Status.UpdatedReplicas < desired:
+		state.message = fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...", s.Status.UpdatedReplicas, desired)
+	default:
+		state.message = fmt.Sprintf("statefulset %q rolled out to revision %s", name, updateRevision)
+		state.done = true
+	}
+	return state, nil
+}
+
+// daemonSetRollout reports a daemonset's rolling update progress
+func daemonSetRollout(ctx context.Context, clientset kubernetes.Interface, namespace, name string) (*rolloutState, error) {
+	ds, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	state := &rolloutState{selector: ds.Spec.Selector}
+
+	switch {
+	case ds.Generation > ds.Status.ObservedGeneration:
+		state.message = "Waiting for daemon set spec update to be observed..."
+	case ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled:
+		state.message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d out of %d new pods have been updated...", name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+	case ds.Status.NumberAvailable < ds.Status.DesiredNumberScheduled:
+		state.message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d of %d updated pods are available...", name, ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)
+	default:
+		state.message = fmt.Sprintf("daemon set %q successfully rolled out", name)
+		state.done = true
+	}
+	return state, nil
+}
+
+// reportPodProgress prints a line for every workload pod whose state changed since the last check
+func reportPodProgress(ctx context.Context, clientset kubernetes.Interface, namespace string, state *rolloutState, last map[string]string, collector *analysis.Collector) {
+	selector, err := metav1.LabelSelectorAsSelector(state.selector)
+	if err != nil {
+		return
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing pods: %v\n", err)
+		return
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	seen := make(map[string]bool, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		seen[pod.Name] = true
+		progress := podProgress(pod, state.isNew)
+		if collector != nil && state.isNew != nil {
+			collector.Observe(podSample(pod, state.isNew(pod)))
+		}
+		if last[pod.Name] != progress {
+			fmt.Printf("  pod %s: %s\n", pod.Name, progress)
+			last[pod.Name] = progress
+		}
+	}
+	for name := range last {
+		if !seen[name] {
+			fmt.Printf("  pod %s: deleted\n", name)
+			delete(last, name)
+		}
+	}
+}
+
+// podSample captures the health signals of a rollout pod for analysis
+func podSample(pod *v1.Pod, isNew bool) analysis.PodSample {
+	sample := analysis.PodSample{Name: pod.Name, Role: analysis.Old}
+	if isNew {
+		sample.Role = analysis.New
+	}
+
+	sample.Revision = pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if sample.Revision == "" {
+		sample.Revision = pod.Labels[appsv1.StatefulSetRevisionLabel]
+	}
+	if c := podCondition(pod, v1.PodReady); c != nil {
+		sample.Ready = c.Status == v1.ConditionTrue
+	}
+	sample.Restarts = model.NewPod(pod).RestartCount()
+	return sample
+}
+
+// podProgress summarizes a pod's revision, phase, readiness and restarts
+func podProgress(pod *v1.Pod, isNew func(*v1.Pod) bool) string {
+	podModel := model.NewPod(pod)
+	ready, total := podModel.ReadyContainers()
+
+	revision := ""
+	if isNew != nil {
+		revision = "[old] "
+		if isNew(pod) {
+			revision = "[new] "
+		}
+	}
+	progress := fmt.Sprintf("%s%s, ready %d/%d, restarts %d", revision, podModel.Phase(), ready, total, podModel.RestartCount())
+	if pod.DeletionTimestamp != nil {
+		progress += ", terminating"
+	}
+	if waiting := podModel.WaitingReasons(); len(waiting) > 0 {
+		progress += ", waiting: " + strings.Join(waiting, ",")
+	}
+	return progress
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"adv-go/model"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// ruleFixtures is a file of alert rule tests
+type ruleFixtures struct {
+	Tests []ruleTest `json:"tests"`
+}
+
+// ruleTest replays pod states through the configured alert rules and compares what fires with the expectation
+type ruleTest struct {
+	Name string `json:"name"`
+	// Pods are the observed pod states in order, the same pod may appear several times as it changes
+	Pods []fixturePod `json:"pods"`
+	// PromQL are the values the Prometheus alert queries return, by alert name
+	PromQL map[string]float64 `json:"promql,omitempty"`
+	// Expect are the alerts that must fire, nil skips the comparison and only reports them
+	Expect []firedAlert `json:"expect"`
+}
+
+// fixturePod is a pod state observed at an offset from the start of the test
+type fixturePod struct {
+	At metav1.Duration `json:"at"`
+	v1.Pod
+}
+
+// firedAlert is a rule that fired, for a pod when the rule is about one
+type firedAlert struct {
+	Rule string `json:"rule"`
+	Pod  string `json:"pod,omitempty"`
+}
+
+// String renders the alert like "notify/CrashLoopBackOff shop/checkout-7b6d4-9wz8m"
+func (a firedAlert) String() string {
+	if a.Pod == "" {
+		return a.Rule
+	}
+	return a.Rule + " " + a.Pod
+}
+
+// newRulesCommand creates the rules command and its test subcommand
+func newRulesCommand() *cobra.Command {
+	test := &cobra.Command{
+		Use:   "test <fixtures.yaml>...",
+		Short: "Check the alert rules of the loaded config against fixture pod states",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exitCode(runRulesTest(args))
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Work with the configured alert rules",
+	}
+	cmd.AddCommand(test)
+	return cmd
+}
+
+// runRulesTest evaluates the alert rules of the loaded config against the fixture files and reports which fire
+func runRulesTest(paths []string) int {
+	code := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading fixtures: %v\n", err)
+			return 1
+		}
+		var fixtures ruleFixtures
+		if err := yaml.UnmarshalStrict(data, &fixtures); err != nil {
+			fmt.Fprintf(os.Stderr, "error parsing %s: %v\n", path, err)
+			return 1
+		}
+
+		for _, test := range fixtures.Tests {
+			fired := evaluateRuleTest(test)
+			missing, unexpected := compareAlerts(test.Expect, fired)
+			switch {
+			case test.Expect == nil:
+				fmt.Printf("RAN  %s\n", test.Name)
+			case len(missing) == 0 && len(unexpected) == 0:
+				fmt.Printf("PASS %s\n", test.Name)
+			default:
+				fmt.Printf("FAIL %s\n", test.Name)
+				code = 1
+			}
+			for _, a := range fired {
+				fmt.Printf("  fired: %s\n", a)
+			}
+			for _, a := range missing {
+				fmt.Printf("  missing: %s\n", a)
+			}
+			for _, a := range unexpected {
+				fmt.Printf("  unexpected: %s\n", a)
+			}
+		}
+	}
+	return code
+}
+
+// evaluateRuleTest replays the pod states through the notification and restart rules the agent applies on every
+// pass, then checks the Prometheus rules against the fixture values
+func evaluateRuleTest(test ruleTest) []firedAlert {
+	var fired []firedAlert
+	start := time.Now()
+	tracker := model.NewRestartTracker(cfg.RestartAlertWindow.Duration)
+	inBadPhase := make(map[types.UID]string)
+
+	for i := range test.Pods {
+		fp := &test.Pods[i]
+		if fp.UID == "" {
+			fp.UID = types.UID(fp.Namespace + "/" + fp.Name)
+		}
+		pod := model.NewPod(&fp.Pod)
+		name := pod.Namespace() + "/" + pod.Name()
+
+		// Notifications fire when a pod enters a bad phase, not on every pass it stays in it
+		reason, _ := badPhase(pod)
+		if previous, seen := inBadPhase[pod.UID()]; reason != "" && (!seen || previous != reason) {
+			fired = append(fired, firedAlert{Rule: "notify/" + reason, Pod: name})
+		}
+		if reason == "" {
+			delete(inBadPhase, pod.UID())
+		} else {
+			inBadPhase[pod.UID()] = reason
+		}
+
+		if cfg.RestartAlertThreshold > 0 {
+			delta := tracker.Observe(pod.UID(), pod.RestartCount(), start.Add(fp.At.Duration))
+			if int(delta) > cfg.RestartAlertThreshold {
+				tracker.Reset(pod.UID())
+				fired = append(fired, firedAlert{Rule: "restarts", Pod: name})
+			}
+		}
+	}
+
+	for _, rule := range cfg.Prometheus.Alerts {
+		if value, ok := test.PromQL[rule.Name]; ok && value > rule.Threshold {
+			fired = append(fired, firedAlert{Rule: "promql/" + rule.Name})
+		}
+	}
+	return fired
+}
+
+// compareAlerts returns the expected alerts that did not fire and the fired alerts that were not expected.
+// An expectation without a pod matches the rule firing for any pod
+func compareAlerts(expect, fired []firedAlert) (missing, unexpected []firedAlert) {
+	matched := make([]bool, len(fired))
+	for _, e := range expect {
+		found := false
+		for i, f := range fired {
+			if !matched[i] && e.Rule == f.Rule && (e.Pod == "" || e.Pod == f.Pod) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+	for i, f := range fired {
+		if !matched[i] {
+			unexpected = append(unexpected, f)
+		}
+	}
+	sort.Slice(unexpected, func(i, j int) bool {
+		return strings.Compare(unexpected[i].String(), unexpected[j].String()) < 0
+	})
+	return missing, unexpected
+}
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"adv-go/metrics"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// selfTestPrefix starts the names of the pods the selftest command creates
+const selfTestPrefix = "pod-logger-selftest-"
+
+// selfTestLabel marks the pods the selftest command creates
+const selfTestLabel = "podlogger.io/selftest"
+
+// selfTestRetention is how long the agent remembers when a selftest pod's records were written
+const selfTestRetention = 10 * time.Minute
+
+var selfTestName = regexp.MustCompile(selfTestPrefix + `[a-z0-9]+`)
+
+// selfTests holds, per selftest pod, when each sink first wrote one of its records
+var selfTests = struct {
+	sync.Mutex
+	deliveries map[string]map[string]time.Time
+	seen       map[string]time.Time
+}{deliveries: make(map[string]map[string]time.Time), seen: make(map[string]time.Time)}
+
+// selfTestResult is the API representation of the deliveries of a selftest pod's records
+type selfTestResult struct {
+	Sinks     []string             `json:"sinks"`
+	Delivered map[string]time.Time `json:"delivered"`
+}
+
+// recordSelfTestDelivery notes the first time a sink wrote a record of a selftest pod
+func recordSelfTestDelivery(sinkName string, records [][]byte) {
+	var names []string
+	for _, record := range records {
+		if bytes.Contains(record, []byte(selfTestPrefix)) {
+			names = append(names, string(selfTestName.Find(record)))
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	now := time.Now()
+	var observe []string
+	selfTests.Lock()
+	for name, at := range selfTests.seen {
+		if now.Sub(at) > selfTestRetention {
+			delete(selfTests.seen, name)
+			delete(selfTests.deliveries, name)
+		}
+	}
+	for _, name := range names {
+		sinks := selfTests.deliveries[name]
+		if sinks == nil {
+			sinks = make(map[string]time.Time)
+			selfTests.deliveries[name] = sinks
+			selfTests.seen[name] = now
+		}
+		if _, ok := sinks[sinkName]; !ok {
+			sinks[sinkName] = now
+			observe = append(observe, name)
+		}
+	}
+	selfTests.Unlock()
+
+	for _, name := range observe {
+		for _, pod := range podStore.List() {
+			if pod.Name() == name {
+				metrics.SelfTestLatency.WithLabelValues(sinkName).Observe(now.Sub(pod.Created()).Seconds())
+				break
+			}
+		}
+	}
+}
+
+// serveSelfTest returns when each sink wrote the first record of a selftest pod
+func serveSelfTest(w http.ResponseWriter, r *http.Request) {
+	result := selfTestResult{Sinks: cfg.Sinks.Names, Delivered: make(map[string]time.Time)}
+	selfTests.Lock()
+	for sinkName, at := range selfTests.deliveries[r.PathValue("name")] {
+		result.Delivered[sinkName] = at
+	}
+	selfTests.Unlock()
+	writeJSON(w, result)
+}
+
+// selfTestOptions configure a selftest run
+type selfTestOptions struct {
+	namespace string
+	agentURL  string
+	// tokenFile and caFile authenticate to an agent with --api-token-file, and trust its --api-tls-cert
+	tokenFile  string
+	caFile     string
+	image      string
+	timeout    time.Duration
+	maxLatency time.Duration
+}
+
+// newSelfTestCommand creates the selftest command
+func newSelfTestCommand() *cobra.Command {
+	opts := selfTestOptions{}
+	cmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "Create a test pod and report how long each sink of a running agent took to write its record",
+		Long: "Creates a pod labeled " + selfTestLabel + ", polls the agent at --agent-url until every configured sink " +
+			"wrote a record of it or --timeout passes, prints each sink's latency from the pod's creation and deletes " +
+			"the pod. Exits 1 when a sink wrote no record or took longer than --max-latency.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.namespace == "" {
+				opts.namespace = "default"
+				if len(cfg.Namespaces) > 0 {
+					opts.namespace = cfg.Namespaces[0]
+				}
+			}
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runSelfTest(clientset, opts))
+		},
+	}
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "namespace of the test pod, empty for the first monitored namespace or default")
+	cmd.Flags().StringVar(&opts.agentURL, "agent-url", "http://localhost:8080", "metrics address of the leading agent")
+	cmd.Flags().StringVar(&opts.tokenFile, "agent-token-file", "", "file whose first line is the bearer token the agent's API wants")
+	cmd.Flags().StringVar(&opts.caFile, "agent-ca", "", "CA (PEM) to verify an https --agent-url with, instead of the system roots")
+	cmd.Flags().StringVar(&opts.image, "image", "registry.k8s.io/pause:3.9", "image of the test pod")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 2*time.Minute, "how long to wait for every sink")
+	cmd.Flags().DurationVar(&opts.maxLatency, "max-latency", 0, "latency above which a sink fails the test, 0 disables")
+	return cmd
+}
+
+// runSelfTest creates the test pod, waits for the sinks to write its record and deletes it again
+func runSelfTest(clientset kubernetes.Interface, opts selfTestOptions) int {
+	client, token, err := selfTestClient(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error setting up the agent client: %v\n", err)
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	pods := clientset.CoreV1().Pods(opts.namespace)
+	grace := int64(0)
+	// Creation timestamps have second precision, so latencies count from just before the pod is created
+	started := time.Now()
+	pod, err := pods.Create(ctx, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: selfTestPrefix,
+			Labels:       map[string]string{selfTestLabel: "true"},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy:                 v1.RestartPolicyNever,
+			TerminationGracePeriodSeconds: &grace,
+			Containers:                    []v1.Container{{Name: "pause", Image: opts.image}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating the test pod in %s: %v\n", opts.namespace, err)
+		return 1
+	}
+	defer func() {
+		// The pod goes even when the test was interrupted
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &grace}); err != nil {
+			fmt.Fprintf(os.Stderr, "error deleting test pod %s/%s: %v\n", opts.namespace, pod.Name, err)
+		}
+	}()
+	fmt.Printf("Created test pod %s/%s, waiting up to %s for the sinks\n", opts.namespace, pod.Name, opts.timeout)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+	url := strings.TrimSuffix(opts.agentURL, "/") + "/api/v1/selftest/" + pod.Name
+	var result selfTestResult
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for {
+		if err := getSelfTest(ctx, client, url, token, &result); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "error querying the agent: %v\n", err)
+		}
+		if len(result.Sinks) > 0 && len(result.Delivered) >= len(result.Sinks) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	sinks := append([]string(nil), result.Sinks...)
+	sort.Strings(sinks)
+	code := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SINK\tLATENCY\tRESULT")
+	for _, name := range sinks {
+		at, ok := result.Delivered[name]
+		switch latency := at.Sub(started); {
+		case !ok:
+			fmt.Fprintf(w, "%s\t-\tno record within %s\n", name, opts.timeout)
+			code = 1
+		case opts.maxLatency > 0 && latency > opts.maxLatency:
+			fmt.Fprintf(w, "%s\t%s\tslower than %s\n", name, latency.Round(time.Millisecond), opts.maxLatency)
+			code = 1
+		default:
+			fmt.Fprintf(w, "%s\t%s\tok\n", name, latency.Round(time.Millisecond))
+		}
+	}
+	w.Flush()
+	if len(sinks) == 0 {
+		fmt.Fprintf(os.Stderr, "the agent at %s did not report its sinks\n", opts.agentURL)
+		code = 1
+	}
+	return code
+}
+
+// selfTestClient is the client querying the agent, trusting --agent-ca, and the token read from --agent-token-file
+func selfTestClient(opts selfTestOptions) (*http.Client, string, error) {
+	var token string
+	if opts.tokenFile != "" {
+		data, err := os.ReadFile(opts.tokenFile)
+		if err != nil {
+			return nil, "", err
+		}
+		token, _, _ = strings.Cut(strings.TrimSpace(string(data)), "\n")
+		token = strings.TrimSpace(token)
+	}
+	if opts.caFile == "" {
+		return http.DefaultClient, token, nil
+	}
+	data, err := os.ReadFile(opts.caFile)
+	if err != nil {
+		return nil, "", err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, "", fmt.Errorf("%s holds no PEM certificates", opts.caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport}, token, nil
+}
+
+// getSelfTest reads which sinks of the agent wrote a record of the test pod
+func getSelfTest(ctx context.Context, client *http.Client, url, token string, result *selfTestResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
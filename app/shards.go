@@ -0,0 +1,181 @@
+package app
+
+import (
+	"adv-go/election"
+	"adv-go/model"
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// heldShard is the shard this replica leads and the namespaces assigned to it, shard is -1 while none is held
+var (
+	heldShard           = -1
+	heldShardNamespaces []string
+	heldShardMu         sync.RWMutex
+)
+
+// sharding reports whether the namespaces are split across replicas with --lease-shards
+func sharding() bool {
+	return cfg.Lease.Shards > 1
+}
+
+// shardOf assigns a namespace to one of shards shards by the hash of its name
+func shardOf(namespace string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// claimShard assigns the shard's namespaces to this replica: the configured namespaces, or every namespace of the
+// cluster when none are set. Namespaces created later are picked up the next time a replica claims the shard,
+// except for those matching a namespace selector, which are picked up right away
+func claimShard(ctx context.Context, clientset kubernetes.Interface, shard int) {
+	candidates := cfg.Namespaces
+	if selectingNamespaces() {
+		candidates = selectedNamespaceList()
+	} else if len(candidates) == 0 {
+		list, err := retryCall(ctx, "list namespaces", func(ctx context.Context) (*v1.NamespaceList, error) {
+			return clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			electionLog.Error("Error listing namespaces, the shard monitors none", "shard", shard, "err", err)
+		} else {
+			for _, ns := range list.Items {
+				candidates = append(candidates, ns.Name)
+			}
+		}
+	}
+
+	namespaces := []string{}
+	for _, ns := range candidates {
+		if shardOf(ns, cfg.Lease.Shards) == shard {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+
+	heldShardMu.Lock()
+	heldShard, heldShardNamespaces = shard, namespaces
+	heldShardMu.Unlock()
+	electionLog.Info("Claimed shard", "shard", shard, "shards", cfg.Lease.Shards, "namespaces", strings.Join(namespaces, ","))
+}
+
+// releaseShard forgets the shard once its term ended
+func releaseShard() {
+	heldShardMu.Lock()
+	defer heldShardMu.Unlock()
+	heldShard, heldShardNamespaces = -1, nil
+}
+
+// shardNamespaces returns the namespaces of the held shard, false when not sharding or no shard is held
+func shardNamespaces() ([]string, bool) {
+	heldShardMu.RLock()
+	defer heldShardMu.RUnlock()
+	if !sharding() || heldShard < 0 {
+		return nil, false
+	}
+	if selectingNamespaces() {
+		// The selection changes while the shard is held
+		namespaces := []string{}
+		for _, ns := range selectedNamespaceList() {
+			if shardOf(ns, cfg.Lease.Shards) == heldShard {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		return namespaces, true
+	}
+	return heldShardNamespaces, true
+}
+
+// heldShardIndex returns the shard this replica leads, false when none is held
+func heldShardIndex() (int, bool) {
+	heldShardMu.RLock()
+	defer heldShardMu.RUnlock()
+	return heldShard, heldShard >= 0
+}
+
+// ownsClusterWork reports whether this replica does the cluster-scoped work, such as reporting nodes, which is not
+// split by namespace: always without sharding, only the holder of shard 0 when sharding, and only the first
+// replica by identity when hashing pods
+func ownsClusterWork() bool {
+	if members, ok := podMembers(); ok {
+		replicas := members.Members()
+		return len(replicas) > 0 && replicas[0] == members.Identity()
+	}
+	if !sharding() {
+		return true
+	}
+	heldShardMu.RLock()
+	defer heldShardMu.RUnlock()
+	return heldShard == 0
+}
+
+// ownsNamespaceWork reports whether this replica reports the namespaced objects other than pods, such as events
+// and Jobs: every replica of its namespaces, except when hashing pods, where they are not split and the replica
+// doing the cluster-scoped work reports them
+func ownsNamespaceWork() bool {
+	if _, ok := podMembers(); ok {
+		return ownsClusterWork()
+	}
+	return true
+}
+
+// hashingPods reports whether the pods are split across replicas with --lease-hash-pods
+func hashingPods() bool {
+	return cfg.Lease.HashPods
+}
+
+// podMembers returns the replicas the pods are hashed across, false when not hashing pods or not electing
+func podMembers() (*election.Membership, bool) {
+	members, ok := elector.(*election.Membership)
+	return members, ok && hashingPods()
+}
+
+// podHashKey is the key a pod is hashed by: the namespace and the value of --lease-hash-label, or else the workload
+// the pod belongs to, so a workload's pods stay on one replica, keyed like the workload rollups
+func podHashKey(pod *v1.Pod) string {
+	if value, ok := pod.Labels[cfg.Lease.HashLabel]; cfg.Lease.HashLabel != "" && ok {
+		return pod.Namespace + "/" + value
+	}
+	kind, name, _ := strings.Cut(model.NewPod(pod).Workload(), "/")
+	return kind + "/" + pod.Namespace + "/" + name
+}
+
+// ownsPod reports whether this replica watches and reports the pod: always unless hashing pods
+func ownsPod(pod *v1.Pod) bool {
+	members, ok := podMembers()
+	return !ok || members.Owns(podHashKey(pod))
+}
+
+// ownedPods filters the pods down to those this replica owns
+func ownedPods(pods []v1.Pod) []v1.Pod {
+	if _, ok := podMembers(); !ok {
+		return pods
+	}
+	owned := pods[:0]
+	for i := range pods {
+		if ownsPod(&pods[i]) {
+			owned = append(owned, pods[i])
+		}
+	}
+	return owned
+}
+
+// newElector creates the elector for the Lease, one of the shard Leases with --lease-shards, or the member Leases
+// with --lease-hash-pods
+func newElector(clientset kubernetes.Interface) (election.Elector, error) {
+	if hashingPods() {
+		return election.NewMembership(clientset, electionConfig())
+	}
+	if sharding() {
+		return election.NewSharded(clientset, electionConfig(), cfg.Lease.Shards)
+	}
+	return election.New(clientset, electionConfig())
+}
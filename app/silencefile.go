@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// fileSilence mutes the notifications of the pods of a namespace matching a label selector until it expires
+type fileSilence struct {
+	// Namespace is the pods' namespace, empty for every namespace
+	Namespace string `json:"namespace"`
+	// Selector is a label selector such as app=nightly,tier!=web, empty for every pod
+	Selector  string    `json:"selector"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Comment   string    `json:"comment"`
+
+	selector labels.Selector
+}
+
+// silencesFile is the format of --notify-silences-file
+type silencesFile struct {
+	Silences []fileSilence `json:"silences"`
+}
+
+// fileSilences are the silences of --notify-silences-file as last read, guarded by alertsMu
+var fileSilences []fileSilence
+
+// loadSilencesFile rereads --notify-silences-file, keeping the silences read before when it is invalid
+func loadSilencesFile() {
+	if cfg.Notify.SilencesFile == "" {
+		return
+	}
+	loaded, err := readSilencesFile(cfg.Notify.SilencesFile)
+	if err != nil {
+		alertsLog.Error("Error loading the silences file", "file", cfg.Notify.SilencesFile, "err", err)
+		return
+	}
+	alertsMu.Lock()
+	fileSilences = loaded
+	alertsMu.Unlock()
+}
+
+// readSilencesFile parses a silences file, every silence needs an expiry
+func readSilencesFile(path string) ([]fileSilence, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f silencesFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	for i := range f.Silences {
+		s := &f.Silences[i]
+		if s.ExpiresAt.IsZero() {
+			return nil, fmt.Errorf("silence %d has no expiresAt", i+1)
+		}
+		if s.selector, err = labels.Parse(s.Selector); err != nil {
+			return nil, fmt.Errorf("silence %d has an invalid selector %q: %w", i+1, s.Selector, err)
+		}
+	}
+	return f.Silences, nil
+}
+
+// fileSilenceFor returns the unexpired silence of the file that matches the pod
+func fileSilenceFor(pod *v1.Pod) (fileSilence, bool) {
+	alertsMu.Lock()
+	defer alertsMu.Unlock()
+	now := time.Now()
+	for _, s := range fileSilences {
+		if s.ExpiresAt.After(now) && (s.Namespace == "" || s.Namespace == pod.Namespace) &&
+			s.selector.Matches(labels.Set(pod.Labels)) {
+			return s, true
+		}
+	}
+	return fileSilence{}, false
+}
@@ -0,0 +1,325 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"adv-go/signing"
+	"adv-go/sink"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterSnapshot is the cluster state captured once and rendered to every requested format
+type clusterSnapshot struct {
+	Taken          time.Time            `json:"taken"`
+	ClusterVersion string               `json:"clusterVersion"`
+	Nodes          []nodeSnapshot       `json:"nodes"`
+	Deployments    []deploymentSnapshot `json:"deployments"`
+	Pods           []podSnapshot        `json:"pods"`
+}
+
+// nodeSnapshot is a node's state in a snapshot
+type nodeSnapshot struct {
+	Name        string `json:"name"`
+	OS          string `json:"os"`
+	Ready       bool   `json:"ready"`
+	Schedulable bool   `json:"schedulable"`
+	CPU         string `json:"cpu"`
+	Memory      string `json:"memory"`
+}
+
+// deploymentSnapshot is a deployment's replica counts in a snapshot
+type deploymentSnapshot struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Healthy   bool   `json:"healthy"`
+	Desired   int32  `json:"desired"`
+	Ready     int32  `json:"ready"`
+	Available int32  `json:"available"`
+	Updated   int32  `json:"updated"`
+}
+
+// snapshotOptions are the files a snapshot is written to
+type snapshotOptions struct {
+	jsonPath, csvPath, htmlPath string
+	signKey                     string
+	// upload is an s3:// or gs:// bucket URL the files are uploaded to, empty to keep them local
+	upload string
+}
+
+// newSnapshotCommand creates the snapshot command
+func newSnapshotCommand() *cobra.Command {
+	var opts snapshotOptions
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "List the cluster once and write it as JSON, CSV and an HTML report",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.jsonPath == "" && opts.csvPath == "" && opts.htmlPath == "" {
+				return errors.New("at least one of --json, --csv or --html is required")
+			}
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runSnapshot(clientset, opts))
+		},
+	}
+	cmd.Flags().StringVar(&opts.jsonPath, "json", "", "write the snapshot as JSON to this file")
+	cmd.Flags().StringVar(&opts.csvPath, "csv", "", "write the pods as CSV to this file")
+	cmd.Flags().StringVar(&opts.htmlPath, "html", "", "write an HTML report to this file")
+	cmd.Flags().StringVar(&opts.signKey, "sign-key", "", "ed25519 private key (PEM) signing each file, --signing-key when empty; a .sha256 checksum is always written")
+	cmd.Flags().StringVar(&opts.upload, "upload", "", "also upload the files and their checksums to this bucket, s3://bucket/prefix or gs://bucket/prefix")
+	return cmd
+}
+
+// runSnapshot lists the cluster once and writes it in every requested format
+func runSnapshot(clientset kubernetes.Interface, opts snapshotOptions) int {
+	if opts.signKey == "" {
+		opts.signKey = cfg.SigningKey
+	}
+	var signer *signing.Signer
+	if opts.signKey != "" {
+		var err error
+		if signer, err = signing.LoadSigner(opts.signKey); err != nil {
+			fmt.Fprintf(os.Stderr, "error loading signing key: %v\n", err)
+			return 1
+		}
+	}
+	var store sink.ObjectStore
+	var prefix string
+	if opts.upload != "" {
+		var err error
+		if store, prefix, err = sink.OpenObjectStore(opts.upload); err != nil {
+			fmt.Fprintf(os.Stderr, "error opening %s: %v\n", opts.upload, err)
+			return 1
+		}
+		// Every snapshot gets a folder of its own, so later ones never replace it
+		prefix = path.Join(prefix, "snapshots", time.Now().UTC().Format("2006-01-02T15-04-05Z"))
+	}
+
+	snap, err := collectSnapshot(context.Background(), clientset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error taking snapshot: %v\n", err)
+		return 1
+	}
+
+	outputs := []struct {
+		path  string
+		write func(io.Writer, *clusterSnapshot) error
+	}{
+		{opts.jsonPath, writeSnapshotJSON},
+		{opts.csvPath, writeSnapshotCSV},
+		{opts.htmlPath, writeSnapshotHTML},
+	}
+	code := 0
+	for _, o := range outputs {
+		if o.path == "" {
+			continue
+		}
+		if err := writeSnapshotFile(o.path, snap, o.write, signer); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s: %v\n", o.path, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("Wrote %s\n", o.path)
+		if store == nil {
+			continue
+		}
+		if err := uploadSnapshotFile(context.Background(), store, prefix, o.path); err != nil {
+			fmt.Fprintf(os.Stderr, "error uploading %s: %v\n", o.path, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("Uploaded %s to %s\n", o.path, opts.upload)
+	}
+	return code
+}
+
+// uploadSnapshotFile uploads a snapshot file and the sidecars written next to it
+func uploadSnapshotFile(ctx context.Context, store sink.ObjectStore, prefix, file string) error {
+	for _, suffix := range []string{"", signing.ChecksumSuffix, signing.SignatureSuffix} {
+		data, err := os.ReadFile(file + suffix)
+		if os.IsNotExist(err) && suffix != "" {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(file + suffix))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if err := store.Put(ctx, path.Join(prefix, filepath.Base(file+suffix)), data, contentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectSnapshot lists the pods, nodes and deployments of the monitored namespaces
+func collectSnapshot(ctx context.Context, clientset kubernetes.Interface) (*clusterSnapshot, error) {
+	snap := &clusterSnapshot{Taken: time.Now().UTC(), ClusterVersion: cluster.Version}
+
+	pods, err := getAllPods(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		snap.Pods = append(snap.Pods, newPodSnapshot(model.NewPod(&pods.Items[i])))
+	}
+
+	nodes, err := getAllNodes(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes.Items {
+		n := model.NewNode(&nodes.Items[i])
+		allocatable := n.Allocatable()
+		snap.Nodes = append(snap.Nodes, nodeSnapshot{
+			Name:        n.Name(),
+			OS:          n.OS(),
+			Ready:       n.IsReady(),
+			Schedulable: n.IsSchedulable(),
+			CPU:         format.CPU(allocatable.Cpu().MilliValue()),
+			Memory:      format.Bytes(allocatable.Memory().Value()),
+		})
+	}
+
+	for _, namespace := range monitoredNamespaces() {
+		deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range deployments.Items {
+			d := model.NewDeployment(&deployments.Items[i])
+			desired, ready, available, updated := d.Replicas()
+			snap.Deployments = append(snap.Deployments, deploymentSnapshot{
+				Namespace: d.Namespace(), Name: d.Name(), Healthy: d.IsHealthy(),
+				Desired: desired, Ready: ready, Available: available, Updated: updated,
+			})
+		}
+	}
+	sortSnapshot(snap)
+	return snap, nil
+}
+
+// sortSnapshot orders every list of the snapshot by namespace and name, so the same cluster state always renders
+// to the same bytes and successive snapshots diff cleanly
+func sortSnapshot(snap *clusterSnapshot) {
+	sortPodSnapshots(snap.Pods)
+	sort.SliceStable(snap.Nodes, func(i, j int) bool { return snap.Nodes[i].Name < snap.Nodes[j].Name })
+	sort.SliceStable(snap.Deployments, func(i, j int) bool {
+		a, b := snap.Deployments[i], snap.Deployments[j]
+		return lessNamespaced(a.Namespace, a.Name, b.Namespace, b.Name)
+	})
+}
+
+// sortPodSnapshots orders the pods by namespace and name
+func sortPodSnapshots(pods []podSnapshot) {
+	sort.SliceStable(pods, func(i, j int) bool {
+		return lessNamespaced(pods[i].Namespace, pods[i].Name, pods[j].Namespace, pods[j].Name)
+	})
+}
+
+// lessNamespaced orders objects by namespace, then by name
+func lessNamespaced(namespaceA, nameA, namespaceB, nameB string) bool {
+	if namespaceA != namespaceB {
+		return namespaceA < namespaceB
+	}
+	return nameA < nameB
+}
+
+// writeSnapshotFile renders the snapshot into the file and writes its checksum and, with a signer, its signature next to it
+func writeSnapshotFile(path string, snap *clusterSnapshot, write func(io.Writer, *clusterSnapshot) error, signer *signing.Signer) error {
+	var buf bytes.Buffer
+	if err := write(&buf, snap); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return signing.WriteSidecars(path, buf.Bytes(), signer)
+}
+
+// writeSnapshotJSON renders the whole snapshot as indented JSON
+func writeSnapshotJSON(w io.Writer, snap *clusterSnapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// writeSnapshotCSV renders the pods as CSV, one row per pod
+func writeSnapshotCSV(w io.Writer, snap *clusterSnapshot) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"namespace", "name", "node", "phase", "ready", "containers", "restarts", "waiting"}); err != nil {
+		return err
+	}
+	for _, p := range snap.Pods {
+		row := []string{p.Namespace, p.Name, p.Node, p.Phase, strconv.Itoa(p.Ready), strconv.Itoa(p.Containers),
+			strconv.Itoa(int(p.Restarts)), strings.Join(p.Waiting, ";")}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// snapshotReport is the HTML report template
+var snapshotReport = template.Must(template.New("report").Funcs(template.FuncMap{"time": format.Time}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Cluster snapshot {{time .Taken}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+.bad { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Cluster snapshot</h1>
+<p>Taken {{time .Taken}} from Kubernetes {{.ClusterVersion}}: {{len .Nodes}} nodes, {{len .Deployments}} deployments, {{len .Pods}} pods.</p>
+<h2>Nodes</h2>
+<table>
+<tr><th>Name</th><th>OS</th><th>Ready</th><th>Schedulable</th><th>CPU</th><th>Memory</th></tr>
+{{range .Nodes}}<tr{{if not .Ready}} class="bad"{{end}}><td>{{.Name}}</td><td>{{.OS}}</td><td>{{.Ready}}</td><td>{{.Schedulable}}</td><td>{{.CPU}}</td><td>{{.Memory}}</td></tr>
+{{end}}</table>
+<h2>Deployments</h2>
+<table>
+<tr><th>Namespace</th><th>Name</th><th>Desired</th><th>Ready</th><th>Available</th><th>Updated</th></tr>
+{{range .Deployments}}<tr{{if not .Healthy}} class="bad"{{end}}><td>{{.Namespace}}</td><td>{{.Name}}</td><td>{{.Desired}}</td><td>{{.Ready}}</td><td>{{.Available}}</td><td>{{.Updated}}</td></tr>
+{{end}}</table>
+<h2>Pods</h2>
+<table>
+<tr><th>Namespace</th><th>Name</th><th>Node</th><th>Phase</th><th>Ready</th><th>Restarts</th><th>Waiting</th></tr>
+{{range .Pods}}<tr{{if or (ne .Ready .Containers) .Waiting}} class="bad"{{end}}><td>{{.Namespace}}</td><td>{{.Name}}</td><td>{{.Node}}</td><td>{{.Phase}}</td><td>{{.Ready}}/{{.Containers}}</td><td>{{.Restarts}}</td><td>{{range $i, $w := .Waiting}}{{if $i}}, {{end}}{{$w}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeSnapshotHTML renders the snapshot as a standalone HTML report
+func writeSnapshotHTML(w io.Writer, snap *clusterSnapshot) error {
+	return snapshotReport.Execute(w, snap)
+}
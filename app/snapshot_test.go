@@ -0,0 +1,143 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// update rewrites the golden files with the current output: go test ./app -run Snapshot -update
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// testSnapshot is a cluster listed out of order, with containers reporting their states out of order
+func testSnapshot(t *testing.T) *clusterSnapshot {
+	t.Helper()
+	if err := format.SetTimezone("UTC"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { format.SetTimezone("Local") })
+
+	pod := func(namespace, name, node string, phase v1.PodPhase, statuses ...v1.ContainerStatus) v1.Pod {
+		p := v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       v1.PodSpec{NodeName: node},
+			Status:     v1.PodStatus{Phase: phase, ContainerStatuses: statuses},
+		}
+		for _, s := range statuses {
+			p.Spec.Containers = append(p.Spec.Containers, v1.Container{Name: s.Name, Image: s.Image})
+		}
+		return p
+	}
+	running := func(name string) v1.ContainerStatus {
+		return v1.ContainerStatus{Name: name, Image: name + ":1", Ready: true, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}
+	}
+	waiting := func(name, reason string, restarts int32, exitCode int32) v1.ContainerStatus {
+		return v1.ContainerStatus{
+			Name: name, Image: name + ":1", RestartCount: restarts,
+			State:                v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}},
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: exitCode}},
+		}
+	}
+	pods := []v1.Pod{
+		pod("shop", "web-1", "node-b", v1.PodRunning, running("web")),
+		pod("payments", "web-0", "node-a", v1.PodRunning, running("web")),
+		pod("shop", "worker-0", "node-a", v1.PodRunning, waiting("worker", "CrashLoopBackOff", 4, 1), waiting("sidecar", "ImagePullBackOff", 2, 137)),
+		pod("shop", "web-0", "node-a", v1.PodPending),
+		pod("kube-system", "dns-0", "node-b", v1.PodRunning, running("dns")),
+	}
+
+	snap := &clusterSnapshot{
+		Taken:          time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		ClusterVersion: "v1.30.2",
+		Nodes: []nodeSnapshot{
+			{Name: "node-b", OS: "linux", Ready: false, Schedulable: true, CPU: "4", Memory: "16Gi"},
+			{Name: "node-a", OS: "linux", Ready: true, Schedulable: true, CPU: "8", Memory: "32Gi"},
+		},
+		Deployments: []deploymentSnapshot{
+			{Namespace: "shop", Name: "worker", Healthy: false, Desired: 1, Ready: 0, Available: 0, Updated: 1},
+			{Namespace: "payments", Name: "web", Healthy: true, Desired: 1, Ready: 1, Available: 1, Updated: 1},
+			{Namespace: "shop", Name: "web", Healthy: false, Desired: 2, Ready: 1, Available: 1, Updated: 2},
+		},
+	}
+	for i := range pods {
+		snap.Pods = append(snap.Pods, newPodSnapshot(model.NewPod(&pods[i])))
+	}
+	sortSnapshot(snap)
+	return snap
+}
+
+func TestSnapshotOrder(t *testing.T) {
+	snap := testSnapshot(t)
+
+	var pods []string
+	for _, p := range snap.Pods {
+		pods = append(pods, p.Namespace+"/"+p.Name)
+	}
+	want := []string{"kube-system/dns-0", "payments/web-0", "shop/web-0", "shop/web-1", "shop/worker-0"}
+	if !slices.Equal(pods, want) {
+		t.Errorf("pods = %v, want %v", pods, want)
+	}
+
+	var deployments []string
+	for _, d := range snap.Deployments {
+		deployments = append(deployments, d.Namespace+"/"+d.Name)
+	}
+	if want := []string{"payments/web", "shop/web", "shop/worker"}; !slices.Equal(deployments, want) {
+		t.Errorf("deployments = %v, want %v", deployments, want)
+	}
+
+	if snap.Nodes[0].Name != "node-a" || snap.Nodes[1].Name != "node-b" {
+		t.Errorf("nodes = %s, %s, want node-a, node-b", snap.Nodes[0].Name, snap.Nodes[1].Name)
+	}
+
+	// Container states are sorted by container, whatever order the kubelet reported them in
+	worker := snap.Pods[4]
+	if want := []string{"CrashLoopBackOff", "ImagePullBackOff"}; !slices.Equal(worker.Waiting, want) {
+		t.Errorf("waiting = %v, want %v", worker.Waiting, want)
+	}
+	if len(worker.Terminations) != 2 || worker.Terminations[0].Container != "sidecar" || worker.Terminations[1].Container != "worker" {
+		t.Errorf("terminations = %v, want sidecar then worker", worker.Terminations)
+	}
+}
+
+func TestSnapshotGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		write  func(io.Writer, *clusterSnapshot) error
+	}{
+		{"snapshot.json", writeSnapshotJSON},
+		{"snapshot.csv", writeSnapshotCSV},
+		{"snapshot.html", writeSnapshotHTML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf, testSnapshot(t)); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s differs from %s, rerun with -update if the change is intended:\n%s", tt.golden, path, buf.String())
+			}
+		})
+	}
+}
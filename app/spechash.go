@@ -0,0 +1,198 @@
+package app
+
+import (
+	"adv-go/history"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// workloadSpec is what is known of the specs of a workload's pods
+type workloadSpec struct {
+	// live counts the pods per spec hash that have not been deleted
+	live map[string]int
+	// latest is the newest pod, the one new specs are described against
+	latest *model.Pod
+}
+
+// workloadSpecs holds the specs of every workload by namespace and kind/name
+var (
+	workloadSpecs   = make(map[string]*workloadSpec)
+	workloadSpecsMu sync.Mutex
+)
+
+// seedWorkloadSpecs learns the specs of every workload from the cached pods without recording markers, so taking
+// over leadership does not mark the rollouts that happened before
+func seedWorkloadSpecs(pods []v1.Pod) {
+	sort.SliceStable(pods, func(i, j int) bool { return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp) })
+	specs := make(map[string]*workloadSpec)
+	for i := range pods {
+		pod := model.NewPod(&pods[i])
+		key, ok := workloadSpecKey(pod)
+		if !ok {
+			continue
+		}
+		spec, ok := specs[key]
+		if !ok {
+			spec = &workloadSpec{live: make(map[string]int)}
+			specs[key] = spec
+		}
+		spec.live[pod.SpecHash()]++
+		spec.latest = pod
+	}
+	workloadSpecsMu.Lock()
+	workloadSpecs = specs
+	workloadSpecsMu.Unlock()
+}
+
+// workloadSpecKey identifies the workload of a pod, bare pods have no workload template to track
+func workloadSpecKey(pod *model.Pod) (string, bool) {
+	workload := pod.Workload()
+	if strings.HasPrefix(workload, "Pod/") {
+		return "", false
+	}
+	return pod.Namespace() + "/" + workload, true
+}
+
+// observePodSpec records a rollout marker when a new pod of a workload has a spec hash none of its live pods has, and
+// an in-place change when the spec of an existing pod changed. old is nil for new pods. Replacements created by the
+// old ReplicaSet during a rollout, or pods added by scaling up, share a live hash and are not marked
+func observePodSpec(old, pod *v1.Pod) {
+	current := model.NewPod(pod)
+	hash := current.SpecHash()
+	var previous *model.Pod
+	previousHash := ""
+	if old != nil {
+		previous = model.NewPod(old)
+		if previousHash = previous.SpecHash(); previousHash == hash {
+			return
+		}
+		recordInPlaceChange(current, current.SpecChanges(previous))
+	}
+
+	key, ok := workloadSpecKey(current)
+	if !ok {
+		return
+	}
+	workloadSpecsMu.Lock()
+	spec, seen := workloadSpecs[key]
+	if !seen {
+		spec = &workloadSpec{live: make(map[string]int)}
+		workloadSpecs[key] = spec
+	}
+	if previous != nil {
+		forgetSpecHash(spec, previousHash)
+	}
+	marked := old == nil && seen && spec.live[hash] == 0 && spec.latest != nil && spec.latest.SpecHash() != hash
+	var from *model.Pod
+	if marked {
+		from = spec.latest
+	}
+	spec.live[hash]++
+	if old == nil {
+		spec.latest = current
+	}
+	workloadSpecsMu.Unlock()
+
+	if marked {
+		recordRolloutStarted(current, from.SpecHash(), hash, current.SpecChanges(from))
+	}
+}
+
+// forgetPodSpec stops counting a deleted pod among its workload's live pods
+func forgetPodSpec(pod *v1.Pod) {
+	p := model.NewPod(pod)
+	key, ok := workloadSpecKey(p)
+	if !ok {
+		return
+	}
+	workloadSpecsMu.Lock()
+	defer workloadSpecsMu.Unlock()
+	if spec, ok := workloadSpecs[key]; ok {
+		forgetSpecHash(spec, p.SpecHash())
+	}
+}
+
+// forgetSpecHash decrements the live pods of a hash. The workload itself is kept when its last pod goes, so scaling
+// it back up with a new spec is still marked
+func forgetSpecHash(spec *workloadSpec, hash string) {
+	if spec.live[hash] <= 1 {
+		delete(spec.live, hash)
+		return
+	}
+	spec.live[hash]--
+}
+
+// recordRolloutStarted writes a rollout started marker to the sinks and the history store
+func recordRolloutStarted(pod *model.Pod, from, to string, changes []string) {
+	workload := pod.Workload()
+	metrics.PodSpecChanges.WithLabelValues(pod.Namespace(), "rollout").Inc()
+	status := fmt.Sprintf("Rollout started: %s in %s, spec %s -> %s, first pod %s: %s", workload, pod.Namespace(), from, to,
+		pod.Name(), describeSpecChanges(changes))
+	if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+		watchLog.Error("Error writing to sink", "err", err)
+	}
+	watchLog.Info(status, "namespace", pod.Namespace(), "workload", workload, "from", from, "to", to)
+
+	if historyStore == nil {
+		return
+	}
+	m := history.RolloutMarker{Namespace: pod.Namespace(), Workload: workload, FromHash: from, ToHash: to, Changes: changes, At: time.Now()}
+	queueHistory("rollout marker of "+m.Namespace+"/"+m.Workload, func(ctx context.Context) error {
+		return historyStore.RecordRolloutMarker(ctx, m)
+	})
+}
+
+// recordInPlaceChange writes a change made to the spec of a running pod, such as an image patched or resources resized
+// in place, to the sinks and the pod's timeline
+func recordInPlaceChange(pod *model.Pod, changes []string) {
+	metrics.PodSpecChanges.WithLabelValues(pod.Namespace(), "in-place").Inc()
+	detail := describeSpecChanges(changes)
+	status := fmt.Sprintf("Pod spec changed in place: %s/%s: %s", pod.Namespace(), pod.Name(), detail)
+	if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+		watchLog.Error("Error writing to sink", "err", err)
+	}
+	watchLog.Info(status, "namespace", pod.Namespace(), "pod", pod.Name(), "node", pod.NodeName())
+	recordPodEvent(pod.Namespace(), pod.Name(), history.PodSpecChange, detail)
+}
+
+// describeSpecChanges joins the changes, falling back to a generic note when none could be described
+func describeSpecChanges(changes []string) string {
+	if len(changes) == 0 {
+		return "spec changed"
+	}
+	return strings.Join(changes, "; ")
+}
+
+// serveRolloutMarkers returns the rollout markers as JSON, ?since= limits how far back they go (default 24h) and
+// ?namespace= to one namespace
+func serveRolloutMarkers(w http.ResponseWriter, r *http.Request) {
+	since := 24 * time.Hour
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+
+	markers, err := historyStore.RolloutMarkers(r.Context(), r.URL.Query().Get("namespace"), time.Now().Add(-since))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if markers == nil {
+		markers = []history.RolloutMarker{}
+	}
+	writeJSON(w, markers)
+}
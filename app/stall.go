@@ -0,0 +1,157 @@
+package app
+
+import (
+	"adv-go/election"
+	"adv-go/format"
+	"adv-go/metrics"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// lastPass is when the term's reporting loop started or last finished a pass, in Unix nanoseconds, 0 while
+	// no loop runs
+	lastPass atomic.Int64
+	// campaignCancel ends the current leader election campaign, releasing the lock held, nil outside one
+	campaignCancel context.CancelCauseFunc
+	campaignMu     sync.Mutex
+)
+
+// startPassClock starts timing the term's reporting loop and, until the term ends, watches it for a stall. The
+// heartbeat file is removed with the term, so only the leader has one
+func startPassClock(ctx context.Context) {
+	lastPass.Store(time.Now().UnixNano())
+	goLeader(func() {
+		if cfg.Lease.StallTimeout.Duration > 0 {
+			watchStall(ctx)
+		}
+		<-ctx.Done()
+		lastPass.Store(0)
+		if cfg.Lease.HeartbeatFile != "" {
+			if err := os.Remove(cfg.Lease.HeartbeatFile); err != nil && !os.IsNotExist(err) {
+				electionLog.Warn("Error removing the heartbeat file", "file", cfg.Lease.HeartbeatFile, "err", err)
+			}
+		}
+	})
+}
+
+// passFinished records a finished reporting pass and writes the heartbeat to the file and the held Lease
+func passFinished(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	lastPass.Store(now.UnixNano())
+	metrics.ReportingLastPass.Set(float64(now.Unix()))
+
+	if cfg.Lease.HeartbeatFile != "" {
+		if err := writeHeartbeatFile(cfg.Lease.HeartbeatFile, now); err != nil {
+			electionLog.Warn("Error writing the heartbeat file", "file", cfg.Lease.HeartbeatFile, "err", err)
+		}
+	}
+	if config, ok := heldLease(); ok && cfg.Lease.Heartbeat {
+		annotateCtx, cancel := context.WithTimeout(ctx, cfg.Lease.RetryPeriod.Duration)
+		defer cancel()
+		if err := election.AnnotateHeartbeat(annotateCtx, clientset, config, now); err != nil && ctx.Err() == nil {
+			electionLog.Warn("Error annotating the Lease with the heartbeat", "lease", config.Namespace+"/"+config.Name, "err", err)
+		}
+	}
+}
+
+// writeHeartbeatFile replaces the file with the time, through a rename so a reader never sees it half written
+func writeHeartbeatFile(path string, at time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := fmt.Fprintln(tmp, at.UTC().Format(time.RFC3339)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// heldLease is the Lease this replica holds for the term: the lock, the shard lock with --lease-shards or the
+// member Lease with --lease-hash-pods. Local runs without an elector hold none
+func heldLease() (election.Config, bool) {
+	if elector == nil || !isLeading() {
+		return election.Config{}, false
+	}
+	config := electionConfig()
+	if hashingPods() {
+		config.Name = election.MemberName(config.Name, elector.Identity())
+	} else if shard, ok := heldShardIndex(); ok {
+		config = election.ShardConfig(config, shard)
+	} else if sharding() {
+		return election.Config{}, false
+	}
+	return config, true
+}
+
+// sinceLastPass is how long the term's reporting loop went without finishing a pass, false while none runs
+func sinceLastPass() (time.Duration, bool) {
+	last := lastPass.Load()
+	if last == 0 {
+		return 0, false
+	}
+	return time.Since(time.Unix(0, last)), true
+}
+
+// watchStall checks the reporting loop every retry period until the term ends or the loop stalls. A stall is
+// logged and counted once per term and, with --lease-release-on-stall, gives up the Lease
+func watchStall(ctx context.Context) {
+	ticker := time.NewTicker(cfg.Lease.RetryPeriod.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		since, ok := sinceLastPass()
+		if !ok || since < cfg.Lease.StallTimeout.Duration {
+			continue
+		}
+		metrics.ReportingStalls.Inc()
+		electionLog.Error("Reporting loop stalled", "since", format.Duration(since), "timeout", format.Duration(cfg.Lease.StallTimeout.Duration))
+		if cfg.Lease.ReleaseOnStall {
+			releaseLeadership(fmt.Errorf("the reporting loop finished no pass for %s", format.Duration(since)))
+		}
+		return
+	}
+}
+
+// releaseLeadership ends the campaign, releasing the lock so a standby replica takes over, and campaigns again
+// after a lease duration. Local runs elect no leader and have nothing to give up
+func releaseLeadership(cause error) {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+	if campaignCancel == nil {
+		electionLog.Warn("Not electing a leader, cannot release leadership", "reason", cause.Error())
+		return
+	}
+	electionLog.Warn("Releasing leadership", "reason", cause.Error())
+	campaignCancel(cause)
+	campaignCancel = nil
+}
+
+// stallCheck fails liveness while the leader's reporting loop finished no pass within --lease-stall-timeout
+func stallCheck() healthCheck {
+	since, ok := sinceLastPass()
+	if !ok {
+		return healthCheck{name: "reporting", ok: true, info: "not running"}
+	}
+	if since >= cfg.Lease.StallTimeout.Duration {
+		return healthCheck{name: "reporting", info: "no pass finished for " + format.Duration(since)}
+	}
+	return healthCheck{name: "reporting", ok: true, info: "last pass " + format.Duration(since) + " ago"}
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// agentStarted marks the point after which pods count as newly created
+var agentStarted = time.Now()
+
+// reportedStartups and reportedSchedulings remember the pods whose start and scheduling latencies have already
+// been measured
+var (
+	reportedStartups    = make(map[types.UID]bool)
+	reportedSchedulings = make(map[types.UID]bool)
+	reportedStartupsMu  sync.Mutex
+)
+
+// startupBreakdown is the time a pod spent in each phase between creation and readiness
+type startupBreakdown struct {
+	Scheduling     time.Duration
+	ImagePull      time.Duration
+	ContainerStart time.Duration
+	Readiness      time.Duration
+	// ScheduledToReady spans the last three phases
+	ScheduledToReady time.Duration
+}
+
+// observePodScheduled measures how long a newly created pod waited for a node the first time it is seen scheduled,
+// whether or not it ever becomes Ready
+func observePodScheduled(pod *v1.Pod) {
+	if pod.CreationTimestamp.Time.Before(agentStarted) {
+		return
+	}
+	scheduled := podCondition(pod, v1.PodScheduled)
+	if scheduled == nil || scheduled.Status != v1.ConditionTrue {
+		return
+	}
+
+	reportedStartupsMu.Lock()
+	if reportedSchedulings[pod.UID] {
+		reportedStartupsMu.Unlock()
+		return
+	}
+	reportedSchedulings[pod.UID] = true
+	reportedStartupsMu.Unlock()
+
+	latency := nonNegative(scheduled.LastTransitionTime.Sub(pod.CreationTimestamp.Time))
+	metrics.PodStartLatency.WithLabelValues("created_to_scheduled").Observe(latency.Seconds())
+	watchLog.Debug("Pod scheduled", append(podFields(pod), "scheduling", format.Duration(latency))...)
+}
+
+// observePodStartup measures the start latency of a newly created pod the first time it becomes Ready
+func observePodStartup(clientset kubernetes.Interface, pod *v1.Pod) {
+	if pod.CreationTimestamp.Time.Before(agentStarted) {
+		return
+	}
+	ready := podCondition(pod, v1.PodReady)
+	if ready == nil || ready.Status != v1.ConditionTrue {
+		return
+	}
+
+	reportedStartupsMu.Lock()
+	if reportedStartups[pod.UID] {
+		reportedStartupsMu.Unlock()
+		return
+	}
+	reportedStartups[pod.UID] = true
+	reportedStartupsMu.Unlock()
+
+	pullStart, pullEnd := imagePullWindow(clientset, pod)
+	b := podStartupBreakdown(pod, pullStart, pullEnd)
+
+	metrics.PodStartupPhase.WithLabelValues("scheduling").Observe(b.Scheduling.Seconds())
+	metrics.PodStartupPhase.WithLabelValues("image_pull").Observe(b.ImagePull.Seconds())
+	metrics.PodStartupPhase.WithLabelValues("container_start").Observe(b.ContainerStart.Seconds())
+	metrics.PodStartupPhase.WithLabelValues("readiness").Observe(b.Readiness.Seconds())
+	metrics.PodStartLatency.WithLabelValues("scheduled_to_ready").Observe(b.ScheduledToReady.Seconds())
+	watchLog.Info("Pod started", append(podFields(pod),
+		"scheduling", format.Duration(b.Scheduling), "imagePull", format.Duration(b.ImagePull),
+		"containerStart", format.Duration(b.ContainerStart), "readiness", format.Duration(b.Readiness),
+		"scheduledToReady", format.Duration(b.ScheduledToReady))...)
+}
+
+// forgetPodStartup drops the bookkeeping for a deleted pod
+func forgetPodStartup(pod *v1.Pod) {
+	reportedStartupsMu.Lock()
+	defer reportedStartupsMu.Unlock()
+	delete(reportedStartups, pod.UID)
+	delete(reportedSchedulings, pod.UID)
+}
+
+// podStartupBreakdown splits creation-to-ready time into phases using conditions, container states and the pull window
+func podStartupBreakdown(pod *v1.Pod, pullStart, pullEnd time.Time) startupBreakdown {
+	created := pod.CreationTimestamp.Time
+	scheduled := created
+	if c := podCondition(pod, v1.PodScheduled); c != nil {
+		scheduled = c.LastTransitionTime.Time
+	}
+	ready := podCondition(pod, v1.PodReady).LastTransitionTime.Time
+
+	// The last container to start gates readiness
+	started := scheduled
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Running != nil && cs.State.Running.StartedAt.After(started) {
+			started = cs.State.Running.StartedAt.Time
+		}
+	}
+
+	var b startupBreakdown
+	b.Scheduling = nonNegative(scheduled.Sub(created))
+	if !pullStart.IsZero() && pullEnd.After(pullStart) {
+		b.ImagePull = pullEnd.Sub(pullStart)
+	}
+	b.ContainerStart = nonNegative(started.Sub(scheduled) - b.ImagePull)
+	b.Readiness = nonNegative(ready.Sub(started))
+	b.ScheduledToReady = nonNegative(ready.Sub(scheduled))
+	return b
+}
+
+// imagePullWindow returns the first Pulling and last Pulled event times recorded for the pod
+func imagePullWindow(clientset kubernetes.Interface, pod *v1.Pod) (time.Time, time.Time) {
+	events, err := clientset.CoreV1().Events(pod.Namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: "involvedObject.uid=" + string(pod.UID),
+	})
+	if err != nil {
+		watchLog.Error("Error listing pod events", append(podFields(pod), "err", err)...)
+		return time.Time{}, time.Time{}
+	}
+
+	var first, last time.Time
+	for _, ev := range events.Items {
+		at := ev.FirstTimestamp.Time
+		if at.IsZero() {
+			at = ev.EventTime.Time
+		}
+		switch ev.Reason {
+		case "Pulling":
+			if first.IsZero() || at.Before(first) {
+				first = at
+			}
+		case "Pulled":
+			if ev.LastTimestamp.After(at) {
+				at = ev.LastTimestamp.Time
+			}
+			if at.After(last) {
+				last = at
+			}
+		}
+	}
+	return first, last
+}
+
+// podCondition returns the condition of the given type, or nil if the pod does not report it
+func podCondition(pod *v1.Pod, t v1.PodConditionType) *v1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == t {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// nonNegative clamps durations made negative by second-granularity timestamps
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"adv-go/model"
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// dynamicClient writes the PodStatusReport custom resources, nil unless --status-reports is set
+var dynamicClient dynamic.Interface
+
+// statusReports is the resource of the PodStatusReport CRD in k8s-leader/podstatusreport-crd.yaml
+var statusReports = schema.GroupVersionResource{Group: "podlogger.io", Version: "v1alpha1", Resource: "podstatusreports"}
+
+// statusReportName is the name of the one report per namespace
+const statusReportName = "pod-logger"
+
+// maxReportedPods caps the pods listed by name in a report, the counts cover all of them
+const maxReportedPods = 50
+
+// reportedNamespaces are the namespaces the previous pass wrote a report to, only touched by the reporting loop
+var reportedNamespaces = make(map[string]bool)
+
+// podStatusReport is the status of a PodStatusReport
+type podStatusReport struct {
+	ObservedAt metav1.Time `json:"observedAt"`
+	Reporter   string      `json:"reporter"`
+	Pods       int         `json:"pods"`
+	Healthy    int         `json:"healthy"`
+	Degraded   int         `json:"degraded"`
+	Failed     int         `json:"failed"`
+	// States counts the pods by the state of the namespace summary, see summaryState
+	States map[string]int `json:"states"`
+	// UnhealthyPods are the pods not classified Healthy, worst first
+	UnhealthyPods []reportedPod `json:"unhealthyPods,omitempty"`
+}
+
+// reportedPod is a pod a PodStatusReport lists by name
+type reportedPod struct {
+	Name   string `json:"name"`
+	Health string `json:"health"`
+	Rule   string `json:"rule,omitempty"`
+	Phase  string `json:"phase"`
+}
+
+// writeStatusReports applies one PodStatusReport per namespace with the pass's pods. Namespaces whose pods are
+// all gone get an empty report rather than keeping the last one
+func writeStatusReports(ctx context.Context, pods []v1.Pod) {
+	if dynamicClient == nil || cfg.ReadOnly {
+		return
+	}
+	reports := make(map[string]*podStatusReport)
+	now := metav1.Now()
+	reporter := reporterIdentity()
+	report := func(ns string) *podStatusReport {
+		if reports[ns] == nil {
+			reports[ns] = &podStatusReport{ObservedAt: now, Reporter: reporter, States: make(map[string]int)}
+		}
+		return reports[ns]
+	}
+	for i := range pods {
+		pod := model.NewPod(&pods[i])
+		r := report(pod.Namespace())
+		r.Pods++
+		r.States[summaryState(pod)]++
+		health, rule := podHealth(pod)
+		switch health {
+		case model.HealthHealthy:
+			r.Healthy++
+			continue
+		case model.HealthDegraded:
+			r.Degraded++
+		case model.HealthFailed:
+			r.Failed++
+		}
+		r.UnhealthyPods = append(r.UnhealthyPods, reportedPod{Name: pod.Name(), Health: health, Rule: rule, Phase: string(pod.Phase())})
+	}
+	for ns := range reportedNamespaces {
+		report(ns)
+	}
+
+	written := make(map[string]bool, len(reports))
+	for _, ns := range sortedKeys(reports) {
+		if ctx.Err() != nil {
+			return
+		}
+		r := reports[ns]
+		sort.SliceStable(r.UnhealthyPods, func(i, j int) bool {
+			a, b := r.UnhealthyPods[i], r.UnhealthyPods[j]
+			if a.Health != b.Health {
+				return a.Health == model.HealthFailed
+			}
+			return a.Name < b.Name
+		})
+		if len(r.UnhealthyPods) > maxReportedPods {
+			r.UnhealthyPods = r.UnhealthyPods[:maxReportedPods]
+		}
+		err := applyStatusReport(ctx, ns, r)
+		if apierrors.IsNotFound(err) && r.Pods == 0 {
+			// The namespace went away with its pods and its report
+			continue
+		}
+		if apierrors.IsNotFound(err) {
+			collectorLog.Error("PodStatusReport is not served, install k8s-leader/podstatusreport-crd.yaml", "err", err)
+			return
+		}
+		if err != nil {
+			collectorLog.Error("Failed to write the pod status report", "namespace", ns, "err", err)
+			continue
+		}
+		if r.Pods > 0 {
+			written[ns] = true
+		}
+	}
+	reportedNamespaces = written
+}
+
+// applyStatusReport creates or replaces the namespace's report with a server-side apply
+func applyStatusReport(ctx context.Context, namespace string, report *podStatusReport) error {
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(report)
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": status}}
+	obj.SetAPIVersion(statusReports.GroupVersion().String())
+	obj.SetKind("PodStatusReport")
+	obj.SetNamespace(namespace)
+	obj.SetName(statusReportName)
+	obj.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "pod-logger"})
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err = dynamicClient.Resource(statusReports).Namespace(namespace).Apply(ctx, statusReportName, obj,
+		metav1.ApplyOptions{FieldManager: "pod-logger", Force: true})
+	return err
+}
+
+// reporterIdentity names the replica writing the reports
+func reporterIdentity() string {
+	if elector != nil {
+		return elector.Identity()
+	}
+	host, _ := os.Hostname()
+	return host
+}
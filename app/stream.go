@@ -0,0 +1,71 @@
+package app
+
+import (
+	"adv-go/model"
+	"adv-go/podstream"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// podStream fans pod status transitions out to WatchPodStatuses subscribers and dashboards, nil without
+// --grpc-addr or --dashboard
+var podStream *podstream.Hub
+
+// startPodStream serves the PodStatus gRPC service with --grpc-addr. The stream accepts subscribers on every replica
+// once the pod cache synced
+func startPodStream() {
+	podStream = podstream.NewHub()
+	podStream.Current = currentPodStatuses
+	if cfg.GRPCAddr == "" {
+		return
+	}
+	if err := podstream.Serve(cfg.GRPCAddr, podStream, apiAuth.ServerOptions()...); err != nil {
+		agentLog.Fatal("Failed to serve pod status stream", "addr", cfg.GRPCAddr, "err", err)
+	}
+}
+
+// publishPodStatus streams the pod's transition when its state changed, old is nil for new pods and pod is nil
+// for deleted ones
+func publishPodStatus(old, pod *v1.Pod) {
+	if podStream == nil {
+		return
+	}
+	u := podstream.Update{ObservedAt: time.Now()}
+	current := pod
+	if old != nil {
+		u.PreviousState = model.PodState(model.NewPod(old))
+		current = old
+	}
+	if pod != nil {
+		u.State = model.PodState(model.NewPod(pod))
+		current = pod
+	} else {
+		u.State = "Deleted"
+	}
+	if u.State == u.PreviousState {
+		return
+	}
+	u.Namespace, u.Pod, u.Node = current.Namespace, current.Name, current.Spec.NodeName
+	if cfg.Dashboard {
+		rememberTransition(u)
+	}
+	podStream.Publish(u)
+}
+
+// currentPodStatuses returns the state of the pods in the pod store, limited to a namespace unless it is empty
+func currentPodStatuses(namespace string) []podstream.Update {
+	pods := podStore.List()
+	if namespace != "" {
+		pods = podStore.ByNamespace(namespace)
+	}
+	pods = monitoredPods(pods)
+	now := time.Now()
+	updates := make([]podstream.Update, 0, len(pods))
+	for _, pod := range pods {
+		updates = append(updates, podstream.Update{
+			Namespace: pod.Namespace(), Pod: pod.Name(), Node: pod.NodeName(), State: model.PodState(pod), ObservedAt: now,
+		})
+	}
+	return updates
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// summaryState is the state a pod is counted under in the namespace summary: its first waiting reason, so crash
+// looping and image pull failures stand out from the pods that run fine, otherwise its phase
+func summaryState(pod *model.Pod) string {
+	if reasons := pod.WaitingReasons(); len(reasons) > 0 {
+		sort.Strings(reasons)
+		return reasons[0]
+	}
+	return string(pod.Phase())
+}
+
+// formatNamespaceSummary renders one line per namespace counting its pods by state, most common state first, with
+// the namespace names padded so the lines read as a table
+func formatNamespaceSummary(pods []v1.Pod) []string {
+	counts := make(map[string]map[string]int)
+	totals := make(map[string]int)
+	width := 0
+	for i := range pods {
+		pod := model.NewPod(&pods[i])
+		ns := pod.Namespace()
+		if counts[ns] == nil {
+			counts[ns] = make(map[string]int)
+		}
+		counts[ns][summaryState(pod)]++
+		totals[ns]++
+		width = max(width, len(ns))
+	}
+
+	lines := make([]string, 0, len(counts))
+	for _, ns := range sortedKeys(counts) {
+		states := sortedKeys(counts[ns])
+		sort.SliceStable(states, func(i, j int) bool { return counts[ns][states[i]] > counts[ns][states[j]] })
+		parts := make([]string, len(states))
+		for i, state := range states {
+			parts[i] = fmt.Sprintf("%d %s", counts[ns][state], state)
+		}
+		lines = append(lines, fmt.Sprintf("Namespace Summary: %-*s %5d pods: %s", width+1, ns+":", totals[ns], strings.Join(parts, ", ")))
+	}
+	return lines
+}
+
+// logNamespaceSummary writes the namespace summary of a pass to the log and the sinks
+func logNamespaceSummary(ctx context.Context, pods []v1.Pod) {
+	lines := formatNamespaceSummary(pods)
+	batch := make([][]byte, len(lines))
+	for i, line := range lines {
+		collectorLog.Info(line)
+		batch[i] = []byte(line)
+	}
+	writeBatch(ctx, sink.PriorityHeartbeat, batch)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"adv-go/model"
+	"bytes"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// podTemplateFuncs are the functions available to --template besides the text/template builtins
+var podTemplateFuncs = template.FuncMap{
+	"join":  func(sep string, items []string) string { return strings.Join(items, sep) },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// podTemplateData is what --template is applied to: the pod model, whose methods such as .Name, .Phase and
+// .NodeName are available as fields, and what the built-in format appends to it
+type podTemplateData struct {
+	*model.Pod
+	VirtualNode string
+	NodeState   []string
+	Usage       string
+	Enrichment  []string
+	// Health is the pod's class by the health rules and HealthRule the rule deciding it, empty when none matched
+	Health     string
+	HealthRule string
+}
+
+// compilePodTemplate parses --template and renders it once against an empty pod, so a misspelled field fails at
+// startup rather than on every record
+func compilePodTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("pod").Funcs(podTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, podTemplateData{Pod: model.NewPod(&v1.Pod{})}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// templateEncoder renders the pod records with --template. The template data's extras come from the record
+// fields the built-in format would append
+type templateEncoder struct {
+	tmpl *template.Template
+}
+
+// Encode implements model.Encoder, falling back to the built-in format when the template fails
+func (e templateEncoder) Encode(pod *model.Pod) ([]byte, error) {
+	data := podTemplateData{Pod: pod}
+	data.Health, data.HealthRule = podHealth(pod)
+	if f, ok := pod.RecordField("Virtual Node"); ok {
+		data.VirtualNode = f.Value
+	}
+	if f, ok := pod.RecordField("Node State"); ok {
+		data.NodeState = f.Values
+	}
+	if cpu, ok := pod.RecordField("CPU"); ok {
+		memory, _ := pod.RecordField("Memory")
+		data.Usage = "CPU: " + cpu.Value + ", Memory: " + memory.Value
+	}
+	if f, ok := pod.RecordField("Enrichment"); ok {
+		data.Enrichment = f.Values
+	}
+
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, data); err != nil {
+		collectorLog.Error("Error rendering pod template", "namespace", pod.Namespace(), "pod", pod.Name(), "err", err)
+		return model.TextEncoder{}.Encode(pod)
+	}
+	record := bytes.TrimRight(buf.Bytes(), "\n")
+	if f, ok := pod.RecordField("Transition"); ok {
+		record = append(record, ", Transition: "+f.Value...)
+	}
+	return record, nil
+}
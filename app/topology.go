@@ -0,0 +1,248 @@
+package app
+
+import (
+	"adv-go/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Findings of the topology report
+const (
+	// topologySingleNode is a workload whose replicas all run on one node
+	topologySingleNode = "single-node"
+	// topologySingleZone is a workload whose replicas all run in one zone of a cluster with several
+	topologySingleZone = "single-zone"
+)
+
+// unspreadKinds are the workloads whose placement is not about availability: daemons run on every node by design,
+// batch pods and bare or static pods have no replicas to spread
+var unspreadKinds = map[string]bool{"DaemonSet": true, "Job": true, "CronJob": true, "Pod": true, "Node": true}
+
+// workloadTopology is how a workload's running and pending pods are spread over the nodes and zones
+type workloadTopology struct {
+	Namespace string         `json:"namespace"`
+	Workload  string         `json:"workload"`
+	Pods      int            `json:"pods"`
+	Nodes     map[string]int `json:"nodes"`
+	Zones     map[string]int `json:"zones,omitempty"`
+	// Unscheduled are the pods without a node, left out of the counts by node and zone
+	Unscheduled int `json:"unscheduled,omitempty"`
+	// Spread lists the topology keys of the pods' spread constraints and anti-affinity, empty when they set none
+	Spread  []string `json:"spread"`
+	Finding string   `json:"finding,omitempty"`
+}
+
+// topologyReport is the placement of every replicated workload, with the number of zones the nodes are in
+type topologyReport struct {
+	Zones     int                `json:"zones"`
+	Workloads []workloadTopology `json:"workloads"`
+}
+
+// nodeZone is the zone of the node from its topology label, or the deprecated failure-domain label
+func nodeZone(node *v1.Node) string {
+	if zone := node.Labels[v1.LabelTopologyZone]; zone != "" {
+		return zone
+	}
+	return node.Labels[v1.LabelFailureDomainBetaZone]
+}
+
+// buildTopology spreads each replicated workload's live pods over the nodes and zones and flags those packed on a
+// single node, with at least two pods, or in a single zone while the nodes are in several
+func buildTopology(pods []v1.Pod, nodes []v1.Node) topologyReport {
+	zoneOf := make(map[string]string, len(nodes))
+	zones := make(map[string]bool)
+	for i := range nodes {
+		if zone := nodeZone(&nodes[i]); zone != "" {
+			zoneOf[nodes[i].Name] = zone
+			zones[zone] = true
+		}
+	}
+
+	byWorkload := make(map[string]*workloadTopology)
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed || pod.DeletionTimestamp != nil {
+			continue
+		}
+		workload := model.NewPod(pod).Workload()
+		kind, _, _ := strings.Cut(workload, "/")
+		if unspreadKinds[kind] {
+			continue
+		}
+		key := pod.Namespace + "/" + workload
+		t := byWorkload[key]
+		if t == nil {
+			t = &workloadTopology{Namespace: pod.Namespace, Workload: workload, Nodes: make(map[string]int), Spread: podSpreadKeys(pod)}
+			byWorkload[key] = t
+		}
+		t.Pods++
+		if pod.Spec.NodeName == "" {
+			t.Unscheduled++
+			continue
+		}
+		t.Nodes[pod.Spec.NodeName]++
+		if zone := zoneOf[pod.Spec.NodeName]; zone != "" {
+			if t.Zones == nil {
+				t.Zones = make(map[string]int)
+			}
+			t.Zones[zone]++
+		}
+	}
+
+	report := topologyReport{Zones: len(zones), Workloads: []workloadTopology{}}
+	for _, key := range sortedKeys(byWorkload) {
+		t := byWorkload[key]
+		scheduled := t.Pods - t.Unscheduled
+		switch {
+		case scheduled >= 2 && len(t.Nodes) == 1:
+			t.Finding = topologySingleNode
+		case scheduled >= 2 && len(zones) > 1 && len(t.Zones) == 1:
+			t.Finding = topologySingleZone
+		}
+		report.Workloads = append(report.Workloads, *t)
+	}
+	return report
+}
+
+// podSpreadKeys lists the topology keys the pod spreads over, by spread constraint or by anti-affinity to pods
+// like it, e.g. [topology.kubernetes.io/zone kubernetes.io/hostname]
+func podSpreadKeys(pod *v1.Pod) []string {
+	keys := []string{}
+	add := func(key string) {
+		for _, k := range keys {
+			if k == key {
+				return
+			}
+		}
+		keys = append(keys, key)
+	}
+	for _, c := range pod.Spec.TopologySpreadConstraints {
+		add(c.TopologyKey)
+	}
+	if pod.Spec.Affinity != nil && pod.Spec.Affinity.PodAntiAffinity != nil {
+		anti := pod.Spec.Affinity.PodAntiAffinity
+		for _, term := range anti.RequiredDuringSchedulingIgnoredDuringExecution {
+			add(term.TopologyKey)
+		}
+		for _, term := range anti.PreferredDuringSchedulingIgnoredDuringExecution {
+			add(term.PodAffinityTerm.TopologyKey)
+		}
+	}
+	return keys
+}
+
+// topologyHandler serves the topology report of the cached pods, optionally of one ?namespace=
+func topologyHandler(clientset kubernetes.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pods := cachedPods()
+		if pods == nil {
+			http.Error(w, "pod cache not synced yet", http.StatusServiceUnavailable)
+			return
+		}
+		nodes, err := getAllNodes(r.Context(), clientset)
+		if err != nil {
+			http.Error(w, "error listing nodes: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		namespace := r.URL.Query().Get("namespace")
+		kept := pods[:0]
+		for _, pod := range pods {
+			if namespace == "" || pod.Namespace == namespace {
+				kept = append(kept, pod)
+			}
+		}
+		writeJSON(w, buildTopology(kept, nodes.Items))
+	}
+}
+
+// newTopologyCommand creates the topology command
+func newTopologyCommand() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "topology",
+		Short: "Show how each workload's pods are spread over nodes and zones, flagging those packed on one node or zone",
+		Long: `Show how each workload's pods are spread over nodes and zones, flagging those packed on one node or zone.
+
+Zones come from the topology.kubernetes.io/zone node label. A workload with at least two pods is flagged
+single-node when they all run on one node, and single-zone when they all run in one zone while the nodes are in
+several. The spread column lists the topology keys of the pods' spread constraints and anti-affinity, so a packed
+workload that sets none stands out. Exits 1 when a workload is flagged.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(); err != nil {
+				return err
+			}
+			return exitCode(runTopology(clientset, asJSON))
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+	return cmd
+}
+
+// runTopology lists the monitored pods and the nodes once and prints the report, returning 1 when a workload is
+// packed on one node or zone
+func runTopology(clientset kubernetes.Interface, asJSON bool) int {
+	ctx := context.Background()
+	pods, err := getAllPods(ctx, clientset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing pods: %v\n", err)
+		return 1
+	}
+	nodes, err := getAllNodes(ctx, clientset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listing nodes: %v\n", err)
+		return 1
+	}
+	report := buildTopology(pods.Items, nodes.Items)
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing the report: %v\n", err)
+			return 1
+		}
+	} else {
+		printTopology(report)
+	}
+	for _, t := range report.Workloads {
+		if t.Finding != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// printTopology prints one row per workload with its pods by zone, or by node when the nodes have no zones
+func printTopology(report topologyReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tWORKLOAD\tPODS\tNODES\tZONES\tDISTRIBUTION\tSPREAD\tFINDING")
+	for _, t := range report.Workloads {
+		counts := t.Zones
+		if report.Zones == 0 {
+			counts = t.Nodes
+		}
+		distribution := make([]string, 0, len(counts))
+		for _, name := range sortedKeys(counts) {
+			distribution = append(distribution, fmt.Sprintf("%s=%d", name, counts[name]))
+		}
+		if t.Unscheduled > 0 {
+			distribution = append(distribution, fmt.Sprintf("unscheduled=%d", t.Unscheduled))
+		}
+		spread := strings.Join(t.Spread, ",")
+		if spread == "" {
+			spread = "none"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", t.Namespace, t.Workload, t.Pods, len(t.Nodes), len(t.Zones),
+			strings.Join(distribution, ","), spread, t.Finding)
+	}
+	w.Flush()
+}
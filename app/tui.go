@@ -0,0 +1,234 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/logging"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// tuiRefresh is how often the terminal UI redraws the pods, a key press redraws at once
+	tuiRefresh = time.Second
+	// tuiLogLines is how many of the latest log lines are shown below the table
+	tuiLogLines = 3
+)
+
+// tuiSorts are the orders the terminal UI sorts by, by key
+var tuiSorts = map[byte]string{'n': "name", 'p': "phase", 'r': "restarts", 'a': "age"}
+
+// tuiRow is a pod as a row of the terminal UI
+type tuiRow struct {
+	podSnapshot
+	created time.Time
+}
+
+// tuiLog keeps the latest log lines for the terminal UI instead of writing them over it
+type tuiLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// Write keeps the complete lines of p, the handlers write one record per call
+func (l *tuiLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+	}
+	if len(l.lines) > tuiLogLines {
+		l.lines = l.lines[len(l.lines)-tuiLogLines:]
+	}
+	return len(p), nil
+}
+
+// latest returns the kept lines, oldest first
+func (l *tuiLog) latest() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// startTUI takes over the terminal and draws the pods of the read cache until q is pressed or the agent is stopped,
+// then restores the terminal and exits. The log goes below the table
+func startTUI() error {
+	in, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(outFd) {
+		return errors.New("--tui needs a terminal on stdin and stdout")
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return fmt.Errorf("error switching the terminal to raw mode: %w", err)
+	}
+	logs := &tuiLog{}
+	if err := logging.SetOutput(logs); err != nil {
+		term.Restore(in, state)
+		return err
+	}
+	// The alternate screen leaves the shell's scrollback as it was
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	quit := func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(in, state)
+		os.Exit(0)
+	}
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(buf); err != nil {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		ticker := time.NewTicker(tuiRefresh)
+		defer ticker.Stop()
+		by, reverse := "name", false
+		for {
+			drawTUI(outFd, by, reverse, logs.latest())
+			select {
+			case key, ok := <-keys:
+				if !ok || key == 'q' || key == 3 {
+					// 3 is Ctrl-C, which raw mode delivers as a key rather than a signal
+					quit()
+				}
+				if next, ok := tuiSorts[key]; ok {
+					// The same key again flips the order
+					reverse = next == by && !reverse
+					by = next
+				}
+			case <-signals:
+				quit()
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// drawTUI redraws the screen: a summary line, the keys, the pods sorted as chosen and cut to the terminal and the
+// latest log lines
+func drawTUI(fd int, by string, reverse bool, logLines []string) {
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		width, height = 120, 40
+	}
+	var lines []string
+	rows, synced := tuiRows()
+	order := by
+	if reverse {
+		order += " (reversed)"
+	}
+	if !synced {
+		lines = append(lines, "pod-logger  waiting for the pod cache to sync...")
+	} else {
+		phases := make(map[string]int)
+		for _, r := range rows {
+			phases[r.Phase]++
+		}
+		summary := fmt.Sprintf("pod-logger  %d pods", len(rows))
+		for _, phase := range sortedKeys(phases) {
+			summary += fmt.Sprintf("  %s %d", phase, phases[phase])
+		}
+		lines = append(lines, summary+"  "+format.Time(time.Now()))
+	}
+	lines = append(lines, "sorted by "+order+"  keys: n name  p phase  r restarts  a age (again to reverse)  q quit", "")
+
+	if synced {
+		sortTUIRows(rows, by, reverse)
+		var table bytes.Buffer
+		w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAMESPACE\tPOD\tPHASE\tHEALTH\tREADY\tRESTARTS\tAGE\tNODE\tWAITING")
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d/%d\t%d\t%s\t%s\t%s\n", r.Namespace, r.Name, r.Phase, r.Health, r.Ready,
+				r.Containers, r.Restarts, format.Age(time.Since(r.created)), r.Node, strings.Join(r.Waiting, ","))
+		}
+		w.Flush()
+		tableLines := strings.Split(strings.TrimRight(table.String(), "\n"), "\n")
+		// Room is kept for the log and, when the pods do not fit, the line saying how many were cut
+		room := height - len(lines) - len(logLines) - 1
+		if len(logLines) > 0 {
+			room--
+		}
+		if room < 1 {
+			room = 1
+		}
+		if len(tableLines) > room {
+			cut := len(tableLines) - room + 1
+			tableLines = append(tableLines[:room-1], fmt.Sprintf("... %d more pods", cut))
+		}
+		lines = append(lines, tableLines...)
+	}
+	if len(logLines) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, logLines...)
+	}
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		if runes := []rune(line); len(runes) > width {
+			line = string(runes[:width])
+		}
+		// Raw mode does not turn a newline into a carriage return and line feed
+		screen.WriteString(line + "\x1b[K\r\n")
+	}
+	screen.WriteString("\x1b[J")
+	os.Stdout.WriteString(screen.String())
+}
+
+// tuiRows are the monitored pods of the read cache, false until it synced
+func tuiRows() ([]tuiRow, bool) {
+	if !podStore.HasSynced() {
+		return nil, false
+	}
+	stored := monitoredPods(podStore.List())
+	rows := make([]tuiRow, 0, len(stored))
+	for _, p := range stored {
+		rows = append(rows, tuiRow{podSnapshot: newPodSnapshot(p), created: p.Object().CreationTimestamp.Time})
+	}
+	return rows, true
+}
+
+// sortTUIRows orders the rows by name, by phase, by most restarts or by youngest first, then by name
+func sortTUIRows(rows []tuiRow, by string, reverse bool) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if reverse {
+			a, b = b, a
+		}
+		switch {
+		case by == "phase" && a.Phase != b.Phase:
+			return a.Phase < b.Phase
+		case by == "restarts" && a.Restarts != b.Restarts:
+			return a.Restarts > b.Restarts
+		case by == "age" && !a.created.Equal(b.created):
+			return a.created.After(b.created)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// stuckPod is a pod Pending without a node for longer than the threshold
+type stuckPod struct {
+	namespace string
+	name      string
+	pending   time.Duration
+	message   string
+}
+
+// detectUnschedulablePods explains why the pods Pending without a node for longer than --unschedulable-threshold
+// cannot be scheduled, from their PodScheduled condition or else their latest FailedScheduling event
+func detectUnschedulablePods(ctx context.Context, clientset kubernetes.Interface, pods []v1.Pod) {
+	metrics.UnschedulablePods.Reset()
+	threshold := cfg.UnschedulableThreshold.Duration
+	if threshold <= 0 {
+		return
+	}
+
+	var stuck []stuckPod
+	needEvents := false
+	for i := range pods {
+		pod := &pods[i]
+		pending := time.Since(pod.CreationTimestamp.Time)
+		if pod.Status.Phase != v1.PodPending || pod.Spec.NodeName != "" || pending < threshold {
+			continue
+		}
+		s := stuckPod{namespace: pod.Namespace, name: pod.Name, pending: pending}
+		if c := podCondition(pod, v1.PodScheduled); c != nil && c.Status == v1.ConditionFalse {
+			s.message = c.Message
+			if c.Reason == v1.PodReasonSchedulingGated {
+				s.message = "waiting on scheduling gates " + schedulingGates(pod)
+			}
+		}
+		needEvents = needEvents || s.message == ""
+		stuck = append(stuck, s)
+	}
+	if len(stuck) == 0 {
+		return
+	}
+
+	// The condition message is only set once the scheduler tried, its events may still explain an older attempt
+	var failures map[string]string
+	if needEvents {
+		events, err := getAllEvents(ctx, clientset)
+		if err != nil {
+			collectorLog.Error("Error listing events", "err", err)
+		}
+		failures = latestSchedulingFailures(events)
+	}
+
+	for _, s := range stuck {
+		if s.message == "" {
+			s.message = failures[s.namespace+"/"+s.name]
+		}
+		logUnschedulablePod(s)
+	}
+}
+
+// logUnschedulablePod writes the pod's diagnosis to the sinks and the log
+func logUnschedulablePod(s stuckPod) {
+	explanation := "not attempted by the scheduler yet"
+	categories := []string{"pending"}
+	if strings.HasPrefix(s.message, "waiting on scheduling gates") {
+		explanation, categories = s.message, []string{model.SchedulingGated}
+	} else if s.message != "" {
+		diagnosis := model.DiagnoseScheduling(s.message)
+		explanation, categories = diagnosis.String(), diagnosis.Categories()
+	}
+	for _, category := range categories {
+		metrics.UnschedulablePods.WithLabelValues(s.namespace, category).Inc()
+	}
+
+	status := fmt.Sprintf("Unschedulable: Pod %s/%s pending %s: %s", s.namespace, s.name, format.Age(s.pending), explanation)
+	if err := sink.WritePriority(out, sink.PriorityTransition, []byte(status)); err != nil {
+		collectorLog.Error("Error writing to sink", "err", err)
+	}
+	collectorLog.Warn("Pod cannot be scheduled", "namespace", s.namespace, "pod", s.name,
+		"pending", format.Age(s.pending), "categories", strings.Join(categories, ","), "reason", explanation)
+}
+
+// latestSchedulingFailures maps "namespace/pod" to the message of the pod's most recent FailedScheduling event
+func latestSchedulingFailures(events []*model.Event) map[string]string {
+	failures := make(map[string]string)
+	latest := make(map[string]time.Time)
+	for _, ev := range events {
+		if ev.Reason != "FailedScheduling" || ev.Regarding.Kind != "Pod" {
+			continue
+		}
+		key := ev.Regarding.Namespace + "/" + ev.Regarding.Name
+		if at, ok := latest[key]; !ok || ev.LastObserved.After(at) {
+			latest[key] = ev.LastObserved
+			failures[key] = ev.Note
+		}
+	}
+	return failures
+}
+
+// schedulingGates lists the gates holding the pod back, like "[example.com/quota]"
+func schedulingGates(pod *v1.Pod) string {
+	names := make([]string, len(pod.Spec.SchedulingGates))
+	for i, g := range pod.Spec.SchedulingGates {
+		names[i] = g.Name
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// podMetricsClient reads current pod usage from metrics-server, nil unless --with-metrics is set
+var podMetricsClient metricsclientset.Interface
+
+// podUsage maps "namespace/name" to the pod's current resource usage for one reporting pass
+type podUsage map[string]v1.ResourceList
+
+// loadPodUsage fetches the current usage of the pods in the monitored namespaces, or returns nil when disabled
+func loadPodUsage() podUsage {
+	if podMetricsClient == nil {
+		return nil
+	}
+	usage := make(podUsage)
+	for _, namespace := range monitoredNamespaces() {
+		list, err := podMetricsClient.MetricsV1beta1().PodMetricses(namespace).List(context.Background(), metav1.ListOptions{
+			LabelSelector: cfg.LabelSelector,
+		})
+		if err != nil {
+			collectorLog.Error("Error reading pod metrics", "namespace", namespace, "err", err)
+			continue
+		}
+		for _, pm := range list.Items {
+			total := v1.ResourceList{}
+			for _, c := range pm.Containers {
+				addResources(total, c.Usage)
+			}
+			usage[pm.Namespace+"/"+pm.Name] = total
+		}
+	}
+	return usage
+}
+
+// describe renders the pod's CPU and memory usage against its requests and limits, empty when disabled
+func (u podUsage) describe(pod *model.Pod) string {
+	cpu, memory, ok := u.resources(pod)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(", CPU: %s, Memory: %s", cpu, memory)
+}
+
+// addFields adds the pod's CPU and memory usage to its record, nothing when disabled
+func (u podUsage) addFields(pod *model.Pod) {
+	if cpu, memory, ok := u.resources(pod); ok {
+		pod.AddRecordField("CPU", cpu)
+		pod.AddRecordField("Memory", memory)
+	}
+}
+
+// resources renders the pod's CPU and memory as [usage, request, limit], false when usage is disabled
+func (u podUsage) resources(pod *model.Pod) (string, string, bool) {
+	if u == nil {
+		return "", "", false
+	}
+	used, ok := u[pod.Namespace()+"/"+pod.Name()]
+	if !ok {
+		used = v1.ResourceList{}
+	}
+	requests, limits := pod.Requests(), pod.Limits()
+	return fmt.Sprintf("[usage %s, request %s, limit %s]", cpuOf(used, ok), cpuOf(requests, true), cpuOf(limits, true)),
+		fmt.Sprintf("[usage %s, request %s, limit %s]", memoryOf(used, ok), memoryOf(requests, true), memoryOf(limits, true)),
+		true
+}
+
+// cpuOf renders the CPU of the list, "-" when unknown or not set
+func cpuOf(list v1.ResourceList, known bool) string {
+	q, ok := list[v1.ResourceCPU]
+	if !known || !ok {
+		return "-"
+	}
+	return format.CPU(q.MilliValue())
+}
+
+// memoryOf renders the memory of the list, "-" when unknown or not set
+func memoryOf(list v1.ResourceList, known bool) string {
+	q, ok := list[v1.ResourceMemory]
+	if !known || !ok {
+		return "-"
+	}
+	return format.Bytes(q.Value())
+}
+
+// addResources adds the quantities of src to dst
+func addResources(dst, src v1.ResourceList) {
+	for name, q := range src {
+		sum := dst[name]
+		sum.Add(q)
+		dst[name] = sum
+	}
+}
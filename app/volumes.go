@@ -0,0 +1,147 @@
+package app
+
+import (
+	"adv-go/format"
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// logVolumeStatus logs one line per PersistentVolumeClaim with its bound volume, and warns about pods that cannot
+// start because a claim they mount is unbound
+func logVolumeStatus(ctx context.Context, clientset kubernetes.Interface, pods []v1.Pod) {
+	volumes, err := retryCall(ctx, "list persistent volumes", func(ctx context.Context) (*v1.PersistentVolumeList, error) {
+		return clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	})
+	if err != nil {
+		collectorLog.Error("Error listing persistent volumes", "err", err)
+		return
+	}
+	pvs := make(map[string]*v1.PersistentVolume, len(volumes.Items))
+	for i := range volumes.Items {
+		pvs[volumes.Items[i].Name] = &volumes.Items[i]
+	}
+
+	claims := make(map[string]*model.PVC)
+	for _, namespace := range monitoredNamespaces() {
+		list, err := retryCall(ctx, "list persistent volume claims", func(ctx context.Context) (*v1.PersistentVolumeClaimList, error) {
+			return clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing persistent volume claims", "namespace", namespace, "err", err)
+			continue
+		}
+		for i := range list.Items {
+			pvc := model.NewPVC(&list.Items[i])
+			claims[pvc.Namespace()+"/"+pvc.Name()] = pvc
+		}
+	}
+
+	waiting := waitingOnClaims(pods, claims)
+	var batch [][]byte
+	for _, key := range sortedKeys(claims) {
+		pvc := claims[key]
+		status := formatPVCStatus(pvc, pvs[pvc.VolumeName()], waiting[key])
+		if pvc.IsBound() {
+			collectorLog.Info(status, "namespace", pvc.Namespace(), "pvc", pvc.Name())
+		} else {
+			collectorLog.Warn(status, "namespace", pvc.Namespace(), "pvc", pvc.Name())
+		}
+		batch = append(batch, []byte(status))
+	}
+	writeBatch(ctx, sink.PriorityEnrichment, batch)
+
+	for _, key := range sortedKeys(waiting) {
+		for _, w := range waiting[key] {
+			collectorLog.Warn("Pod is waiting for an unbound volume claim", "namespace", w.namespace, "pod", w.pod,
+				"pvc", strings.TrimPrefix(key, w.namespace+"/"), "phase", w.claimPhase, "reason", w.reason)
+		}
+	}
+}
+
+// claimWait is a pending pod mounting a claim that is not bound
+type claimWait struct {
+	namespace  string
+	pod        string
+	reason     string
+	claimPhase string
+}
+
+// waitingOnClaims maps "namespace/claim" to the pending pods mounting it while it is unbound or missing
+func waitingOnClaims(pods []v1.Pod, claims map[string]*model.PVC) map[string][]claimWait {
+	waiting := make(map[string][]claimWait)
+	for i := range pods {
+		pod := model.NewPod(&pods[i])
+		if pod.Phase() != v1.PodPending {
+			continue
+		}
+		// Scheduled pods wait in ContainerCreating for the volume to attach, unscheduled ones for it to bind
+		reason := "Unschedulable"
+		if pod.IsScheduled() {
+			reason = "ContainerCreating"
+		}
+		for _, name := range pod.ClaimNames() {
+			key := pod.Namespace() + "/" + name
+			phase := "Missing"
+			if pvc, ok := claims[key]; ok {
+				if pvc.IsBound() {
+					continue
+				}
+				phase = string(pvc.Phase())
+			}
+			waiting[key] = append(waiting[key], claimWait{namespace: pod.Namespace(), pod: pod.Name(), reason: reason, claimPhase: phase})
+		}
+	}
+	return waiting
+}
+
+// formatPVCStatus renders a claim, the volume bound to it and the pods waiting for it as a log line
+func formatPVCStatus(pvc *model.PVC, pv *v1.PersistentVolume, waiting []claimWait) string {
+	capacity, provisioned := pvc.Capacity()
+	size := format.Bytes(capacity.Value())
+	if !provisioned {
+		size += " requested"
+	}
+	storageClass := pvc.StorageClass()
+	if storageClass == "" {
+		storageClass = "<default>"
+	}
+
+	volume := "<none>"
+	switch {
+	case pv != nil:
+		volume = fmt.Sprintf("%s (%s, %s)", pv.Name, pv.Status.Phase, pv.Spec.PersistentVolumeReclaimPolicy)
+	case pvc.VolumeName() != "":
+		// A Lost claim points at a volume that no longer exists
+		volume = pvc.VolumeName() + " (missing)"
+	}
+
+	status := fmt.Sprintf("PVC: %s/%s, Phase: %s, Volume: %s, Capacity: %s, StorageClass: %s",
+		pvc.Namespace(), pvc.Name(), pvc.Phase(), volume, size, storageClass)
+	if len(waiting) > 0 {
+		names := make([]string, len(waiting))
+		for i, w := range waiting {
+			names[i] = w.pod
+		}
+		sort.Strings(names)
+		status += fmt.Sprintf(", Waiting Pods: [%s]", strings.Join(names, ","))
+	}
+	return status
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
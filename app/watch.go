@@ -0,0 +1,187 @@
+package app
+
+import (
+	"adv-go/features"
+	"adv-go/format"
+	"adv-go/metrics"
+	"adv-go/tracing"
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// startPodWatch watches pods and their Warning events in the monitored namespaces and measures how far behind the
+// agent's view is, recording node lifecycles when history is enabled and the cluster-scoped work is owned. With the
+// EndpointLatency gate it also measures endpoint propagation. It returns once the informers have warmed up
+func startPodWatch(ctx context.Context, clientset kubernetes.Interface) {
+	if historyStore != nil && ownsClusterWork() {
+		startNodeTimeline(ctx, clientset)
+	}
+	endpoints := features.Enabled(features.EndpointLatency)
+	if endpoints && !cluster.EndpointSlices {
+		watchLog.Warn("EndpointSlices are not served, not measuring endpoint propagation")
+		endpoints = false
+	}
+	watchPods(ctx, clientset)
+	for _, namespace := range watchedNamespaces() {
+		if !ownsNamespaceWork() {
+			continue
+		}
+		watchPodEvents(ctx, clientset, namespace)
+		if endpoints {
+			watchEndpointSlices(ctx, clientset, namespace)
+		}
+	}
+	warmUp(ctx)
+}
+
+// watchPods handles the changes of the pods in the monitored namespaces for the leadership term, fed by the read
+// cache's informers
+func watchPods(ctx context.Context, clientset kubernetes.Interface) {
+	leaderPodHandler(ctx, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			// Adds replaying the cache when the term starts describe old state, warmUp waits for them to pass
+			if isWarm() {
+				observePodLag(obj)
+				if pod, ok := obj.(*v1.Pod); ok {
+					recordPodTransition(nil, pod, string(pod.Status.Phase))
+					observePodSpec(nil, pod)
+					linkSuccessor(pod)
+					observePodChurn(pod, true)
+					observePodScheduled(pod)
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, obj interface{}) {
+			if !isWarm() {
+				return
+			}
+			observePodLag(obj)
+			if pod, ok := obj.(*v1.Pod); ok {
+				if old, ok := oldObj.(*v1.Pod); ok {
+					recordPodTransition(old, pod, string(pod.Status.Phase))
+					observePodSpec(old, pod)
+					recordPodRestarts(old, pod)
+					observePodDeparture(old, pod)
+					observeOOMKills(old, pod)
+					if features.Enabled(features.EndpointLatency) {
+						observePodReady(old, pod)
+					}
+				}
+				observePodScheduled(pod)
+				// Looking up pull events hits the apiserver, keep it off the informer goroutine
+				goLeader(func() { observePodStartup(clientset, pod) })
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if !isWarm() {
+				return
+			}
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				forgetPodStartup(pod)
+				recordPodDisruption(pod)
+				recordPodTransition(nil, pod, "Deleted")
+				forgetPodSpec(pod)
+				observePodDeparture(nil, pod)
+				observePodChurn(pod, false)
+				// Looking up the owner's events hits the apiserver, keep it off the informer goroutine
+				goLeader(func() { recordPodDeletion(clientset, pod) })
+				if historyStore != nil {
+					goLeader(func() { checkNodeDrained(clientset, pod.Spec.NodeName) })
+				}
+			}
+		},
+	})
+}
+
+// ownedPodHandler passes on the pods this replica owns and does not ignore, the pods of other replicas when hashing
+// pods are dropped. A term's handlers are added with each rebalance, so ownership is that of the term
+func ownedPodHandler(handler cache.ResourceEventHandler) cache.ResourceEventHandler {
+	if !hashingPods() && len(cfg.IgnoreNamespaces) == 0 && len(ignoredLabels) == 0 {
+		return handler
+	}
+	return podFilter(handler, func(pod *v1.Pod) bool { return ownsPod(pod) && !ignoredPod(pod) })
+}
+
+// keptPodHandler passes on the pods not ignored, whichever replica owns them
+func keptPodHandler(handler cache.ResourceEventHandler) cache.ResourceEventHandler {
+	if len(cfg.IgnoreNamespaces) == 0 && len(ignoredLabels) == 0 {
+		return handler
+	}
+	return podFilter(handler, func(pod *v1.Pod) bool { return !ignoredPod(pod) })
+}
+
+// podFilter passes on the pods, including those of tombstones, that keep reports true for
+func podFilter(handler cache.ResourceEventHandler, keep func(*v1.Pod) bool) cache.ResourceEventHandler {
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			pod, ok := obj.(*v1.Pod)
+			return ok && keep(pod)
+		},
+		Handler: handler,
+	}
+}
+
+// observePodLag records the lag between the pod's latest change and now
+func observePodLag(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	changed := latestPodTimestamp(pod)
+	if changed.IsZero() {
+		return
+	}
+
+	ctx, span := tracing.Start(context.Background(), "observe pod",
+		attribute.String("namespace", pod.Namespace), attribute.String("pod", pod.Name))
+	defer span.End()
+
+	lag := time.Since(changed)
+	if lag < 0 {
+		lag = 0
+	}
+	span.SetAttributes(attribute.Float64("lag_seconds", lag.Seconds()))
+	watchLog.Debug("Observed pod", append(podFields(pod), "lag", format.Duration(lag))...)
+	metrics.ObserveWithTrace(metrics.WatchLag.WithLabelValues("pod"), lag.Seconds(), tracing.TraceID(ctx))
+	metrics.WatchLagLast.WithLabelValues("pod").Set(lag.Seconds())
+	lastPodLag.Store(int64(lag))
+
+	if cfg.LagAlertThreshold.Duration > 0 && lag > cfg.LagAlertThreshold.Duration {
+		metrics.WatchLagAlerts.WithLabelValues("pod").Inc()
+		watchLog.Warn(tracing.Annotate(ctx, fmt.Sprintf("ALERT: watch lag %s exceeds %s for pod %s/%s, agent view is stale",
+			format.Duration(lag), format.Duration(cfg.LagAlertThreshold.Duration), pod.Namespace, pod.Name)),
+			append(podFields(pod), "lag", format.Duration(lag))...)
+	}
+}
+
+// latestPodTimestamp returns the most recent write time recorded on the pod
+func latestPodTimestamp(pod *v1.Pod) time.Time {
+	latest := pod.CreationTimestamp.Time
+	later := func(t time.Time) {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+
+	for _, f := range pod.ManagedFields {
+		if f.Time != nil {
+			later(f.Time.Time)
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		later(c.LastTransitionTime.Time)
+	}
+	return latest
+}
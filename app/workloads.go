@@ -0,0 +1,142 @@
+package app
+
+import (
+	"adv-go/model"
+	"adv-go/sink"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workloadPods are the pods rolled up to one workload
+type workloadPods struct {
+	total     int
+	unhealthy []string
+}
+
+// logWorkloadStatus rolls the pods up to their Deployments and standalone ReplicaSets and logs one line per workload
+func logWorkloadStatus(ctx context.Context, clientset kubernetes.Interface, pods []v1.Pod) {
+	var records []string
+	for _, namespace := range monitoredNamespaces() {
+		deployments, err := retryCall(ctx, "list deployments", func(ctx context.Context) (*appsv1.DeploymentList, error) {
+			return clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing deployments", "namespace", namespace, "err", err)
+			continue
+		}
+		replicaSets, err := retryCall(ctx, "list replica sets", func(ctx context.Context) (*appsv1.ReplicaSetList, error) {
+			return clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+		})
+		if err != nil {
+			collectorLog.Error("Error listing replica sets", "namespace", namespace, "err", err)
+			continue
+		}
+
+		// Map each replica set to the workload it rolls up to
+		owners := make(map[string]string, len(replicaSets.Items))
+		var standalone []*model.ReplicaSet
+		for i := range replicaSets.Items {
+			rs := model.NewReplicaSet(&replicaSets.Items[i])
+			if deployment := rs.Deployment(); deployment != "" {
+				owners[rs.Namespace()+"/"+rs.Name()] = "Deployment/" + rs.Namespace() + "/" + deployment
+			} else {
+				owners[rs.Namespace()+"/"+rs.Name()] = "ReplicaSet/" + rs.Namespace() + "/" + rs.Name()
+				standalone = append(standalone, rs)
+			}
+		}
+		rollup := rollupPods(pods, owners)
+
+		for i := range deployments.Items {
+			d := model.NewDeployment(&deployments.Items[i])
+			if !rollup.reports("Deployment/" + d.Namespace() + "/" + d.Name()) {
+				continue
+			}
+			desired, ready, available, updated := d.Replicas()
+			recordWorkloadReplicas(d.Namespace(), "Deployment/"+d.Name(), ready, desired)
+			records = append(records, fmt.Sprintf("Deployment: %s/%s, Healthy: %t, Desired: %d, Ready: %d, Available: %d, Updated: %d%s",
+				d.Namespace(), d.Name(), d.IsHealthy(), desired, ready, available, updated,
+				rollup.describe("Deployment/"+d.Namespace()+"/"+d.Name())))
+		}
+		for _, rs := range standalone {
+			if !rollup.reports("ReplicaSet/" + rs.Namespace() + "/" + rs.Name()) {
+				continue
+			}
+			desired, ready, available := rs.Replicas()
+			recordWorkloadReplicas(rs.Namespace(), "ReplicaSet/"+rs.Name(), ready, desired)
+			records = append(records, fmt.Sprintf("ReplicaSet: %s/%s, Healthy: %t, Desired: %d, Ready: %d, Available: %d%s",
+				rs.Namespace(), rs.Name(), available >= desired, desired, ready, available,
+				rollup.describe("ReplicaSet/"+rs.Namespace()+"/"+rs.Name())))
+		}
+	}
+
+	for _, status := range records {
+		collectorLog.Info(status)
+	}
+	batch := make([][]byte, len(records))
+	for i, status := range records {
+		batch[i] = []byte(status)
+	}
+	writeBatch(ctx, sink.PriorityHeartbeat, batch)
+	writeAvailabilityDigest(ctx)
+}
+
+// podRollup maps workload keys to their pods
+type podRollup map[string]*workloadPods
+
+// rollupPods groups the pods by the workload owning their replica set
+func rollupPods(pods []v1.Pod, owners map[string]string) podRollup {
+	rollup := make(podRollup)
+	for i := range pods {
+		pod := &pods[i]
+		for _, ref := range pod.OwnerReferences {
+			if ref.Kind != "ReplicaSet" {
+				continue
+			}
+			key, ok := owners[pod.Namespace+"/"+ref.Name]
+			if !ok {
+				continue
+			}
+			w := rollup[key]
+			if w == nil {
+				w = &workloadPods{}
+				rollup[key] = w
+			}
+			w.total++
+			if !podHealthy(model.NewPod(pod)) {
+				w.unhealthy = append(w.unhealthy, pod.Name)
+			}
+		}
+	}
+	return rollup
+}
+
+// reports reports whether this replica logs the workload: always unless hashing pods, then the replica its pods
+// hash to. Pods hashed by --lease-hash-label may be spread out, every replica holding some reports the workload then,
+// and the one the workload hashes to
+func (r podRollup) reports(key string) bool {
+	members, ok := podMembers()
+	if !ok {
+		return true
+	}
+	if _, held := r[key]; held && cfg.Lease.HashLabel != "" {
+		return true
+	}
+	return members.Owns(key)
+}
+
+// describe renders the pod counts of a workload, listing the unhealthy pods
+func (r podRollup) describe(key string) string {
+	w, ok := r[key]
+	if !ok {
+		return ", Pods: 0"
+	}
+	sort.Strings(w.unhealthy)
+	return fmt.Sprintf(", Pods: %d, Unhealthy Pods: [%s]", w.total, strings.Join(w.unhealthy, ","))
+}
@@ -0,0 +1,201 @@
+// Package auth authenticates the clients of the metrics server and the pod status stream, by bearer token or by a
+// client certificate signed by a trusted CA
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Options select how the servers authenticate their clients
+type Options struct {
+	// TokenFile holds the accepted bearer tokens, one per line, blank lines and # comments ignored
+	TokenFile string
+	// CertFile and KeyFile serve TLS
+	CertFile string
+	KeyFile  string
+	// ClientCAFile accepts client certificates signed by these CAs
+	ClientCAFile string
+	// PublicPaths are the HTTP paths served without authentication, such as the health probes. A path ending in /
+	// covers the paths under it
+	PublicPaths []string
+}
+
+// Authenticator admits the requests carrying one of the tokens or a verified client certificate. Without tokens or
+// a client CA it admits every request
+type Authenticator struct {
+	tokens    [][]byte
+	tlsConfig *tls.Config
+	mtls      bool
+	public    []string
+}
+
+// New loads the tokens, the server certificate and the client CAs
+func New(opts Options) (*Authenticator, error) {
+	a := &Authenticator{public: opts.PublicPaths}
+	if opts.TokenFile != "" {
+		tokens, err := readTokens(opts.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("auth: %s holds no tokens", opts.TokenFile)
+		}
+		a.tokens = tokens
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: loading the server certificate: %w", err)
+		}
+		a.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	if opts.ClientCAFile != "" {
+		if a.tlsConfig == nil {
+			return nil, errors.New("auth: client certificates need a server certificate and key")
+		}
+		data, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading the client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("auth: %s holds no PEM certificates", opts.ClientCAFile)
+		}
+		// Clients without a certificate may still present a token, and probes reach the public paths
+		a.tlsConfig.ClientCAs = pool
+		a.tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		a.mtls = true
+	}
+	return a, nil
+}
+
+// readTokens reads the tokens of the file, one per line
+func readTokens(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading the token file: %w", err)
+	}
+	defer f.Close()
+	var tokens [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, []byte(line))
+	}
+	return tokens, scanner.Err()
+}
+
+// TLSConfig is the servers' TLS configuration, nil to serve plain text
+func (a *Authenticator) TLSConfig() *tls.Config {
+	if a == nil || a.tlsConfig == nil {
+		return nil
+	}
+	return a.tlsConfig.Clone()
+}
+
+// enabled reports whether requests need credentials
+func (a *Authenticator) enabled() bool {
+	return a != nil && (len(a.tokens) > 0 || a.mtls)
+}
+
+// validToken reports whether the Authorization header value carries one of the tokens
+func (a *Authenticator) validToken(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	valid := false
+	for _, t := range a.tokens {
+		// Every token is compared so the time taken tells nothing about which matched
+		if subtle.ConstantTimeCompare(t, []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// verifiedClient reports whether the connection presented a client certificate signed by a trusted CA
+func (a *Authenticator) verifiedClient(state *tls.ConnectionState) bool {
+	return a.mtls && state != nil && len(state.VerifiedChains) > 0
+}
+
+// publicPath reports whether the path is served without authentication
+func (a *Authenticator) publicPath(path string) bool {
+	for _, p := range a.public {
+		if path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap returns a handler answering 401 to the requests for a path that is not public without a token or a verified
+// client certificate
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	if !a.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.publicPath(r.URL.Path) || a.verifiedClient(r.TLS) || a.validToken(r.Header.Get("Authorization")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(a.tokens) > 0 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pod-logger"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// ServerOptions are the gRPC server options serving TLS and rejecting the streams without a token in the
+// authorization metadata or a verified client certificate
+func (a *Authenticator) ServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if config := a.TLSConfig(); config != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	}
+	if a.enabled() {
+		opts = append(opts, grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := a.authorizeStream(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}))
+	}
+	return opts
+}
+
+// authorizeStream checks the stream's client certificate, then its authorization metadata
+func (a *Authenticator) authorizeStream(ctx context.Context) error {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && a.verifiedClient(&info.State) {
+			return nil
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range md.Get("authorization") {
+		if a.validToken(header) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "a bearer token or a client certificate is required")
+}
@@ -0,0 +1,926 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the complete configuration of the pod logger, loaded from a YAML file and overridden by flags
+type Config struct {
+	// Kubeconfig is the kubeconfig used when running outside of a cluster
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Context is the kubeconfig context to use instead of the current one, set it to use the kubeconfig even in a
+	// cluster
+	Context string `json:"context,omitempty"`
+	// APIServer and Token override the address of the apiserver and the bearer token of the kubeconfig or the
+	// in-cluster config
+	APIServer string `json:"apiserver,omitempty"`
+	Token     string `json:"token,omitempty"`
+	// Namespaces limits monitoring to these namespaces, empty means all namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector of the namespaces to monitor instead of Namespaces. The namespaces are
+	// watched, so those labeled later are monitored without a restart
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// LabelSelector and FieldSelector restrict which pods are listed and watched
+	LabelSelector string `json:"labelSelector,omitempty"`
+	FieldSelector string `json:"fieldSelector,omitempty"`
+	// IgnoreNamespaces and IgnoreLabels drop pods after listing: those in these namespaces and those matching any of
+	// these label selectors
+	IgnoreNamespaces []string `json:"ignoreNamespaces,omitempty"`
+	IgnoreLabels     []string `json:"ignoreLabels,omitempty"`
+	// Interval is how often the cluster state is re-listed and logged, 0 logs it once
+	Interval metav1.Duration `json:"interval"`
+	// PageSize is the number of pods fetched per list call, 0 fetches them all at once
+	PageSize int64 `json:"pageSize"`
+	// ListConcurrency lists the pods of this many namespaces at once instead of one namespace after another, turning
+	// a list of all namespaces into one list per namespace. 0 keeps the sequential lists
+	ListConcurrency int `json:"listConcurrency"`
+	// Workers is the number of goroutines formatting pod records
+	Workers int `json:"workers"`
+	// BatchSize is the number of records written to the sinks at once
+	BatchSize int `json:"batchSize"`
+	// EmitEvents emits a Warning Event on pods found Failed, Unknown or crash looping
+	EmitEvents bool `json:"emitEvents"`
+	// MaxRetries is how often a failed apiserver call is retried with exponential backoff before the pass gives up on it
+	MaxRetries   int             `json:"maxRetries"`
+	RetryBackoff metav1.Duration `json:"retryBackoff"`
+	// APIQPS and APIBurst are the client's request rate limit, the client-go defaults of 5 and 10 throttle the
+	// reporting passes of large clusters
+	APIQPS   float64 `json:"apiQPS"`
+	APIBurst int     `json:"apiBurst"`
+	// ListWatchQPS and ListWatchBurst additionally limit list and watch requests, 0 leaves them to the client limit
+	ListWatchQPS   float64 `json:"listWatchQPS"`
+	ListWatchBurst int     `json:"listWatchBurst"`
+	// ReadOnly makes no writes to the cluster: no events are emitted and no Lease is taken, so leader election is skipped
+	ReadOnly bool `json:"readOnly"`
+	// CheckPermissions reports the RBAC permissions the config needs and exits instead of running the agent
+	CheckPermissions bool `json:"checkPermissions"`
+	// MetricsAddr is the address Prometheus metrics are served on, empty disables the endpoint
+	MetricsAddr string `json:"metricsAddr"`
+	// GRPCAddr is the address the PodStatus gRPC stream of pod status transitions is served on, empty disables it
+	GRPCAddr string `json:"grpcAddr"`
+	// Dashboard serves a live HTML dashboard on the metrics server at /dashboard/
+	Dashboard bool `json:"dashboard"`
+	// TUI draws a live table of the watched pods in the terminal, the log is shown below it
+	TUI bool `json:"tui"`
+	// WithMetrics adds current CPU and memory usage from metrics-server to each pod record
+	WithMetrics bool `json:"withMetrics"`
+	// LogLevel is the default level followed by per-component overrides, e.g. "info,collector=debug"
+	LogLevel string `json:"logLevel"`
+	// LogFormat is the log output format, text or json
+	LogFormat string `json:"logFormat"`
+	// FeatureGates switches experimental subsystems on or off by name, see the features command
+	FeatureGates map[string]bool `json:"featureGates"`
+	// Timezone is the zone timestamps in text, CSV and HTML output are rendered in: Local, UTC or an IANA name
+	Timezone string `json:"timezone"`
+	// PodRecords writes one record per pod, disable to keep only the workload rollups
+	PodRecords bool `json:"podRecords"`
+	// HealthRules classify every pod as Healthy, Degraded or Failed, the first matching rule deciding. They replace
+	// the built-in rules when set
+	HealthRules []HealthRuleConfig `json:"healthRules,omitempty"`
+	// Template is a Go template rendering each pod record instead of the built-in format, e.g. "{{.Name}} {{.Phase}}"
+	Template string `json:"template"`
+	// RecordFormat encodes the pod records: text, json or protobuf, see model.NewEncoder
+	RecordFormat string `json:"recordFormat"`
+	// PodRecordMode is "full" to write every pod each pass or "transitions" to write only pods whose state changed
+	PodRecordMode string `json:"podRecordMode"`
+	// FullRecordInterval still writes every pod this often in the transitions mode, 0 never does
+	FullRecordInterval metav1.Duration `json:"fullRecordInterval"`
+	// Summary writes one line per namespace counting its pods by state instead of the per-pod records
+	Summary bool `json:"summary"`
+	// StatusReports applies a PodStatusReport custom resource per namespace with its pod counts every pass
+	StatusReports bool `json:"statusReports"`
+	// EndpointProbe also measures when pods become reachable on their endpoint port, with the EndpointLatency feature
+	EndpointProbe bool `json:"endpointProbe"`
+	// HeartbeatInterval prints a one line summary to stdout this often, 0 disables the heartbeat
+	HeartbeatInterval metav1.Duration `json:"heartbeatInterval"`
+	// LagAlertThreshold alerts when the watch lag exceeds it, 0 disables the alert
+	LagAlertThreshold metav1.Duration `json:"lagAlertThreshold"`
+	// ClockSkewThreshold warns when a node clock is off by more than it, 0 disables the warning
+	ClockSkewThreshold metav1.Duration `json:"clockSkewThreshold"`
+	// RestartAlertThreshold alerts when a pod restarts more than this many times within RestartAlertWindow, 0 disables the alert
+	RestartAlertThreshold int             `json:"restartAlertThreshold"`
+	RestartAlertWindow    metav1.Duration `json:"restartAlertWindow"`
+	// UnschedulableThreshold is how long a pod may be Pending without a node before it is explained as
+	// unschedulable, 0 disables the detector
+	UnschedulableThreshold metav1.Duration `json:"unschedulableThreshold"`
+	// DaemonSetCoverageGrace is how long a node a DaemonSet targets may go without a ready pod of it before an
+	// alert, so new nodes and rollouts have time to start their daemons
+	DaemonSetCoverageGrace metav1.Duration `json:"daemonSetCoverageGrace"`
+	// ReachabilityTargets are the "namespace/service" Services resolved and connected to on every pass, to catch
+	// Services that kube-proxy or the CNI did not program
+	ReachabilityTargets []string `json:"reachabilityTargets"`
+	// ClusterDomain is the DNS domain of the cluster Service names are resolved in
+	ClusterDomain string `json:"clusterDomain"`
+	// ChurnAlertThreshold alerts when more than this many pods of a namespace are created or deleted within
+	// ChurnAlertWindow, 0 disables the alert
+	ChurnAlertThreshold int             `json:"churnAlertThreshold"`
+	ChurnAlertWindow    metav1.Duration `json:"churnAlertWindow"`
+	// SigningKey is an ed25519 private key (PEM) signing snapshots and incident bundles
+	SigningKey string `json:"signingKey"`
+
+	Enrichment  EnrichmentConfig  `json:"enrichment"`
+	Lease       LeaseConfig       `json:"lease"`
+	Sinks       SinkConfig        `json:"sinks"`
+	Prometheus  PrometheusConfig  `json:"prometheus"`
+	Notify      NotifyConfig      `json:"notify"`
+	History     HistoryConfig     `json:"history"`
+	Incidents   IncidentConfig    `json:"incidents"`
+	Remediation RemediationConfig `json:"remediation"`
+	Tracing     TracingConfig     `json:"tracing"`
+	APIAuth     APIAuthConfig     `json:"apiAuth"`
+}
+
+// APIAuthConfig secures the metrics server, with the query API and dashboard, and the gRPC pod status stream
+type APIAuthConfig struct {
+	// TokenFile holds the bearer tokens accepted, one per line
+	TokenFile string `json:"tokenFile"`
+	// TLSCert and TLSKey serve both over TLS
+	TLSCert string `json:"tlsCert"`
+	TLSKey  string `json:"tlsKey"`
+	// ClientCA accepts client certificates signed by it in place of a token
+	ClientCA string `json:"clientCA"`
+	// PublicPaths are served without a token or client certificate, a path ending in / covers those under it
+	PublicPaths []string `json:"publicPaths"`
+}
+
+// NotifyConfig selects the bad phases that trigger notifications and where they are sent
+type NotifyConfig struct {
+	// Phases are pod phases (Failed, Unknown) or problem reasons (CrashLoopBackOff, InitCrashLoopBackOff) worth a notification
+	Phases          []string `json:"phases"`
+	SlackWebhookURL string   `json:"slackWebhookURL"`
+	WebhookURL      string   `json:"webhookURL"`
+	// Cooldown is how long a pod is not notified about again for the same reason, 0 notifies every time it
+	// enters the bad phase
+	Cooldown metav1.Duration `json:"cooldown"`
+	// SilencesFile is a YAML file of silences by namespace and label selector, reread every pass
+	SilencesFile string `json:"silencesFile"`
+}
+
+// HistoryConfig selects the database pod status transitions are recorded in
+type HistoryConfig struct {
+	// DSN is a postgres:// URL or a SQLite file path, history is disabled when empty
+	DSN string `json:"dsn"`
+	// AvailabilityDigest writes each workload's availability over 24h, 7d and 30d this often, 0 disables it
+	AvailabilityDigest metav1.Duration `json:"availabilityDigest"`
+	// Retention keeps the transitions and pod events this long before rolling them up into hourly counts, 0 keeps
+	// them forever
+	Retention metav1.Duration `json:"retention"`
+	// HourlyRetention keeps the hourly counts this long before rolling them up into daily counts
+	HourlyRetention metav1.Duration `json:"hourlyRetention"`
+	// DailyRetention keeps the daily counts this long, 0 keeps them forever
+	DailyRetention metav1.Duration `json:"dailyRetention"`
+	// CompactionInterval is how often the leader compacts the history with a retention set
+	CompactionInterval metav1.Duration `json:"compactionInterval"`
+}
+
+// IncidentConfig selects where the incident bundles captured for pods entering a bad phase go
+type IncidentConfig struct {
+	// Dir is the directory bundles are saved in
+	Dir string `json:"dir"`
+	// UploadURL is a base URL bundles are PUT under, e.g. a pre-authorized bucket prefix
+	UploadURL string `json:"uploadURL"`
+}
+
+// RemediationConfig sets which crash looping pods the PodRemediation feature deletes and the budgets and circuit
+// breaker limiting it
+type RemediationConfig struct {
+	// MinRestarts is the restart count a crash looping pod needs before it is deleted
+	MinRestarts int `json:"minRestarts"`
+	// Window is the period the budgets apply to
+	Window metav1.Duration `json:"window"`
+	// GlobalBudget and NamespaceBudget cap the deletions per window across all namespaces and per namespace, 0
+	// leaves them uncapped
+	GlobalBudget    int `json:"globalBudget"`
+	NamespaceBudget int `json:"namespaceBudget"`
+	// ErrorThreshold is the ratio of failed deletions, among at least MinSamples within the window, that halts
+	// every deletion for Cooldown
+	ErrorThreshold float64         `json:"errorThreshold"`
+	MinSamples     int             `json:"minSamples"`
+	Cooldown       metav1.Duration `json:"cooldown"`
+	// DryRun logs the deletions, counted against the budgets, without making them
+	DryRun bool `json:"dryRun"`
+}
+
+// TracingConfig enables OpenTelemetry tracing of the agent's own pipeline
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector address, tracing is disabled when empty
+	Endpoint    string  `json:"endpoint"`
+	SampleRatio float64 `json:"sampleRatio"`
+	// LinkTemplate renders trace links in alerts, {traceID} is replaced with the trace ID
+	LinkTemplate string `json:"linkTemplate"`
+}
+
+// PrometheusConfig is the server PromQL queries in alert rules and canary analysis run against
+type PrometheusConfig struct {
+	URL string `json:"url"`
+	// Alerts are evaluated after every reporting pass
+	Alerts []PromQLAlert `json:"alerts,omitempty"`
+}
+
+// PromQLAlert fires when its query returns a value above the threshold
+type PromQLAlert struct {
+	Name      string  `json:"name"`
+	Query     string  `json:"query"`
+	Threshold float64 `json:"threshold"`
+}
+
+// EnrichmentConfig selects the node and namespace metadata joined into every pod record
+type EnrichmentConfig struct {
+	NodeLabels           []string `json:"nodeLabels,omitempty"`
+	NamespaceLabels      []string `json:"namespaceLabels,omitempty"`
+	NamespaceAnnotations []string `json:"namespaceAnnotations,omitempty"`
+}
+
+// LeaseConfig configures the leader election lock
+type LeaseConfig struct {
+	Name string `json:"name"`
+	// Namespace of the Lease, empty means the namespace the agent runs in
+	Namespace string `json:"namespace"`
+	// LockType is leases, configmaps, or configmapsleases / endpointsleases while migrating from an older lock
+	LockType string `json:"lockType"`
+	// Identity names this replica in the lock, empty uses POD_NAME or the hostname with a random suffix
+	Identity string `json:"identity"`
+	// ElectLocally runs leader election outside a cluster too, so local copies elect a single leader
+	ElectLocally  bool            `json:"electLocally"`
+	Duration      metav1.Duration `json:"duration"`
+	RenewDeadline metav1.Duration `json:"renewDeadline"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod"`
+	// WarmupTimeout bounds how long a new leader waits for its informer caches to sync before emitting records
+	WarmupTimeout metav1.Duration `json:"warmupTimeout"`
+	// Shards splits the namespaces into this many shards, each led through its own Lease, 0 or 1 keeps a single leader
+	Shards int `json:"shards"`
+	// HashPods splits the pods across every live replica by a hash, each replica holding a member Lease
+	HashPods bool `json:"hashPods"`
+	// HashLabel is the pod label hashed with --lease-hash-pods, empty hashes the controller or pod name
+	HashLabel string `json:"hashLabel"`
+	// AuditLog is the file every leadership transition is written to as a JSON line, empty to disable
+	AuditLog string `json:"auditLog"`
+	// Annotate records this replica's gains and losses of leadership in an annotation of the Lease
+	Annotate bool `json:"annotate"`
+	// Heartbeat stamps the Lease held with the time of every reporting pass the leader finished
+	Heartbeat bool `json:"heartbeat"`
+	// HeartbeatFile is a file the leader writes the time of every finished reporting pass to, empty to disable
+	HeartbeatFile string `json:"heartbeatFile"`
+	// StallTimeout is how long the leader may go without finishing a reporting pass before its loop counts as
+	// stalled and liveness fails, 0 disables the check
+	StallTimeout metav1.Duration `json:"stallTimeout"`
+	// ReleaseOnStall gives up the Lease when the loop stalled, so a standby replica takes over
+	ReleaseOnStall bool `json:"releaseOnStall"`
+}
+
+// StageConfig is a stage of the record pipeline, see sink.StageSpec
+type StageConfig struct {
+	Name string `json:"name"`
+	// Type is filter, enrich, redact, ids, route, or a stage type registered with sink.RegisterStage
+	Type  string            `json:"type"`
+	Match map[string]string `json:"match,omitempty"`
+	Keep  bool              `json:"keep,omitempty"`
+	Set   map[string]string `json:"set,omitempty"`
+	// Fields and Patterns are masked by redact stages
+	Fields   []string `json:"fields,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	// Sinks receive the records matched by route stages
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+// HealthRuleConfig classifies the pods matching all of its criteria, see model.HealthRule
+type HealthRuleConfig struct {
+	Name string `json:"name"`
+	// Health is Healthy, Degraded or Failed
+	Health      string            `json:"health"`
+	Phases      []string          `json:"phases,omitempty"`
+	Conditions  map[string]string `json:"conditions,omitempty"`
+	Reasons     []string          `json:"reasons,omitempty"`
+	MinRestarts int32             `json:"minRestarts,omitempty"`
+	MinAge      metav1.Duration   `json:"minAge,omitempty"`
+}
+
+// SinkConfig selects and configures the sinks records are written to
+type SinkConfig struct {
+	Names   []string          `json:"names"`
+	File    FileSinkConfig    `json:"file"`
+	HTTP    HTTPSinkConfig    `json:"http"`
+	Syslog  SyslogSinkConfig  `json:"syslog"`
+	Archive ArchiveSinkConfig `json:"archive"`
+	// Fields selects the record fields each sink receives, keyed by sink name
+	Fields map[string]FieldSelection `json:"fields"`
+	// IDs label every record with a sequence number ("seq") and/or a ULID ("ulid")
+	IDs []string `json:"ids"`
+	// Journal is the directory records are journaled to until every sink acknowledged them, empty to disable
+	Journal string `json:"journal"`
+	// JournalMaxRecords bounds the records kept for a lagging sink, 0 keeps all of them
+	JournalMaxRecords int `json:"journalMaxRecords"`
+	// QueueSize queues this many records for the sinks, shedding the lowest priority ones once full, 0 writes
+	// synchronously
+	QueueSize int `json:"queueSize"`
+	// Buffer buffers the records of every sink and writes them in the background, see BufferConfig
+	Buffer BufferConfig `json:"buffer"`
+	// Buffers override Buffer for single sinks, keyed by sink name. Unset fields keep Buffer's values
+	Buffers map[string]BufferConfig `json:"buffers,omitempty"`
+	// Pipeline are the named stages records pass in order before reaching the sinks
+	Pipeline []StageConfig `json:"pipeline,omitempty"`
+}
+
+// BufferConfig configures the buffer in front of a sink
+type BufferConfig struct {
+	// Size is how many records are buffered for the sink, 0 writes to it synchronously
+	Size int `json:"size"`
+	// BatchSize flushes once this many records are buffered, 0 only flushes on the interval
+	BatchSize int `json:"batchSize"`
+	// FlushInterval flushes what is buffered at least this often
+	FlushInterval metav1.Duration `json:"flushInterval"`
+	// Policy is what a full buffer does: drop-oldest drops the oldest record, block makes the writer wait
+	Policy string `json:"policy"`
+}
+
+// BufferFor returns the buffer of the named sink, its override with the unset fields taken from Buffer
+func (c SinkConfig) BufferFor(name string) BufferConfig {
+	b, ok := c.Buffers[name]
+	if !ok {
+		return c.Buffer
+	}
+	if b.Size == 0 {
+		b.Size = c.Buffer.Size
+	}
+	if b.BatchSize == 0 {
+		b.BatchSize = c.Buffer.BatchSize
+	}
+	if b.FlushInterval.Duration == 0 {
+		b.FlushInterval = c.Buffer.FlushInterval
+	}
+	if b.Policy == "" {
+		b.Policy = c.Buffer.Policy
+	}
+	return b
+}
+
+// FieldSelection lists the record fields (e.g. "Pod Name", "Phase") to include in or exclude from a sink
+type FieldSelection struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// FileSinkConfig configures the file sink and its rotation
+type FileSinkConfig struct {
+	Path           string          `json:"path"`
+	MaxSizeMB      int64           `json:"maxSizeMB"`
+	RotateInterval metav1.Duration `json:"rotateInterval"`
+	MaxBackups     int             `json:"maxBackups"`
+	MaxAge         metav1.Duration `json:"maxAge"`
+}
+
+// HTTPSinkConfig configures the HTTP POST sink
+type HTTPSinkConfig struct {
+	URL string `json:"url"`
+	// Gzip compresses the request bodies
+	Gzip bool `json:"gzip"`
+	// Delta posts each batch as one body with only the pods that changed since the last successful POST
+	Delta bool `json:"delta"`
+	// ResyncInterval posts every pod again this often in the delta mode, marked as a full sync
+	ResyncInterval metav1.Duration `json:"resyncInterval"`
+}
+
+// SyslogSinkConfig configures the syslog sink
+type SyslogSinkConfig struct {
+	Addr string `json:"addr"`
+	Tag  string `json:"tag"`
+}
+
+// ArchiveSinkConfig configures the sink uploading log segments to an S3 or GCS bucket
+type ArchiveSinkConfig struct {
+	// URL is s3://bucket/prefix or gs://bucket/prefix
+	URL string `json:"url"`
+	// Dir holds the segments until they are uploaded, empty for a directory under the system temp dir
+	Dir            string          `json:"dir"`
+	UploadInterval metav1.Duration `json:"uploadInterval"`
+	MaxSizeMB      int64           `json:"maxSizeMB"`
+}
+
+// Default returns the configuration used when neither a file nor flags set a value
+func Default() Config {
+	var kubeconfig string
+	if home, err := os.UserHomeDir(); err == nil {
+		kubeconfig = filepath.Join(home, ".kube", "config")
+	}
+
+	return Config{
+		Kubeconfig:             kubeconfig,
+		Interval:               metav1.Duration{Duration: 30 * time.Second},
+		PageSize:               500,
+		Workers:                8,
+		BatchSize:              100,
+		EmitEvents:             true,
+		MaxRetries:             5,
+		RetryBackoff:           metav1.Duration{Duration: 500 * time.Millisecond},
+		APIQPS:                 50,
+		APIBurst:               100,
+		MetricsAddr:            ":8080",
+		LogLevel:               "info",
+		LogFormat:              "text",
+		Timezone:               "Local",
+		PodRecords:             true,
+		PodRecordMode:          "full",
+		RecordFormat:           "text",
+		HeartbeatInterval:      metav1.Duration{Duration: time.Minute},
+		LagAlertThreshold:      metav1.Duration{Duration: time.Minute},
+		ClockSkewThreshold:     metav1.Duration{Duration: 30 * time.Second},
+		RestartAlertThreshold:  3,
+		RestartAlertWindow:     metav1.Duration{Duration: 10 * time.Minute},
+		UnschedulableThreshold: metav1.Duration{Duration: 5 * time.Minute},
+		DaemonSetCoverageGrace: metav1.Duration{Duration: 5 * time.Minute},
+		ClusterDomain:          "cluster.local",
+		ChurnAlertThreshold:    100,
+		ChurnAlertWindow:       metav1.Duration{Duration: 10 * time.Minute},
+		Lease: LeaseConfig{
+			Name:          "leader-election",
+			LockType:      "leases",
+			Duration:      metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+			WarmupTimeout: metav1.Duration{Duration: 2 * time.Minute},
+		},
+		Sinks: SinkConfig{
+			Names: []string{"file"},
+			File: FileSinkConfig{
+				Path:       "pod_status.log",
+				MaxSizeMB:  100,
+				MaxBackups: 5,
+				MaxAge:     metav1.Duration{Duration: 7 * 24 * time.Hour},
+			},
+			Syslog:            SyslogSinkConfig{Tag: "pod-logger"},
+			HTTP:              HTTPSinkConfig{ResyncInterval: metav1.Duration{Duration: 10 * time.Minute}},
+			Archive:           ArchiveSinkConfig{UploadInterval: metav1.Duration{Duration: 10 * time.Minute}, MaxSizeMB: 50},
+			JournalMaxRecords: 100000,
+			Buffer:            BufferConfig{BatchSize: 100, FlushInterval: metav1.Duration{Duration: time.Second}, Policy: "drop-oldest"},
+		},
+		Notify: NotifyConfig{
+			Phases:   []string{"Failed", "Unknown", "CrashLoopBackOff", "InitCrashLoopBackOff"},
+			Cooldown: metav1.Duration{Duration: 30 * time.Minute},
+		},
+		History: HistoryConfig{
+			AvailabilityDigest: metav1.Duration{Duration: 24 * time.Hour},
+			HourlyRetention:    metav1.Duration{Duration: 30 * 24 * time.Hour},
+			CompactionInterval: metav1.Duration{Duration: time.Hour},
+		},
+		Remediation: RemediationConfig{
+			MinRestarts:     5,
+			Window:          metav1.Duration{Duration: time.Hour},
+			GlobalBudget:    10,
+			NamespaceBudget: 3,
+			ErrorThreshold:  0.5,
+			MinSamples:      4,
+			Cooldown:        metav1.Duration{Duration: 15 * time.Minute},
+		},
+		Tracing: TracingConfig{SampleRatio: 1},
+		APIAuth: APIAuthConfig{PublicPaths: []string{"/healthz", "/readyz"}},
+	}
+}
+
+// BindFlags registers a flag for every setting that can be overridden on the command line
+func BindFlags(fs *flag.FlagSet, c *Config) {
+	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "(optional) absolute path to the kubeconfig file")
+	fs.StringVar(&c.Context, "context", c.Context, "kubeconfig context to use instead of the current one, even when running in a cluster")
+	fs.StringVar(&c.APIServer, "apiserver", c.APIServer, "address of the apiserver, overriding the kubeconfig or in-cluster one")
+	fs.StringVar(&c.Token, "token", c.Token, "bearer token authenticating to the apiserver, overriding the kubeconfig or in-cluster one")
+	fs.Var((*stringList)(&c.Namespaces), "namespaces", "comma separated list of namespaces to monitor, empty for all")
+	fs.StringVar(&c.NamespaceSelector, "namespace-selector", c.NamespaceSelector, "label selector of the namespaces to monitor, e.g. team=payments, instead of --namespaces")
+	fs.StringVar(&c.LabelSelector, "selector", c.LabelSelector, "label selector restricting the monitored pods")
+	fs.StringVar(&c.FieldSelector, "field-selector", c.FieldSelector, "field selector restricting the monitored pods")
+	fs.Var((*stringList)(&c.IgnoreNamespaces), "ignore-namespaces", "comma separated list of namespaces whose pods are ignored")
+	fs.Var((*stringList)(&c.IgnoreLabels), "ignore-labels", "comma separated label selectors, e.g. tier=ci, pods matching any of them are ignored")
+	fs.DurationVar(&c.Interval.Duration, "interval", c.Interval.Duration, "how often to re-list and log the cluster state, 0 to log it once")
+	fs.Int64Var(&c.PageSize, "page-size", c.PageSize, "number of pods fetched per list call, 0 to fetch them all at once")
+	fs.IntVar(&c.ListConcurrency, "list-concurrency", c.ListConcurrency, "namespaces whose pods are listed at once, with one list per namespace instead of one for all namespaces, 0 lists them one after another")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "number of workers formatting pod records")
+	fs.IntVar(&c.BatchSize, "batch-size", c.BatchSize, "number of records written to the sinks at once")
+	fs.BoolVar(&c.EmitEvents, "emit-events", c.EmitEvents, "emit a Warning Event on pods found Failed, Unknown or crash looping")
+	fs.IntVar(&c.MaxRetries, "max-retries", c.MaxRetries, "retry apiserver calls failing with a transient error this many times, 0 to disable")
+	fs.DurationVar(&c.RetryBackoff.Duration, "retry-backoff", c.RetryBackoff.Duration, "delay before the first retry, doubled with jitter on each further retry up to 30s")
+	fs.Float64Var(&c.APIQPS, "kube-api-qps", c.APIQPS, "requests per second the Kubernetes client sends at most")
+	fs.IntVar(&c.APIBurst, "kube-api-burst", c.APIBurst, "requests the Kubernetes client sends in a burst above --kube-api-qps")
+	fs.Float64Var(&c.ListWatchQPS, "list-watch-qps", c.ListWatchQPS, "list and watch requests per second sent at most, 0 for no limit beyond --kube-api-qps")
+	fs.IntVar(&c.ListWatchBurst, "list-watch-burst", c.ListWatchBurst, "list and watch requests sent in a burst above --list-watch-qps")
+	fs.BoolVar(&c.ReadOnly, "read-only", c.ReadOnly, "make no writes to the cluster: no events and no leader election Lease, every replica logs")
+	fs.BoolVar(&c.CheckPermissions, "check-permissions", c.CheckPermissions, "check the RBAC permissions the config needs with SelfSubjectAccessReviews, print a report and exit")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "address to serve Prometheus metrics on, empty to disable")
+	fs.BoolVar(&c.Dashboard, "dashboard", c.Dashboard, "serve a live dashboard of pod phases, transitions and leadership at /dashboard/ on the metrics server")
+	fs.BoolVar(&c.TUI, "tui", c.TUI, "draw a live table of the watched pods in the terminal, sortable by phase, restarts and age, for local debugging")
+	fs.StringVar(&c.APIAuth.TokenFile, "api-token-file", c.APIAuth.TokenFile, "require one of the bearer tokens in this file, one per line, on the metrics server and the gRPC stream")
+	fs.StringVar(&c.APIAuth.TLSCert, "api-tls-cert", c.APIAuth.TLSCert, "serve the metrics server and the gRPC stream over TLS with this certificate (PEM)")
+	fs.StringVar(&c.APIAuth.TLSKey, "api-tls-key", c.APIAuth.TLSKey, "private key (PEM) of --api-tls-cert")
+	fs.StringVar(&c.APIAuth.ClientCA, "api-client-ca", c.APIAuth.ClientCA, "accept client certificates signed by this CA (PEM) in place of a token, needs --api-tls-cert")
+	fs.Var((*stringList)(&c.APIAuth.PublicPaths), "api-public-paths", "comma separated paths of the metrics server served without authentication, a path ending in / covers those under it")
+	fs.StringVar(&c.GRPCAddr, "grpc-addr", c.GRPCAddr, "address to serve the WatchPodStatuses gRPC stream on from the leader, empty to disable")
+	fs.BoolVar(&c.WithMetrics, "with-metrics", c.WithMetrics, "add CPU and memory usage from metrics-server, requests and limits to each pod record")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "debug, info, warn, error or quiet, optionally followed by per-component levels (e.g. info,collector=debug); components: agent, collector, watch, events, alerts, history, api, election")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "log output format, text or json")
+	fs.Var((*gateMap)(&c.FeatureGates), "feature-gates", "comma separated Feature=true|false pairs switching experimental subsystems, list them with the features command")
+	fs.StringVar(&c.Timezone, "timezone", c.Timezone, "timezone of the timestamps in text, CSV and HTML output: Local, UTC or an IANA name like Europe/Berlin")
+	fs.StringVar(&c.Template, "template", c.Template, "Go template rendering each pod record instead of the built-in format, e.g. '{{.Name}} {{.Phase}} {{.NodeName}}'")
+	fs.StringVar(&c.RecordFormat, "record-format", c.RecordFormat, "encoding of the pod records: text, json or protobuf (base64, one message of model/podrecord.proto per line)")
+	fs.BoolVar(&c.PodRecords, "pod-records", c.PodRecords, "write one record per pod, set to false to keep only the per-workload rollups")
+	fs.StringVar(&c.PodRecordMode, "pod-record-mode", c.PodRecordMode, "full writes every pod each pass, transitions only the pods whose phase or waiting reasons changed and deletions")
+	fs.DurationVar(&c.FullRecordInterval.Duration, "full-record-interval", c.FullRecordInterval.Duration, "in the transitions record mode, still write every pod this often, 0 to never")
+	fs.BoolVar(&c.EndpointProbe, "endpoint-probe", c.EndpointProbe, "with the EndpointLatency feature, also measure when Ready pods accept TCP connections on their endpoint port")
+	fs.BoolVar(&c.Summary, "summary", c.Summary, "write a table of pod counts by namespace and state instead of the per-pod records")
+	fs.BoolVar(&c.StatusReports, "status-reports", c.StatusReports, "apply a PodStatusReport custom resource per namespace with its pod counts every pass")
+	fs.DurationVar(&c.HeartbeatInterval.Duration, "heartbeat-interval", c.HeartbeatInterval.Duration, "log a one line summary of the agent's state this often, 0 to disable")
+	fs.DurationVar(&c.LagAlertThreshold.Duration, "lag-alert-threshold", c.LagAlertThreshold.Duration, "alert when the watch lag exceeds this duration, 0 to disable")
+	fs.DurationVar(&c.ClockSkewThreshold.Duration, "clock-skew-threshold", c.ClockSkewThreshold.Duration, "warn when a node clock is off by more than this duration, 0 to disable")
+	fs.IntVar(&c.RestartAlertThreshold, "restart-alert-threshold", c.RestartAlertThreshold, "alert when a pod restarts more than this many times within the restart alert window, 0 to disable")
+	fs.DurationVar(&c.RestartAlertWindow.Duration, "restart-alert-window", c.RestartAlertWindow.Duration, "window the restart alert threshold applies to")
+	fs.DurationVar(&c.UnschedulableThreshold.Duration, "unschedulable-threshold", c.UnschedulableThreshold.Duration, "explain why pods Pending without a node for longer than this cannot be scheduled, 0 to disable")
+	fs.DurationVar(&c.DaemonSetCoverageGrace.Duration, "daemonset-coverage-grace", c.DaemonSetCoverageGrace.Duration, "alert once a node a DaemonSet targets had no ready pod of it for this long")
+	fs.Var((*stringList)(&c.ReachabilityTargets), "reachability-targets", "comma separated namespace/service list to resolve and connect to on every pass, reporting a reachability matrix")
+	fs.StringVar(&c.ClusterDomain, "cluster-domain", c.ClusterDomain, "DNS domain of the cluster, Services resolve as <service>.<namespace>.svc.<domain>")
+	fs.IntVar(&c.ChurnAlertThreshold, "churn-alert-threshold", c.ChurnAlertThreshold, "alert when more than this many pods of a namespace are created or deleted within the churn alert window, 0 to disable")
+	fs.DurationVar(&c.ChurnAlertWindow.Duration, "churn-alert-window", c.ChurnAlertWindow.Duration, "window the churn alert threshold applies to")
+
+	fs.StringVar(&c.Prometheus.URL, "prometheus-url", c.Prometheus.URL, "Prometheus server used by PromQL alert rules and canary analysis")
+	fs.Var((*stringList)(&c.Notify.Phases), "notify-phases", "comma separated pod phases or problem reasons that trigger notifications")
+	fs.StringVar(&c.Notify.SlackWebhookURL, "slack-webhook-url", c.Notify.SlackWebhookURL, "Slack incoming webhook notified when pods enter a bad phase")
+	fs.StringVar(&c.Notify.WebhookURL, "notify-webhook-url", c.Notify.WebhookURL, "URL receiving a JSON notification when pods enter a bad phase")
+	fs.DurationVar(&c.Notify.Cooldown.Duration, "notify-cooldown", c.Notify.Cooldown.Duration, "do not notify about a pod again for the same reason within this duration, 0 to notify every time")
+	fs.StringVar(&c.Notify.SilencesFile, "notify-silences-file", c.Notify.SilencesFile, "YAML file of notification silences by namespace and label selector, reread every pass")
+	fs.StringVar(&c.History.DSN, "history-dsn", c.History.DSN, "record pod status transitions in Postgres (postgres://...) or a SQLite file (e.g. history.db)")
+	fs.DurationVar(&c.History.AvailabilityDigest.Duration, "availability-digest", c.History.AvailabilityDigest.Duration, "with --history-dsn, write each workload's availability over 24h, 7d and 30d this often, 0 to never")
+	fs.DurationVar(&c.History.Retention.Duration, "retention", c.History.Retention.Duration, "with --history-dsn, keep pod transitions and events this long before rolling them up into hourly counts, 0 to keep them forever")
+	fs.DurationVar(&c.History.HourlyRetention.Duration, "retention-hourly", c.History.HourlyRetention.Duration, "with --retention, keep the hourly counts this long before rolling them up into daily counts")
+	fs.DurationVar(&c.History.DailyRetention.Duration, "retention-daily", c.History.DailyRetention.Duration, "with --retention, keep the daily counts this long, 0 to keep them forever")
+	fs.DurationVar(&c.History.CompactionInterval.Duration, "compaction-interval", c.History.CompactionInterval.Duration, "with --retention, compact the history this often")
+	fs.StringVar(&c.Incidents.Dir, "incident-dir", c.Incidents.Dir, "directory to save an incident bundle in when a pod enters a bad phase")
+	fs.StringVar(&c.Incidents.UploadURL, "incident-upload-url", c.Incidents.UploadURL, "base URL to PUT incident bundles under when a pod enters a bad phase")
+	fs.IntVar(&c.Remediation.MinRestarts, "remediation-min-restarts", c.Remediation.MinRestarts, "with the PodRemediation feature, delete controller-managed pods crash looping after this many restarts")
+	fs.DurationVar(&c.Remediation.Window.Duration, "remediation-window", c.Remediation.Window.Duration, "window the remediation budgets apply to")
+	fs.IntVar(&c.Remediation.GlobalBudget, "remediation-global-budget", c.Remediation.GlobalBudget, "most pods remediated per window across all namespaces, 0 for no limit")
+	fs.IntVar(&c.Remediation.NamespaceBudget, "remediation-namespace-budget", c.Remediation.NamespaceBudget, "most pods remediated per window in each namespace, 0 for no limit")
+	fs.Float64Var(&c.Remediation.ErrorThreshold, "remediation-error-threshold", c.Remediation.ErrorThreshold, "ratio of failed remediations within the window that halts remediation for the cooldown")
+	fs.IntVar(&c.Remediation.MinSamples, "remediation-min-samples", c.Remediation.MinSamples, "remediations within the window needed before the error threshold can halt remediation")
+	fs.DurationVar(&c.Remediation.Cooldown.Duration, "remediation-cooldown", c.Remediation.Cooldown.Duration, "how long remediation stays halted once the error threshold is reached")
+	fs.BoolVar(&c.Remediation.DryRun, "remediation-dry-run", c.Remediation.DryRun, "log the pods remediation would delete, within the budgets, without deleting them")
+	fs.StringVar(&c.SigningKey, "signing-key", c.SigningKey, "ed25519 private key (PEM) signing snapshots and incident bundles, create one with the keygen command")
+	fs.StringVar(&c.Tracing.Endpoint, "otlp-endpoint", c.Tracing.Endpoint, "OTLP/HTTP collector address (e.g. tempo:4318) to export traces to, empty disables tracing")
+	fs.Float64Var(&c.Tracing.SampleRatio, "trace-sample-ratio", c.Tracing.SampleRatio, "fraction of traces to sample")
+	fs.StringVar(&c.Tracing.LinkTemplate, "trace-link-template", c.Tracing.LinkTemplate, "link to traces attached to alerts, {traceID} is replaced (e.g. http://jaeger:16686/trace/{traceID})")
+
+	fs.StringVar(&c.Lease.Name, "lease-name", c.Lease.Name, "name of the Lease used for leader election")
+	fs.StringVar(&c.Lease.Namespace, "lease-namespace", c.Lease.Namespace, "namespace of the leader election Lease, empty for the namespace the agent runs in")
+	fs.StringVar(&c.Lease.LockType, "lease-lock-type", c.Lease.LockType, "resource holding the leader election lock: leases, configmaps, or configmapsleases / endpointsleases to migrate from an older lock")
+	fs.StringVar(&c.Lease.Identity, "lease-identity", c.Lease.Identity, "name of this replica in the leader election lock, empty for POD_NAME or the hostname with a random suffix")
+	fs.BoolVar(&c.Lease.ElectLocally, "leader-elect-locally", c.Lease.ElectLocally, "run leader election outside a cluster too, so several local copies elect one leader")
+	fs.DurationVar(&c.Lease.Duration.Duration, "lease-duration", c.Lease.Duration.Duration, "how long followers wait before trying to take over leadership")
+	fs.DurationVar(&c.Lease.RenewDeadline.Duration, "lease-renew-deadline", c.Lease.RenewDeadline.Duration, "how long the leader retries renewing before giving up leadership")
+	fs.DurationVar(&c.Lease.RetryPeriod.Duration, "lease-retry-period", c.Lease.RetryPeriod.Duration, "how often leader election actions are retried")
+	fs.DurationVar(&c.Lease.WarmupTimeout.Duration, "lease-warmup-timeout", c.Lease.WarmupTimeout.Duration, "how long a new leader waits for its informer caches to sync before emitting records anyway")
+	fs.IntVar(&c.Lease.Shards, "lease-shards", c.Lease.Shards, "split the namespaces into this many shards, each replica leading one through its own Lease, 0 for a single leader")
+	fs.BoolVar(&c.Lease.HashPods, "lease-hash-pods", c.Lease.HashPods, "split the pods across every live replica by a consistent hash, rebalancing when replicas join or leave")
+	fs.StringVar(&c.Lease.HashLabel, "lease-hash-label", c.Lease.HashLabel, "pod label whose value is hashed with --lease-hash-pods, so pods sharing it stay on one replica; empty hashes the controller or pod name")
+	fs.StringVar(&c.Lease.AuditLog, "lease-audit-log", c.Lease.AuditLog, "file every leadership gain, loss and new leader is written to as a JSON line, empty to disable")
+	fs.BoolVar(&c.Lease.Annotate, "lease-annotate", c.Lease.Annotate, "record the latest leadership gains and losses in an annotation of the Lease")
+	fs.BoolVar(&c.Lease.Heartbeat, "lease-heartbeat", c.Lease.Heartbeat, "stamp the held Lease with the time of every reporting pass the leader finished")
+	fs.StringVar(&c.Lease.HeartbeatFile, "lease-heartbeat-file", c.Lease.HeartbeatFile, "file the leader writes the time of every finished reporting pass to, empty to disable")
+	fs.DurationVar(&c.Lease.StallTimeout.Duration, "lease-stall-timeout", c.Lease.StallTimeout.Duration, "fail liveness when the leader finished no reporting pass for this long, 0 to disable")
+	fs.BoolVar(&c.Lease.ReleaseOnStall, "lease-release-on-stall", c.Lease.ReleaseOnStall, "give up leadership when the reporting loop stalled for --lease-stall-timeout, so a standby replica takes over")
+
+	fs.Var((*stringList)(&c.Sinks.Names), "sink", "comma separated list of sinks to write records to: file, stdout, syslog, http, archive")
+	fs.StringVar(&c.Sinks.Journal, "sink-journal", c.Sinks.Journal, "directory records are journaled to until every sink wrote them, so sinks resume at their offset after a restart or outage")
+	fs.IntVar(&c.Sinks.JournalMaxRecords, "sink-journal-max-records", c.Sinks.JournalMaxRecords, "records kept in the journal for a sink that keeps failing before the oldest are dropped, 0 keeps all")
+	fs.IntVar(&c.Sinks.QueueSize, "sink-queue-size", c.Sinks.QueueSize, "records queued for slow sinks before enrichment, then heartbeat, then transition records are dropped, 0 writes synchronously")
+	fs.IntVar(&c.Sinks.Buffer.Size, "sink-buffer-size", c.Sinks.Buffer.Size, "records buffered for each sink and written in the background, so a slow sink does not hold up the others, 0 writes synchronously")
+	fs.IntVar(&c.Sinks.Buffer.BatchSize, "sink-buffer-batch", c.Sinks.Buffer.BatchSize, "flush a sink's buffer once this many records are buffered, 0 only flushes on the interval")
+	fs.DurationVar(&c.Sinks.Buffer.FlushInterval.Duration, "sink-flush-interval", c.Sinks.Buffer.FlushInterval.Duration, "flush each sink's buffer at least this often")
+	fs.StringVar(&c.Sinks.Buffer.Policy, "sink-buffer-policy", c.Sinks.Buffer.Policy, "what a full sink buffer does with a new record: drop-oldest drops the oldest record, block waits for the flush")
+	fs.Var((*stringList)(&c.Sinks.IDs), "record-ids", "comma separated IDs every record starts with: seq for a sequence number, ulid for a ULID")
+	fs.StringVar(&c.Sinks.File.Path, "sink-file", c.Sinks.File.Path, "path of the file sink")
+	fs.Int64Var(&c.Sinks.File.MaxSizeMB, "log-max-size", c.Sinks.File.MaxSizeMB, "rotate the file sink once it exceeds this many megabytes, 0 to disable")
+	fs.DurationVar(&c.Sinks.File.RotateInterval.Duration, "log-rotate-interval", c.Sinks.File.RotateInterval.Duration, "rotate the file sink after this duration, 0 to disable")
+	fs.IntVar(&c.Sinks.File.MaxBackups, "log-max-backups", c.Sinks.File.MaxBackups, "number of rotated log files to keep, 0 to keep all")
+	fs.DurationVar(&c.Sinks.File.MaxAge.Duration, "log-max-age", c.Sinks.File.MaxAge.Duration, "remove rotated log files older than this, 0 to keep all")
+	fs.StringVar(&c.Sinks.HTTP.URL, "sink-http-url", c.Sinks.HTTP.URL, "URL the http sink posts records to")
+	fs.BoolVar(&c.Sinks.HTTP.Gzip, "sink-http-gzip", c.Sinks.HTTP.Gzip, "gzip the http sink's request bodies")
+	fs.BoolVar(&c.Sinks.HTTP.Delta, "sink-http-delta", c.Sinks.HTTP.Delta, "post each batch as one body with only the pods that changed since the last successful POST")
+	fs.DurationVar(&c.Sinks.HTTP.ResyncInterval.Duration, "sink-http-resync-interval", c.Sinks.HTTP.ResyncInterval.Duration, "in the delta mode, post every pod again this often, marked X-Podlogger-Sync: full")
+	fs.StringVar(&c.Sinks.Archive.URL, "sink-archive-url", c.Sinks.Archive.URL, "bucket the archive sink uploads log segments to, s3://bucket/prefix or gs://bucket/prefix")
+	fs.StringVar(&c.Sinks.Archive.Dir, "sink-archive-dir", c.Sinks.Archive.Dir, "directory the archive sink keeps segments in until they are uploaded")
+	fs.DurationVar(&c.Sinks.Archive.UploadInterval.Duration, "sink-archive-interval", c.Sinks.Archive.UploadInterval.Duration, "how often the archive sink closes the current segment and uploads it")
+	fs.StringVar(&c.Sinks.Syslog.Addr, "sink-syslog-addr", c.Sinks.Syslog.Addr, "syslog daemon address (e.g. udp://host:514), empty for the local daemon")
+}
+
+// envVars maps environment variables to the flags they set
+var envVars = map[string]string{
+	"LEASE_NAME":           "lease-name",
+	"LEASE_NAMESPACE":      "lease-namespace",
+	"LEASE_DURATION":       "lease-duration",
+	"LEASE_RENEW_DEADLINE": "lease-renew-deadline",
+	"LEASE_RETRY_PERIOD":   "lease-retry-period",
+	"LEASE_SHARDS":         "lease-shards",
+	"LEASE_HASH_PODS":      "lease-hash-pods",
+}
+
+// Load layers the YAML file (if path is set) and the environment over c, then re-applies any flags
+// set on the command line so the precedence is flags, environment, file, defaults
+func Load(path string, c *Config, fs *flag.FlagSet) error {
+	// Remember the explicitly set flags before the file overwrites the values they are bound to
+	overrides := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		overrides[f.Name] = f.Value.String()
+	})
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+
+	for env, name := range envVars {
+		if value, ok := os.LookupEnv(env); ok {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid %s: %w", env, err)
+			}
+		}
+	}
+
+	for name, value := range overrides {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return c.Validate()
+}
+
+// Validate checks the settings that would otherwise fail deep inside the clients
+func (c *Config) Validate() error {
+	if c.BatchSize < 1 {
+		return errors.New("batch size must be at least 1")
+	}
+	if c.Dashboard && c.MetricsAddr == "" {
+		return errors.New("the dashboard is served on the metrics server, set a metrics address")
+	}
+	if (c.APIAuth.TLSCert == "") != (c.APIAuth.TLSKey == "") {
+		return errors.New("--api-tls-cert and --api-tls-key go together")
+	}
+	if c.APIAuth.ClientCA != "" && c.APIAuth.TLSCert == "" {
+		return errors.New("client certificates are checked over TLS, set --api-tls-cert and --api-tls-key")
+	}
+	if c.TUI && contains(c.Sinks.Names, "stdout") {
+		return errors.New("the stdout sink would write over the terminal UI, pick another sink")
+	}
+	if c.APIQPS <= 0 || c.APIBurst < 1 {
+		return fmt.Errorf("kube API QPS must be positive and burst at least 1, got %g and %d", c.APIQPS, c.APIBurst)
+	}
+	if c.ListConcurrency < 0 {
+		return fmt.Errorf("list concurrency must not be negative, got %d", c.ListConcurrency)
+	}
+	if c.ListWatchQPS < 0 {
+		return fmt.Errorf("list and watch QPS must not be negative, got %g", c.ListWatchQPS)
+	}
+	l := c.Lease
+	if l.Name == "" {
+		return errors.New("lease name must not be empty")
+	}
+	if l.Duration.Duration <= l.RenewDeadline.Duration {
+		return fmt.Errorf("lease duration %s must be greater than the renew deadline %s", l.Duration.Duration, l.RenewDeadline.Duration)
+	}
+	// Mirrors the leader election JitterFactor of 1.2
+	if l.RenewDeadline.Duration <= time.Duration(1.2*float64(l.RetryPeriod.Duration)) {
+		return fmt.Errorf("lease renew deadline %s must be greater than 1.2 times the retry period %s", l.RenewDeadline.Duration, l.RetryPeriod.Duration)
+	}
+	if l.Shards < 0 {
+		return fmt.Errorf("lease shards must not be negative, got %d", l.Shards)
+	}
+	switch l.LockType {
+	case "leases", "configmaps", "configmapsleases", "endpointsleases":
+	default:
+		return fmt.Errorf("unknown lease lock type %q, expected leases, configmaps, configmapsleases or endpointsleases", l.LockType)
+	}
+	if l.HashPods && l.Shards > 1 {
+		return errors.New("lease hash pods and lease shards are exclusive, pick one way to split the work")
+	}
+	if l.HashPods && c.StatusReports {
+		return errors.New("status reports need each namespace written by one replica, use lease shards instead of lease hash pods")
+	}
+	if l.Annotate && l.LockType == "configmaps" {
+		return errors.New("lease annotations need a lock type with a Lease, not configmaps")
+	}
+	// Member Leases are Leases whatever the lock type
+	if l.Heartbeat && l.LockType == "configmaps" && !l.HashPods {
+		return errors.New("the lease heartbeat needs a lock type with a Lease, not configmaps")
+	}
+	if l.StallTimeout.Duration < 0 {
+		return errors.New("the lease stall timeout must not be negative")
+	}
+	if l.StallTimeout.Duration > 0 && c.Interval.Duration <= 0 {
+		return errors.New("the lease stall timeout needs a reporting loop, set an interval")
+	}
+	if l.StallTimeout.Duration > 0 && l.StallTimeout.Duration <= c.Interval.Duration {
+		return fmt.Errorf("the lease stall timeout %s must be longer than the interval %s", l.StallTimeout.Duration, c.Interval.Duration)
+	}
+	if l.ReleaseOnStall && l.StallTimeout.Duration <= 0 {
+		return errors.New("releasing leadership on a stall needs a lease stall timeout")
+	}
+	if c.Template != "" && c.RecordFormat != "text" {
+		return fmt.Errorf("a template replaces the text record format, it cannot be combined with %s records", c.RecordFormat)
+	}
+	if c.PodRecordMode != "full" && c.PodRecordMode != "transitions" {
+		return fmt.Errorf("unknown pod record mode %q, expected full or transitions", c.PodRecordMode)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	if c.NamespaceSelector != "" {
+		if len(c.Namespaces) > 0 {
+			return errors.New("--namespaces and --namespace-selector are exclusive")
+		}
+		if _, err := labels.Parse(c.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid namespace selector: %w", err)
+		}
+	}
+	if c.DaemonSetCoverageGrace.Duration < 0 {
+		return errors.New("the DaemonSet coverage grace must not be negative")
+	}
+	if h := c.History; h.Retention.Duration < 0 || h.HourlyRetention.Duration < 0 || h.DailyRetention.Duration < 0 {
+		return errors.New("history retentions must not be negative")
+	}
+	if h := c.History; h.Retention.Duration > 0 {
+		if h.HourlyRetention.Duration < h.Retention.Duration {
+			return fmt.Errorf("the hourly retention %s must not be shorter than the retention %s", h.HourlyRetention.Duration, h.Retention.Duration)
+		}
+		if h.DailyRetention.Duration > 0 && h.DailyRetention.Duration < h.HourlyRetention.Duration {
+			return fmt.Errorf("the daily retention %s must not be shorter than the hourly retention %s", h.DailyRetention.Duration, h.HourlyRetention.Duration)
+		}
+		if h.CompactionInterval.Duration <= 0 {
+			return errors.New("the history retention needs a compaction interval greater than 0")
+		}
+	}
+	if c.Notify.Cooldown.Duration < 0 {
+		return errors.New("the notify cooldown must not be negative")
+	}
+	if r := c.Remediation; r.MinRestarts < 1 {
+		return fmt.Errorf("the remediation min restarts must be at least 1, got %d", r.MinRestarts)
+	}
+	if r := c.Remediation; r.GlobalBudget < 0 || r.NamespaceBudget < 0 || r.MinSamples < 0 || r.Cooldown.Duration < 0 {
+		return errors.New("remediation budgets, min samples and cooldown must not be negative")
+	}
+	if c.Remediation.Window.Duration <= 0 {
+		return errors.New("the remediation window must be greater than 0")
+	}
+	if t := c.Remediation.ErrorThreshold; t <= 0 || t > 1 {
+		return fmt.Errorf("the remediation error threshold must be above 0 and at most 1, got %g", t)
+	}
+	for _, a := range c.Prometheus.Alerts {
+		if a.Name == "" || a.Query == "" {
+			return errors.New("prometheus alerts need a name and a query")
+		}
+	}
+	if len(c.Prometheus.Alerts) > 0 && c.Prometheus.URL == "" {
+		return errors.New("prometheus alerts are configured but no prometheus url is set")
+	}
+	for _, target := range c.ReachabilityTargets {
+		if ns, name, ok := strings.Cut(target, "/"); !ok || ns == "" || name == "" {
+			return fmt.Errorf("invalid reachability target %q, expected namespace/service", target)
+		}
+	}
+	for name := range c.Sinks.Fields {
+		if !contains(c.Sinks.Names, name) {
+			return fmt.Errorf("fields are selected for sink %q which is not enabled", name)
+		}
+	}
+	if c.Sinks.QueueSize < 0 {
+		return fmt.Errorf("sink queue size must not be negative, got %d", c.Sinks.QueueSize)
+	}
+	for name := range c.Sinks.Buffers {
+		if !contains(c.Sinks.Names, name) {
+			return fmt.Errorf("a buffer is configured for sink %q which is not enabled", name)
+		}
+	}
+	for _, name := range c.Sinks.Names {
+		b := c.Sinks.BufferFor(name)
+		switch {
+		case b.Size < 0 || b.BatchSize < 0:
+			return fmt.Errorf("buffer size and batch size of the %s sink must not be negative", name)
+		case b.Size > 0 && b.FlushInterval.Duration <= 0:
+			return fmt.Errorf("buffer flush interval of the %s sink must be positive", name)
+		case b.Policy != "drop-oldest" && b.Policy != "block":
+			return fmt.Errorf("unknown buffer policy %q of the %s sink, expected drop-oldest or block", b.Policy, name)
+		case b.Size > 0 && c.Sinks.Journal != "":
+			// The journal advances a sink's offset once its write returns, a buffered record is not written yet
+			return fmt.Errorf("the %s sink cannot be buffered with a sink journal, the journal would acknowledge records before they are written", name)
+		}
+	}
+	if c.Sinks.JournalMaxRecords < 0 {
+		return fmt.Errorf("sink journal max records must not be negative, got %d", c.Sinks.JournalMaxRecords)
+	}
+	if c.Sinks.HTTP.Delta {
+		switch {
+		case c.RecordFormat != "json":
+			// Text records name no namespace, so same-named pods of two namespaces could not be told apart
+			return fmt.Errorf("the http sink delta mode needs json records, %s records do not identify a pod by namespace and name", c.RecordFormat)
+		case c.Sinks.HTTP.ResyncInterval.Duration <= 0:
+			return errors.New("http sink resync interval must be positive")
+		case c.Sinks.HTTP.ResyncInterval.Duration < c.Interval.Duration:
+			// A resync drops the pods not written since the previous one, so every pass has to fall between two
+			return fmt.Errorf("http sink resync interval %s must be at least the interval %s", c.Sinks.HTTP.ResyncInterval.Duration, c.Interval.Duration)
+		}
+	}
+	if contains(c.Sinks.Names, "archive") {
+		if !strings.HasPrefix(c.Sinks.Archive.URL, "s3://") && !strings.HasPrefix(c.Sinks.Archive.URL, "gs://") {
+			return fmt.Errorf("archive sink needs an s3:// or gs:// bucket URL, got %q", c.Sinks.Archive.URL)
+		}
+		if c.Sinks.Archive.UploadInterval.Duration <= 0 {
+			return errors.New("archive sink upload interval must be positive")
+		}
+		if c.Sinks.Archive.MaxSizeMB < 0 {
+			return fmt.Errorf("archive sink max size must not be negative, got %d", c.Sinks.Archive.MaxSizeMB)
+		}
+	}
+	for _, id := range c.Sinks.IDs {
+		if id != "seq" && id != "ulid" {
+			return fmt.Errorf("unknown record ID %q, expected seq or ulid", id)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList is a comma separated flag value that replaces the list each time it is set
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = nil
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
+
+// gateMap is a comma separated Name=bool flag value that replaces the map each time it is set
+type gateMap map[string]bool
+
+func (g *gateMap) String() string {
+	if g == nil || *g == nil {
+		return ""
+	}
+	names := make([]string, 0, len(*g))
+	for name := range *g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.FormatBool((*g)[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (g *gateMap) Set(value string) error {
+	gates := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected Feature=true|false, got %q", pair)
+		}
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for feature gate %s: %w", name, err)
+		}
+		gates[strings.TrimSpace(name)] = on
+	}
+	*g = gates
+	return nil
+}
@@ -0,0 +1,101 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// Events of a Transition
+const (
+	TransitionStarted   = "started-leading"
+	TransitionStopped   = "stopped-leading"
+	TransitionNewLeader = "new-leader"
+)
+
+// TransitionsAnnotation holds the latest transitions of the lock's holders on the Lease, see Config.AnnotateLease
+const TransitionsAnnotation = "podlogger.io/leadership-transitions"
+
+// annotatedTransitions is how many transitions the annotation keeps
+const annotatedTransitions = 10
+
+// Transition is a leadership change seen by this replica
+type Transition struct {
+	At    time.Time `json:"at"`
+	Event string    `json:"event"`
+	// Identity is the replica that saw the change, Leader the holder it saw for new-leader events
+	Identity string `json:"identity"`
+	Leader   string `json:"leader,omitempty"`
+	Lock     string `json:"lock"`
+	Reason   string `json:"reason"`
+}
+
+// transition reports a change to the configured callback and, for the replica's own gains and losses, to the Lease
+func (e *leaseElector) transition(event, leader, reason string) {
+	t := Transition{At: time.Now().UTC(), Event: event, Identity: e.config.Identity, Leader: leader, Lock: e.lock.Describe(), Reason: reason}
+	if e.config.OnTransition != nil {
+		e.config.OnTransition(t)
+	}
+	if !e.config.AnnotateLease || event == TransitionNewLeader {
+		return
+	}
+	// The lock is renewed against its own copy of the Lease, a renewal racing this update retries after a conflict
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.RetryPeriod)
+	defer cancel()
+	if err := annotateLease(ctx, e.client, e.config, t); err != nil {
+		electionLog.Warn("Error annotating Lease with the transition", "lease", e.lock.Describe(), "event", event, "err", err)
+	}
+}
+
+// annotateLease appends the transition to the Lease's TransitionsAnnotation, dropping the oldest beyond
+// annotatedTransitions
+func annotateLease(ctx context.Context, client kubernetes.Interface, config Config, t Transition) error {
+	leases := client.CoordinationV1().Leases(config.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease, err := leases.Get(ctx, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		transitions := append(parseTransitions(lease.Annotations[TransitionsAnnotation]), t)
+		if len(transitions) > annotatedTransitions {
+			transitions = transitions[len(transitions)-annotatedTransitions:]
+		}
+		value, err := json.Marshal(transitions)
+		if err != nil {
+			return err
+		}
+		if lease.Annotations == nil {
+			lease.Annotations = make(map[string]string)
+		}
+		lease.Annotations[TransitionsAnnotation] = string(value)
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// ReadTransitions returns the transitions annotated on the configured Lease, oldest first. Locks without a Lease
+// have none
+func ReadTransitions(ctx context.Context, client kubernetes.Interface, config Config) ([]Transition, error) {
+	lease, err := client.CoordinationV1().Leases(config.Namespace).Get(ctx, config.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseTransitions(lease.Annotations[TransitionsAnnotation]), nil
+}
+
+// parseTransitions decodes the annotation, a damaged value is dropped rather than blocking new transitions
+func parseTransitions(value string) []Transition {
+	var transitions []Transition
+	if value == "" || json.Unmarshal([]byte(value), &transitions) != nil {
+		return nil
+	}
+	return transitions
+}
@@ -0,0 +1,154 @@
+package election
+
+import (
+	"adv-go/logging"
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// electionLog logs leadership changes
+var electionLog = logging.For("election")
+
+var (
+	// isLeader is 1 while this replica holds the Lease
+	isLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "podlogger_leader",
+		Help: "1 while this replica is the leader, 0 otherwise.",
+	})
+
+	// transitions counts how often this replica gained or lost leadership
+	transitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_leader_transitions_total",
+		Help: "Number of times this replica started or stopped leading.",
+	}, []string{"transition"})
+)
+
+// Config configures a leader election
+type Config struct {
+	Name      string
+	Namespace string
+	// LockType is one of LockTypes, empty means LockLeases
+	LockType string
+	// Identity names this replica in the lock, DetectIdentity is used when empty
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// OnTransition is called with every leadership change this replica sees
+	OnTransition func(Transition)
+	// AnnotateLease records this replica's gains and losses in the TransitionsAnnotation of the Lease
+	AnnotateLease bool
+}
+
+// Elector runs a leader election, calling onStart with a context cancelled when leadership is lost
+type Elector interface {
+	Run(ctx context.Context, onStart func(ctx context.Context), onStop func())
+	IsLeader() bool
+	Identity() string
+}
+
+// leaseElector is an Elector backed by a resource lock, a coordination.k8s.io Lease unless configured otherwise
+type leaseElector struct {
+	client  kubernetes.Interface
+	lock    resourcelock.Interface
+	config  Config
+	leading atomic.Bool
+	// previous is the last other replica seen holding the lock
+	previous atomic.Value
+}
+
+// New creates an Elector competing for the lock described by the config
+func New(client kubernetes.Interface, config Config) (Elector, error) {
+	if config.Identity == "" {
+		config.Identity = DetectIdentity()
+	}
+	lock, err := newLock(client, config)
+	if err != nil {
+		return nil, err
+	}
+	return &leaseElector{client: client, lock: lock, config: config}, nil
+}
+
+// DetectIdentity returns the pod name from POD_NAME. Outside a pod the hostname is shared by every copy running on
+// the machine, so it is suffixed with a random UUID to keep two local copies from both holding the lock
+func DetectIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return host + "_" + string(uuid.NewUUID())
+}
+
+// Run takes part in the election until ctx is cancelled or leadership is lost. A lock held when ctx is cancelled is
+// released, so another replica takes over without waiting for it to expire
+func (e *leaseElector) Run(ctx context.Context, onStart func(ctx context.Context), onStop func()) {
+	electionLog.Info("Campaigning for leadership", "lock", e.config.LockType, "name", e.lock.Describe(), "identity", e.config.Identity)
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            e.lock,
+		LeaseDuration:   e.config.LeaseDuration,
+		RenewDeadline:   e.config.RenewDeadline,
+		RetryPeriod:     e.config.RetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				e.leading.Store(true)
+				isLeader.Set(1)
+				transitions.WithLabelValues("started").Inc()
+				reason := "acquired the lock"
+				if previous, _ := e.previous.Load().(string); previous != "" {
+					reason = "took over from " + previous
+				}
+				e.transition(TransitionStarted, e.config.Identity, reason)
+				onStart(ctx)
+			},
+			OnStoppedLeading: func() {
+				wasLeading := e.leading.Swap(false)
+				isLeader.Set(0)
+				transitions.WithLabelValues("stopped").Inc()
+				// Also called when the campaign ends without the lock ever being acquired
+				if wasLeading {
+					reason := "failed to renew the lock within the renew deadline"
+					if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+						// Cancelled with a cause, such as the agent giving up a stalled term
+						reason = "released the lock: " + cause.Error()
+					} else if ctx.Err() != nil {
+						reason = "stopped campaigning"
+					}
+					e.transition(TransitionStopped, "", reason)
+				}
+				onStop()
+			},
+			OnNewLeader: func(identity string) {
+				if identity == e.config.Identity {
+					electionLog.Info("I am still the leader!")
+				} else {
+					e.previous.Store(identity)
+					electionLog.Info("New leader elected", "identity", identity)
+				}
+				e.transition(TransitionNewLeader, identity, "observed a new holder of the lock")
+			},
+		},
+	})
+}
+
+// IsLeader reports whether this replica currently holds the Lease
+func (e *leaseElector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Identity returns the name this replica uses in the lock
+func (e *leaseElector) Identity() string {
+	return e.config.Identity
+}
@@ -0,0 +1,55 @@
+package election
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// HeartbeatAnnotation holds when the holder of the Lease last finished its work loop, as RFC3339. The lock's renew
+// time only shows the holder's elector runs, the heartbeat shows its work does too
+const HeartbeatAnnotation = "podlogger.io/heartbeat"
+
+// AnnotateHeartbeat sets the HeartbeatAnnotation of the configured Lease to at. The lock is renewed against its own
+// copy of the Lease, a renewal racing this update retries after a conflict
+func AnnotateHeartbeat(ctx context.Context, client kubernetes.Interface, config Config, at time.Time) error {
+	leases := client.CoordinationV1().Leases(config.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease, err := leases.Get(ctx, config.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if lease.Annotations == nil {
+			lease.Annotations = make(map[string]string)
+		}
+		lease.Annotations[HeartbeatAnnotation] = at.UTC().Format(time.RFC3339)
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// ReadHeartbeat returns the HeartbeatAnnotation of the configured Lease, zero when the Lease or the annotation is
+// missing
+func ReadHeartbeat(ctx context.Context, client kubernetes.Interface, config Config) (time.Time, error) {
+	lease, err := client.CoordinationV1().Leases(config.Namespace).Get(ctx, config.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseHeartbeat(lease.Annotations[HeartbeatAnnotation]), nil
+}
+
+// parseHeartbeat decodes the annotation, a damaged value reads as no heartbeat
+func parseHeartbeat(value string) time.Time {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return at
+}
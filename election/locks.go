@@ -0,0 +1,195 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Lock types selectable with Config.LockType. The migration types hold the old lock and the Lease together, so
+// replicas of an older release that only know the old lock keep seeing the current leader during a rollout
+const (
+	LockLeases           = "leases"
+	LockConfigMaps       = "configmaps"
+	LockConfigMapsLeases = "configmapsleases"
+	LockEndpointsLeases  = "endpointsleases"
+)
+
+// LockTypes lists the supported lock types
+var LockTypes = []string{LockLeases, LockConfigMaps, LockConfigMapsLeases, LockEndpointsLeases}
+
+// newLock creates the resource lock of the configured type
+func newLock(client kubernetes.Interface, config Config) (resourcelock.Interface, error) {
+	meta := metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace}
+	lockConfig := resourcelock.ResourceLockConfig{Identity: config.Identity}
+	lease := &resourcelock.LeaseLock{LeaseMeta: meta, Client: client.CoordinationV1(), LockConfig: lockConfig}
+
+	switch config.LockType {
+	case "", LockLeases:
+		return lease, nil
+	case LockConfigMaps:
+		return newConfigMapLock(client, meta, config.Identity), nil
+	case LockConfigMapsLeases:
+		return &resourcelock.MultiLock{Primary: newConfigMapLock(client, meta, config.Identity), Secondary: lease}, nil
+	case LockEndpointsLeases:
+		return &resourcelock.MultiLock{Primary: newEndpointsLock(client, meta, config.Identity), Secondary: lease}, nil
+	default:
+		return nil, fmt.Errorf("unknown lock type %q, expected one of %v", config.LockType, LockTypes)
+	}
+}
+
+// ReadRecord returns the leader election record held in the configured lock
+func ReadRecord(ctx context.Context, client kubernetes.Interface, config Config) (*resourcelock.LeaderElectionRecord, error) {
+	lock, err := newLock(client, config)
+	if err != nil {
+		return nil, err
+	}
+	record, _, err := lock.Get(ctx)
+	return record, err
+}
+
+// annotationLock keeps the leader election record in an annotation of a ConfigMap or Endpoints object, the way
+// client-go did before it removed those locks
+type annotationLock struct {
+	kind     string
+	meta     metav1.ObjectMeta
+	identity string
+	get      func(ctx context.Context) (metav1.Object, error)
+	create   func(ctx context.Context, meta metav1.ObjectMeta) (metav1.Object, error)
+	update   func(ctx context.Context, obj metav1.Object) (metav1.Object, error)
+	// current is the object last read or written, updates are made against its resource version
+	current metav1.Object
+}
+
+// newConfigMapLock creates a lock held in a ConfigMap
+func newConfigMapLock(client kubernetes.Interface, meta metav1.ObjectMeta, identity string) *annotationLock {
+	configMaps := client.CoreV1().ConfigMaps(meta.Namespace)
+	return &annotationLock{
+		kind:     "ConfigMap",
+		meta:     meta,
+		identity: identity,
+		get: func(ctx context.Context) (metav1.Object, error) {
+			cm, err := configMaps.Get(ctx, meta.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return cm, nil
+		},
+		create: func(ctx context.Context, meta metav1.ObjectMeta) (metav1.Object, error) {
+			cm, err := configMaps.Create(ctx, &v1.ConfigMap{ObjectMeta: meta}, metav1.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return cm, nil
+		},
+		update: func(ctx context.Context, obj metav1.Object) (metav1.Object, error) {
+			cm, err := configMaps.Update(ctx, obj.(*v1.ConfigMap), metav1.UpdateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return cm, nil
+		},
+	}
+}
+
+// newEndpointsLock creates a lock held in an Endpoints object, only used as the old half of a migration lock
+func newEndpointsLock(client kubernetes.Interface, meta metav1.ObjectMeta, identity string) *annotationLock {
+	endpoints := client.CoreV1().Endpoints(meta.Namespace)
+	return &annotationLock{
+		kind:     "Endpoints",
+		meta:     meta,
+		identity: identity,
+		get: func(ctx context.Context) (metav1.Object, error) {
+			ep, err := endpoints.Get(ctx, meta.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return ep, nil
+		},
+		create: func(ctx context.Context, meta metav1.ObjectMeta) (metav1.Object, error) {
+			ep, err := endpoints.Create(ctx, &v1.Endpoints{ObjectMeta: meta}, metav1.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return ep, nil
+		},
+		update: func(ctx context.Context, obj metav1.Object) (metav1.Object, error) {
+			ep, err := endpoints.Update(ctx, obj.(*v1.Endpoints), metav1.UpdateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return ep, nil
+		},
+	}
+}
+
+// Get returns the record in the object's annotation, an empty record when the object carries none
+func (l *annotationLock) Get(ctx context.Context) (*resourcelock.LeaderElectionRecord, []byte, error) {
+	obj, err := l.get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	l.current = obj
+	var record resourcelock.LeaderElectionRecord
+	raw, ok := obj.GetAnnotations()[resourcelock.LeaderElectionRecordAnnotationKey]
+	if ok {
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &record, []byte(raw), nil
+}
+
+// Create creates the object holding the record
+func (l *annotationLock) Create(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	raw, err := json.Marshal(ler)
+	if err != nil {
+		return err
+	}
+	meta := l.meta
+	meta.Annotations = map[string]string{resourcelock.LeaderElectionRecordAnnotationKey: string(raw)}
+	l.current, err = l.create(ctx, meta)
+	return err
+}
+
+// Update writes the record to the object last read
+func (l *annotationLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	if l.current == nil {
+		return errors.New(l.kind + " lock not initialized, call Get or Create first")
+	}
+	raw, err := json.Marshal(ler)
+	if err != nil {
+		return err
+	}
+	annotations := make(map[string]string, len(l.current.GetAnnotations())+1)
+	for k, v := range l.current.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[resourcelock.LeaderElectionRecordAnnotationKey] = string(raw)
+	l.current.SetAnnotations(annotations)
+	obj, err := l.update(ctx, l.current)
+	if err != nil {
+		return err
+	}
+	l.current = obj
+	return nil
+}
+
+// RecordEvent is a no-op, leadership changes are logged and counted by the elector
+func (l *annotationLock) RecordEvent(string) {}
+
+// Identity returns the identity the lock is taken with
+func (l *annotationLock) Identity() string {
+	return l.identity
+}
+
+// Describe names the lock object
+func (l *annotationLock) Describe() string {
+	return l.meta.Namespace + "/" + l.meta.Name
+}
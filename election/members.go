@@ -0,0 +1,311 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MemberOfLabel marks the member Leases with the name of the configured lock they belong to
+const MemberOfLabel = "podlogger.io/member-of"
+
+// Member is a replica holding a member Lease
+type Member struct {
+	Identity string
+	Renewed  time.Time
+	Expires  time.Time
+	// Heartbeat is the replica's HeartbeatAnnotation, zero when it writes none
+	Heartbeat time.Time
+}
+
+// Membership is an Elector for replicas that all work at once, each on its share of the keys. Every replica holds a
+// member Lease of its own and watches the others, and a term runs for as long as the set of live replicas is
+// unchanged: when a replica joins or leaves the term is stopped and a new one started with the new set, so the
+// keys are rebalanced
+type Membership struct {
+	client kubernetes.Interface
+	config Config
+
+	mu      sync.RWMutex
+	members []string
+	leading atomic.Bool
+}
+
+// NewMembership creates a Membership for the replicas of the lock described by the config. Only the lock name,
+// namespace, identity and timings are used, member Leases are always coordination.k8s.io Leases
+func NewMembership(client kubernetes.Interface, config Config) (*Membership, error) {
+	if config.Identity == "" {
+		config.Identity = DetectIdentity()
+	}
+	if config.RetryPeriod <= 0 || config.LeaseDuration <= config.RetryPeriod {
+		return nil, fmt.Errorf("lease duration %s must be greater than the retry period %s", config.LeaseDuration, config.RetryPeriod)
+	}
+	return &Membership{client: client, config: config}, nil
+}
+
+// MemberName is the name of the member Lease of a replica. Identities may not be valid object names, so the name
+// carries a hash of the identity and the Lease holds the identity itself
+func MemberName(name, identity string) string {
+	h := fnv.New32a()
+	h.Write([]byte(identity))
+	return fmt.Sprintf("%s-member-%08x", name, h.Sum32())
+}
+
+// Owner returns the member owning the key by rendezvous hashing: every member is scored by the hash of the member
+// and the key and the highest wins, so a member joining or leaving moves only the keys it gains or held
+func Owner(members []string, key string) string {
+	var owner string
+	var best uint64
+	for _, m := range members {
+		h := fnv.New64a()
+		h.Write([]byte(m))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if score := mix(h.Sum64()); owner == "" || score > best || (score == best && m < owner) {
+			owner, best = m, score
+		}
+	}
+	return owner
+}
+
+// mix is the splitmix64 finalizer, FNV alone scores similar short keys alike and splits them unevenly
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// Run keeps this replica's member Lease renewed and calls onStart with the live replicas known, and onStop before
+// every rebalance, until ctx is cancelled. The member Lease is deleted on the way out so the others rebalance
+// right away instead of once it expired
+func (m *Membership) Run(ctx context.Context, onStart func(ctx context.Context), onStop func()) {
+	factory := informers.NewSharedInformerFactoryWithOptions(m.client, 0,
+		informers.WithNamespace(m.config.Namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = MemberOfLabel + "=" + m.config.Name
+		}),
+	)
+	informer := factory.Coordination().V1().Leases().Informer()
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	})
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		m.keepAlive(ctx)
+	}()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		<-renewed
+		return
+	}
+
+	var cancel context.CancelFunc
+	stop := func() {
+		if cancel == nil {
+			return
+		}
+		cancel()
+		cancel = nil
+		m.leading.Store(false)
+		onStop()
+	}
+	defer func() {
+		stop()
+		// Running again starts from no replicas, so the first set seen starts a term
+		m.mu.Lock()
+		m.members = nil
+		m.mu.Unlock()
+		<-renewed
+	}()
+
+	// A new set of replicas is applied once it held for a retry period, so replicas starting together rebalance
+	// once rather than for each of them
+	var pending []string
+	var pendingSince time.Time
+	tick := time.NewTicker(m.config.RetryPeriod)
+	defer tick.Stop()
+	for {
+		live := liveMembers(informer.GetStore().List(), time.Now())
+		switch {
+		case slices.Equal(live, m.Members()):
+			pending = nil
+		case !slices.Equal(live, pending):
+			pending, pendingSince = live, time.Now()
+		case time.Since(pendingSince) >= m.config.RetryPeriod:
+			electionLog.Info("Replicas changed, rebalancing", "identity", m.config.Identity, "replicas", strings.Join(live, ","))
+			stop()
+			m.mu.Lock()
+			m.members = live
+			m.mu.Unlock()
+			pending = nil
+			// A replica whose own Lease lapsed works on nothing until it renewed it
+			if slices.Contains(live, m.config.Identity) {
+				termCtx, cancelTerm := context.WithCancel(ctx)
+				cancel = cancelTerm
+				m.leading.Store(true)
+				onStart(termCtx)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-tick.C:
+		}
+	}
+}
+
+// keepAlive renews the member Lease every retry period until ctx is cancelled, then deletes it
+func (m *Membership) keepAlive(ctx context.Context) {
+	leases := m.client.CoordinationV1().Leases(m.config.Namespace)
+	name := MemberName(m.config.Name, m.config.Identity)
+	for {
+		if err := m.renew(ctx); err != nil && ctx.Err() == nil {
+			electionLog.Warn("Failed to renew the member Lease", "lease", name, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			deleteCtx, cancel := context.WithTimeout(context.Background(), m.config.RetryPeriod)
+			defer cancel()
+			if err := leases.Delete(deleteCtx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				electionLog.Warn("Failed to delete the member Lease", "lease", name, "err", err)
+			}
+			return
+		case <-time.After(m.config.RetryPeriod):
+		}
+	}
+}
+
+// renew creates or renews the member Lease
+func (m *Membership) renew(ctx context.Context) error {
+	leases := m.client.CoordinationV1().Leases(m.config.Namespace)
+	name := MemberName(m.config.Name, m.config.Identity)
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(m.config.LeaseDuration / time.Second)
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: m.config.Namespace,
+				Labels:    map[string]string{MemberOfLabel: m.config.Name},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.config.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = &m.config.Identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// liveMembers returns the sorted identities of the member Leases that have not expired
+func liveMembers(objects []interface{}, now time.Time) []string {
+	var live []string
+	for _, obj := range objects {
+		lease, ok := obj.(*coordinationv1.Lease)
+		if !ok {
+			continue
+		}
+		if member, ok := memberOf(lease); ok && now.Before(member.Expires) {
+			live = append(live, member.Identity)
+		}
+	}
+	sort.Strings(live)
+	return live
+}
+
+// memberOf reads the member a Lease is held by, false when it is not held
+func memberOf(lease *coordinationv1.Lease) (Member, bool) {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return Member{}, false
+	}
+	renewed := spec.RenewTime.Time
+	return Member{
+		Identity:  *spec.HolderIdentity,
+		Renewed:   renewed,
+		Expires:   renewed.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second),
+		Heartbeat: parseHeartbeat(lease.Annotations[HeartbeatAnnotation]),
+	}, true
+}
+
+// ReadMembers lists the member Leases of the lock described by the config, expired ones included, by identity
+func ReadMembers(ctx context.Context, client kubernetes.Interface, config Config) ([]Member, error) {
+	list, err := client.CoordinationV1().Leases(config.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: MemberOfLabel + "=" + config.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var members []Member
+	for i := range list.Items {
+		if member, ok := memberOf(&list.Items[i]); ok {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Identity < members[j].Identity })
+	return members, nil
+}
+
+// Members returns the sorted identities of the replicas the current term shares the work with
+func (m *Membership) Members() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.members)
+}
+
+// Owns reports whether this replica owns the key among the replicas of the current term
+func (m *Membership) Owns(key string) bool {
+	return Owner(m.Members(), key) == m.config.Identity
+}
+
+// IsLeader reports whether a term is running, that is this replica works on its share of the keys
+func (m *Membership) IsLeader() bool {
+	return m.leading.Load()
+}
+
+// Identity is the name of this replica in its member Lease
+func (m *Membership) Identity() string {
+	return m.config.Identity
+}
@@ -0,0 +1,135 @@
+package election
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podKeys are namespace/name keys like the agent hashes pods by
+func podKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("shop/web-%d", i)
+	}
+	return keys
+}
+
+// owners assigns every key to its owner among the members
+func owners(members, keys []string) map[string]string {
+	owned := make(map[string]string, len(keys))
+	for _, key := range keys {
+		owned[key] = Owner(members, key)
+	}
+	return owned
+}
+
+func TestOwnerStable(t *testing.T) {
+	members := []string{"pod-logger-0", "pod-logger-1", "pod-logger-2"}
+	keys := podKeys(1000)
+	first := owners(members, keys)
+
+	// The member order and repeated calls change nothing
+	reversed := slices.Clone(members)
+	slices.Reverse(reversed)
+	for key, owner := range owners(reversed, keys) {
+		if first[key] != owner {
+			t.Fatalf("%s moved from %s to %s with the members unchanged", key, first[key], owner)
+		}
+	}
+
+	// Every member gets a fair share
+	counts := make(map[string]int)
+	for _, owner := range first {
+		counts[owner]++
+	}
+	for _, m := range members {
+		if counts[m] < 250 || counts[m] > 420 {
+			t.Errorf("%s owns %d of %d keys, want about a third", m, counts[m], len(keys))
+		}
+	}
+}
+
+func TestOwnerSingleAndNone(t *testing.T) {
+	if owner := Owner([]string{"pod-logger-0"}, "shop/web-0"); owner != "pod-logger-0" {
+		t.Errorf("single member owner = %q", owner)
+	}
+	if owner := Owner(nil, "shop/web-0"); owner != "" {
+		t.Errorf("owner without members = %q, want none", owner)
+	}
+}
+
+func TestOwnerRebalance(t *testing.T) {
+	keys := podKeys(2000)
+	members := []string{"pod-logger-0", "pod-logger-1", "pod-logger-2", "pod-logger-3"}
+	before := owners(members, keys)
+
+	// A member joining takes about 1/N of the keys, all of them from the others and nothing moves between them
+	joined := append(slices.Clone(members), "pod-logger-4")
+	moved := 0
+	for key, owner := range owners(joined, keys) {
+		if owner == before[key] {
+			continue
+		}
+		if owner != "pod-logger-4" {
+			t.Fatalf("%s moved from %s to %s, not to the joining member", key, before[key], owner)
+		}
+		moved++
+	}
+	if want := len(keys) / len(joined); moved < want*3/4 || moved > want*5/4 {
+		t.Errorf("joining moved %d of %d keys, want about %d", moved, len(keys), want)
+	}
+
+	// A member leaving hands over only the keys it held
+	left := []string{"pod-logger-0", "pod-logger-2", "pod-logger-3"}
+	moved = 0
+	for key, owner := range owners(left, keys) {
+		if owner == before[key] {
+			continue
+		}
+		if before[key] != "pod-logger-1" {
+			t.Fatalf("%s moved from %s to %s although its owner stayed", key, before[key], owner)
+		}
+		moved++
+	}
+	if want := len(keys) / len(members); moved < want*3/4 || moved > want*5/4 {
+		t.Errorf("leaving moved %d of %d keys, want about %d", moved, len(keys), want)
+	}
+}
+
+func memberLease(identity string, renewed time.Time, seconds int32) *coordinationv1.Lease {
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: MemberName("pod-logger", identity)}}
+	if identity != "" {
+		lease.Spec.HolderIdentity = &identity
+	}
+	at := metav1.NewMicroTime(renewed)
+	lease.Spec.RenewTime = &at
+	lease.Spec.LeaseDurationSeconds = &seconds
+	return lease
+}
+
+func TestLiveMembers(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	released := memberLease("pod-logger-4", now, 15)
+	released.Spec.RenewTime = nil
+	objects := []interface{}{
+		memberLease("pod-logger-2", now.Add(-5*time.Second), 15),
+		memberLease("pod-logger-0", now.Add(-14*time.Second), 15),
+		// Lapsed: renewed a lease duration ago or longer
+		memberLease("pod-logger-1", now.Add(-15*time.Second), 15),
+		memberLease("pod-logger-3", now.Add(-time.Hour), 15),
+		// Not held by anyone
+		memberLease("", now, 15),
+		released,
+		"not a lease",
+	}
+
+	live := liveMembers(objects, now)
+	if want := []string{"pod-logger-0", "pod-logger-2"}; !slices.Equal(live, want) {
+		t.Errorf("live members = %v, want %v", live, want)
+	}
+}
@@ -0,0 +1,164 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ShardedElector competes for one of several shard locks at a time, so each replica leads a share of the work.
+// The shard locks are named by ShardName after the configured lock
+type ShardedElector struct {
+	client kubernetes.Interface
+	config Config
+	shards int
+
+	mu      sync.Mutex
+	current Elector
+	shard   int
+}
+
+// NewSharded creates an Elector that claims one of shards shard locks
+func NewSharded(client kubernetes.Interface, config Config, shards int) (*ShardedElector, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("invalid shard count %d", shards)
+	}
+	if config.Identity == "" {
+		config.Identity = DetectIdentity()
+	}
+	// Building a lock validates the lock type before campaigning
+	if _, err := newLock(client, config); err != nil {
+		return nil, err
+	}
+	return &ShardedElector{client: client, config: config, shards: shards, shard: -1}, nil
+}
+
+// ShardName is the name of the lock of a shard
+func ShardName(name string, shard int) string {
+	return fmt.Sprintf("%s-shard-%d", name, shard)
+}
+
+// ShardConfig is the lock config of a shard
+func ShardConfig(config Config, shard int) Config {
+	config.Name = ShardName(config.Name, shard)
+	return config
+}
+
+// Run claims a free shard and leads it, calling onStart and onStop around every shard held, until ctx is cancelled.
+// Replicas that find every shard held stand by and look again every retry period
+func (e *ShardedElector) Run(ctx context.Context, onStart func(ctx context.Context), onStop func()) {
+	for ctx.Err() == nil {
+		shard, ok := e.freeShard(ctx)
+		if !ok {
+			electionLog.Debug("Every shard is held, standing by", "shards", e.shards)
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait.Jitter(e.config.RetryPeriod, 1.2)):
+			}
+			continue
+		}
+		e.campaign(ctx, shard, onStart, onStop)
+	}
+}
+
+// campaign runs the election of a single shard until its leadership is lost. Another replica may win the race for
+// the same free shard, the campaign gives up after a lease duration so the loser moves on to the next free one
+func (e *ShardedElector) campaign(ctx context.Context, shard int, onStart func(ctx context.Context), onStop func()) {
+	shardElector, err := New(e.client, ShardConfig(e.config, shard))
+	if err != nil {
+		electionLog.Error("Failed to set up shard election", "shard", shard, "err", err)
+		return
+	}
+	e.mu.Lock()
+	e.current, e.shard = shardElector, shard
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.current, e.shard = nil, -1
+		e.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	started := make(chan struct{})
+	go func() {
+		select {
+		case <-started:
+		case <-ctx.Done():
+		case <-time.After(e.config.LeaseDuration + 2*e.config.RetryPeriod):
+			electionLog.Info("Shard claimed by another replica", "shard", shard)
+			cancel()
+		}
+	}()
+
+	shardElector.Run(ctx, func(ctx context.Context) {
+		close(started)
+		onStart(ctx)
+	}, func() {
+		// The leader election calls onStop even when the shard was never acquired
+		select {
+		case <-started:
+			onStop()
+		default:
+		}
+	})
+}
+
+// freeShard returns a shard whose lock is not held or expired, starting at a shard picked by the identity so
+// replicas starting together try different shards first
+func (e *ShardedElector) freeShard(ctx context.Context) (int, bool) {
+	h := fnv.New32a()
+	h.Write([]byte(e.config.Identity))
+	offset := int(h.Sum32() % uint32(e.shards))
+	for i := 0; i < e.shards; i++ {
+		shard := (offset + i) % e.shards
+		record, err := ReadRecord(ctx, e.client, ShardConfig(e.config, shard))
+		if apierrors.IsNotFound(err) {
+			return shard, true
+		}
+		if err != nil {
+			electionLog.Warn("Error reading shard lock", "shard", shard, "err", err)
+			continue
+		}
+		if record.HolderIdentity == "" || record.HolderIdentity == e.config.Identity {
+			return shard, true
+		}
+		expires := record.RenewTime.Add(time.Duration(record.LeaseDurationSeconds) * time.Second)
+		if time.Now().After(expires) {
+			return shard, true
+		}
+	}
+	return 0, false
+}
+
+// Shard returns the shard this replica currently leads
+func (e *ShardedElector) Shard() (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current == nil || !e.current.IsLeader() {
+		return -1, false
+	}
+	return e.shard, true
+}
+
+// Shards is the number of shards competed for
+func (e *ShardedElector) Shards() int {
+	return e.shards
+}
+
+// IsLeader reports whether this replica currently leads a shard
+func (e *ShardedElector) IsLeader() bool {
+	_, ok := e.Shard()
+	return ok
+}
+
+// Identity is the name of this replica in every shard lock
+func (e *ShardedElector) Identity() string {
+	return e.config.Identity
+}
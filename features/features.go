@@ -0,0 +1,104 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Feature names an experimental subsystem that can be switched on or off with --feature-gates
+type Feature string
+
+const (
+	// VolumeReporting logs PersistentVolumeClaims and the pods waiting on unbound claims every pass
+	VolumeReporting Feature = "VolumeReporting"
+	// AutoscalerCorrelation explains pod disruptions with the node autoscaler events preceding them
+	AutoscalerCorrelation Feature = "AutoscalerCorrelation"
+	// EndpointLatency measures how long pods that became Ready take to appear in their Services' EndpointSlices
+	EndpointLatency Feature = "EndpointLatency"
+	// JobTracking logs finished Jobs and missed CronJob schedules every pass
+	JobTracking Feature = "JobTracking"
+	// DaemonSetCoverage checks every pass that each DaemonSet has a ready pod on every node it targets
+	DaemonSetCoverage Feature = "DaemonSetCoverage"
+	// PodRemediation deletes controller-managed pods stuck crash looping, within budgets and behind a circuit breaker
+	PodRemediation Feature = "PodRemediation"
+)
+
+// Stage is how mature a feature is, Alpha features are off unless enabled
+type Stage string
+
+const (
+	Alpha Stage = "Alpha"
+	Beta  Stage = "Beta"
+	GA    Stage = "GA"
+)
+
+// Spec describes a feature and whether it is on by default
+type Spec struct {
+	Default     bool
+	Stage       Stage
+	Description string
+}
+
+// specs are the known features
+var specs = map[Feature]Spec{
+	VolumeReporting:       {Default: true, Stage: Beta, Description: "report PersistentVolumeClaims and pods waiting on unbound claims"},
+	AutoscalerCorrelation: {Default: true, Stage: Beta, Description: "correlate pod disruptions with Karpenter and cluster-autoscaler activity"},
+	EndpointLatency:       {Default: false, Stage: Alpha, Description: "measure the delay between a pod becoming Ready and its IP appearing in EndpointSlices"},
+	JobTracking:           {Default: true, Stage: Beta, Description: "log Job completions and failures and missed CronJob schedules"},
+	DaemonSetCoverage:     {Default: true, Stage: Beta, Description: "report the nodes a DaemonSet targets but has no ready pod on, with the reason"},
+	PodRemediation:        {Default: false, Stage: Alpha, Description: "delete crash looping pods of Deployments, StatefulSets and DaemonSets so their controller replaces them"},
+}
+
+// overrides are the gates set explicitly, the rest follow their default
+var (
+	overrides = make(map[Feature]bool)
+	mu        sync.RWMutex
+)
+
+// Set replaces the explicitly set gates, rejecting unknown features
+func Set(gates map[string]bool) error {
+	next := make(map[Feature]bool, len(gates))
+	for name, on := range gates {
+		f := Feature(name)
+		spec, ok := specs[f]
+		if !ok {
+			return fmt.Errorf("unknown feature gate %q", name)
+		}
+		if spec.Stage == GA && !on {
+			return fmt.Errorf("feature gate %s is GA and can no longer be disabled", name)
+		}
+		next[f] = on
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	overrides = next
+	return nil
+}
+
+// Enabled reports whether the feature is on, unknown features are off
+func Enabled(f Feature) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	if on, ok := overrides[f]; ok {
+		return on
+	}
+	return specs[f].Default
+}
+
+// Status is a feature with its spec and current state
+type Status struct {
+	Name Feature
+	Spec
+	Enabled bool
+}
+
+// List returns every known feature sorted by name
+func List() []Status {
+	list := make([]Status, 0, len(specs))
+	for f, spec := range specs {
+		list = append(list, Status{Name: f, Spec: spec, Enabled: Enabled(f)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,108 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	// Embedded so IANA timezone names resolve in images without /usr/share/zoneinfo
+	_ "time/tzdata"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// location is the timezone Time renders timestamps in
+var location = time.Local
+
+// SetTimezone selects the timezone Time renders in: Local, UTC or an IANA name like Europe/Berlin
+func SetTimezone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	location = loc
+	return nil
+}
+
+// Location returns the timezone selected with SetTimezone
+func Location() *time.Location {
+	return location
+}
+
+// Time renders a timestamp as RFC3339 in the selected timezone, e.g. 2024-05-01T14:03:00+02:00
+func Time(t time.Time) string {
+	return t.In(location).Format(time.RFC3339)
+}
+
+// Age renders a duration the way kubectl renders ages, e.g. 45s, 3m20s, 5h, 5d3h
+func Age(d time.Duration) string {
+	return duration.HumanDuration(d)
+}
+
+// Since renders the age of a timestamp, "<unknown>" for the zero time
+func Since(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return Age(time.Since(t))
+}
+
+// Duration renders a measured delay, keeping millisecond precision below a second where ages would show 0s
+func Duration(d time.Duration) string {
+	if d < 0 {
+		return "-" + Duration(-d)
+	}
+	if d < time.Second {
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+	return Age(d)
+}
+
+// binaryUnits are the suffixes kubectl uses for memory, by powers of 1024
+var binaryUnits = []string{"", "Ki", "Mi", "Gi", "Ti", "Pi", "Ei"}
+
+// Bytes renders a byte size with binary suffixes like kubectl top, e.g. 512Mi or 1.5Gi
+func Bytes(n int64) string {
+	if n < 0 {
+		return "-" + Bytes(-n)
+	}
+	value, unit := float64(n), 0
+	for value >= 1024 && unit < len(binaryUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return trimFloat(value) + binaryUnits[unit]
+}
+
+// CPU renders millicores like kubectl top, e.g. 250m, or whole cores when exact, e.g. 2
+func CPU(millicores int64) string {
+	if millicores%1000 == 0 {
+		return strconv.FormatInt(millicores/1000, 10)
+	}
+	return strconv.FormatInt(millicores, 10) + "m"
+}
+
+// Rate renders how often something happened over a period, per second, minute or hour whichever reads best
+func Rate(count float64, period time.Duration) string {
+	if period <= 0 {
+		return "0/s"
+	}
+	perSecond := count / period.Seconds()
+	switch {
+	case perSecond >= 1 || perSecond == 0:
+		return trimFloat(perSecond) + "/s"
+	case perSecond*60 >= 1:
+		return trimFloat(perSecond*60) + "/min"
+	default:
+		return trimFloat(perSecond*3600) + "/h"
+	}
+}
+
+// trimFloat renders at most one decimal, dropping it when the value is whole
+func trimFloat(v float64) string {
+	v = math.Round(v*10) / 10
+	if v == math.Trunc(v) {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
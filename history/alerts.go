@@ -0,0 +1,178 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path"
+	"time"
+)
+
+// alertSchema creates the alert state tables, the key of an alert identifies what it is about, e.g.
+// "bad-phase/shop/cart-6c8f5-q7r2d", so an agent that restarts or takes over leadership finds it firing
+const alertSchema = `
+CREATE TABLE IF NOT EXISTS alert_states (
+	alert_key       TEXT PRIMARY KEY,
+	namespace       TEXT NOT NULL,
+	subject         TEXT NOT NULL,
+	reason          TEXT NOT NULL,
+	detail          TEXT NOT NULL,
+	state           TEXT NOT NULL,
+	fired_at        TIMESTAMP NOT NULL,
+	resolved_at     TIMESTAMP,
+	acknowledged_by TEXT NOT NULL,
+	acknowledged_at TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS alert_silences (
+	id         TEXT PRIMARY KEY,
+	matcher    TEXT NOT NULL,
+	comment    TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+`
+
+// States of an alert
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// ErrNotFiring is returned when acknowledging an alert that is not firing
+var ErrNotFiring = errors.New("alert is not firing")
+
+// Alert is the last known state of an alert, the zero times mean not resolved or not acknowledged
+type Alert struct {
+	Key            string    `json:"key"`
+	Namespace      string    `json:"namespace"`
+	Subject        string    `json:"subject"`
+	Reason         string    `json:"reason"`
+	Detail         string    `json:"detail"`
+	State          string    `json:"state"`
+	FiredAt        time.Time `json:"firedAt"`
+	ResolvedAt     time.Time `json:"resolvedAt,omitempty"`
+	AcknowledgedBy string    `json:"acknowledgedBy,omitempty"`
+	AcknowledgedAt time.Time `json:"acknowledgedAt,omitempty"`
+}
+
+// Silence mutes the notifications of the alerts whose key matches the glob until it expires
+type Silence struct {
+	ID        string    `json:"id"`
+	Matcher   string    `json:"matcher"`
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Matches reports whether the silence applies to the alert key, matchers are path.Match globs like "bad-phase/shop/*"
+func (s Silence) Matches(key string) bool {
+	ok, err := path.Match(s.Matcher, key)
+	return err == nil && ok
+}
+
+// FireAlert marks the alert firing for its reason, clearing an acknowledgment of an earlier firing
+func (s *Store) FireAlert(ctx context.Context, a Alert) error {
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO alert_states (alert_key, namespace, subject, reason, detail, state, fired_at, resolved_at, acknowledged_by, acknowledged_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NULL, '', NULL)
+ON CONFLICT (alert_key) DO UPDATE SET namespace = excluded.namespace, subject = excluded.subject, reason = excluded.reason,
+	detail = excluded.detail, state = excluded.state, fired_at = excluded.fired_at, resolved_at = NULL,
+	acknowledged_by = '', acknowledged_at = NULL`,
+		a.Key, a.Namespace, a.Subject, a.Reason, a.Detail, AlertFiring, a.FiredAt.UTC())
+	return err
+}
+
+// ResolveAlert marks the alert resolved, alerts that are not firing are left alone
+func (s *Store) ResolveAlert(ctx context.Context, key string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE alert_states SET state = $1, resolved_at = $2 WHERE alert_key = $3 AND state = $4",
+		AlertResolved, at.UTC(), key, AlertFiring)
+	return err
+}
+
+// AcknowledgeAlert records who acknowledged the firing alert, returning ErrNotFiring when it is not firing
+func (s *Store) AcknowledgeAlert(ctx context.Context, key, by string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE alert_states SET acknowledged_by = $1, acknowledged_at = $2 WHERE alert_key = $3 AND state = $4",
+		by, at.UTC(), key, AlertFiring)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFiring
+	}
+	return nil
+}
+
+// Alerts returns the firing alerts and, with since set, the alerts resolved since then, most recently fired first
+func (s *Store) Alerts(ctx context.Context, since time.Time) ([]Alert, error) {
+	query := `
+SELECT alert_key, namespace, subject, reason, detail, state, fired_at, resolved_at, acknowledged_by, acknowledged_at
+FROM alert_states WHERE state = $1`
+	args := []any{AlertFiring}
+	if !since.IsZero() {
+		query += " OR resolved_at >= $2"
+		args = append(args, since.UTC())
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY fired_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		var resolved, acknowledged sql.NullTime
+		if err := rows.Scan(&a.Key, &a.Namespace, &a.Subject, &a.Reason, &a.Detail, &a.State, &a.FiredAt, &resolved,
+			&a.AcknowledgedBy, &acknowledged); err != nil {
+			return nil, err
+		}
+		a.ResolvedAt, a.AcknowledgedAt = resolved.Time, acknowledged.Time
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// AddSilence stores a silence
+func (s *Store) AddSilence(ctx context.Context, silence Silence) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO alert_silences (id, matcher, comment, created_by, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		silence.ID, silence.Matcher, silence.Comment, silence.CreatedBy, silence.CreatedAt.UTC(), silence.ExpiresAt.UTC())
+	return err
+}
+
+// DeleteSilence removes a silence, returning sql.ErrNoRows when there is none with the ID
+func (s *Store) DeleteSilence(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM alert_silences WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Silences returns the silences that have not expired at the given time, soonest to expire first
+func (s *Store) Silences(ctx context.Context, at time.Time) ([]Silence, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, matcher, comment, created_by, created_at, expires_at FROM alert_silences WHERE expires_at > $1 ORDER BY expires_at",
+		at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var silences []Silence
+	for rows.Next() {
+		var silence Silence
+		if err := rows.Scan(&silence.ID, &silence.Matcher, &silence.Comment, &silence.CreatedBy, &silence.CreatedAt, &silence.ExpiresAt); err != nil {
+			return nil, err
+		}
+		silences = append(silences, silence)
+	}
+	return silences, rows.Err()
+}
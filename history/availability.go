@@ -0,0 +1,71 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Availability is the share of a window a workload had all its desired replicas ready
+type Availability struct {
+	Namespace string
+	Workload  string
+	// Percent is the available time over the observed time, 100 when nothing was observed
+	Percent float64
+	// Observed is how much of the window is covered by samples, the time before a workload's first sample and
+	// while it was scaled to zero is not counted
+	Observed time.Duration
+	Downtime time.Duration
+}
+
+// ComputeAvailability replays the samples, oldest first as returned by ReplicaSamples, over the window from since
+// to now. A workload is down while fewer replicas than desired are ready, the last sample holds until now
+func ComputeAvailability(samples []ReplicaSample, since, now time.Time) []Availability {
+	type key struct{ namespace, workload string }
+	last := make(map[key]ReplicaSample)
+	totals := make(map[key]*Availability)
+
+	account := func(k key, prev ReplicaSample, until time.Time) {
+		from := prev.At
+		if from.Before(since) {
+			from = since
+		}
+		if !until.After(from) || prev.Desired == 0 {
+			return
+		}
+		a := totals[k]
+		a.Observed += until.Sub(from)
+		if prev.Ready < prev.Desired {
+			a.Downtime += until.Sub(from)
+		}
+	}
+
+	for _, s := range samples {
+		k := key{s.Namespace, s.Workload}
+		if totals[k] == nil {
+			totals[k] = &Availability{Namespace: s.Namespace, Workload: s.Workload}
+		}
+		if prev, ok := last[k]; ok {
+			account(k, prev, s.At)
+		}
+		last[k] = s
+	}
+	for k, prev := range last {
+		account(k, prev, now)
+	}
+
+	result := make([]Availability, 0, len(totals))
+	for _, a := range totals {
+		a.Percent = 100
+		if a.Observed > 0 {
+			a.Percent = 100 * float64(a.Observed-a.Downtime) / float64(a.Observed)
+		}
+		result = append(result, *a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Workload < result[j].Workload
+	})
+	return result
+}
@@ -0,0 +1,266 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// rollupSchema creates the table of hourly and daily counts the compaction rolls old transitions and events up into
+const rollupSchema = `
+CREATE TABLE IF NOT EXISTS pod_rollups (
+	resolution TEXT NOT NULL,
+	bucket     TIMESTAMP NOT NULL,
+	namespace  TEXT NOT NULL,
+	record     TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	count      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pod_rollups_bucket ON pod_rollups (resolution, bucket);
+`
+
+// Resolutions of the roll-ups
+const (
+	Hourly = "hour"
+	Daily  = "day"
+)
+
+// Records counted by the roll-ups
+const (
+	RollupTransition = "transition"
+	RollupEvent      = "event"
+)
+
+// Rollup counts a namespace's transitions into a phase, or its pod events of a kind, over an hour or a day
+type Rollup struct {
+	Resolution string    `json:"resolution"`
+	Bucket     time.Time `json:"bucket"`
+	Namespace  string    `json:"namespace"`
+	// Record is RollupTransition or RollupEvent
+	Record string `json:"record"`
+	// Name is the phase transitioned into, or the kind of pod event
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// Retention is how long each resolution of the history is kept
+type Retention struct {
+	// Raw keeps the transitions, pod events and lineage this long before rolling them up by hour
+	Raw time.Duration
+	// Hourly keeps the hourly roll-ups this long before rolling them up by day
+	Hourly time.Duration
+	// Daily keeps the daily roll-ups this long, 0 forever
+	Daily time.Duration
+}
+
+// Compaction counts what a compaction rolled up or removed
+type Compaction struct {
+	Transitions int64
+	Events      int64
+	Lineage     int64
+	// Hourly are the hourly roll-ups merged into daily ones
+	Hourly int64
+	// Daily are the daily roll-ups past their retention
+	Daily int64
+}
+
+// rollupKey identifies a roll-up row
+type rollupKey struct {
+	bucket                  time.Time
+	namespace, record, name string
+}
+
+// Compact rolls the transitions and pod events older than the raw retention up into hourly counts, the hourly counts
+// older than their retention up into daily ones, and drops the daily counts past theirs. Cutoffs are rounded down to
+// the hour and the day so only complete buckets are rolled up. The latest transition of each pod that was not
+// deleted is kept, so the pods known at a time after the cutoff, see PodsAt, stay the same. Everything runs in one
+// transaction
+func (s *Store) Compact(ctx context.Context, r Retention, now time.Time) (Compaction, error) {
+	var c Compaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	rawCutoff := now.Add(-r.Raw).UTC().Truncate(time.Hour)
+	hourly := make(map[rollupKey]int64)
+	if c.Transitions, err = rollUpTransitions(ctx, tx, rawCutoff, hourly); err != nil {
+		return c, err
+	}
+	if c.Events, err = rollUpEvents(ctx, tx, rawCutoff, hourly); err != nil {
+		return c, err
+	}
+	if c.Lineage, err = execCount(ctx, tx, "DELETE FROM pod_lineage WHERE observed_at < $1", rawCutoff); err != nil {
+		return c, err
+	}
+	if err := mergeRollups(ctx, tx, Hourly, hourly); err != nil {
+		return c, err
+	}
+
+	hourlyCutoff := now.Add(-r.Hourly).UTC().Truncate(24 * time.Hour)
+	if c.Hourly, err = rollUpHours(ctx, tx, hourlyCutoff); err != nil {
+		return c, err
+	}
+	if r.Daily > 0 {
+		dailyCutoff := now.Add(-r.Daily).UTC().Truncate(24 * time.Hour)
+		if c.Daily, err = execCount(ctx, tx, "DELETE FROM pod_rollups WHERE resolution = $1 AND bucket < $2", Daily, dailyCutoff); err != nil {
+			return c, err
+		}
+	}
+	return c, tx.Commit()
+}
+
+// rollUpTransitions counts the transitions before the cutoff by hour and deletes them, keeping each pod's latest one
+// unless it is the pod's deletion
+func rollUpTransitions(ctx context.Context, tx *sql.Tx, cutoff time.Time, hourly map[rollupKey]int64) (int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT namespace, pod, phase, observed_at FROM pod_transitions WHERE observed_at < $1 ORDER BY namespace, pod, observed_at",
+		cutoff)
+	if err != nil {
+		return 0, err
+	}
+	// The rows of one pod are held until the next pod's show which of them is its latest
+	var pod []Transition
+	flush := func() {
+		if len(pod) == 0 {
+			return
+		}
+		latest := pod[len(pod)-1].At
+		for _, t := range pod {
+			if t.At.Equal(latest) && t.Phase != "Deleted" {
+				continue
+			}
+			hourly[rollupKey{t.At.UTC().Truncate(time.Hour), t.Namespace, RollupTransition, t.Phase}]++
+		}
+		pod = pod[:0]
+	}
+	for rows.Next() {
+		var t Transition
+		if err := rows.Scan(&t.Namespace, &t.Pod, &t.Phase, &t.At); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if len(pod) > 0 && (pod[0].Namespace != t.Namespace || pod[0].Pod != t.Pod) {
+			flush()
+		}
+		pod = append(pod, t)
+	}
+	flush()
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	return execCount(ctx, tx, `DELETE FROM pod_transitions WHERE observed_at < $1 AND NOT (phase <> 'Deleted' AND observed_at = (
+		SELECT MAX(observed_at) FROM pod_transitions u
+		WHERE u.namespace = pod_transitions.namespace AND u.pod = pod_transitions.pod AND u.observed_at < $1
+	))`, cutoff)
+}
+
+// rollUpEvents counts the pod events before the cutoff by hour and kind and deletes them
+func rollUpEvents(ctx context.Context, tx *sql.Tx, cutoff time.Time, hourly map[rollupKey]int64) (int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT namespace, kind, observed_at FROM pod_events WHERE observed_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var e PodEvent
+		if err := rows.Scan(&e.Namespace, &e.Kind, &e.At); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hourly[rollupKey{e.At.UTC().Truncate(time.Hour), e.Namespace, RollupEvent, e.Kind}]++
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+	return execCount(ctx, tx, "DELETE FROM pod_events WHERE observed_at < $1", cutoff)
+}
+
+// rollUpHours merges the hourly roll-ups before the cutoff into daily ones and deletes them
+func rollUpHours(ctx context.Context, tx *sql.Tx, cutoff time.Time) (int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT bucket, namespace, record, name, count FROM pod_rollups WHERE resolution = $1 AND bucket < $2", Hourly, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	daily := make(map[rollupKey]int64)
+	for rows.Next() {
+		var r Rollup
+		if err := rows.Scan(&r.Bucket, &r.Namespace, &r.Record, &r.Name, &r.Count); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		daily[rollupKey{r.Bucket.UTC().Truncate(24 * time.Hour), r.Namespace, r.Record, r.Name}] += r.Count
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+	if err := mergeRollups(ctx, tx, Daily, daily); err != nil {
+		return 0, err
+	}
+	return execCount(ctx, tx, "DELETE FROM pod_rollups WHERE resolution = $1 AND bucket < $2", Hourly, cutoff)
+}
+
+// mergeRollups adds the counts to the roll-ups of the resolution, a bucket rolled up before, for instance after
+// migrate imported older records, grows rather than being duplicated
+func mergeRollups(ctx context.Context, tx *sql.Tx, resolution string, counts map[rollupKey]int64) error {
+	for k, count := range counts {
+		updated, err := execCount(ctx, tx,
+			"UPDATE pod_rollups SET count = count + $1 WHERE resolution = $2 AND bucket = $3 AND namespace = $4 AND record = $5 AND name = $6",
+			count, resolution, k.bucket, k.namespace, k.record, k.name)
+		if err != nil {
+			return err
+		}
+		if updated > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO pod_rollups (resolution, bucket, namespace, record, name, count) VALUES ($1, $2, $3, $4, $5, $6)",
+			resolution, k.bucket, k.namespace, k.record, k.name, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execCount runs the statement and returns the number of rows it affected
+func execCount(ctx context.Context, tx *sql.Tx, query string, args ...any) (int64, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Rollups returns the roll-ups of buckets starting at or after the given time, optionally limited to one namespace,
+// oldest first
+func (s *Store) Rollups(ctx context.Context, namespace string, since time.Time) ([]Rollup, error) {
+	query := "SELECT resolution, bucket, namespace, record, name, count FROM pod_rollups WHERE bucket >= $1"
+	args := []any{since.UTC()}
+	if namespace != "" {
+		query += " AND namespace = $2"
+		args = append(args, namespace)
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY bucket, namespace, record, name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []Rollup
+	for rows.Next() {
+		var r Rollup
+		if err := rows.Scan(&r.Resolution, &r.Bucket, &r.Namespace, &r.Record, &r.Name, &r.Count); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
@@ -0,0 +1,376 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// driverTags are the build tags leaving a database driver out of the binary, see sqlite.go and postgres.go
+var driverTags = map[string]string{"sqlite": "nosqlite", "pgx": "nopostgres"}
+
+// schema creates the tables on first use, it is valid for both SQLite and Postgres
+const schema = `
+CREATE TABLE IF NOT EXISTS pod_transitions (
+	namespace   TEXT NOT NULL,
+	pod         TEXT NOT NULL,
+	node        TEXT NOT NULL,
+	phase       TEXT NOT NULL,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pod_transitions_pod ON pod_transitions (namespace, pod, observed_at);
+CREATE TABLE IF NOT EXISTS pod_events (
+	namespace   TEXT NOT NULL,
+	pod         TEXT NOT NULL,
+	kind        TEXT NOT NULL,
+	detail      TEXT NOT NULL,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pod_events_pod ON pod_events (namespace, pod, observed_at);
+CREATE TABLE IF NOT EXISTS pod_lineage (
+	namespace   TEXT NOT NULL,
+	pod         TEXT NOT NULL,
+	workload    TEXT NOT NULL,
+	predecessor TEXT NOT NULL,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pod_lineage_pod ON pod_lineage (namespace, pod);
+CREATE TABLE IF NOT EXISTS node_events (
+	node        TEXT NOT NULL,
+	event       TEXT NOT NULL,
+	detail      TEXT NOT NULL,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS node_events_node ON node_events (node, observed_at);
+CREATE TABLE IF NOT EXISTS workload_replicas (
+	namespace   TEXT NOT NULL,
+	workload    TEXT NOT NULL,
+	ready       INTEGER NOT NULL,
+	desired     INTEGER NOT NULL,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS workload_replicas_workload ON workload_replicas (namespace, workload, observed_at);
+`
+
+// Transition is a single observed change of a pod's phase or node
+type Transition struct {
+	Namespace string
+	Pod       string
+	Node      string
+	Phase     string
+	At        time.Time
+}
+
+// PodEvent is a restart or alert observed for a pod
+type PodEvent struct {
+	Namespace string
+	Pod       string
+	Kind      string
+	Detail    string
+	At        time.Time
+}
+
+// Kinds of pod events recorded in the history
+const (
+	PodRestart    = "Restart"
+	PodAlert      = "Alert"
+	PodSpecChange = "SpecChange"
+	PodDeletion   = "Deletion"
+)
+
+// Lineage links a pod to the pod of the same workload replica it replaced
+type Lineage struct {
+	Namespace   string
+	Pod         string
+	Workload    string
+	Predecessor string
+	At          time.Time
+}
+
+// NodeEvent is a single entry of a node's lifecycle timeline
+type NodeEvent struct {
+	Node   string    `json:"node"`
+	Event  string    `json:"event"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Node lifecycle events recorded in the timeline
+const (
+	NodeRegistered = "Registered"
+	NodeCordoned   = "Cordoned"
+	NodeUncordoned = "Uncordoned"
+	NodeDrained    = "Drained"
+	NodeNotReady   = "NotReady"
+	NodeReady      = "Ready"
+	NodeDeleted    = "Deleted"
+)
+
+// ReplicaSample is an observed change of a workload's ready or desired replica count
+type ReplicaSample struct {
+	Namespace string
+	// Workload is the kind and name, e.g. Deployment/cart
+	Workload string
+	Ready    int32
+	Desired  int32
+	At       time.Time
+}
+
+// Store records pod status transitions and node timelines in SQLite or Postgres
+type Store struct {
+	db *sql.DB
+}
+
+// Open connects to the database selected by the DSN and creates the schema.
+// postgres:// and postgresql:// DSNs use Postgres, anything else is a SQLite file (sqlite:// prefix optional)
+func Open(dsn string) (*Store, error) {
+	driver, source := "sqlite", strings.TrimPrefix(dsn, "sqlite://")
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		driver, source = "pgx", dsn
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("history: the %s driver is not built into this binary, rebuild without -tags %s or minimal", driver, driverTags[driver])
+	}
+
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite" {
+		// SQLite allows a single writer, serialize access instead of failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range strings.Split(schema+alertSchema+rolloutSchema+rollupSchema, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+// Record stores a transition
+func (s *Store) Record(ctx context.Context, t Transition) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO pod_transitions (namespace, pod, node, phase, observed_at) VALUES ($1, $2, $3, $4, $5)",
+		t.Namespace, t.Pod, t.Node, t.Phase, t.At.UTC())
+	return err
+}
+
+// PodHistory returns the transitions of a pod observed since the given time, oldest first
+func (s *Store) PodHistory(ctx context.Context, namespace, pod string, since time.Time) ([]Transition, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT namespace, pod, node, phase, observed_at FROM pod_transitions WHERE namespace = $1 AND pod = $2 AND observed_at >= $3 ORDER BY observed_at",
+		namespace, pod, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []Transition
+	for rows.Next() {
+		var t Transition
+		if err := rows.Scan(&t.Namespace, &t.Pod, &t.Node, &t.Phase, &t.At); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// Transitions returns the transitions of every pod recorded since the given time, oldest first
+func (s *Store) Transitions(ctx context.Context, since time.Time) ([]Transition, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT namespace, pod, node, phase, observed_at FROM pod_transitions WHERE observed_at >= $1 ORDER BY observed_at, namespace, pod",
+		since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []Transition
+	for rows.Next() {
+		var t Transition
+		if err := rows.Scan(&t.Namespace, &t.Pod, &t.Node, &t.Phase, &t.At); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// TransitionsBetween returns the transitions of every pod recorded from one time up to another, oldest first
+func (s *Store) TransitionsBetween(ctx context.Context, from, to time.Time) ([]Transition, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT namespace, pod, node, phase, observed_at FROM pod_transitions WHERE observed_at >= $1 AND observed_at <= $2 ORDER BY observed_at, namespace, pod",
+		from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []Transition
+	for rows.Next() {
+		var t Transition
+		if err := rows.Scan(&t.Namespace, &t.Pod, &t.Node, &t.Phase, &t.At); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// PodsAt returns the latest transition of every pod recorded at or before the given time, ordered by namespace and
+// pod. Pods whose latest transition is their deletion are left out
+func (s *Store) PodsAt(ctx context.Context, at time.Time) ([]Transition, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT namespace, pod, node, phase, observed_at FROM pod_transitions t WHERE observed_at = (
+			SELECT MAX(observed_at) FROM pod_transitions u WHERE u.namespace = t.namespace AND u.pod = t.pod AND u.observed_at <= $1
+		) ORDER BY namespace, pod`,
+		at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pods []Transition
+	for rows.Next() {
+		var t Transition
+		if err := rows.Scan(&t.Namespace, &t.Pod, &t.Node, &t.Phase, &t.At); err != nil {
+			return nil, err
+		}
+		// Transitions recorded at the same instant leave one row per pod
+		if n := len(pods); n > 0 && pods[n-1].Namespace == t.Namespace && pods[n-1].Pod == t.Pod {
+			pods = pods[:n-1]
+		}
+		pods = append(pods, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(pods, func(t Transition) bool { return t.Phase == "Deleted" }), nil
+}
+
+// RecordPodEvent stores a restart or alert of a pod
+func (s *Store) RecordPodEvent(ctx context.Context, e PodEvent) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO pod_events (namespace, pod, kind, detail, observed_at) VALUES ($1, $2, $3, $4, $5)",
+		e.Namespace, e.Pod, e.Kind, e.Detail, e.At.UTC())
+	return err
+}
+
+// PodEvents returns the restarts and alerts of a pod recorded since the given time, oldest first
+func (s *Store) PodEvents(ctx context.Context, namespace, pod string, since time.Time) ([]PodEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT namespace, pod, kind, detail, observed_at FROM pod_events WHERE namespace = $1 AND pod = $2 AND observed_at >= $3 ORDER BY observed_at",
+		namespace, pod, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []PodEvent
+	for rows.Next() {
+		var e PodEvent
+		if err := rows.Scan(&e.Namespace, &e.Pod, &e.Kind, &e.Detail, &e.At); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// RecordLineage stores the predecessor of a pod
+func (s *Store) RecordLineage(ctx context.Context, l Lineage) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO pod_lineage (namespace, pod, workload, predecessor, observed_at) VALUES ($1, $2, $3, $4, $5)",
+		l.Namespace, l.Pod, l.Workload, l.Predecessor, l.At.UTC())
+	return err
+}
+
+// Predecessor returns the pod the given pod replaced, or an empty name when none was recorded
+func (s *Store) Predecessor(ctx context.Context, namespace, pod string) (string, error) {
+	var predecessor string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT predecessor FROM pod_lineage WHERE namespace = $1 AND pod = $2 ORDER BY observed_at DESC LIMIT 1",
+		namespace, pod).Scan(&predecessor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return predecessor, err
+}
+
+// RecordNodeEvent appends an event to the node's timeline
+func (s *Store) RecordNodeEvent(ctx context.Context, e NodeEvent) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO node_events (node, event, detail, observed_at) VALUES ($1, $2, $3, $4)",
+		e.Node, e.Event, e.Detail, e.At.UTC())
+	return err
+}
+
+// NodeTimeline returns the lifecycle events of a node recorded since the given time, oldest first
+func (s *Store) NodeTimeline(ctx context.Context, node string, since time.Time) ([]NodeEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT node, event, detail, observed_at FROM node_events WHERE node = $1 AND observed_at >= $2 ORDER BY observed_at",
+		node, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timeline []NodeEvent
+	for rows.Next() {
+		var e NodeEvent
+		if err := rows.Scan(&e.Node, &e.Event, &e.Detail, &e.At); err != nil {
+			return nil, err
+		}
+		timeline = append(timeline, e)
+	}
+	return timeline, rows.Err()
+}
+
+// RecordReplicas stores a change of a workload's replica counts
+func (s *Store) RecordReplicas(ctx context.Context, r ReplicaSample) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO workload_replicas (namespace, workload, ready, desired, observed_at) VALUES ($1, $2, $3, $4, $5)",
+		r.Namespace, r.Workload, r.Ready, r.Desired, r.At.UTC())
+	return err
+}
+
+// ReplicaSamples returns the replica samples of every workload recorded since the given time, preceded by each
+// workload's last sample before it so its state at the start of the window is known. Oldest first
+func (s *Store) ReplicaSamples(ctx context.Context, since time.Time) ([]ReplicaSample, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT namespace, workload, ready, desired, observed_at FROM workload_replicas w
+WHERE observed_at >= $1 OR observed_at = (
+	SELECT MAX(observed_at) FROM workload_replicas
+	WHERE namespace = w.namespace AND workload = w.workload AND observed_at < $1
+)
+ORDER BY observed_at, namespace, workload`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []ReplicaSample
+	for rows.Next() {
+		var r ReplicaSample
+		if err := rows.Scan(&r.Namespace, &r.Workload, &r.Ready, &r.Desired, &r.At); err != nil {
+			return nil, err
+		}
+		samples = append(samples, r)
+	}
+	return samples, rows.Err()
+}
+
+// Close closes the database connection
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,69 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// rolloutSchema creates the table of rollout markers, recorded when a workload's pods start coming up with a new spec
+const rolloutSchema = `
+CREATE TABLE IF NOT EXISTS rollout_markers (
+	namespace   TEXT NOT NULL,
+	workload    TEXT NOT NULL,
+	from_hash   TEXT NOT NULL,
+	to_hash     TEXT NOT NULL,
+	changes     TEXT NOT NULL,
+	observed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS rollout_markers_observed ON rollout_markers (observed_at);
+`
+
+// RolloutMarker records the first pod of a workload observed with a new spec hash
+type RolloutMarker struct {
+	Namespace string `json:"namespace"`
+	// Workload is the kind and name, e.g. Deployment/cart
+	Workload string    `json:"workload"`
+	FromHash string    `json:"fromHash"`
+	ToHash   string    `json:"toHash"`
+	Changes  []string  `json:"changes"`
+	At       time.Time `json:"at"`
+}
+
+// RecordRolloutMarker stores a rollout marker
+func (s *Store) RecordRolloutMarker(ctx context.Context, m RolloutMarker) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO rollout_markers (namespace, workload, from_hash, to_hash, changes, observed_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		m.Namespace, m.Workload, m.FromHash, m.ToHash, strings.Join(m.Changes, "\n"), m.At.UTC())
+	return err
+}
+
+// RolloutMarkers returns the rollout markers recorded since the given time, optionally limited to one namespace,
+// oldest first
+func (s *Store) RolloutMarkers(ctx context.Context, namespace string, since time.Time) ([]RolloutMarker, error) {
+	query := "SELECT namespace, workload, from_hash, to_hash, changes, observed_at FROM rollout_markers WHERE observed_at >= $1"
+	args := []any{since.UTC()}
+	if namespace != "" {
+		query += " AND namespace = $2"
+		args = append(args, namespace)
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY observed_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var markers []RolloutMarker
+	for rows.Next() {
+		var m RolloutMarker
+		var changes string
+		if err := rows.Scan(&m.Namespace, &m.Workload, &m.FromHash, &m.ToHash, &changes, &m.At); err != nil {
+			return nil, err
+		}
+		if changes != "" {
+			m.Changes = strings.Split(changes, "\n")
+		}
+		markers = append(markers, m)
+	}
+	return markers, rows.Err()
+}
@@ -0,0 +1,6 @@
+//go:build !minimal && !nosqlite
+
+package history
+
+// Registers the "sqlite" database/sql driver
+import _ "modernc.org/sqlite"
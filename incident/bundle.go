@@ -0,0 +1,129 @@
+package incident
+
+import (
+	"adv-go/signing"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// file is a single entry of a bundle
+type file struct {
+	name string
+	data []byte
+}
+
+// Bundle collects the files describing an incident and packs them as a .tar.gz archive
+type Bundle struct {
+	Name    string
+	created time.Time
+	files   []file
+}
+
+// NewBundle creates an empty bundle, the name is used for the archive and its top level directory
+func NewBundle(name string) *Bundle {
+	return &Bundle{Name: name, created: time.Now()}
+}
+
+// Add adds a file to the bundle
+func (b *Bundle) Add(name string, data []byte) {
+	b.files = append(b.files, file{name: name, data: data})
+}
+
+// AddJSON adds the value as an indented JSON file
+func (b *Bundle) AddJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	b.Add(name, data)
+	return nil
+}
+
+// Archive packs the bundle as a .tar.gz archive
+func (b *Bundle) Archive() ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range b.files {
+		hdr := &tar.Header{
+			Name:    b.Name + "/" + f.name,
+			Mode:    0644,
+			Size:    int64(len(f.data)),
+			ModTime: b.created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Save writes the archive, its checksum and, when signer is set, its signature into the directory and returns its path
+func (b *Bundle) Save(dir string, signer *signing.Signer) (string, error) {
+	data, err := b.Archive()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, b.Name+".tar.gz")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, signing.WriteSidecars(path, data, signer)
+}
+
+// Upload PUTs the archive to baseURL/<name>.tar.gz, followed by its checksum and signature, and returns the uploaded URL
+func (b *Bundle) Upload(ctx context.Context, client *http.Client, baseURL string, signer *signing.Signer) (string, error) {
+	data, err := b.Archive()
+	if err != nil {
+		return "", err
+	}
+	url := baseURL + "/" + b.Name + ".tar.gz"
+	if err := put(ctx, client, url, "application/gzip", data); err != nil {
+		return "", err
+	}
+	for _, s := range signing.Sidecars(url, data, signer) {
+		if err := put(ctx, client, url+s.Suffix, "text/plain", s.Data); err != nil {
+			return "", err
+		}
+	}
+	return url, nil
+}
+
+// put uploads the data to the URL
+func put(ctx context.Context, client *http.Client, url, contentType string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("incident: upload to %s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is the minimum severity a component logs
+type Level = slog.Level
+
+// Levels from most to least verbose, LevelQuiet silences a component entirely
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+	LevelQuiet = slog.Level(100)
+)
+
+// levelNames maps the accepted flag values to levels
+var levelNames = map[string]Level{
+	"debug": LevelDebug,
+	"info":  LevelInfo,
+	"warn":  LevelWarn,
+	"error": LevelError,
+	"quiet": LevelQuiet,
+}
+
+// The handler every component logs through, the default level and the per-component overrides
+var (
+	mu        sync.RWMutex
+	handler   slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: LevelDebug})
+	defaultLv              = LevelInfo
+	overrides              = map[string]Level{}
+	// outputFormat is the format Configure was given, SetOutput keeps it
+	outputFormat = "text"
+)
+
+// ParseLevel parses a level name such as "debug" or "warn"
+func ParseLevel(name string) (Level, error) {
+	level, ok := levelNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn, error or quiet", name)
+	}
+	return level, nil
+}
+
+// Configure sets the levels from a spec like "info,collector=debug,watch=quiet" and the output format, text or json.
+// The entry without a component is the default level for every component. Output of the standard log package is
+// routed through the same handler
+func Configure(spec, format string) error {
+	def := LevelInfo
+	parsed := map[string]Level{}
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		component, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, component = component, ""
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		if component == "" {
+			def = level
+		} else {
+			parsed[strings.TrimSpace(component)] = level
+		}
+	}
+
+	h, err := newHandler(os.Stderr, format)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	handler, defaultLv, overrides, outputFormat = h, def, parsed, format
+	mu.Unlock()
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// SetOutput writes the log to w in the configured format instead of stderr, for instance while the terminal UI
+// draws on the terminal
+func SetOutput(w io.Writer) error {
+	mu.Lock()
+	defer mu.Unlock()
+	h, err := newHandler(w, outputFormat)
+	if err != nil {
+		return err
+	}
+	handler = h
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// newHandler creates the handler for the format, levels are filtered per component so it accepts everything
+func newHandler(w io.Writer, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: LevelDebug}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+}
+
+// Logger logs on behalf of one component, honouring its configured level
+type Logger struct {
+	component string
+}
+
+// For returns the logger of a component
+func For(component string) *Logger {
+	return &Logger{component: component}
+}
+
+// Enabled reports whether the component logs at the given level
+func (l *Logger) Enabled(level Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	min, ok := overrides[l.component]
+	if !ok {
+		min = defaultLv
+	}
+	return level >= min && level < LevelQuiet
+}
+
+// Debug logs detail only useful while debugging the component
+func (l *Logger) Debug(msg string, args ...any) {
+	l.log(LevelDebug, msg, args...)
+}
+
+// Info logs normal operation
+func (l *Logger) Info(msg string, args ...any) {
+	l.log(LevelInfo, msg, args...)
+}
+
+// Warn logs alerts and conditions that need attention
+func (l *Logger) Warn(msg string, args ...any) {
+	l.log(LevelWarn, msg, args...)
+}
+
+// Error logs failures
+func (l *Logger) Error(msg string, args ...any) {
+	l.log(LevelError, msg, args...)
+}
+
+// Fatal logs the failure and exits
+func (l *Logger) Fatal(msg string, args ...any) {
+	l.log(LevelError, msg, args...)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, args ...any) {
+	if !l.Enabled(level) {
+		return
+	}
+	mu.RLock()
+	h := handler
+	mu.RUnlock()
+	slog.New(h).With("component", l.component).Log(context.Background(), level, msg, args...)
+}
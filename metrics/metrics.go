@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"adv-go/auth"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// WatchLag is the distribution of delays between a resource change and the agent observing it
+	WatchLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podlogger_watch_lag_seconds",
+		Help:    "Delay between the latest resource timestamp and the agent observing the change.",
+		Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300},
+	}, []string{"resource"})
+
+	// WatchLagLast is the most recently observed watch delay
+	WatchLagLast = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_watch_lag_last_seconds",
+		Help: "Most recently observed delay between a resource change and the agent observing it.",
+	}, []string{"resource"})
+
+	// WatchLagAlerts counts observations that exceeded the configured lag threshold
+	WatchLagAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_watch_lag_alerts_total",
+		Help: "Number of observations where the watch lag exceeded the alert threshold.",
+	}, []string{"resource"})
+
+	// NodeClockSkew is the estimated offset of each node's clock from the agent's clock
+	NodeClockSkew = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_node_clock_skew_seconds",
+		Help: "Estimated offset of the node clock from the agent clock, positive when the node is ahead.",
+	}, []string{"node"})
+
+	// PodStartupPhase is the time newly created pods spend in each phase before becoming Ready
+	PodStartupPhase = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podlogger_pod_startup_phase_seconds",
+		Help:    "Time newly created pods spend in each startup phase: scheduling, image_pull, container_start, readiness.",
+		Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"phase"})
+
+	// PodStartLatency is the time newly created pods take from creation to being scheduled and from being scheduled
+	// to becoming Ready
+	PodStartLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podlogger_pod_start_latency_seconds",
+		Help:    "Time newly created pods take from creation to being scheduled (stage created_to_scheduled) and from being scheduled to becoming Ready (stage scheduled_to_ready).",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"stage"})
+
+	// EndpointPropagation is the delay between a pod becoming Ready and it appearing in an EndpointSlice of its
+	// Service, or accepting connections on its endpoint port
+	EndpointPropagation = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podlogger_endpoint_propagation_seconds",
+		Help:    "Delay between a pod becoming Ready and its IP being ready in an EndpointSlice of each Service (stage endpointslice) or reachable on the endpoint port (stage reachable).",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60},
+	}, []string{"stage"})
+
+	// SelfTestLatency is the delay between a self-test pod's creation and each sink writing its record
+	SelfTestLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podlogger_selftest_latency_seconds",
+		Help:    "Delay between the creation of a selftest pod and each sink writing its pod record.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, []string{"sink"})
+
+	// ServiceReachable is whether the agent last resolved and connected to each checked Service
+	ServiceReachable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_service_reachable",
+		Help: "1 when the last check of the Service succeeded, 0 when it failed, per check: dns, or tcp/<port> of the ClusterIP.",
+	}, []string{"namespace", "service", "check"})
+
+	// UnschedulablePods is the number of pods stuck Pending without a node per namespace and failure category
+	UnschedulablePods = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_unschedulable_pods",
+		Help: "Pods Pending without a node for longer than the unschedulable threshold, per category of scheduling failure (resources, taints, affinity, cordoned, volumes, ports, gated, ...).",
+	}, []string{"namespace", "category"})
+
+	// DaemonSetMissingNodes is the number of nodes each DaemonSet targets without a ready pod past the grace period
+	DaemonSetMissingNodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_daemonset_missing_nodes",
+		Help: "Nodes a DaemonSet's node selector and affinity target that had no ready pod of it for longer than the coverage grace.",
+	}, []string{"namespace", "daemonset"})
+
+	// PodChurn counts pods created and deleted per namespace and workload
+	PodChurn = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_pod_churn_total",
+		Help: "Number of pods created or deleted, by namespace, workload (kind/name, Pod for bare pods) and event.",
+	}, []string{"namespace", "workload", "event"})
+
+	// PodChurnAlerts counts namespaces flagged for abnormal churn
+	PodChurnAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_pod_churn_alerts_total",
+		Help: "Number of times a namespace created and deleted more pods than the churn alert threshold within the window.",
+	}, []string{"namespace"})
+
+	// PodDeletions counts the pods the watch saw deleted, by the reason derived from their final state
+	PodDeletions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_pod_deletions_total",
+		Help: "Number of pods deleted by namespace and reason: Evicted, Preempted, ScaledDown, OOMKilled, GarbageCollected, Completed or Unknown.",
+	}, []string{"namespace", "reason"})
+
+	// NotificationsSuppressed counts the bad phase notifications not sent, by why
+	NotificationsSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_notifications_suppressed_total",
+		Help: "Number of bad phase notifications not sent, by reason: silenced or cooldown.",
+	}, []string{"reason"})
+
+	// PodSpecChanges counts workload template changes and in-place pod spec changes
+	PodSpecChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_pod_spec_changes_total",
+		Help: "Number of pod spec changes by namespace and kind: rollout when a workload's new pods have a new spec hash, in-place when a running pod's spec was changed.",
+	}, []string{"namespace", "kind"})
+
+	// Remediations counts the crash looping pods the PodRemediation feature acted on, by outcome
+	Remediations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_remediations_total",
+		Help: "Number of crash looping pods remediated by namespace and result: deleted, dry-run, failed, budget-exceeded or circuit-open.",
+	}, []string{"namespace", "result"})
+
+	// APIRetries counts apiserver calls retried after a transient failure
+	APIRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_apiserver_retries_total",
+		Help: "Number of apiserver calls retried after a transient failure, by call.",
+	}, []string{"call"})
+
+	// ReportingLastPass is when the reporting loop last finished a pass
+	ReportingLastPass = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "podlogger_reporting_last_pass_timestamp_seconds",
+		Help: "Unix time the leader last finished a reporting pass.",
+	})
+
+	// ReportingStalls counts the leadership terms whose reporting loop went longer than the stall timeout without
+	// finishing a pass
+	ReportingStalls = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "podlogger_reporting_stalls_total",
+		Help: "Number of times the reporting loop finished no pass within --lease-stall-timeout.",
+	})
+
+	// HistoryCompacted counts the rows the history compaction rolled up or removed
+	HistoryCompacted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_history_compacted_rows_total",
+		Help: "Number of history rows compacted, by table: transitions and events rolled up by hour, lineage removed, hourly roll-ups rolled up by day and daily roll-ups removed.",
+	}, []string{"table"})
+
+	// JobOutcomes counts the Jobs that completed or failed
+	JobOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_job_outcomes_total",
+		Help: "Number of Jobs that finished, by namespace, outcome (Complete or Failed) and reason.",
+	}, []string{"namespace", "outcome", "reason"})
+
+	// CronJobMissedSchedules counts the scheduled runs CronJobs did not start in time
+	CronJobMissedSchedules = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_cronjob_missed_schedules_total",
+		Help: "Number of scheduled CronJob runs not started within the starting deadline or 2 minutes.",
+	}, []string{"namespace"})
+
+	// ListWatchThrottle is the time list and watch requests waited for the --list-watch-qps limiter
+	ListWatchThrottle = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podlogger_list_watch_throttle_seconds",
+		Help:    "Time list and watch requests waited for the client-side list and watch rate limiter.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"verb"})
+)
+
+// mux serves /metrics and any handlers added with Handle
+var mux = http.NewServeMux()
+
+// Serve exposes the registered metrics on /metrics at the given address in the background, over TLS and for the
+// clients the authenticator admits when it has a certificate and credentials. A nil authenticator serves plain HTTP
+// to everyone
+func Serve(addr string, authenticator *auth.Authenticator) {
+	// OpenMetrics is needed for exemplars to be exposed
+	mux.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	server := &http.Server{Addr: addr, Handler: authenticator.Wrap(mux), TLSConfig: authenticator.TLSConfig()}
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			// The certificate is in the TLS config
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+}
+
+// Handle registers an additional handler, such as a query API, on the metrics server
+func Handle(pattern string, handler http.Handler) {
+	mux.Handle(pattern, handler)
+}
+
+// ObserveWithTrace records the value, attaching the trace ID as an exemplar when there is one
+func ObserveWithTrace(o prometheus.Observer, value float64, traceID string) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && traceID != "" {
+		eo.ObserveWithExemplar(value, prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	o.Observe(value)
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// churnEvent is a pod created or deleted in a namespace
+type churnEvent struct {
+	workload string
+	created  bool
+	at       time.Time
+}
+
+// Churn is the pods created and deleted in a namespace within the window, with the workload churning most
+type Churn struct {
+	Created int
+	Deleted int
+	// TopWorkload created or deleted the most pods, TopCount of them
+	TopWorkload string
+	TopCount    int
+}
+
+// Total is the number of pods created and deleted
+func (c Churn) Total() int {
+	return c.Created + c.Deleted
+}
+
+// ChurnTracker counts pod creations and deletions per namespace over a sliding window
+type ChurnTracker struct {
+	mu     sync.Mutex
+	window time.Duration
+	events map[string][]churnEvent
+}
+
+// NewChurnTracker creates a tracker counting churn over the given window
+func NewChurnTracker(window time.Duration) *ChurnTracker {
+	return &ChurnTracker{window: window, events: make(map[string][]churnEvent)}
+}
+
+// Observe records a pod of the workload being created or deleted in the namespace and returns the namespace's
+// churn within the window
+func (t *ChurnTracker) Observe(namespace, workload string, created bool, at time.Time) Churn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := append(t.events[namespace], churnEvent{workload: workload, created: created, at: at})
+	start := sort.Search(len(events), func(i int) bool { return events[i].at.After(at.Add(-t.window)) })
+	events = events[start:]
+	t.events[namespace] = events
+
+	var churn Churn
+	perWorkload := make(map[string]int)
+	for _, e := range events {
+		if e.created {
+			churn.Created++
+		} else {
+			churn.Deleted++
+		}
+		perWorkload[e.workload]++
+	}
+	for workload, n := range perWorkload {
+		if n > churn.TopCount || (n == churn.TopCount && workload < churn.TopWorkload) {
+			churn.TopWorkload, churn.TopCount = workload, n
+		}
+	}
+	return churn
+}
+
+// Reset forgets the namespace's churn so far, e.g. after alerting on it
+func (t *ChurnTracker) Reset(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.events, namespace)
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed CronJob schedule in the standard five field cron format
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set for fields given as *, a day then matches if either of the others matches
+	domAny, dowAny bool
+	location       *time.Location
+	// every is the interval of an "@every" schedule, which fires that long after the previous time
+	every time.Duration
+}
+
+// cronMacros are the predefined schedules the CronJob controller accepts
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronNames are the month and weekday names allowed in place of numbers
+var cronNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseSchedule parses a CronJob schedule like "*/15 2-4 * * mon-fri", a macro like "@daily" or an interval like
+// "@every 90m", optionally prefixed with "CRON_TZ=<zone> ". Times are in location unless the schedule names a zone
+func ParseSchedule(spec string, location *time.Location) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", name)
+		}
+		location, spec = loc, strings.TrimSpace(rest)
+	}
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("invalid interval in schedule %q", spec)
+		}
+		// As in the CronJob controller, intervals are whole seconds and at least one
+		return &Schedule{location: location, every: max(every.Truncate(time.Second), time.Second)}, nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in schedule %q, got %d", spec, len(fields))
+	}
+
+	s := &Schedule{location: location, domAny: fields[2] == "*" || fields[2] == "?", dowAny: fields[4] == "*" || fields[4] == "?"}
+	bounds := []struct {
+		bits     *uint64
+		min, max int
+	}{{&s.minute, 0, 59}, {&s.hour, 0, 23}, {&s.dom, 1, 31}, {&s.month, 1, 12}, {&s.dow, 0, 7}}
+	for i, b := range bounds {
+		bits, err := parseCronField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		*b.bits = bits
+	}
+	// 7 is Sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField parses a comma separated list of *, values, ranges and steps into a bit set
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+		lo, hi := min, max
+		switch {
+		case expr == "*" || expr == "?":
+		case strings.Contains(expr, "-"):
+			from, to, _ := strings.Cut(expr, "-")
+			var err error
+			if lo, err = cronValue(from, min, max); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(to, min, max); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := cronValue(expr, min, max)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			// "5/10" runs from 5 to the end of the range
+			if hasStep {
+				hi = max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", expr)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// cronValue parses a number or a month or weekday name within bounds
+func cronValue(text string, min, max int) (int, error) {
+	v, ok := cronNames[strings.ToLower(text)]
+	if !ok {
+		var err error
+		if v, err = strconv.Atoi(text); err != nil {
+			return 0, fmt.Errorf("invalid value %q", text)
+		}
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+// Next returns the first time the schedule fires after t, zero when it never does within five years (e.g. "0 0 30 2 *")
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Truncate(time.Second).Add(s.every)
+	}
+	loc := s.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc).Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	for limit := day.AddDate(5, 0, 0); day.Before(limit); day = day.AddDate(0, 0, 1) {
+		if !s.matchesDay(day) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			if s.hour&(1<<hour) == 0 {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if s.minute&(1<<minute) == 0 {
+					continue
+				}
+				// A time skipped by a daylight saving change is normalized by time.Date to another hour, it does not
+				// fire that day, as with the CronJob controller. A repeated time is the first of the two, so it fires once
+				at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+				if at.Hour() == hour && at.Minute() == minute && !at.Before(t) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the schedule fires on the day. As in cron, when both the day of month and the day of
+// week are restricted a day matching either fires
+func (s *Schedule) matchesDay(day time.Time) bool {
+	if s.month&(1<<int(day.Month())) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<day.Day()) != 0
+	dow := s.dow&(1<<int(day.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
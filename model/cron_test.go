@@ -0,0 +1,131 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestScheduleNext(t *testing.T) {
+	utc := time.UTC
+	berlin := mustLocation(t, "Europe/Berlin")
+	newYork := mustLocation(t, "America/New_York")
+	at := func(loc *time.Location, value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		location *time.Location
+		after    time.Time
+		// next are the following times the schedule fires, in order
+		next []time.Time
+	}{
+		{"every minute", "* * * * *", utc, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(utc, "2026-03-01 12:01"), at(utc, "2026-03-01 12:02")}},
+		{"seconds are dropped", "* * * * *", utc, at(utc, "2026-03-01 12:00").Add(59 * time.Second),
+			[]time.Time{at(utc, "2026-03-01 12:01")}},
+		{"every 15 minutes", "*/15 * * * *", utc, at(utc, "2026-03-01 12:07"),
+			[]time.Time{at(utc, "2026-03-01 12:15"), at(utc, "2026-03-01 12:30"), at(utc, "2026-03-01 12:45"), at(utc, "2026-03-01 13:00")}},
+		{"offset step", "5/20 * * * *", utc, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(utc, "2026-03-01 12:05"), at(utc, "2026-03-01 12:25"), at(utc, "2026-03-01 12:45"), at(utc, "2026-03-01 13:05")}},
+		{"stepped range", "0 9-17/4 * * *", utc, at(utc, "2026-03-01 10:00"),
+			[]time.Time{at(utc, "2026-03-01 13:00"), at(utc, "2026-03-01 17:00"), at(utc, "2026-03-02 09:00")}},
+		{"list", "0,30 6 * * *", utc, at(utc, "2026-03-01 06:00"),
+			[]time.Time{at(utc, "2026-03-01 06:30"), at(utc, "2026-03-02 06:00")}},
+		{"weekdays by name", "30 2 * * mon-fri", utc, at(utc, "2026-03-06 03:00"), // a Friday
+			[]time.Time{at(utc, "2026-03-09 02:30"), at(utc, "2026-03-10 02:30")}},
+		{"sunday as 7", "0 0 * * 7", utc, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(utc, "2026-03-08 00:00")}},
+		{"months by name", "0 0 1 jan,jul *", utc, at(utc, "2026-03-01 00:00"),
+			[]time.Time{at(utc, "2026-07-01 00:00"), at(utc, "2027-01-01 00:00")}},
+		{"day of month or day of week", "0 0 13 * fri", utc, at(utc, "2026-03-01 00:00"),
+			[]time.Time{at(utc, "2026-03-06 00:00"), at(utc, "2026-03-13 00:00"), at(utc, "2026-03-20 00:00")}},
+		{"stepped day of week restricts only the week", "0 0 * * */3", utc, at(utc, "2026-03-01 00:00"), // a Sunday
+			[]time.Time{at(utc, "2026-03-04 00:00"), at(utc, "2026-03-07 00:00"), at(utc, "2026-03-08 00:00")}},
+		{"last day of a short month is skipped", "0 0 31 * *", utc, at(utc, "2026-03-31 00:00"),
+			[]time.Time{at(utc, "2026-05-31 00:00"), at(utc, "2026-07-31 00:00")}},
+		{"leap day", "0 0 29 2 *", utc, at(utc, "2026-03-01 00:00"),
+			[]time.Time{at(utc, "2028-02-29 00:00")}},
+		{"hourly", "@hourly", utc, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(utc, "2026-03-01 13:00")}},
+		{"daily", "@daily", utc, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(utc, "2026-03-02 00:00")}},
+		{"weekly", "@weekly", utc, at(utc, "2026-03-02 12:00"),
+			[]time.Time{at(utc, "2026-03-08 00:00")}},
+		{"monthly", "@monthly", utc, at(utc, "2026-03-02 12:00"),
+			[]time.Time{at(utc, "2026-04-01 00:00")}},
+		{"yearly", "@yearly", utc, at(utc, "2026-03-02 12:00"),
+			[]time.Time{at(utc, "2027-01-01 00:00")}},
+		{"every interval", "@every 90m", utc, at(utc, "2026-03-01 12:00").Add(30 * time.Second),
+			[]time.Time{at(utc, "2026-03-01 13:30").Add(30 * time.Second), at(utc, "2026-03-01 15:00").Add(30 * time.Second)}},
+		{"time zone of the cron job", "0 9 * * *", berlin, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(berlin, "2026-03-02 09:00")}},
+		{"CRON_TZ prefix", "CRON_TZ=America/New_York 0 9 * * *", utc, at(utc, "2026-03-01 12:00"),
+			[]time.Time{at(newYork, "2026-03-01 09:00"), at(newYork, "2026-03-02 09:00")}},
+		// Like the CronJob controller, a time skipped when the clocks go forward does not fire that day
+		{"skipped by daylight saving", "30 2 * * *", newYork, at(newYork, "2026-03-07 03:00"),
+			[]time.Time{at(newYork, "2026-03-09 02:30")}},
+		{"hourly over daylight saving", "0 * * * *", newYork, at(newYork, "2026-03-08 00:30"),
+			[]time.Time{at(newYork, "2026-03-08 01:00"), at(newYork, "2026-03-08 03:00"), at(newYork, "2026-03-08 04:00")}},
+		// A time repeated when the clocks go back fires once
+		{"repeated by daylight saving", "30 1 * * *", newYork, at(newYork, "2026-11-01 00:00"),
+			[]time.Time{at(newYork, "2026-11-01 01:30"), at(newYork, "2026-11-02 01:30")}},
+		{"never", "0 0 30 2 *", utc, at(utc, "2026-03-01 00:00"),
+			[]time.Time{{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.schedule, tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := tt.after
+			for i, want := range tt.next {
+				got := s.Next(after)
+				if !got.Equal(want) {
+					t.Fatalf("Next #%d of %q after %s = %s, want %s", i+1, tt.schedule, after, got, want)
+				}
+				after = got
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, schedule := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every",
+		"@every 0s",
+		"@every soon",
+		"@fortnightly",
+		"CRON_TZ=Mars/Olympus 0 0 * * *",
+	} {
+		if _, err := ParseSchedule(schedule, time.UTC); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want an error", schedule)
+		}
+	}
+}
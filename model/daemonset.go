@@ -0,0 +1,141 @@
+package model
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// daemonTolerations are the tolerations the DaemonSet controller adds to every daemon pod
+var daemonTolerations = []v1.Toleration{
+	{Key: v1.TaintNodeNotReady, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute},
+	{Key: v1.TaintNodeUnreachable, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute},
+	{Key: v1.TaintNodeDiskPressure, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+	{Key: v1.TaintNodeMemoryPressure, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+	{Key: v1.TaintNodePIDPressure, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+	{Key: v1.TaintNodeUnschedulable, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+}
+
+// DaemonSet struct to represent a Kubernetes DaemonSet and the nodes its pods are meant for
+type DaemonSet struct {
+	mu        sync.RWMutex
+	daemonSet appsv1.DaemonSet
+}
+
+// NewDaemonSet creates a DaemonSet model from the provided daemon set
+func NewDaemonSet(ds *appsv1.DaemonSet) *DaemonSet {
+	return &DaemonSet{
+		daemonSet: *ds,
+	}
+}
+
+// Name returns the name of the daemon set
+func (d *DaemonSet) Name() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.daemonSet.Name
+}
+
+// Namespace returns the namespace of the daemon set
+func (d *DaemonSet) Namespace() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.daemonSet.Namespace
+}
+
+// Object returns the underlying daemon set
+func (d *DaemonSet) Object() *appsv1.DaemonSet {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return &d.daemonSet
+}
+
+// Targets reports whether the pod template's node selector and required node affinity select the node, so the
+// controller runs a pod there unless a taint keeps it off
+func (d *DaemonSet) Targets(node *Node) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	spec := d.daemonSet.Spec.Template.Spec
+	nodeLabels := node.Labels()
+	for key, value := range spec.NodeSelector {
+		if v, ok := nodeLabels[key]; !ok || v != value {
+			return false
+		}
+	}
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// Terms are ORed, the requirements of a term ANDed, and a term without requirements selects nothing
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		matches := true
+		for _, req := range term.MatchExpressions {
+			value, ok := nodeLabels[req.Key]
+			matches = matches && nodeRequirementMatches(req, value, ok)
+		}
+		for _, req := range term.MatchFields {
+			// metadata.name is the only field nodes can be selected by
+			matches = matches && req.Key == "metadata.name" && nodeRequirementMatches(req, node.Name(), true)
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRequirementMatches applies a node selector requirement to a label value, ok false when the label is missing
+func nodeRequirementMatches(req v1.NodeSelectorRequirement, value string, ok bool) bool {
+	switch req.Operator {
+	case v1.NodeSelectorOpIn:
+		return ok && slices.Contains(req.Values, value)
+	case v1.NodeSelectorOpNotIn:
+		return !ok || !slices.Contains(req.Values, value)
+	case v1.NodeSelectorOpExists:
+		return ok
+	case v1.NodeSelectorOpDoesNotExist:
+		return !ok
+	case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+		if !ok || len(req.Values) != 1 {
+			return false
+		}
+		have, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		want, err := strconv.ParseInt(req.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if req.Operator == v1.NodeSelectorOpGt {
+			return have > want
+		}
+		return have < want
+	}
+	return false
+}
+
+// BlockingTaint returns the first taint of the node keeping the daemon pods off it: a NoSchedule or NoExecute taint
+// tolerated neither by the pod template nor by the tolerations the controller adds
+func (d *DaemonSet) BlockingTaint(node *Node) (v1.Taint, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	tolerations := append(slices.Clone(d.daemonSet.Spec.Template.Spec.Tolerations), daemonTolerations...)
+	if d.daemonSet.Spec.Template.Spec.HostNetwork {
+		tolerations = append(tolerations, v1.Toleration{Key: v1.TaintNodeNetworkUnavailable, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule})
+	}
+	for _, taint := range node.Taints() {
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !slices.ContainsFunc(tolerations, func(t v1.Toleration) bool { return t.ToleratesTaint(&taint) }) {
+			return taint, true
+		}
+	}
+	return v1.Taint{}, false
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Encoder renders a pod as a record for the sinks
+type Encoder interface {
+	Encode(*Pod) ([]byte, error)
+}
+
+// encoders are the record formats NewEncoder knows, by name
+var encoders = map[string]Encoder{
+	"text":     TextEncoder{},
+	"json":     JSONEncoder{},
+	"protobuf": ProtobufEncoder{},
+}
+
+// NewEncoder returns the encoder of a record format: text, json or protobuf
+func NewEncoder(format string) (Encoder, error) {
+	if e, ok := encoders[format]; ok {
+		return e, nil
+	}
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown record format %q, expected one of %s", format, strings.Join(names, ", "))
+}
+
+// RecordField is context a pod's record carries beyond the pod object, such as its health or the state of its
+// node. A field has either a single Value or a list of Values
+type RecordField struct {
+	Key    string
+	Value  string
+	Values []string
+}
+
+// String renders the field's value as the text format shows it, lists in brackets
+func (f RecordField) String() string {
+	if f.Values != nil {
+		return "[" + strings.Join(f.Values, ",") + "]"
+	}
+	return f.Value
+}
+
+// AddRecordField adds a field to the pod's record, after those added before it
+func (p *Pod) AddRecordField(key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fields = append(p.fields, RecordField{Key: key, Value: value})
+}
+
+// AddRecordList adds a field with a list of values to the pod's record
+func (p *Pod) AddRecordList(key string, values []string) {
+	if values == nil {
+		values = []string{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fields = append(p.fields, RecordField{Key: key, Values: values})
+}
+
+// RecordFields returns the fields added to the pod's record, in the order they were added
+func (p *Pod) RecordFields() []RecordField {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]RecordField(nil), p.fields...)
+}
+
+// RecordField returns the record field with the key, false when none was added
+func (p *Pod) RecordField(key string) (RecordField, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, f := range p.fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return RecordField{}, false
+}
+
+// TextEncoder renders the built-in "Pod Name: ..., Phase: ..." record, with the record fields appended as
+// ", Key: value"
+type TextEncoder struct{}
+
+// Encode implements Encoder
+func (TextEncoder) Encode(pod *Pod) ([]byte, error) {
+	ready, total := pod.ReadyContainers()
+	terminations := make([]string, 0, len(pod.LastTerminations()))
+	for _, t := range pod.LastTerminations() {
+		terminations = append(terminations, fmt.Sprintf("%s:%s(%d)", t.Container, t.Reason, t.ExitCode))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pod Name: %s, Node: %s, Phase: %s, Ready: %d/%d, Restarts: %d, Waiting: [%s], Last Termination: [%s]",
+		pod.Name(), pod.NodeName(), pod.Phase(), ready, total, pod.RestartCount(),
+		strings.Join(pod.WaitingReasons(), ","), strings.Join(terminations, ","))
+	if init, ok := pod.InitStatus(); ok {
+		b.WriteString(", Init: " + init)
+	}
+	if debug := pod.EphemeralStates(); len(debug) > 0 {
+		b.WriteString(", Ephemeral Containers: [" + strings.Join(debug, ",") + "]")
+	}
+	b.WriteString(", Owner: " + pod.OwnerKind() + "/" + pod.Owner())
+	for _, f := range pod.RecordFields() {
+		b.WriteString(", " + f.Key + ": " + f.String())
+	}
+	return []byte(b.String()), nil
+}
+
+// podRecord is what the JSON and protobuf encoders render of a pod
+type podRecord struct {
+	Name                string                 `json:"name"`
+	Namespace           string                 `json:"namespace"`
+	Node                string                 `json:"node,omitempty"`
+	Phase               string                 `json:"phase"`
+	Ready               int                    `json:"ready"`
+	Containers          int                    `json:"containers"`
+	Restarts            int32                  `json:"restarts"`
+	Waiting             []string               `json:"waiting,omitempty"`
+	LastTerminations    []ContainerTermination `json:"lastTerminations,omitempty"`
+	Init                string                 `json:"init,omitempty"`
+	EphemeralContainers []string               `json:"ephemeralContainers,omitempty"`
+	OwnerKind           string                 `json:"ownerKind"`
+	Owner               string                 `json:"owner"`
+	// Fields are the record fields by key, a string or a list of strings
+	Fields map[string]any `json:"fields,omitempty"`
+
+	fields []RecordField
+}
+
+// newPodRecord collects what the structured encoders render of the pod
+func newPodRecord(pod *Pod) podRecord {
+	ready, total := pod.ReadyContainers()
+	r := podRecord{
+		Name:                pod.Name(),
+		Namespace:           pod.Namespace(),
+		Node:                pod.NodeName(),
+		Phase:               string(pod.Phase()),
+		Ready:               ready,
+		Containers:          total,
+		Restarts:            pod.RestartCount(),
+		Waiting:             pod.WaitingReasons(),
+		LastTerminations:    pod.LastTerminations(),
+		EphemeralContainers: pod.EphemeralStates(),
+		OwnerKind:           pod.OwnerKind(),
+		Owner:               pod.Owner(),
+		fields:              pod.RecordFields(),
+	}
+	r.Init, _ = pod.InitStatus()
+	if len(r.fields) > 0 {
+		r.Fields = make(map[string]any, len(r.fields))
+		for _, f := range r.fields {
+			if f.Values != nil {
+				r.Fields[f.Key] = f.Values
+			} else {
+				r.Fields[f.Key] = f.Value
+			}
+		}
+	}
+	return r
+}
+
+// JSONEncoder renders a pod as a single line JSON object
+type JSONEncoder struct{}
+
+// Encode implements Encoder
+func (JSONEncoder) Encode(pod *Pod) ([]byte, error) {
+	return json.Marshal(newPodRecord(pod))
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+)
+
+// Event represents a Kubernetes event normalized from either events.k8s.io/v1 or core/v1
+type Event struct {
+	UID          string
+	Namespace    string
+	Type         string
+	Reason       string
+	Note         string
+	Regarding    v1.ObjectReference
+	Host         string
+	Count        int32
+	LastObserved time.Time
+}
+
+// NewEventFromEventsV1 creates an Event from an events.k8s.io/v1 event, folding its series into the count
+func NewEventFromEventsV1(e *eventsv1.Event) *Event {
+	ev := &Event{
+		UID:          string(e.UID),
+		Namespace:    e.Namespace,
+		Type:         e.Type,
+		Reason:       e.Reason,
+		Note:         e.Note,
+		Regarding:    e.Regarding,
+		Host:         e.DeprecatedSource.Host,
+		Count:        1,
+		LastObserved: e.EventTime.Time,
+	}
+	if ev.Host == "" {
+		ev.Host = e.ReportingInstance
+	}
+	if e.Series != nil {
+		ev.Count = e.Series.Count
+		ev.LastObserved = e.Series.LastObservedTime.Time
+	} else if e.DeprecatedCount > 0 {
+		ev.Count = e.DeprecatedCount
+	}
+	if ev.LastObserved.IsZero() {
+		ev.LastObserved = e.DeprecatedLastTimestamp.Time
+	}
+	return ev
+}
+
+// NewEventFromCoreV1 creates an Event from a core/v1 event
+func NewEventFromCoreV1(e *v1.Event) *Event {
+	ev := &Event{
+		UID:          string(e.UID),
+		Namespace:    e.Namespace,
+		Type:         e.Type,
+		Reason:       e.Reason,
+		Note:         e.Message,
+		Regarding:    e.InvolvedObject,
+		Host:         e.Source.Host,
+		Count:        e.Count,
+		LastObserved: e.LastTimestamp.Time,
+	}
+	if e.Series != nil {
+		ev.Count = e.Series.Count
+		ev.LastObserved = e.Series.LastObservedTime.Time
+	}
+	if ev.Count == 0 {
+		ev.Count = 1
+	}
+	if ev.LastObserved.IsZero() {
+		ev.LastObserved = e.EventTime.Time
+	}
+	return ev
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Classes of pod health, from best to worst
+const (
+	HealthHealthy  = "Healthy"
+	HealthDegraded = "Degraded"
+	HealthFailed   = "Failed"
+)
+
+// HealthRule classifies the pods matching all of its criteria, a criterion left empty matches every pod
+type HealthRule struct {
+	Name string
+	// Health is the class of the matching pods: Healthy, Degraded or Failed
+	Health string
+	// Phases the pod is in
+	Phases []string
+	// Conditions are the statuses the pod's conditions have, by type, e.g. Ready: False. A pod without one of the
+	// conditions does not match
+	Conditions map[string]string
+	// Reasons a container or init container is waiting for, e.g. CrashLoopBackOff
+	Reasons []string
+	// MinRestarts is the restarts across the containers from which the rule matches
+	MinRestarts int32
+	// MinAge is how long the pod must exist for the rule to match
+	MinAge time.Duration
+}
+
+// DefaultHealthRules classify pods that failed or crash loop as Failed, and pods that are pending or not ready as
+// Degraded
+func DefaultHealthRules() []HealthRule {
+	return []HealthRule{
+		{Name: "failed", Health: HealthFailed, Phases: []string{string(v1.PodFailed), string(v1.PodUnknown)}},
+		{Name: "crash-loop", Health: HealthFailed, Reasons: []string{"CrashLoopBackOff"}},
+		{Name: "pending", Health: HealthDegraded, Phases: []string{string(v1.PodPending)}},
+		{Name: "not-ready", Health: HealthDegraded, Phases: []string{string(v1.PodRunning)}, Conditions: map[string]string{string(v1.PodReady): string(v1.ConditionFalse)}},
+	}
+}
+
+// CheckHealthRules validates the rules' names, classes and condition statuses
+func CheckHealthRules(rules []HealthRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("a health rule classifying pods as %s has no name", r.Health)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("health rule %q is defined twice", r.Name)
+		}
+		seen[r.Name] = true
+		if r.Health != HealthHealthy && r.Health != HealthDegraded && r.Health != HealthFailed {
+			return fmt.Errorf("health rule %q has health %q, expected Healthy, Degraded or Failed", r.Name, r.Health)
+		}
+		for t, status := range r.Conditions {
+			if status != string(v1.ConditionTrue) && status != string(v1.ConditionFalse) && status != string(v1.ConditionUnknown) {
+				return fmt.Errorf("health rule %q expects condition %s to be %q, expected True, False or Unknown", r.Name, t, status)
+			}
+		}
+		if r.MinRestarts < 0 || r.MinAge < 0 {
+			return fmt.Errorf("health rule %q has a negative threshold", r.Name)
+		}
+	}
+	return nil
+}
+
+// ClassifyHealth returns the class of the first rule the pod matches and the rule's name, Healthy and no rule when
+// it matches none
+func ClassifyHealth(rules []HealthRule, pod *Pod, now time.Time) (string, string) {
+	for _, r := range rules {
+		if r.matches(pod, now) {
+			return r.Health, r.Name
+		}
+	}
+	return HealthHealthy, ""
+}
+
+// matches reports whether the pod meets every criterion of the rule
+func (r HealthRule) matches(pod *Pod, now time.Time) bool {
+	if len(r.Phases) > 0 && !slices.Contains(r.Phases, string(pod.Phase())) {
+		return false
+	}
+	for t, status := range r.Conditions {
+		if got, ok := pod.ConditionStatus(v1.PodConditionType(t)); !ok || string(got) != status {
+			return false
+		}
+	}
+	if len(r.Reasons) > 0 && !r.waitingFor(pod) {
+		return false
+	}
+	if r.MinRestarts > 0 && pod.RestartCount() < r.MinRestarts {
+		return false
+	}
+	if r.MinAge > 0 && now.Sub(pod.Created()) < r.MinAge {
+		return false
+	}
+	return true
+}
+
+// waitingFor reports whether a container or init container waits for one of the rule's reasons
+func (r HealthRule) waitingFor(pod *Pod) bool {
+	for _, reason := range pod.WaitingReasons() {
+		if slices.Contains(r.Reasons, reason) {
+			return true
+		}
+	}
+	for _, cs := range pod.InitContainerStatuses() {
+		if w := cs.State.Waiting; w != nil && slices.Contains(r.Reasons, w.Reason) {
+			return true
+		}
+	}
+	return false
+}
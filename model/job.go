@@ -0,0 +1,219 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Outcomes of a finished Job
+const (
+	JobComplete = "Complete"
+	JobFailed   = "Failed"
+)
+
+// Job struct to represent a Kubernetes Job's progress and outcome
+type Job struct {
+	mu  sync.RWMutex
+	job batchv1.Job
+}
+
+// NewJob creates a Job model from the provided job
+func NewJob(j *batchv1.Job) *Job {
+	return &Job{
+		job: *j,
+	}
+}
+
+// Update updates the job model, replacing it with a shallow copy of the provided job
+func (j *Job) Update(job *batchv1.Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job = *job
+}
+
+// Name returns the name of the job
+func (j *Job) Name() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.job.Name
+}
+
+// Namespace returns the namespace of the job
+func (j *Job) Namespace() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.job.Namespace
+}
+
+// CronJob returns the name of the cron job that created the job, or an empty string
+func (j *Job) CronJob() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	for _, ref := range j.job.OwnerReferences {
+		if ref.Kind == "CronJob" && ref.Controller != nil && *ref.Controller {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+// Pods returns the active, succeeded and failed pod counts
+func (j *Job) Pods() (active, succeeded, failed int32) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	s := j.job.Status
+	return s.Active, s.Succeeded, s.Failed
+}
+
+// BackoffLimit returns the number of retries before the job is marked failed, 6 when unset as in the Job API
+func (j *Job) BackoffLimit() int32 {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.job.Spec.BackoffLimit != nil {
+		return *j.job.Spec.BackoffLimit
+	}
+	return 6
+}
+
+// Outcome returns JobComplete or JobFailed with the reason, message and time of the condition once the job
+// finished, and an empty outcome while it runs
+func (j *Job) Outcome() (outcome, reason, message string, at time.Time) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	for _, c := range j.job.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return JobComplete, c.Reason, c.Message, c.LastTransitionTime.Time
+		case batchv1.JobFailed:
+			return JobFailed, c.Reason, c.Message, c.LastTransitionTime.Time
+		}
+	}
+	return "", "", "", time.Time{}
+}
+
+// Duration returns how long the job ran, from its start to its completion or to now
+func (j *Job) Duration(now time.Time) time.Duration {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	s := j.job.Status
+	if s.StartTime == nil {
+		return 0
+	}
+	end := now
+	if s.CompletionTime != nil {
+		end = s.CompletionTime.Time
+	} else {
+		for _, c := range s.Conditions {
+			if c.Type == batchv1.JobFailed && c.Status == v1.ConditionTrue {
+				end = c.LastTransitionTime.Time
+			}
+		}
+	}
+	return end.Sub(s.StartTime.Time)
+}
+
+// CronJob struct to represent a Kubernetes CronJob's schedule
+type CronJob struct {
+	mu      sync.RWMutex
+	cronJob batchv1.CronJob
+}
+
+// NewCronJob creates a CronJob model from the provided cron job
+func NewCronJob(c *batchv1.CronJob) *CronJob {
+	return &CronJob{
+		cronJob: *c,
+	}
+}
+
+// Update updates the cron job model, replacing it with a shallow copy of the provided cron job
+func (c *CronJob) Update(cronJob *batchv1.CronJob) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cronJob = *cronJob
+}
+
+// Name returns the name of the cron job
+func (c *CronJob) Name() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cronJob.Name
+}
+
+// Namespace returns the namespace of the cron job
+func (c *CronJob) Namespace() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cronJob.Namespace
+}
+
+// Schedule returns the cron schedule as written in the spec
+func (c *CronJob) Schedule() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cronJob.Spec.Schedule
+}
+
+// IsSuspended reports whether the cron job is suspended, it then starts no jobs
+func (c *CronJob) IsSuspended() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cronJob.Spec.Suspend != nil && *c.cronJob.Spec.Suspend
+}
+
+// Active returns the number of jobs of the cron job still running
+func (c *CronJob) Active() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.cronJob.Status.Active)
+}
+
+// LastScheduled returns when the cron job last started a job, its creation when it never did
+func (c *CronJob) LastScheduled() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if t := c.cronJob.Status.LastScheduleTime; t != nil {
+		return t.Time
+	}
+	return c.cronJob.CreationTimestamp.Time
+}
+
+// StartingDeadline returns how late a job may still be started, 0 when the spec sets no deadline
+func (c *CronJob) StartingDeadline() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if d := c.cronJob.Spec.StartingDeadlineSeconds; d != nil {
+		return time.Duration(*d) * time.Second
+	}
+	return 0
+}
+
+// MissedSchedule returns the first scheduled time after the last job started that is more than grace in the past,
+// zero when none is: the cron job is suspended, on time, or its schedule cannot be parsed
+func (c *CronJob) MissedSchedule(now time.Time, grace time.Duration) time.Time {
+	if c.IsSuspended() {
+		return time.Time{}
+	}
+	c.mu.RLock()
+	location := time.UTC
+	if tz := c.cronJob.Spec.TimeZone; tz != nil && *tz != "" {
+		if loc, err := time.LoadLocation(*tz); err == nil {
+			location = loc
+		}
+	}
+	c.mu.RUnlock()
+	schedule, err := ParseSchedule(c.Schedule(), location)
+	if err != nil {
+		return time.Time{}
+	}
+	next := schedule.Next(c.LastScheduled())
+	if next.IsZero() || !next.Add(grace).Before(now) {
+		return time.Time{}
+	}
+	return next
+}
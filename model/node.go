@@ -0,0 +1,184 @@
+package model
+
+import (
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Node struct to represent a Kubernetes Node's basic information
+type Node struct {
+	mu   sync.RWMutex
+	node v1.Node
+}
+
+// NewNode creates a Node model from the provided node
+func NewNode(n *v1.Node) *Node {
+	return &Node{
+		node: *n,
+	}
+}
+
+// Update updates the node model, replacing it with a shallow copy of the provided node
+func (n *Node) Update(node *v1.Node) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.node = *node
+}
+
+// Name returns the name of the node
+func (n *Node) Name() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Name
+}
+
+// Labels returns the labels of the node
+func (n *Node) Labels() map[string]string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Labels
+}
+
+// Conditions returns the node conditions as reported by the kubelet
+func (n *Node) Conditions() []v1.NodeCondition {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Status.Conditions
+}
+
+// Condition returns the status of the given condition type, or ConditionUnknown if it is not reported
+func (n *Node) Condition(t v1.NodeConditionType) v1.ConditionStatus {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, c := range n.node.Status.Conditions {
+		if c.Type == t {
+			return c.Status
+		}
+	}
+	return v1.ConditionUnknown
+}
+
+// IsReady returns true if the node reports the Ready condition as True
+func (n *Node) IsReady() bool {
+	return n.Condition(v1.NodeReady) == v1.ConditionTrue
+}
+
+// Capacity returns the total resources of the node
+func (n *Node) Capacity() v1.ResourceList {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Status.Capacity
+}
+
+// Allocatable returns the resources of the node available for scheduling
+func (n *Node) Allocatable() v1.ResourceList {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Status.Allocatable
+}
+
+// Taints returns the taints applied to the node
+func (n *Node) Taints() []v1.Taint {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Spec.Taints
+}
+
+// OS returns the operating system of the node, e.g. linux or windows
+func (n *Node) OS() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if os := n.node.Labels[v1.LabelOSStable]; os != "" {
+		return os
+	}
+	return n.node.Status.NodeInfo.OperatingSystem
+}
+
+// IsCordoned returns true if the node is marked unschedulable
+func (n *Node) IsCordoned() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.node.Spec.Unschedulable
+}
+
+// IsSchedulable returns true if the node is Ready and not cordoned
+func (n *Node) IsSchedulable() bool {
+	return !n.IsCordoned() && n.IsReady()
+}
+
+// disruptionTaints maps the taints put on nodes being drained or lost to how they are reported
+var disruptionTaints = map[string]string{
+	v1.TaintNodeNotReady:                        "NotReady",
+	v1.TaintNodeUnreachable:                     "Unreachable",
+	v1.TaintNodeOutOfService:                    "OutOfService",
+	"ToBeDeletedByClusterAutoscaler":            "ScaleDown (cluster-autoscaler)",
+	"karpenter.sh/disrupted":                    "Disrupted (karpenter)",
+	"karpenter.sh/disruption":                   "Disrupted (karpenter)",
+	"node.cloudprovider.kubernetes.io/shutdown": "Shutdown",
+}
+
+// Disruptions describes what makes the node an infrastructure problem for its pods: cordoned, being deleted,
+// not Ready, under pressure, or tainted by a drain. Empty for a healthy node
+func (n *Node) Disruptions() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	var states []string
+	add := func(state string) {
+		for _, s := range states {
+			if s == state {
+				return
+			}
+		}
+		states = append(states, state)
+	}
+
+	if n.node.DeletionTimestamp != nil {
+		add("Deleting")
+	}
+	if n.node.Spec.Unschedulable {
+		add("Cordoned")
+	}
+	ready := v1.ConditionUnknown
+	for _, c := range n.node.Status.Conditions {
+		switch {
+		case c.Type == v1.NodeReady:
+			ready = c.Status
+		case c.Status == v1.ConditionTrue:
+			// MemoryPressure, DiskPressure, PIDPressure and NetworkUnavailable are only set when something is wrong
+			add(string(c.Type))
+		}
+	}
+	if ready == v1.ConditionFalse {
+		add("NotReady")
+	} else if ready == v1.ConditionUnknown {
+		add("Unreachable")
+	}
+	for _, t := range n.node.Spec.Taints {
+		if state, ok := disruptionTaints[t.Key]; ok {
+			add(state)
+		} else if strings.HasPrefix(t.Key, "aws-node-termination-handler/") {
+			add("Draining (node-termination-handler)")
+		}
+	}
+	return states
+}
+
+// VirtualKind returns "fargate" or "virtual-kubelet" for nodes that are not backed by a real kubelet, or an empty string
+func (n *Node) VirtualKind() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.node.Labels["eks.amazonaws.com/compute-type"] == "fargate" {
+		return "fargate"
+	}
+	if n.node.Labels["type"] == "virtual-kubelet" {
+		return "virtual-kubelet"
+	}
+	for _, t := range n.node.Spec.Taints {
+		if t.Key == "virtual-kubelet.io/provider" {
+			return "virtual-kubelet"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxOwnerDepth bounds the walk up the controllers, ownerReferences can form a cycle
+const maxOwnerDepth = 5
+
+// OwnerResolver finds the controller of an object that owns pods, so Owner can follow a ReplicaSet to its Deployment
+// and a Job to its CronJob
+type OwnerResolver interface {
+	// Controller returns the kind and name of the controller of the kind/name object in the namespace, false when it
+	// has none or the object is not known
+	Controller(namespace, kind, name string) (string, string, bool)
+}
+
+var (
+	ownerResolver   OwnerResolver
+	ownerResolverMu sync.RWMutex
+)
+
+// SetOwnerResolver sets the resolver Owner walks the ownerReferences with, nil falls back to Workload's naming rules
+func SetOwnerResolver(r OwnerResolver) {
+	ownerResolverMu.Lock()
+	defer ownerResolverMu.Unlock()
+	ownerResolver = r
+}
+
+// Owner returns the name of the top-level controller of the pod, the pod itself when it has none
+func (p *Pod) Owner() string {
+	_, name := p.owner()
+	return name
+}
+
+// OwnerKind returns the kind of the top-level controller of the pod, Pod when it has none
+func (p *Pod) OwnerKind() string {
+	kind, _ := p.owner()
+	return kind
+}
+
+// owner walks the pod's controller references up through the resolver. When the resolver does not know the pod's
+// direct controller yet, the owner is derived from the names like Workload does
+func (p *Pod) owner() (string, string) {
+	p.mu.RLock()
+	ref := metav1.GetControllerOfNoCopy(&p.pod)
+	namespace, pod := p.pod.Namespace, p.pod.Name
+	var kind, name string
+	if ref != nil {
+		kind, name = ref.Kind, ref.Name
+	}
+	p.mu.RUnlock()
+	if ref == nil {
+		return "Pod", pod
+	}
+
+	ownerResolverMu.RLock()
+	r := ownerResolver
+	ownerResolverMu.RUnlock()
+	resolved := false
+	for i := 0; i < maxOwnerDepth && r != nil; i++ {
+		k, n, ok := r.Controller(namespace, kind, name)
+		if !ok {
+			break
+		}
+		kind, name, resolved = k, n, true
+	}
+	if !resolved {
+		kind, name, _ = strings.Cut(p.Workload(), "/")
+	}
+	return kind, name
+}
@@ -1,15 +1,21 @@
 package model
 
 import (
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // PodInfo struct to represent a Kubernetes Pod's basic information
 type Pod struct {
 	mu  sync.RWMutex
 	pod v1.Pod
+	// fields are the record fields added by whoever builds the pod's record, see AddRecordField
+	fields []RecordField
 }
 
 // Mutex for thread-safe logging
@@ -47,9 +53,332 @@ func (p *Pod) Name() string {
 	return p.pod.Name
 }
 
+// UID returns the unique ID of the pod
+func (p *Pod) UID() types.UID {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.UID
+}
+
+// Namespace returns the namespace of the pod
+func (p *Pod) Namespace() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.Namespace
+}
+
 // Phase returns the pod phase
 func (p *Pod) Phase() v1.PodPhase {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	return p.pod.Status.Phase
 }
+
+// RequiredOS returns the operating system the pod must run on from spec.os or its node selector, or an empty string
+func (p *Pod) RequiredOS() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.pod.Spec.OS != nil {
+		return string(p.pod.Spec.OS.Name)
+	}
+	return p.pod.Spec.NodeSelector[v1.LabelOSStable]
+}
+
+// ContainerTermination describes how a container last terminated
+type ContainerTermination struct {
+	Container string `json:"container"`
+	Reason    string `json:"reason"`
+	ExitCode  int32  `json:"exitCode"`
+}
+
+// Object returns a shallow copy of the Kubernetes pod, for code written against the API types
+func (p *Pod) Object() *v1.Pod {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	pod := p.pod
+	return &pod
+}
+
+// ContainerStatuses returns the statuses of the pod's app containers
+func (p *Pod) ContainerStatuses() []v1.ContainerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.Status.ContainerStatuses
+}
+
+// InitContainerStatuses returns the statuses of the pod's init containers, in the order they run
+func (p *Pod) InitContainerStatuses() []v1.ContainerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.Status.InitContainerStatuses
+}
+
+// EphemeralContainers returns the ephemeral containers added to the pod, such as kubectl debug containers
+func (p *Pod) EphemeralContainers() []v1.EphemeralContainer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.Spec.EphemeralContainers
+}
+
+// EphemeralContainerStatuses returns the statuses of the pod's ephemeral containers
+func (p *Pod) EphemeralContainerStatuses() []v1.ContainerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.Status.EphemeralContainerStatuses
+}
+
+// EphemeralStates lists the pod's ephemeral containers as name:state, e.g. debugger-x7k:Running, Pending until the
+// kubelet reports them
+func (p *Pod) EphemeralStates() []string {
+	states := make(map[string]string)
+	for _, cs := range p.EphemeralContainerStatuses() {
+		switch {
+		case cs.State.Running != nil:
+			states[cs.Name] = "Running"
+		case cs.State.Terminated != nil:
+			states[cs.Name] = "Terminated"
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "":
+			states[cs.Name] = cs.State.Waiting.Reason
+		}
+	}
+	var containers []string
+	for _, c := range p.EphemeralContainers() {
+		state := states[c.Name]
+		if state == "" {
+			state = "Pending"
+		}
+		containers = append(containers, c.Name+":"+state)
+	}
+	return containers
+}
+
+// InitStatus returns how far the init containers got while the pod initializes, shown by kubectl as Init:<status>:
+// the waiting reason or termination of the init container holding the pod up, such as CrashLoopBackOff or
+// ExitCode:1, or the number done, such as 1/3. It returns false once every init container completed, sidecars
+// counting as done once started
+func (p *Pod) InitStatus() (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	restartable := make(map[string]bool)
+	for _, c := range p.pod.Spec.InitContainers {
+		restartable[c.Name] = c.RestartPolicy != nil && *c.RestartPolicy == v1.ContainerRestartPolicyAlways
+	}
+	statuses := p.pod.Status.InitContainerStatuses
+	for i, cs := range statuses {
+		switch {
+		case restartable[cs.Name] && cs.Started != nil && *cs.Started:
+			continue
+		case cs.State.Terminated != nil && cs.State.Terminated.ExitCode == 0:
+			continue
+		case cs.State.Terminated != nil:
+			t := cs.State.Terminated
+			switch {
+			case t.Reason != "":
+				return t.Reason, true
+			case t.Signal != 0:
+				return fmt.Sprintf("Signal:%d", t.Signal), true
+			}
+			return fmt.Sprintf("ExitCode:%d", t.ExitCode), true
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "" && cs.State.Waiting.Reason != "PodInitializing":
+			return cs.State.Waiting.Reason, true
+		}
+		return fmt.Sprintf("%d/%d", i, len(p.pod.Spec.InitContainers)), true
+	}
+	if len(statuses) < len(p.pod.Spec.InitContainers) && p.pod.Status.Phase == v1.PodPending {
+		return fmt.Sprintf("%d/%d", len(statuses), len(p.pod.Spec.InitContainers)), true
+	}
+	return "", false
+}
+
+// Requests returns the resource requests summed over the app containers
+func (p *Pod) Requests() v1.ResourceList {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	total := v1.ResourceList{}
+	for _, c := range p.pod.Spec.Containers {
+		sumResources(total, c.Resources.Requests)
+	}
+	return total
+}
+
+// Limits returns the resource limits summed over the app containers
+func (p *Pod) Limits() v1.ResourceList {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	total := v1.ResourceList{}
+	for _, c := range p.pod.Spec.Containers {
+		sumResources(total, c.Resources.Limits)
+	}
+	return total
+}
+
+// sumResources adds the quantities of src to total
+func sumResources(total, src v1.ResourceList) {
+	for name, q := range src {
+		sum := total[name]
+		sum.Add(q)
+		total[name] = sum
+	}
+}
+
+// RestartCount returns the total number of restarts across the pod's containers
+func (p *Pod) RestartCount() int32 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var restarts int32
+	for _, cs := range p.pod.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+	}
+	return restarts
+}
+
+// Created returns when the pod was created
+func (p *Pod) Created() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pod.CreationTimestamp.Time
+}
+
+// ConditionStatus returns the status of the pod condition of the type, false when the pod has no such condition
+func (p *Pod) ConditionStatus(t v1.PodConditionType) (v1.ConditionStatus, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, c := range p.pod.Status.Conditions {
+		if c.Type == t {
+			return c.Status, true
+		}
+	}
+	return "", false
+}
+
+// ReadyContainers returns the number of ready containers and the number of containers in the spec
+func (p *Pod) ReadyContainers() (int, int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ready := 0
+	for _, cs := range p.pod.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+	}
+	return ready, len(p.pod.Spec.Containers)
+}
+
+// WaitingReasons returns the reasons containers are waiting, e.g. CrashLoopBackOff or ImagePullBackOff
+func (p *Pod) WaitingReasons() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var reasons []string
+	for _, cs := range p.pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			reasons = append(reasons, cs.State.Waiting.Reason)
+		}
+	}
+	return reasons
+}
+
+// LastTerminations returns the previous termination of every container that has restarted
+func (p *Pod) LastTerminations() []ContainerTermination {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var terminations []ContainerTermination
+	for _, cs := range p.pod.Status.ContainerStatuses {
+		if t := cs.LastTerminationState.Terminated; t != nil {
+			terminations = append(terminations, ContainerTermination{
+				Container: cs.Name,
+				Reason:    t.Reason,
+				ExitCode:  t.ExitCode,
+			})
+		}
+	}
+	return terminations
+}
+
+// ClaimNames returns the PersistentVolumeClaims the pod mounts, including generic ephemeral volume claims
+func (p *Pod) ClaimNames() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var claims []string
+	for _, vol := range p.pod.Spec.Volumes {
+		switch {
+		case vol.PersistentVolumeClaim != nil:
+			claims = append(claims, vol.PersistentVolumeClaim.ClaimName)
+		case vol.Ephemeral != nil:
+			// Generic ephemeral volumes get a claim named after the pod and the volume
+			claims = append(claims, p.pod.Name+"-"+vol.Name)
+		}
+	}
+	return claims
+}
+
+// Workload names the workload that created the pod as kind/name, e.g. Deployment/cart or CronJob/report. ReplicaSet
+// and Job owners are traced back to their Deployment and CronJob by name, pods without a controller are Pod/<name>
+func (p *Pod) Workload() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, ref := range p.pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		switch ref.Kind {
+		case "ReplicaSet":
+			// Deployment ReplicaSets are named <deployment>-<pod-template-hash>
+			if hash := p.pod.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+				return "Deployment/" + strings.TrimSuffix(ref.Name, "-"+hash)
+			}
+		case "Job":
+			// CronJob Jobs are named <cronjob>-<scheduled time in minutes>
+			if i := strings.LastIndex(ref.Name, "-"); i > 0 && isDigits(ref.Name[i+1:]) {
+				return "CronJob/" + ref.Name[:i]
+			}
+		}
+		return ref.Kind + "/" + ref.Name
+	}
+	return "Pod/" + p.pod.Name
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Problem returns a short reason and message when the pod is Failed, Unknown or crash looping, including in its
+// init containers
+func (p *Pod) Problem() (string, string, bool) {
+	switch p.Phase() {
+	case v1.PodFailed:
+		return "PodFailed", "pod " + p.Name() + " is in phase Failed", true
+	case v1.PodUnknown:
+		return "PodUnknown", "pod " + p.Name() + " is in phase Unknown", true
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var crashing []string
+	for _, cs := range p.pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+			crashing = append(crashing, cs.Name)
+		}
+	}
+	if len(crashing) > 0 {
+		return "CrashLoopBackOff", "containers in CrashLoopBackOff: " + strings.Join(crashing, ","), true
+	}
+	// A crashing init container holds the pod in Init:CrashLoopBackOff, its app containers never start
+	for _, cs := range p.pod.Status.InitContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+			crashing = append(crashing, cs.Name)
+		}
+	}
+	if len(crashing) > 0 {
+		return "InitCrashLoopBackOff", "init containers in CrashLoopBackOff: " + strings.Join(crashing, ","), true
+	}
+	return "", "", false
+}
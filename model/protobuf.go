@@ -0,0 +1,164 @@
+package model
+
+import (
+	"encoding/base64"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Descriptors of the messages of podrecord.proto, built from recordFileDescriptor
+var (
+	podRecordDesc   protoreflect.MessageDescriptor
+	terminationDesc protoreflect.MessageDescriptor
+	fieldDesc       protoreflect.MessageDescriptor
+)
+
+func init() {
+	file, err := protodesc.NewFile(recordFileDescriptor(), protoregistry.GlobalFiles)
+	if err != nil {
+		panic("model: invalid record descriptor: " + err.Error())
+	}
+	podRecordDesc = file.Messages().ByName("PodRecord")
+	terminationDesc = file.Messages().ByName("ContainerTermination")
+	fieldDesc = file.Messages().ByName("RecordField")
+}
+
+// ProtobufEncoder renders a pod as the PodRecord message of podrecord.proto. The message is base64 encoded, the
+// sinks write one record per line
+type ProtobufEncoder struct{}
+
+// Encode implements Encoder
+func (ProtobufEncoder) Encode(pod *Pod) ([]byte, error) {
+	r := newPodRecord(pod)
+	m := dynamicpb.NewMessage(podRecordDesc)
+	setString(m, "name", r.Name)
+	setString(m, "namespace", r.Namespace)
+	setString(m, "node", r.Node)
+	setString(m, "phase", r.Phase)
+	setInt(m, "ready", int32(r.Ready))
+	setInt(m, "containers", int32(r.Containers))
+	setInt(m, "restarts", r.Restarts)
+	setStrings(m, "waiting", r.Waiting)
+	setString(m, "init", r.Init)
+	setStrings(m, "ephemeral_containers", r.EphemeralContainers)
+	setString(m, "owner_kind", r.OwnerKind)
+	setString(m, "owner", r.Owner)
+
+	terminations := m.Mutable(podRecordDesc.Fields().ByName("last_terminations")).List()
+	for _, t := range r.LastTerminations {
+		tm := dynamicpb.NewMessage(terminationDesc)
+		setString(tm, "container", t.Container)
+		setString(tm, "reason", t.Reason)
+		setInt(tm, "exit_code", t.ExitCode)
+		terminations.Append(protoreflect.ValueOfMessage(tm))
+	}
+	fields := m.Mutable(podRecordDesc.Fields().ByName("fields")).List()
+	for _, f := range r.fields {
+		fm := dynamicpb.NewMessage(fieldDesc)
+		setString(fm, "key", f.Key)
+		setString(fm, "value", f.Value)
+		setStrings(fm, "values", f.Values)
+		fields.Append(protoreflect.ValueOfMessage(fm))
+	}
+
+	wire, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.AppendEncode(nil, wire), nil
+}
+
+// setString sets a string field, leaving empty strings unset like generated code does
+func setString(m *dynamicpb.Message, name, v string) {
+	if v != "" {
+		m.Set(m.Descriptor().Fields().ByName(protoreflect.Name(name)), protoreflect.ValueOfString(v))
+	}
+}
+
+// setInt sets an int32 field, leaving zero unset
+func setInt(m *dynamicpb.Message, name string, v int32) {
+	if v != 0 {
+		m.Set(m.Descriptor().Fields().ByName(protoreflect.Name(name)), protoreflect.ValueOfInt32(v))
+	}
+}
+
+// setStrings appends the values to a repeated string field
+func setStrings(m *dynamicpb.Message, name string, values []string) {
+	list := m.Mutable(m.Descriptor().Fields().ByName(protoreflect.Name(name))).List()
+	for _, v := range values {
+		list.Append(protoreflect.ValueOfString(v))
+	}
+}
+
+// recordFileDescriptor describes podrecord.proto, there is no protoc in the build so it is written out by hand
+func recordFileDescriptor() *descriptorpb.FileDescriptorProto {
+	field := func(name, jsonName string, number int32, typ descriptorpb.FieldDescriptorProto_Type, repeated bool, typeName string) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonName),
+			Number:   proto.Int32(number),
+			Label:    label.Enum(),
+			Type:     typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	i32 := descriptorpb.FieldDescriptorProto_TYPE_INT32
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("model/podrecord.proto"),
+		Package: proto.String("podlogger.record.v1"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("adv-go/model;model")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("PodRecord"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", "name", 1, str, false, ""),
+					field("namespace", "namespace", 2, str, false, ""),
+					field("node", "node", 3, str, false, ""),
+					field("phase", "phase", 4, str, false, ""),
+					field("ready", "ready", 5, i32, false, ""),
+					field("containers", "containers", 6, i32, false, ""),
+					field("restarts", "restarts", 7, i32, false, ""),
+					field("waiting", "waiting", 8, str, true, ""),
+					field("last_terminations", "lastTerminations", 9, msg, true, ".podlogger.record.v1.ContainerTermination"),
+					field("init", "init", 10, str, false, ""),
+					field("ephemeral_containers", "ephemeralContainers", 11, str, true, ""),
+					field("owner_kind", "ownerKind", 12, str, false, ""),
+					field("owner", "owner", 13, str, false, ""),
+					field("fields", "fields", 14, msg, true, ".podlogger.record.v1.RecordField"),
+				},
+			},
+			{
+				Name: proto.String("ContainerTermination"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("container", "container", 1, str, false, ""),
+					field("reason", "reason", 2, str, false, ""),
+					field("exit_code", "exitCode", 3, i32, false, ""),
+				},
+			},
+			{
+				Name: proto.String("RecordField"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", "key", 1, str, false, ""),
+					field("value", "value", 2, str, false, ""),
+					field("values", "values", 3, str, true, ""),
+				},
+			},
+		},
+	}
+}
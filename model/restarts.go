@@ -0,0 +1,67 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// restartSample is a pod's restart count at a point in time
+type restartSample struct {
+	count int32
+	at    time.Time
+}
+
+// RestartTracker remembers previous restart counts of pods to tell how much they grew within a window
+type RestartTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	samples map[types.UID][]restartSample
+}
+
+// NewRestartTracker creates a tracker measuring restart increases over the given window
+func NewRestartTracker(window time.Duration) *RestartTracker {
+	return &RestartTracker{window: window, samples: make(map[types.UID][]restartSample)}
+}
+
+// Observe records the pod's current restart count and returns how much it increased within the window
+func (t *RestartTracker) Observe(uid types.UID, count int32, at time.Time) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samples := t.samples[uid]
+	if len(samples) == 0 || samples[len(samples)-1].count != count {
+		samples = append(samples, restartSample{count: count, at: at})
+	}
+	// The baseline is the last count known at the start of the window, older samples are not needed
+	start := 0
+	for i, s := range samples {
+		if !s.at.After(at.Add(-t.window)) {
+			start = i
+		}
+	}
+	samples = samples[start:]
+	t.samples[uid] = samples
+	return count - samples[0].count
+}
+
+// Reset makes the pod's current restart count the new baseline, e.g. after alerting on it
+func (t *RestartTracker) Reset(uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if samples := t.samples[uid]; len(samples) > 0 {
+		t.samples[uid] = samples[len(samples)-1:]
+	}
+}
+
+// Forget drops the counts of pods that are not in the given set
+func (t *RestartTracker) Forget(keep map[types.UID]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for uid := range t.samples {
+		if !keep[uid] {
+			delete(t.samples, uid)
+		}
+	}
+}
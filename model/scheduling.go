@@ -0,0 +1,139 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scheduling failure categories, what a reader has to change for the pod to fit
+const (
+	SchedulingResources = "resources"
+	SchedulingTaints    = "taints"
+	SchedulingAffinity  = "affinity"
+	SchedulingCordoned  = "cordoned"
+	SchedulingVolumes   = "volumes"
+	SchedulingPorts     = "ports"
+	SchedulingNoNodes   = "no-nodes"
+	SchedulingGated     = "gated"
+	SchedulingOther     = "other"
+)
+
+// schedulingCategories maps fragments of the scheduler's filter messages to their category, first match wins
+var schedulingCategories = []struct {
+	fragment string
+	category string
+}{
+	// Cordoned nodes carry this taint, newer schedulers report them as untolerated taints
+	{"node.kubernetes.io/unschedulable", SchedulingCordoned},
+	{"Insufficient ", SchedulingResources},
+	{"Too many pods", SchedulingResources},
+	{"taint", SchedulingTaints},
+	{"volume node affinity conflict", SchedulingVolumes},
+	{"PersistentVolumeClaim", SchedulingVolumes},
+	{"volume", SchedulingVolumes},
+	{"affinity", SchedulingAffinity},
+	{"topology spread", SchedulingAffinity},
+	{"unschedulable", SchedulingCordoned},
+	{"free ports", SchedulingPorts},
+	{"no nodes available", SchedulingNoNodes},
+}
+
+// availableNodes matches the head of a FailedScheduling message, e.g. "0/3 nodes are available: ..."
+var availableNodes = regexp.MustCompile(`^(\d+)/(\d+) nodes are available:\s*(.*)$`)
+
+// SchedulingReason is one filter that rejected nodes, Nodes is how many it rejected or 0 when not counted
+type SchedulingReason struct {
+	Nodes    int
+	Reason   string
+	Category string
+}
+
+// SchedulingDiagnosis breaks a scheduler message down into the reasons nodes were rejected
+type SchedulingDiagnosis struct {
+	Available int
+	Total     int
+	Reasons   []SchedulingReason
+}
+
+// DiagnoseScheduling parses the message of an Unschedulable PodScheduled condition or FailedScheduling event, like
+// "0/3 nodes are available: 1 node(s) had untolerated taint {dedicated: gpu}, 2 Insufficient cpu. preemption: ..."
+func DiagnoseScheduling(message string) SchedulingDiagnosis {
+	// What preemption could not do repeats the same nodes and explains nothing new
+	message, _, _ = strings.Cut(message, " preemption:")
+	message = strings.TrimSuffix(strings.TrimSpace(message), ".")
+
+	var d SchedulingDiagnosis
+	m := availableNodes.FindStringSubmatch(message)
+	if m == nil {
+		if message != "" {
+			d.Reasons = []SchedulingReason{{Reason: message, Category: schedulingCategory(message)}}
+		}
+		return d
+	}
+	d.Available, _ = strconv.Atoi(m[1])
+	d.Total, _ = strconv.Atoi(m[2])
+
+	// Reasons are ", " separated and start with a node count, taint lists inside a reason may contain commas too
+	var parts []string
+	for _, part := range strings.Split(m[3], ", ") {
+		if len(parts) > 0 && (part == "" || part[0] < '0' || part[0] > '9') {
+			parts[len(parts)-1] += ", " + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	for _, part := range parts {
+		r := SchedulingReason{Reason: part}
+		if count, rest, ok := strings.Cut(part, " "); ok {
+			if n, err := strconv.Atoi(count); err == nil {
+				r.Nodes, r.Reason = n, rest
+			}
+		}
+		r.Category = schedulingCategory(r.Reason)
+		d.Reasons = append(d.Reasons, r)
+	}
+	sort.SliceStable(d.Reasons, func(i, j int) bool { return d.Reasons[i].Nodes > d.Reasons[j].Nodes })
+	return d
+}
+
+// schedulingCategory classifies one rejection reason
+func schedulingCategory(reason string) string {
+	for _, c := range schedulingCategories {
+		if strings.Contains(reason, c.fragment) {
+			return c.category
+		}
+	}
+	return SchedulingOther
+}
+
+// Categories returns the distinct categories of the reasons, most rejected nodes first
+func (d SchedulingDiagnosis) Categories() []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, r := range d.Reasons {
+		if !seen[r.Category] {
+			seen[r.Category] = true
+			categories = append(categories, r.Category)
+		}
+	}
+	return categories
+}
+
+// String renders the diagnosis like "0/3 nodes available: 2 Insufficient cpu (resources), 1 node(s) had untolerated taint {dedicated: gpu} (taints)"
+func (d SchedulingDiagnosis) String() string {
+	reasons := make([]string, len(d.Reasons))
+	for i, r := range d.Reasons {
+		reason := r.Reason
+		if r.Nodes > 0 {
+			reason = strconv.Itoa(r.Nodes) + " " + reason
+		}
+		reasons[i] = fmt.Sprintf("%s (%s)", reason, r.Category)
+	}
+	if d.Total == 0 {
+		return strings.Join(reasons, ", ")
+	}
+	return fmt.Sprintf("%d/%d nodes available: %s", d.Available, d.Total, strings.Join(reasons, ", "))
+}
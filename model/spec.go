@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// containerSpec holds the fields of a container that a rollout or an in-place update changes. Fields defaulted or
+// injected by the apiserver, such as the service account token mount, are left out so they do not change the hash
+type containerSpec struct {
+	Name      string                  `json:"name"`
+	Init      bool                    `json:"init,omitempty"`
+	Image     string                  `json:"image"`
+	Command   []string                `json:"command,omitempty"`
+	Args      []string                `json:"args,omitempty"`
+	Env       []v1.EnvVar             `json:"env,omitempty"`
+	EnvFrom   []v1.EnvFromSource      `json:"envFrom,omitempty"`
+	Resources v1.ResourceRequirements `json:"resources"`
+}
+
+// containerSpecs returns the relevant fields of the init containers followed by the containers
+func (p *Pod) containerSpecs() []containerSpec {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	specs := make([]containerSpec, 0, len(p.pod.Spec.InitContainers)+len(p.pod.Spec.Containers))
+	add := func(c v1.Container, init bool) {
+		specs = append(specs, containerSpec{
+			Name: c.Name, Init: init, Image: c.Image, Command: c.Command, Args: c.Args,
+			Env: c.Env, EnvFrom: c.EnvFrom, Resources: c.Resources,
+		})
+	}
+	for _, c := range p.pod.Spec.InitContainers {
+		add(c, true)
+	}
+	for _, c := range p.pod.Spec.Containers {
+		add(c, false)
+	}
+	return specs
+}
+
+// SpecHash hashes the images, commands, environment and resources of the pod's containers. Pods created from the same
+// template share it, so a new hash within a workload marks a template change
+func (p *Pod) SpecHash() string {
+	h := fnv.New64a()
+	// Encoding plain structs of API types does not fail
+	b, _ := json.Marshal(p.containerSpecs())
+	h.Write(b)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// SpecChanges describes how the pod's containers differ from those of old, e.g. "image app: nginx:1.25 -> nginx:1.26"
+func (p *Pod) SpecChanges(old *Pod) []string {
+	before := make(map[string]containerSpec)
+	for _, c := range old.containerSpecs() {
+		before[c.Name] = c
+	}
+
+	var changes []string
+	for _, c := range p.containerSpecs() {
+		prev, ok := before[c.Name]
+		delete(before, c.Name)
+		if !ok {
+			changes = append(changes, "added container "+c.Name)
+			continue
+		}
+		if prev.Image != c.Image {
+			changes = append(changes, fmt.Sprintf("image %s: %s -> %s", c.Name, prev.Image, c.Image))
+		}
+		if !equality.Semantic.DeepEqual(prev.Command, c.Command) || !equality.Semantic.DeepEqual(prev.Args, c.Args) {
+			changes = append(changes, "command "+c.Name)
+		}
+		if !equality.Semantic.DeepEqual(prev.Env, c.Env) || !equality.Semantic.DeepEqual(prev.EnvFrom, c.EnvFrom) {
+			if names := changedEnv(prev.Env, c.Env); len(names) > 0 {
+				changes = append(changes, fmt.Sprintf("env %s: %s", c.Name, strings.Join(names, ",")))
+			} else {
+				changes = append(changes, "env "+c.Name)
+			}
+		}
+		if !equality.Semantic.DeepEqual(prev.Resources, c.Resources) {
+			changes = append(changes, "resources "+c.Name)
+		}
+	}
+	for _, c := range old.containerSpecs() {
+		if _, removed := before[c.Name]; removed {
+			changes = append(changes, "removed container "+c.Name)
+		}
+	}
+	return changes
+}
+
+// changedEnv returns the names of the variables added, removed or changed between two environments, values are left
+// out since they may hold secrets
+func changedEnv(old, env []v1.EnvVar) []string {
+	previous := make(map[string]v1.EnvVar, len(old))
+	for _, e := range old {
+		previous[e.Name] = e
+	}
+	var names []string
+	for _, e := range env {
+		if prev, ok := previous[e.Name]; !ok || !equality.Semantic.DeepEqual(prev, e) {
+			names = append(names, e.Name)
+		}
+		delete(previous, e.Name)
+	}
+	for _, e := range old {
+		if _, ok := previous[e.Name]; ok {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// observedState is the last state seen for a pod
+type observedState struct {
+	namespace string
+	name      string
+	state     string
+}
+
+// StateTransition is a pod's change from one observed state to another. From is empty for pods seen for the first
+// time, To is "Deleted" for pods that disappeared
+type StateTransition struct {
+	Namespace string
+	Name      string
+	From      string
+	To        string
+}
+
+// StateTracker remembers the last observed state of each pod to tell which pods changed between passes
+type StateTracker struct {
+	mu     sync.Mutex
+	states map[types.UID]observedState
+}
+
+// NewStateTracker creates an empty tracker, every pod's first observation is a transition
+func NewStateTracker() *StateTracker {
+	return &StateTracker{states: make(map[types.UID]observedState)}
+}
+
+// PodState summarizes what a transition is about: the phase, followed by the sorted waiting reasons of its
+// containers, e.g. "Running (CrashLoopBackOff)", so a crash loop is a change even though the phase stays Running.
+// Init containers' reasons are prefixed, e.g. "Pending (Init:CrashLoopBackOff)"
+func PodState(pod *Pod) string {
+	state := string(pod.Phase())
+	reasons := pod.WaitingReasons()
+	for _, cs := range pod.InitContainerStatuses() {
+		if w := cs.State.Waiting; w != nil && w.Reason != "" && w.Reason != "PodInitializing" {
+			reasons = append(reasons, "Init:"+w.Reason)
+		}
+	}
+	if len(reasons) > 0 {
+		sort.Strings(reasons)
+		state += " (" + strings.Join(reasons, ",") + ")"
+	}
+	return state
+}
+
+// Observe records the pod's current state and returns the transition when it differs from the previous one
+func (t *StateTracker) Observe(pod *Pod) (StateTransition, bool) {
+	state := PodState(pod)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous, seen := t.states[pod.UID()]
+	t.states[pod.UID()] = observedState{namespace: pod.Namespace(), name: pod.Name(), state: state}
+	if seen && previous.state == state {
+		return StateTransition{}, false
+	}
+	return StateTransition{Namespace: pod.Namespace(), Name: pod.Name(), From: previous.state, To: state}, true
+}
+
+// Forget drops the pods that are not in the given set and returns their transitions to Deleted
+func (t *StateTracker) Forget(keep map[types.UID]bool) []StateTransition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var deleted []StateTransition
+	for uid, s := range t.states {
+		if !keep[uid] {
+			deleted = append(deleted, StateTransition{Namespace: s.namespace, Name: s.name, From: s.state, To: "Deleted"})
+			delete(t.states, uid)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		if deleted[i].Namespace != deleted[j].Namespace {
+			return deleted[i].Namespace < deleted[j].Namespace
+		}
+		return deleted[i].Name < deleted[j].Name
+	})
+	return deleted
+}
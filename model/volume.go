@@ -0,0 +1,81 @@
+package model
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// PVC struct to represent a Kubernetes PersistentVolumeClaim's binding state
+type PVC struct {
+	mu  sync.RWMutex
+	pvc v1.PersistentVolumeClaim
+}
+
+// NewPVC creates a PVC model from the provided claim
+func NewPVC(pvc *v1.PersistentVolumeClaim) *PVC {
+	return &PVC{
+		pvc: *pvc,
+	}
+}
+
+// Update updates the PVC model, replacing it with a shallow copy of the provided claim
+func (p *PVC) Update(pvc *v1.PersistentVolumeClaim) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pvc = *pvc
+}
+
+// Name returns the name of the claim
+func (p *PVC) Name() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pvc.Name
+}
+
+// Namespace returns the namespace of the claim
+func (p *PVC) Namespace() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pvc.Namespace
+}
+
+// Phase returns the phase of the claim: Pending, Bound or Lost
+func (p *PVC) Phase() v1.PersistentVolumeClaimPhase {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pvc.Status.Phase
+}
+
+// IsBound returns true if the claim is bound to a volume
+func (p *PVC) IsBound() bool {
+	return p.Phase() == v1.ClaimBound
+}
+
+// VolumeName returns the name of the PersistentVolume the claim is bound to, empty while it is unbound
+func (p *PVC) VolumeName() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pvc.Spec.VolumeName
+}
+
+// StorageClass returns the storage class the claim asks for, empty for the cluster default
+func (p *PVC) StorageClass() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.pvc.Spec.StorageClassName == nil {
+		return ""
+	}
+	return *p.pvc.Spec.StorageClassName
+}
+
+// Capacity returns the provisioned capacity once bound, or the requested storage while the claim is unbound
+func (p *PVC) Capacity() (capacity resource.Quantity, provisioned bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if c, ok := p.pvc.Status.Capacity[v1.ResourceStorage]; ok {
+		return c, true
+	}
+	return p.pvc.Spec.Resources.Requests[v1.ResourceStorage], false
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// Deployment struct to represent a Kubernetes Deployment's replica counts
+type Deployment struct {
+	mu         sync.RWMutex
+	deployment appsv1.Deployment
+}
+
+// NewDeployment creates a Deployment model from the provided deployment
+func NewDeployment(d *appsv1.Deployment) *Deployment {
+	return &Deployment{
+		deployment: *d,
+	}
+}
+
+// Update updates the deployment model, replacing it with a shallow copy of the provided deployment
+func (d *Deployment) Update(deployment *appsv1.Deployment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deployment = *deployment
+}
+
+// Name returns the name of the deployment
+func (d *Deployment) Name() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.deployment.Name
+}
+
+// Namespace returns the namespace of the deployment
+func (d *Deployment) Namespace() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.deployment.Namespace
+}
+
+// Replicas returns the desired, ready, available and updated replica counts
+func (d *Deployment) Replicas() (desired, ready, available, updated int32) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	desired = 1
+	if d.deployment.Spec.Replicas != nil {
+		desired = *d.deployment.Spec.Replicas
+	}
+	s := d.deployment.Status
+	return desired, s.ReadyReplicas, s.AvailableReplicas, s.UpdatedReplicas
+}
+
+// IsHealthy returns true if every desired replica is updated and available
+func (d *Deployment) IsHealthy() bool {
+	desired, _, available, updated := d.Replicas()
+	return available >= desired && updated >= desired
+}
+
+// ReplicaSet struct to represent a Kubernetes ReplicaSet's replica counts
+type ReplicaSet struct {
+	mu         sync.RWMutex
+	replicaSet appsv1.ReplicaSet
+}
+
+// NewReplicaSet creates a ReplicaSet model from the provided replica set
+func NewReplicaSet(rs *appsv1.ReplicaSet) *ReplicaSet {
+	return &ReplicaSet{
+		replicaSet: *rs,
+	}
+}
+
+// Update updates the replica set model, replacing it with a shallow copy of the provided replica set
+func (r *ReplicaSet) Update(rs *appsv1.ReplicaSet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replicaSet = *rs
+}
+
+// Name returns the name of the replica set
+func (r *ReplicaSet) Name() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.replicaSet.Name
+}
+
+// Namespace returns the namespace of the replica set
+func (r *ReplicaSet) Namespace() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.replicaSet.Namespace
+}
+
+// Replicas returns the desired, ready and available replica counts
+func (r *ReplicaSet) Replicas() (desired, ready, available int32) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	desired = 1
+	if r.replicaSet.Spec.Replicas != nil {
+		desired = *r.replicaSet.Spec.Replicas
+	}
+	s := r.replicaSet.Status
+	return desired, s.ReadyReplicas, s.AvailableReplicas
+}
+
+// Deployment returns the name of the deployment controlling the replica set, or an empty string
+func (r *ReplicaSet) Deployment() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, ref := range r.replicaSet.OwnerReferences {
+		if ref.Kind == "Deployment" && ref.Controller != nil && *ref.Controller {
+			return ref.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Notification describes a pod that entered a bad phase
+type Notification struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Node      string    `json:"node"`
+	Phase     string    `json:"phase"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+	// Bundle is where the incident bundle was saved or uploaded, empty when none was captured
+	Bundle string `json:"bundle,omitempty"`
+}
+
+// Text renders the notification as a single human readable line
+func (n Notification) Text() string {
+	text := fmt.Sprintf("Pod %s/%s on %s entered %s: %s", n.Namespace, n.Pod, n.Node, n.Reason, n.Message)
+	if n.Bundle != "" {
+		text += " (incident bundle: " + n.Bundle + ")"
+	}
+	return text
+}
+
+// Notifier delivers notifications to an external system
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Multi delivers every notification to all of its notifiers
+type Multi []Notifier
+
+// Notify sends the notification to every notifier, returning the combined errors
+func (m Multi) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Webhook posts notifications as JSON to a generic HTTP endpoint
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a notifier posting to the given URL
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the notification as JSON
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, w.client, w.url, n)
+}
+
+// Slack posts notifications to a Slack incoming webhook
+type Slack struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlack creates a notifier posting to the given Slack incoming webhook URL
+func NewSlack(url string) *Slack {
+	return &Slack{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the notification as a Slack message
+func (s *Slack) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": ":rotating_light: " + n.Text()})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notify: %s returned %s", url, resp.Status)
+	}
+	return nil
+}
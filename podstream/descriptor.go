@@ -0,0 +1,106 @@
+package podstream
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	// Registers google/protobuf/timestamp.proto, which the file imports
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Names of the service, the method and the messages of podstream.proto
+const (
+	ServiceName = "podlogger.podstatus.v1.PodStatus"
+	MethodName  = "WatchPodStatuses"
+)
+
+// Descriptors of the request and update messages, built from fileDescriptor
+var (
+	requestDesc protoreflect.MessageDescriptor
+	updateDesc  protoreflect.MessageDescriptor
+)
+
+func init() {
+	file, err := protodesc.NewFile(fileDescriptor(), protoregistry.GlobalFiles)
+	if err != nil {
+		panic("podstream: invalid descriptor: " + err.Error())
+	}
+	requestDesc = file.Messages().ByName("WatchPodStatusesRequest")
+	updateDesc = file.Messages().ByName("PodStatusUpdate")
+}
+
+// fileDescriptor describes podstream.proto, there is no protoc in the build so it is written out by hand
+func fileDescriptor() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonName(name)),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("podstream/podstream.proto"),
+		Package:    proto.String("podlogger.podstatus.v1"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		Syntax:     proto.String("proto3"),
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("adv-go/podstream;podstream")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("WatchPodStatusesRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("namespace", 1, str, ""),
+					field("initial_state", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+				},
+			},
+			{
+				Name: proto.String("PodStatusUpdate"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("namespace", 1, str, ""),
+					field("pod", 2, str, ""),
+					field("node", 3, str, ""),
+					field("previous_state", 4, str, ""),
+					field("state", 5, str, ""),
+					field("observed_at", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("PodStatus"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:            proto.String(MethodName),
+				InputType:       proto.String(".podlogger.podstatus.v1.WatchPodStatusesRequest"),
+				OutputType:      proto.String(".podlogger.podstatus.v1.PodStatusUpdate"),
+				ServerStreaming: proto.Bool(true),
+			}},
+		}},
+	}
+}
+
+// jsonName is the lowerCamelCase JSON name protoc derives from a snake_case field name
+func jsonName(name string) string {
+	out := make([]byte, 0, len(name))
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		out = append(out, c)
+	}
+	return string(out)
+}
@@ -0,0 +1,231 @@
+// Package podstream serves the pod status transitions seen by the agent to gRPC subscribers
+package podstream
+
+import (
+	"adv-go/logging"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// streamLog logs subscribers coming and going
+var streamLog = logging.For("api")
+
+// subscriberBuffer is how many updates a slow subscriber may fall behind before updates are dropped for it
+const subscriberBuffer = 256
+
+// Update is a pod status transition
+type Update struct {
+	Namespace     string
+	Pod           string
+	Node          string
+	PreviousState string
+	State         string
+	ObservedAt    time.Time
+}
+
+// subscriber is a connected WatchPodStatuses stream
+type subscriber struct {
+	namespace string
+	updates   chan Update
+	// done is closed to end the stream, with the reason in err
+	done    chan struct{}
+	err     error
+	dropped atomic.Int64
+}
+
+// Hub fans the published updates out to the subscribers
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+	// accepting is false until the pod cache synced, new subscribers are turned away
+	accepting bool
+	// Current returns the current state of the pods of a namespace, empty for all, for subscribers asking for the
+	// initial state. Nil sends none
+	Current func(namespace string) []Update
+}
+
+// NewHub creates a hub without subscribers that accepts none until Accept is called
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*subscriber]bool)}
+}
+
+// Publish hands the update to every subscriber of its namespace without blocking, slow subscribers miss it
+func (h *Hub) Publish(u Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		if s.namespace != "" && s.namespace != u.Namespace {
+			continue
+		}
+		select {
+		case s.updates <- u:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Accept starts taking subscribers, called once the pod cache synced
+func (h *Hub) Accept() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.accepting = true
+}
+
+// Disconnect ends every stream with UNAVAILABLE and turns new subscribers away, so clients reconnect to another
+// replica
+func (h *Hub) Disconnect() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.accepting = false
+	for s := range h.subscribers {
+		s.err = status.Error(codes.Unavailable, "replica stopped streaming, reconnect")
+		close(s.done)
+		delete(h.subscribers, s)
+	}
+}
+
+// subscribe registers a subscriber for the namespace, empty for all
+func (h *Hub) subscribe(namespace string) (*subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.accepting {
+		return nil, status.Error(codes.Unavailable, "pod cache not synced yet")
+	}
+	s := &subscriber{namespace: namespace, updates: make(chan Update, subscriberBuffer), done: make(chan struct{})}
+	h.subscribers[s] = true
+	return s, nil
+}
+
+// unsubscribe removes a subscriber whose stream ended on the client's side
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, s)
+}
+
+// Subscription receives the updates of a namespace outside the gRPC service, such as for the dashboard
+type Subscription struct {
+	hub *Hub
+	s   *subscriber
+}
+
+// Subscribe registers a subscription for the namespace, empty for all, failing until the pod cache synced
+func (h *Hub) Subscribe(namespace string) (*Subscription, error) {
+	s, err := h.subscribe(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription{hub: h, s: s}, nil
+}
+
+// Updates delivers the published updates, those a slow reader falls behind on are dropped
+func (s *Subscription) Updates() <-chan Update {
+	return s.s.updates
+}
+
+// Done is closed once the hub disconnected the subscription
+func (s *Subscription) Done() <-chan struct{} {
+	return s.s.done
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s.s)
+}
+
+// Serve starts a gRPC server for the hub's PodStatus service on addr in the background, with options such as the
+// credentials and interceptors authenticating the subscribers
+func Serve(addr string, hub *Hub, opts ...grpc.ServerOption) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*any)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    MethodName,
+			Handler:       hub.watchPodStatuses,
+			ServerStreams: true,
+		}},
+		Metadata: "podstream/podstream.proto",
+	}, hub)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			streamLog.Error("gRPC server stopped", "err", err)
+		}
+	}()
+	streamLog.Info("Serving pod status stream", "addr", lis.Addr().String())
+	return nil
+}
+
+// watchPodStatuses implements the WatchPodStatuses server-streaming RPC
+func (h *Hub) watchPodStatuses(_ any, stream grpc.ServerStream) error {
+	req := dynamicpb.NewMessage(requestDesc)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	namespace := req.Get(requestDesc.Fields().ByName("namespace")).String()
+	initial := req.Get(requestDesc.Fields().ByName("initial_state")).Bool()
+
+	s, err := h.subscribe(namespace)
+	if err != nil {
+		return err
+	}
+	streamLog.Info("Pod status subscriber connected", "namespace", namespace, "initial_state", initial)
+	defer func() {
+		h.unsubscribe(s)
+		streamLog.Info("Pod status subscriber disconnected", "namespace", namespace, "dropped", s.dropped.Load())
+	}()
+
+	// Subscribing first means transitions during the initial state are queued rather than missed
+	if initial && h.Current != nil {
+		for _, u := range h.Current(namespace) {
+			if err := stream.SendMsg(encode(u)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+		case u := <-s.updates:
+			if err := stream.SendMsg(encode(u)); err != nil {
+				return err
+			}
+		case <-s.done:
+			return s.err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// encode converts an update to a PodStatusUpdate message
+func encode(u Update) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(updateDesc)
+	fields := updateDesc.Fields()
+	set := func(name, v string) {
+		if v != "" {
+			m.Set(fields.ByName(protoreflect.Name(name)), protoreflect.ValueOfString(v))
+		}
+	}
+	set("namespace", u.Namespace)
+	set("pod", u.Pod)
+	set("node", u.Node)
+	set("previous_state", u.PreviousState)
+	set("state", u.State)
+	m.Set(fields.ByName("observed_at"), protoreflect.ValueOfMessage(timestamppb.New(u.ObservedAt).ProtoReflect()))
+	return m
+}
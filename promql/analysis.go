@@ -0,0 +1,36 @@
+package promql
+
+import (
+	"adv-go/analysis"
+	"context"
+)
+
+// AnalysisMetric is a canary analysis hook backed by a templated PromQL query
+type AnalysisMetric struct {
+	client   *Client
+	template *Template
+}
+
+// NewAnalysisMetric creates an analysis metric, the query can reference {{.Namespace}}, {{.Workload}},
+// {{.Role}} and {{.Revision}} of the revision being measured
+func NewAnalysisMetric(client *Client, name, query string) (*AnalysisMetric, error) {
+	t, err := NewTemplate(name, query)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalysisMetric{client: client, template: t}, nil
+}
+
+// Name returns the name the metric is reported under
+func (m *AnalysisMetric) Name() string {
+	return m.template.Name()
+}
+
+// Value runs the query for the given revision
+func (m *AnalysisMetric) Value(ctx context.Context, target analysis.Target) (float64, error) {
+	query, err := m.template.Render(target)
+	if err != nil {
+		return 0, err
+	}
+	return m.client.Query(ctx, query)
+}
@@ -0,0 +1,83 @@
+package promql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// Client runs instant PromQL queries against a Prometheus server
+type Client struct {
+	api promv1.API
+}
+
+// NewClient creates a client for the Prometheus server at the given URL
+func NewClient(url string) (*Client, error) {
+	c, err := api.NewClient(api.Config{Address: url})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{api: promv1.NewAPI(c)}, nil
+}
+
+// Query evaluates the query now and returns its value, the query must yield a scalar or a single sample
+func (c *Client) Query(ctx context.Context, query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	value, warnings, err := c.api.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for _, w := range warnings {
+		log.Printf("Prometheus warning for %q: %s", query, w)
+	}
+
+	switch v := value.(type) {
+	case *model.Scalar:
+		return float64(v.Value), nil
+	case model.Vector:
+		if len(v) != 1 {
+			return 0, fmt.Errorf("query %q returned %d samples, expected exactly one", query, len(v))
+		}
+		return float64(v[0].Value), nil
+	default:
+		return 0, fmt.Errorf("query %q returned a %s, expected a scalar or a single sample", query, value.Type())
+	}
+}
+
+// Template is a PromQL query rendered with Go template fields before it is run
+type Template struct {
+	name  string
+	query *template.Template
+}
+
+// NewTemplate parses a query that may reference fields of the data it is rendered with, e.g. {{.Namespace}}
+func NewTemplate(name, query string) (*Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("parsing query %s: %w", name, err)
+	}
+	return &Template{name: name, query: t}, nil
+}
+
+// Name returns the name of the query
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Render returns the query with data substituted
+func (t *Template) Render(data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.query.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,177 @@
+package remediation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBudgetExceeded is returned when an action would exceed the global or namespace budget
+	ErrBudgetExceeded = errors.New("remediation budget exceeded")
+	// ErrCircuitOpen is returned while the circuit breaker is halting all actions
+	ErrCircuitOpen = errors.New("remediation circuit breaker open")
+)
+
+// Action is a single remediation step against an object in a namespace
+type Action struct {
+	Name      string
+	Namespace string
+	Run       func(ctx context.Context) error
+}
+
+// Config holds the budgets and circuit breaker settings for an Executor
+type Config struct {
+	// Window is the period the budgets apply to
+	Window time.Duration
+	// GlobalBudget is the max number of actions across all namespaces per window, 0 disables the limit
+	GlobalBudget int
+	// NamespaceBudget is the max number of actions per namespace per window, 0 disables the limit
+	NamespaceBudget int
+	// ErrorThreshold is the failure ratio (0-1) that trips the breaker
+	ErrorThreshold float64
+	// MinSamples is the number of recent results needed before the breaker can trip
+	MinSamples int
+	// Cooldown is how long the breaker stays open before allowing a trial action
+	Cooldown time.Duration
+}
+
+// DefaultConfig returns conservative budgets: 10 actions/hour globally, 3 per namespace
+func DefaultConfig() Config {
+	return Config{
+		Window:          time.Hour,
+		GlobalBudget:    10,
+		NamespaceBudget: 3,
+		ErrorThreshold:  0.5,
+		MinSamples:      4,
+		Cooldown:        15 * time.Minute,
+	}
+}
+
+type result struct {
+	at     time.Time
+	failed bool
+}
+
+// Executor runs remediation actions while enforcing budgets and a circuit breaker
+type Executor struct {
+	mu        sync.Mutex
+	cfg       Config
+	now       func() time.Time
+	global    []time.Time
+	namespace map[string][]time.Time
+	results   []result
+	openUntil time.Time
+	halfOpen  bool
+}
+
+// NewExecutor creates an Executor with the provided configuration
+func NewExecutor(cfg Config) *Executor {
+	return &Executor{
+		cfg:       cfg,
+		now:       time.Now,
+		namespace: make(map[string][]time.Time),
+	}
+}
+
+// Execute runs the action if the budgets and the circuit breaker allow it
+func (e *Executor) Execute(ctx context.Context, a Action) error {
+	if err := e.reserve(a.Namespace); err != nil {
+		return fmt.Errorf("action %s in %s: %w", a.Name, a.Namespace, err)
+	}
+
+	err := a.Run(ctx)
+	e.record(err != nil)
+	return err
+}
+
+// CircuitOpen reports whether the breaker is currently halting actions
+func (e *Executor) CircuitOpen() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.now().Before(e.openUntil)
+}
+
+// reserve checks the breaker and budgets and, if allowed, counts the action against them
+func (e *Executor) reserve(namespace string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if now.Before(e.openUntil) || e.halfOpen {
+		return ErrCircuitOpen
+	}
+	if !e.openUntil.IsZero() {
+		// Cooldown elapsed, let a single trial action through
+		e.halfOpen = true
+	}
+
+	cutoff := now.Add(-e.cfg.Window)
+	e.global = prune(e.global, cutoff)
+	e.namespace[namespace] = prune(e.namespace[namespace], cutoff)
+
+	if e.cfg.GlobalBudget > 0 && len(e.global) >= e.cfg.GlobalBudget {
+		e.halfOpen = false
+		return ErrBudgetExceeded
+	}
+	if e.cfg.NamespaceBudget > 0 && len(e.namespace[namespace]) >= e.cfg.NamespaceBudget {
+		e.halfOpen = false
+		return ErrBudgetExceeded
+	}
+
+	e.global = append(e.global, now)
+	e.namespace[namespace] = append(e.namespace[namespace], now)
+	return nil
+}
+
+// record stores the outcome of an action and trips the breaker if the error rate spikes
+func (e *Executor) record(failed bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if e.halfOpen {
+		e.halfOpen = false
+		if failed {
+			e.openUntil = now.Add(e.cfg.Cooldown)
+			return
+		}
+		// Trial succeeded, close the breaker with a clean history
+		e.openUntil = time.Time{}
+		e.results = nil
+	}
+
+	cutoff := now.Add(-e.cfg.Window)
+	kept := e.results[:0]
+	for _, r := range e.results {
+		if r.at.After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	e.results = append(kept, result{at: now, failed: failed})
+
+	if len(e.results) < e.cfg.MinSamples {
+		return
+	}
+	failures := 0
+	for _, r := range e.results {
+		if r.failed {
+			failures++
+		}
+	}
+	if float64(failures)/float64(len(e.results)) >= e.cfg.ErrorThreshold {
+		e.openUntil = now.Add(e.cfg.Cooldown)
+		e.results = nil
+	}
+}
+
+// prune drops timestamps at or before the cutoff
+func prune(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
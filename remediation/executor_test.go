@@ -0,0 +1,110 @@
+package remediation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testExecutor returns an executor on a clock the test advances
+func testExecutor(cfg Config) (*Executor, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := NewExecutor(cfg)
+	e.now = func() time.Time { return now }
+	return e, &now
+}
+
+func action(namespace string, err error) Action {
+	return Action{Name: "delete pod", Namespace: namespace, Run: func(ctx context.Context) error { return err }}
+}
+
+func TestBudgets(t *testing.T) {
+	e, now := testExecutor(Config{Window: time.Hour, GlobalBudget: 3, NamespaceBudget: 2, ErrorThreshold: 1, MinSamples: 10})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := e.Execute(ctx, action("shop", nil)); err != nil {
+			t.Fatalf("action %d in shop: %v", i, err)
+		}
+	}
+	if err := e.Execute(ctx, action("shop", nil)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("third action in shop = %v, want the namespace budget exceeded", err)
+	}
+	if err := e.Execute(ctx, action("payments", nil)); err != nil {
+		t.Fatalf("action in payments: %v", err)
+	}
+	if err := e.Execute(ctx, action("billing", nil)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("fourth action = %v, want the global budget exceeded", err)
+	}
+
+	// Refused actions count against no budget, and the budgets refill once the window passed
+	*now = now.Add(time.Hour)
+	for _, namespace := range []string{"shop", "shop", "billing"} {
+		if err := e.Execute(ctx, action(namespace, nil)); err != nil {
+			t.Fatalf("action in %s after the window: %v", namespace, err)
+		}
+	}
+}
+
+func TestUnlimitedBudgets(t *testing.T) {
+	e, _ := testExecutor(Config{Window: time.Hour, ErrorThreshold: 1, MinSamples: 1000})
+	for i := 0; i < 100; i++ {
+		if err := e.Execute(context.Background(), action("shop", nil)); err != nil {
+			t.Fatalf("action %d: %v", i, err)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	e, now := testExecutor(Config{Window: time.Hour, ErrorThreshold: 0.5, MinSamples: 4, Cooldown: 15 * time.Minute})
+	ctx := context.Background()
+	failure := errors.New("delete failed")
+
+	// Two failures of four trip the breaker
+	for i, err := range []error{nil, failure, nil, failure} {
+		if got := e.Execute(ctx, action("shop", err)); got != err {
+			t.Fatalf("action %d = %v, want %v", i, got, err)
+		}
+	}
+	if !e.CircuitOpen() {
+		t.Fatal("breaker closed after half of the actions failed")
+	}
+	if err := e.Execute(ctx, action("payments", nil)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("action while open = %v, want the circuit open", err)
+	}
+
+	// After the cooldown a failed trial opens it again
+	*now = now.Add(15 * time.Minute)
+	if err := e.Execute(ctx, action("shop", failure)); err != failure {
+		t.Fatalf("trial action = %v, want its failure", err)
+	}
+	if err := e.Execute(ctx, action("shop", nil)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("action after a failed trial = %v, want the circuit open", err)
+	}
+
+	// A successful trial closes it with a clean history
+	*now = now.Add(15 * time.Minute)
+	if err := e.Execute(ctx, action("shop", nil)); err != nil {
+		t.Fatalf("trial action: %v", err)
+	}
+	if e.CircuitOpen() {
+		t.Fatal("breaker still open after a successful trial")
+	}
+	if err := e.Execute(ctx, action("shop", failure)); err != failure {
+		t.Fatalf("action after the trial = %v, want its failure", err)
+	}
+	if e.CircuitOpen() {
+		t.Fatal("a single failure after the trial tripped the breaker")
+	}
+}
+
+func TestBreakerNeedsMinSamples(t *testing.T) {
+	e, _ := testExecutor(Config{Window: time.Hour, ErrorThreshold: 0.5, MinSamples: 4, Cooldown: time.Minute})
+	for i := 0; i < 3; i++ {
+		e.Execute(context.Background(), action("shop", errors.New("delete failed")))
+	}
+	if e.CircuitOpen() {
+		t.Fatal("breaker tripped on fewer results than MinSamples")
+	}
+}
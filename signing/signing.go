@@ -0,0 +1,160 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File suffixes of the checksum and signature written next to an artifact
+const (
+	ChecksumSuffix  = ".sha256"
+	SignatureSuffix = ".sig"
+)
+
+// Sidecar is a file written or uploaded next to an artifact, named after it plus the suffix
+type Sidecar struct {
+	Suffix string
+	Data   []byte
+}
+
+// Signer signs artifacts with an ed25519 private key
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// LoadSigner reads a PEM encoded PKCS#8 ed25519 private key, as written by GenerateKey or
+// "openssl genpkey -algorithm ed25519"
+func LoadSigner(path string) (*Signer, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("signing: parsing %s: %w", path, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing: %s is not an ed25519 key", path)
+	}
+	return &Signer{key: edKey}, nil
+}
+
+// Sign returns the base64 encoded signature of the data
+func (s *Signer) Sign(data []byte) []byte {
+	sig := ed25519.Sign(s.key, data)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// LoadPublicKey reads a PEM encoded PKIX ed25519 public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("signing: parsing %s: %w", path, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("signing: %s is not an ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// GenerateKey writes a new key pair as <prefix>.key and <prefix>.pub
+func GenerateKey(prefix string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(prefix+".key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(prefix+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644)
+}
+
+// Checksum returns the hex encoded SHA-256 of the data
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sidecars returns the checksum of the artifact in sha256sum format and, when signer is set, its signature
+func Sidecars(name string, data []byte, signer *Signer) []Sidecar {
+	sidecars := []Sidecar{{
+		Suffix: ChecksumSuffix,
+		Data:   []byte(Checksum(data) + "  " + filepath.Base(name) + "\n"),
+	}}
+	if signer != nil {
+		sidecars = append(sidecars, Sidecar{Suffix: SignatureSuffix, Data: signer.Sign(data)})
+	}
+	return sidecars
+}
+
+// WriteSidecars writes the sidecars of the artifact at path next to it
+func WriteSidecars(path string, data []byte, signer *Signer) error {
+	for _, s := range Sidecars(path, data, signer) {
+		if err := os.WriteFile(path+s.Suffix, s.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyChecksum checks the data against a checksum in sha256sum format
+func VerifyChecksum(data, checksum []byte) error {
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 {
+		return errors.New("signing: empty checksum")
+	}
+	if got := Checksum(data); got != strings.ToLower(fields[0]) {
+		return fmt.Errorf("signing: checksum mismatch, got %s want %s", got, fields[0])
+	}
+	return nil
+}
+
+// VerifySignature checks a base64 encoded signature of the data
+func VerifySignature(pub ed25519.PublicKey, data, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil {
+		return fmt.Errorf("signing: decoding signature: %w", err)
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return errors.New("signing: signature does not match")
+	}
+	return nil
+}
+
+// readPEM returns the DER bytes of the first PEM block of the given type in the file
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("signing: %s has no %s PEM block", path, blockType)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,170 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ObjectStore stores objects in a bucket
+type ObjectStore interface {
+	// Put creates or replaces the object at key
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// ArchiveOptions configures an Archive
+type ArchiveOptions struct {
+	// URL is the bucket and prefix segments are uploaded to, s3://bucket/prefix or gs://bucket/prefix
+	URL string
+	// Dir holds the segments until they are uploaded
+	Dir string
+	// Interval closes and uploads the current segment this often
+	Interval time.Duration
+	// MaxSize closes a segment early once it grows beyond this many bytes, 0 disables
+	MaxSize int64
+}
+
+// Archive writes records to segment files in a local directory and uploads every closed segment, gzipped, to a
+// bucket, for clusters whose local storage does not outlive the pod. Segments that fail to upload stay in the
+// directory and are retried with the next ones, including those a previous run left behind
+type Archive struct {
+	file     *File
+	store    ObjectStore
+	prefix   string
+	interval time.Duration
+	// host keeps the objects of replicas apart, the pod name in a cluster
+	host string
+
+	uploading sync.Mutex
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// NewArchive opens the bucket and the segment directory and starts uploading
+func NewArchive(opts ArchiveOptions) (*Archive, error) {
+	if opts.Interval <= 0 {
+		return nil, errors.New("archive sink requires a positive upload interval")
+	}
+	store, prefix, err := OpenObjectStore(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "pod-logger-archive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := NewFile(filepath.Join(dir, "pod_status.log"), Rotation{MaxSize: opts.MaxSize})
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	a := &Archive{
+		file:     file,
+		store:    store,
+		prefix:   prefix,
+		interval: opts.Interval,
+		host:     host,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+// Write appends the record to the current segment
+func (a *Archive) Write(record []byte) error {
+	return a.file.Write(record)
+}
+
+// WriteBatch appends the records to the current segment
+func (a *Archive) WriteBatch(records [][]byte) error {
+	return a.file.WriteBatch(records)
+}
+
+// Close uploads the current segment and whatever is still pending, then closes the segment file
+func (a *Archive) Close() error {
+	close(a.done)
+	<-a.stopped
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := a.flush(ctx)
+	return errors.Join(err, a.file.Close())
+}
+
+// run closes and uploads a segment every interval
+func (a *Archive) run() {
+	defer close(a.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-a.done
+		cancel()
+	}()
+
+	// Segments left by a previous run go first
+	if err := a.upload(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Error uploading archived segments: %v", err)
+	}
+	tick := time.NewTicker(a.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-tick.C:
+			if err := a.flush(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error uploading archived segments: %v", err)
+			}
+		}
+	}
+}
+
+// flush closes the current segment unless it is empty and uploads the closed ones
+func (a *Archive) flush(ctx context.Context) error {
+	if err := a.file.rotateIfWritten(); err != nil {
+		return err
+	}
+	return a.upload(ctx)
+}
+
+// upload uploads the closed segments oldest first, removing each once it is stored
+func (a *Archive) upload(ctx context.Context) error {
+	a.uploading.Lock()
+	defer a.uploading.Unlock()
+	segments, err := a.file.backups()
+	if err != nil {
+		return err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i].path
+		data, err := os.ReadFile(seg)
+		if err != nil {
+			return err
+		}
+		var body bytes.Buffer
+		zw := gzip.NewWriter(&body)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		key := path.Join(a.prefix, a.host, filepath.Base(seg)+".gz")
+		if err := a.store.Put(ctx, key, body.Bytes(), "application/gzip"); err != nil {
+			// Later segments wait, so the bucket never has gaps that fill in out of order
+			return err
+		}
+		if err := os.Remove(seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
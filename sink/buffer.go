@@ -0,0 +1,180 @@
+package sink
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Policies of a full Buffered sink
+const (
+	// BufferDropOldest drops the oldest buffered record to make room for the new one
+	BufferDropOldest = "drop-oldest"
+	// BufferBlock makes the writer wait until the flush made room
+	BufferBlock = "block"
+)
+
+var (
+	// bufferedRecords is the number of records waiting in each sink's buffer
+	bufferedRecords = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_sink_buffer_depth",
+		Help: "Number of records buffered for each sink.",
+	}, []string{"sink"})
+
+	// bufferDropped counts the records a full buffer dropped with the drop-oldest policy
+	bufferDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_sink_buffer_dropped_total",
+		Help: "Number of records dropped because the sink's buffer was full, by sink.",
+	}, []string{"sink"})
+
+	// bufferBlocked sums the time writers waited on a full buffer with the block policy
+	bufferBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_sink_buffer_blocked_seconds_total",
+		Help: "Seconds writers waited for room in the sink's full buffer, by sink.",
+	}, []string{"sink"})
+)
+
+// BufferOptions configure the buffer in front of a sink
+type BufferOptions struct {
+	// Size is how many records the buffer holds, 0 writes to the sink synchronously
+	Size int
+	// BatchSize flushes as soon as this many records are buffered, 0 only flushes on the interval
+	BatchSize int
+	// FlushInterval flushes what is buffered at least this often
+	FlushInterval time.Duration
+	// Policy is BufferDropOldest or BufferBlock
+	Policy string
+}
+
+// Buffered holds the records for one sink and writes them in batches in the background, once BatchSize records are
+// buffered or FlushInterval passed, so a slow sink holds up neither the reporting passes nor the other sinks. A full
+// buffer drops its oldest record or blocks the writer, by Policy. Write errors are logged rather than returned
+type Buffered struct {
+	name string
+	next Sink
+	opts BufferOptions
+
+	mu      sync.Mutex
+	room    *sync.Cond
+	queue   [][]byte
+	closing bool
+	kick    chan struct{}
+	closed  chan struct{}
+	done    chan struct{}
+}
+
+// Buffer wraps the named sink with a buffer
+func Buffer(name string, s Sink, opts BufferOptions) *Buffered {
+	b := &Buffered{
+		name:   name,
+		next:   s,
+		opts:   opts,
+		kick:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	b.room = sync.NewCond(&b.mu)
+	go b.run()
+	return b
+}
+
+// Write buffers the record
+func (b *Buffered) Write(record []byte) error {
+	return b.WriteBatch([][]byte{record})
+}
+
+// WriteBatch buffers the records, dropping the oldest or waiting for room when the buffer is full
+func (b *Buffered) WriteBatch(records [][]byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, r := range records {
+		if len(b.queue) >= b.opts.Size {
+			if b.opts.Policy == BufferBlock {
+				b.wait()
+			} else {
+				b.queue = b.queue[1:]
+				bufferDropped.WithLabelValues(b.name).Inc()
+			}
+		}
+		if b.closing {
+			// Close stopped waiting for the flushes, the record would never be written
+			bufferDropped.WithLabelValues(b.name).Inc()
+			continue
+		}
+		b.queue = append(b.queue, r)
+	}
+	bufferedRecords.WithLabelValues(b.name).Set(float64(len(b.queue)))
+	if b.opts.BatchSize > 0 && len(b.queue) >= b.opts.BatchSize {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// wait blocks until a flush made room in the buffer or it is closed. The caller holds mu
+func (b *Buffered) wait() {
+	started := time.Now()
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+	for len(b.queue) >= b.opts.Size && !b.closing {
+		b.room.Wait()
+	}
+	bufferBlocked.WithLabelValues(b.name).Add(time.Since(started).Seconds())
+}
+
+// run flushes the buffer when it is kicked or the interval passed, until it is closed and flushed a last time
+func (b *Buffered) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.kick:
+		case <-ticker.C:
+		case <-b.closed:
+			b.flush()
+			return
+		}
+		b.flush()
+	}
+}
+
+// flush writes everything buffered to the sink as one batch
+func (b *Buffered) flush() {
+	b.mu.Lock()
+	records := b.queue
+	b.queue = nil
+	bufferedRecords.WithLabelValues(b.name).Set(0)
+	b.room.Broadcast()
+	b.mu.Unlock()
+	if len(records) == 0 {
+		return
+	}
+	if err := WriteBatch(b.next, records); err != nil {
+		log.Printf("Error writing %d buffered records to the %s sink: %v", len(records), b.name, err)
+	}
+}
+
+// Close flushes what is buffered, waiting up to closeTimeout, and closes the sink
+func (b *Buffered) Close() error {
+	b.mu.Lock()
+	if !b.closing {
+		b.closing = true
+		close(b.closed)
+	}
+	b.room.Broadcast()
+	b.mu.Unlock()
+	select {
+	case <-b.done:
+	case <-time.After(closeTimeout):
+		log.Printf("Buffer of the %s sink not flushed after %s, closing anyway", b.name, closeTimeout)
+	}
+	return b.next.Close()
+}
@@ -0,0 +1,203 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is used in rotated file names, it sorts lexically in time order
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotationRetry is how long a file keeps being written after a failed rotation before the rotation is tried again
+const rotationRetry = time.Minute
+
+// rename moves the active file to its backup name, tests make it fail
+var rename = os.Rename
+
+// Rotation configures when a file sink rotates and how many old files are kept
+type Rotation struct {
+	// MaxSize rotates the file once it grows beyond this many bytes, 0 disables size based rotation
+	MaxSize int64
+	// Interval rotates the file once it has been open this long, 0 disables time based rotation
+	Interval time.Duration
+	// MaxBackups is the number of rotated files to keep, 0 keeps all of them
+	MaxBackups int
+	// MaxAge removes rotated files older than this, 0 keeps them regardless of age
+	MaxAge time.Duration
+}
+
+// File appends records as lines to a file on disk, rotating it according to its Rotation
+type File struct {
+	mu       sync.Mutex
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+	opened   time.Time
+	// retryRotation holds off rotating again until then after a rotation failed
+	retryRotation time.Time
+}
+
+// NewFile opens or creates the file for appending
+func NewFile(path string, rotation Rotation) (*File, error) {
+	f := &File{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends the record followed by a newline, rotating first if the file is due
+func (f *File) Write(record []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.write(line(record))
+}
+
+// WriteBatch appends all records with a single write, rotating first if the file is due
+func (f *File) WriteBatch(records [][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.write(lines(records))
+}
+
+// write appends the already terminated lines, the caller holds the lock. A failed rotation does not drop them,
+// they go to the current file and the rotation is retried after rotationRetry
+func (f *File) write(l []byte) error {
+	if f.due(int64(len(l))) {
+		if err := f.rotate(); err != nil {
+			log.Printf("Error rotating the log file, retrying in %s: %v", rotationRetry, err)
+			f.retryRotation = time.Now().Add(rotationRetry)
+		}
+	}
+	n, err := f.file.Write(l)
+	f.size += int64(n)
+	return err
+}
+
+// Close closes the underlying file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// open opens the active file, tracking its current size
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// due reports whether writing n more bytes requires a rotation
+func (f *File) due(n int64) bool {
+	if time.Now().Before(f.retryRotation) {
+		return false
+	}
+	if f.rotation.MaxSize > 0 && f.size > 0 && f.size+n > f.rotation.MaxSize {
+		return true
+	}
+	return f.rotation.Interval > 0 && time.Since(f.opened) >= f.rotation.Interval
+}
+
+// rotate renames the active file to a timestamped backup, reopens it and applies retention. The old file is closed
+// only once the new one is open, so after a failed rename or open f.file is still the open old file
+func (f *File) rotate() error {
+	old := f.file
+	if err := rename(f.path, f.backupName(time.Now())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		log.Printf("Error closing the rotated log file: %v", err)
+	}
+	if err := f.prune(); err != nil {
+		log.Printf("Error removing old log files: %v", err)
+	}
+	return nil
+}
+
+// backupName returns the rotated file name for the given time, e.g. pod_status-2006-01-02T15-04-05.000.log
+func (f *File) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), t.UTC().Format(backupTimeFormat), ext)
+}
+
+// rotateIfWritten rotates the active file unless nothing was written to it since it was opened
+func (f *File) rotateIfWritten() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size == 0 {
+		return nil
+	}
+	return f.rotate()
+}
+
+// backup is a rotated file and when it was rotated
+type backup struct {
+	path string
+	at   time.Time
+}
+
+// backups lists the rotated files, newest first
+func (f *File) backups() ([]backup, error) {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, m := range matches {
+		at, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(m, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: m, at: at})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+	return backups, nil
+}
+
+// prune removes the backups that exceed MaxBackups or MaxAge
+func (f *File) prune() error {
+	if f.rotation.MaxBackups <= 0 && f.rotation.MaxAge <= 0 {
+		return nil
+	}
+	// Newest first so the backups to keep are at the front
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, b := range backups {
+		tooMany := f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups
+		tooOld := f.rotation.MaxAge > 0 && time.Since(b.at) > f.rotation.MaxAge
+		if tooMany || tooOld {
+			if err := os.Remove(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,59 @@
+package sink
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileRotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pod_status.log")
+	f, err := NewFile(path, Rotation{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	renames := 0
+	rename = func(oldpath, newpath string) error {
+		renames++
+		return errors.New("read-only file system")
+	}
+	defer func() { rename = os.Rename }()
+
+	// The rotation fails and the records still land in the active file, without a rotation per record
+	for _, record := range []string{"first record", "second record", "third record"} {
+		if err := f.Write([]byte(record)); err != nil {
+			t.Fatalf("writing %q: %v", record, err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first record\nsecond record\nthird record\n"; string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+	if renames != 1 {
+		t.Errorf("rotation tried %d times, want once until the retry is due", renames)
+	}
+
+	// Once the retry is due the rotation goes ahead
+	rename = os.Rename
+	f.retryRotation = time.Time{}
+	if err := f.Write([]byte("fourth record")); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("%d backups after the retried rotation, want 1", len(backups))
+	}
+	if data, _ := os.ReadFile(path); string(data) != "fourth record\n" {
+		t.Errorf("file after the rotation = %q, want only the fourth record", data)
+	}
+}
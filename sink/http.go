@@ -0,0 +1,302 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// SyncHeader marks the bodies of the delta mode: SyncFull for a body carrying every pod, which replaces what the
+// receiver knows, SyncDelta for one carrying only the pods that changed
+const (
+	SyncHeader = "X-Podlogger-Sync"
+	SyncFull   = "full"
+	SyncDelta  = "delta"
+)
+
+var (
+	// httpSentBytes counts the bytes of the request bodies the http sink posted
+	httpSentBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "podlogger_http_sink_sent_bytes_total",
+		Help: "Number of request body bytes the http sink posted, after compression.",
+	})
+
+	// httpUnchanged counts the pod records the delta mode left out
+	httpUnchanged = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "podlogger_http_sink_unchanged_records_total",
+		Help: "Number of pod records the http sink's delta mode did not post because they were unchanged since the last successful POST.",
+	})
+)
+
+// HTTPOptions configures the HTTP sink
+type HTTPOptions struct {
+	URL string
+	// Gzip compresses the request bodies
+	Gzip bool
+	// Delta posts each batch as one body of lines, leaving out the pod records unchanged since the last successful
+	// POST
+	Delta bool
+	// ResyncInterval posts every pod again this often in the delta mode
+	ResyncInterval time.Duration
+}
+
+// HTTP posts each record to an HTTP endpoint, or in the delta mode each batch of records with only the pods that
+// changed, see delta
+type HTTP struct {
+	url    string
+	client *http.Client
+	gzip   bool
+	delta  *delta
+}
+
+// NewHTTP creates a sink posting to the given URL
+func NewHTTP(opts HTTPOptions) *HTTP {
+	h := &HTTP{
+		url:    opts.URL,
+		client: &http.Client{Timeout: 10 * time.Second},
+		gzip:   opts.Gzip,
+	}
+	if opts.Delta {
+		h.delta = newDelta(opts.ResyncInterval)
+	}
+	return h
+}
+
+// Write posts the record as the request body
+func (h *HTTP) Write(record []byte) error {
+	if h.delta != nil {
+		return h.writeDelta([][]byte{record})
+	}
+	return h.post(record, "")
+}
+
+// WriteBatch posts the records one by one, or in the delta mode what changed of them as one body
+func (h *HTTP) WriteBatch(records [][]byte) error {
+	if h.delta != nil {
+		return h.writeDelta(records)
+	}
+	var errs []error
+	for _, r := range records {
+		if err := h.post(r, ""); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeDelta posts the batch's records less the unchanged pod records, or every pod when a resync is due. Nothing
+// is posted when nothing changed
+func (h *HTTP) writeDelta(records [][]byte) error {
+	d := h.delta
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	body, posted, full := d.changes(records, now)
+	if len(body) == 0 {
+		return nil
+	}
+	marker := SyncDelta
+	if full {
+		marker = SyncFull
+	}
+	if err := h.post(lines(body), marker); err != nil {
+		// The receiver may have missed changes and deletions, so it gets every pod again
+		d.resync = true
+		return err
+	}
+	d.acknowledge(posted, full, now)
+	return nil
+}
+
+// post sends the body, compressed when configured, marked with the sync header in the delta mode
+func (h *HTTP) post(body []byte, marker string) error {
+	if h.gzip {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = compressed.Bytes()
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if h.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if marker != "" {
+		req.Header.Set(SyncHeader, marker)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("http sink: %s returned %s", h.url, resp.Status)
+	}
+	httpSentBytes.Add(float64(len(body)))
+	return nil
+}
+
+// Close releases idle connections
+func (h *HTTP) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
+
+// delta remembers each pod's latest record and what was last posted of it. Pod records are the JSON ones, keyed by
+// namespace/name. They are compared without their IDs and transition, so a pod restated unchanged is left out.
+// Other records, such as events, are always posted
+type delta struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// latest is each pod's latest record, posted or not
+	latest map[string]deltaRecord
+	// sent is what was last posted of each pod's record
+	sent map[string]string
+	// resync posts every pod with the next body: at first, after a failed POST and every interval
+	resync     bool
+	lastResync time.Time
+}
+
+// deltaRecord is a pod's latest record, with what is compared of it and when it was written
+type deltaRecord struct {
+	record  []byte
+	content string
+	written time.Time
+}
+
+// deltaPosted is a pod record in a body, with what is compared of it
+type deltaPosted struct {
+	key, content string
+	deleted      bool
+}
+
+func newDelta(interval time.Duration) *delta {
+	return &delta{
+		interval: interval,
+		latest:   make(map[string]deltaRecord),
+		sent:     make(map[string]string),
+		resync:   true,
+	}
+}
+
+// changes returns the records to post, the pod records among them and whether they are every pod. The caller holds
+// mu
+func (d *delta) changes(records [][]byte, now time.Time) ([][]byte, []deltaPosted, bool) {
+	full := d.resync || d.interval > 0 && now.Sub(d.lastResync) >= d.interval
+	var body [][]byte
+	var posted []deltaPosted
+	for _, r := range records {
+		key, content, deleted, ok := podRecordKey(r)
+		if !ok {
+			body = append(body, r)
+			continue
+		}
+		if deleted {
+			delete(d.latest, key)
+			body = append(body, r)
+			posted = append(posted, deltaPosted{key: key, deleted: true})
+			continue
+		}
+		d.latest[key] = deltaRecord{record: r, content: content, written: now}
+		if !full && d.sent[key] == content {
+			httpUnchanged.Inc()
+			continue
+		}
+		if !full {
+			body = append(body, r)
+			posted = append(posted, deltaPosted{key: key, content: content})
+		}
+	}
+	if !full {
+		return body, posted, false
+	}
+	// The records of every pod go first, then the batch's other records. Pods not written since the previous resync
+	// are gone, the agent writes no deletion record of them when it writes every pod on each pass
+	keys := make([]string, 0, len(d.latest))
+	for key, r := range d.latest {
+		if r.written.Before(d.lastResync) {
+			delete(d.latest, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pods := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		pods = append(pods, d.latest[key].record)
+	}
+	return append(pods, body...), posted, true
+}
+
+// acknowledge records what a successful POST sent, a full one sent every pod of latest. The caller holds mu
+func (d *delta) acknowledge(posted []deltaPosted, full bool, now time.Time) {
+	if full {
+		d.sent = make(map[string]string, len(d.latest))
+		for key, r := range d.latest {
+			d.sent[key] = r.content
+		}
+		d.resync = false
+		d.lastResync = now
+		return
+	}
+	for _, p := range posted {
+		if p.deleted {
+			delete(d.sent, p.key)
+		} else {
+			d.sent[p.key] = p.content
+		}
+	}
+}
+
+// podRecordKey returns the namespace/name of the pod a record is about and what the delta mode compares of it,
+// without the IDs and the transition, false when the record is not about a pod. Pod records are JSON records, the
+// text ones name no namespace, and the text deletion records, which do
+func podRecordKey(record []byte) (key, content string, deleted, ok bool) {
+	text := string(record)
+	for strings.HasPrefix(text, "Seq: ") || strings.HasPrefix(text, "ID: ") {
+		_, text, _ = strings.Cut(text, ", ")
+	}
+	if strings.HasPrefix(text, "{") {
+		var r struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			Phase     string `json:"phase"`
+		}
+		var object map[string]any
+		if json.Unmarshal([]byte(text), &r) != nil || json.Unmarshal([]byte(text), &object) != nil || r.Name == "" {
+			return "", "", false, false
+		}
+		if fields, ok := object["fields"].(map[string]any); ok {
+			delete(fields, "Transition")
+		}
+		// Marshaling a map sorts its keys, so a pod restated unchanged compares equal to its transition's record
+		normalized, err := json.Marshal(object)
+		if err != nil {
+			return "", "", false, false
+		}
+		return r.Namespace + "/" + r.Name, string(normalized), r.Phase == "Deleted", true
+	}
+	values := fieldValues(text)
+	if !strings.HasPrefix(text, "Pod Name: ") || values["Phase"] != "Deleted" || values["Namespace"] == "" {
+		return "", "", false, false
+	}
+	return values["Namespace"] + "/" + values["Pod Name"], "", true, true
+}
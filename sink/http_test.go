@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver records the bodies and sync markers the http sink posts
+type receiver struct {
+	mu     sync.Mutex
+	bodies []string
+	syncs  []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	r.syncs = append(r.syncs, req.Header.Get(SyncHeader))
+}
+
+func (r *receiver) last() (string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.bodies) == 0 {
+		return "", ""
+	}
+	return r.bodies[len(r.bodies)-1], r.syncs[len(r.syncs)-1]
+}
+
+func (r *receiver) posts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+func TestHTTPDelta(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	h := NewHTTP(HTTPOptions{URL: server.URL, Delta: true, ResyncInterval: time.Hour})
+	defer h.Close()
+
+	shopWeb := []byte(`{"name":"web-0","namespace":"shop","phase":"Running","restarts":0}`)
+	paymentsWeb := []byte(`{"name":"web-0","namespace":"payments","phase":"Running","restarts":0}`)
+	event := []byte("Pod Event: shop/web-0 Started")
+
+	// The first body is a full sync of every pod
+	if err := h.WriteBatch([][]byte{shopWeb, paymentsWeb}); err != nil {
+		t.Fatal(err)
+	}
+	body, marker := recv.last()
+	if marker != SyncFull || strings.Count(body, "\n") != 2 {
+		t.Fatalf("first post = %q marked %q, want both pods marked %s", body, marker, SyncFull)
+	}
+
+	// Same-named pods of two namespaces are two pods, restating both unchanged posts only the event
+	if err := h.WriteBatch([][]byte{shopWeb, paymentsWeb, event}); err != nil {
+		t.Fatal(err)
+	}
+	if body, marker = recv.last(); marker != SyncDelta || body != string(event)+"\n" {
+		t.Fatalf("unchanged post = %q marked %q, want only the event", body, marker)
+	}
+
+	// A change of one of them posts that one, and a batch with nothing new posts nothing
+	restarted := []byte(`{"name":"web-0","namespace":"payments","phase":"Running","restarts":1}`)
+	if err := h.WriteBatch([][]byte{shopWeb, restarted}); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = recv.last(); body != string(restarted)+"\n" {
+		t.Fatalf("changed post = %q, want the restarted pod", body)
+	}
+	posts := recv.posts()
+	if err := h.WriteBatch([][]byte{shopWeb, restarted}); err != nil {
+		t.Fatal(err)
+	}
+	if recv.posts() != posts {
+		t.Fatalf("an unchanged batch was posted: %q", recv.bodies[len(recv.bodies)-1])
+	}
+
+	// A deletion is posted and leaves the pod out of the next full sync, which keeps the other namespace's pod
+	deletion := []byte("Pod Name: web-0, Namespace: shop, Phase: Deleted, Transition: Running -> Deleted")
+	if err := h.Write(deletion); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = recv.last(); body != string(deletion)+"\n" {
+		t.Fatalf("deletion post = %q", body)
+	}
+	h.delta.resync = true
+	if err := h.Write(event); err != nil {
+		t.Fatal(err)
+	}
+	body, marker = recv.last()
+	if want := string(restarted) + "\n" + string(event) + "\n"; marker != SyncFull || body != want {
+		t.Fatalf("resync = %q marked %q, want %q", body, marker, want)
+	}
+}
+
+func TestPodRecordKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  string
+		key     string
+		deleted bool
+		ok      bool
+	}{
+		{"json", `{"name":"web-0","namespace":"shop","phase":"Running"}`, "shop/web-0", false, true},
+		{"labeled json", `Seq: 7, ID: 01J0, {"name":"web-0","namespace":"shop","phase":"Running"}`, "shop/web-0", false, true},
+		{"text deletion", "Pod Name: web-0, Namespace: shop, Phase: Deleted", "shop/web-0", true, true},
+		{"text pod record", "Pod Name: web-0, Node: n1, Phase: Running", "", false, false},
+		{"event", "Pod Event: shop/web-0 OOMKilled", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, _, deleted, ok := podRecordKey([]byte(tt.record))
+			if key != tt.key || deleted != tt.deleted || ok != tt.ok {
+				t.Errorf("podRecordKey(%q) = %q, %t, %t, want %q, %t, %t", tt.record, key, deleted, ok, tt.key, tt.deleted, tt.ok)
+			}
+		})
+	}
+
+	// A transition's record compares equal to the pod restated unchanged
+	_, withTransition, _, _ := podRecordKey([]byte(`{"name":"a","namespace":"b","phase":"Running","fields":{"Health":"Healthy","Transition":"Pending -> Running"}}`))
+	_, restated, _, _ := podRecordKey([]byte(`{"name":"a","namespace":"b","phase":"Running","fields":{"Health":"Healthy"}}`))
+	if withTransition != restated {
+		t.Errorf("transition record %q differs from restated %q", withTransition, restated)
+	}
+}
@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Record IDs selectable in Options.IDs
+const (
+	IDSequence = "seq"
+	IDULID     = "ulid"
+)
+
+// IDTypes lists the supported record IDs
+var IDTypes = []string{IDSequence, IDULID}
+
+// labeled prefixes every record with its IDs before passing it on, so a consumer of any sink can tell a gap in the
+// sequence or a record delivered twice
+type labeled struct {
+	Sink
+	sequence, ulid bool
+
+	// mu keeps the records in the order of their IDs on the way to the sink
+	mu    sync.Mutex
+	seq   uint64
+	ulids ulidSource
+}
+
+// Label wraps the sink so every record starts with the named IDs, "Seq: 42, ID: 01J9...". The sequence starts at 1
+// with every process unless the sink is a Journal, whose numbering it follows. The ULID is unique across restarts and
+// replicas
+func Label(s Sink, ids []string) (Sink, error) {
+	if len(ids) == 0 {
+		return s, nil
+	}
+	l := &labeled{Sink: s}
+	for _, id := range ids {
+		switch id {
+		case IDSequence:
+			l.sequence = true
+		case IDULID:
+			l.ulid = true
+		default:
+			return nil, fmt.Errorf("unknown record ID %q, expected one of %v", id, IDTypes)
+		}
+	}
+	return l, nil
+}
+
+// sequencer is implemented by sinks that number the records themselves, the Journal, whose numbers the sequence
+// then continues from
+type sequencer interface {
+	Sequence() uint64
+}
+
+// unwrapper is implemented by the pipeline stages, which wrap the sink that may be a sequencer
+type unwrapper interface {
+	Unwrap() Sink
+}
+
+// sync continues the sequence of a sequencer sink, looking through the stages in between. The caller holds mu
+func (l *labeled) sync() {
+	for s := l.Sink; s != nil; {
+		if seq, ok := s.(sequencer); ok {
+			l.seq = seq.Sequence()
+			return
+		}
+		u, ok := s.(unwrapper)
+		if !ok {
+			return
+		}
+		s = u.Unwrap()
+	}
+}
+
+// label returns the record prefixed with the next IDs, the caller holds mu
+func (l *labeled) label(record []byte, now time.Time) []byte {
+	out := make([]byte, 0, len(record)+48)
+	if l.sequence {
+		l.seq++
+		out = append(out, "Seq: "...)
+		out = strconv.AppendUint(out, l.seq, 10)
+		out = append(out, ", "...)
+	}
+	if l.ulid {
+		out = append(out, "ID: "...)
+		out = append(out, l.ulids.next(now)...)
+		out = append(out, ", "...)
+	}
+	return append(out, record...)
+}
+
+// Write writes the labeled record
+func (l *labeled) Write(record []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sync()
+	return l.Sink.Write(l.label(record, time.Now()))
+}
+
+// WriteBatch writes the labeled records in one batch when the wrapped sink supports it
+func (l *labeled) WriteBatch(records [][]byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sync()
+	now := time.Now()
+	out := make([][]byte, len(records))
+	for i, r := range records {
+		out[i] = l.label(r, now)
+	}
+	return WriteBatch(l.Sink, out)
+}
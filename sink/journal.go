@@ -0,0 +1,316 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Files of a journal directory
+const (
+	journalFile = "records.journal"
+	offsetsFile = "offsets.json"
+)
+
+var (
+	// journalPending is how many journaled records each sink has not committed yet
+	journalPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podlogger_sink_pending_records",
+		Help: "Number of journaled records a sink has not acknowledged yet.",
+	}, []string{"sink"})
+
+	// journalDropped counts the records a sink never received because the journal was full
+	journalDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_sink_dropped_records_total",
+		Help: "Number of journaled records dropped before a sink acknowledged them.",
+	}, []string{"sink"})
+)
+
+// journalEntry is a line of the journal file
+type journalEntry struct {
+	Seq    uint64 `json:"seq"`
+	Record string `json:"record"`
+}
+
+// journalOffsets is the offsets file: the last sequence number journaled and the last one each sink committed
+type journalOffsets struct {
+	Sequence uint64            `json:"sequence"`
+	Offsets  map[string]uint64 `json:"offsets"`
+}
+
+// Journal numbers the records and keeps those not yet delivered to every sink on disk, with the offset each sink
+// committed after a successful write. A sink that failed is sent what it missed with the next write, and an agent
+// restarted with the same directory resumes every sink at its offset instead of skipping or repeating records. A
+// sink that fails partway through a batch receives the whole batch again, so delivery is at least once
+type Journal struct {
+	mu    sync.Mutex
+	dir   string
+	names []string
+	sinks map[string]Sink
+	// max bounds the pending records, the oldest are dropped beyond it
+	max  int
+	file *os.File
+	// lines is the number of entries in the journal file, which is compacted once most of them were delivered
+	lines int
+
+	seq     uint64
+	offsets map[string]uint64
+	// pending are the records after the lowest offset, the first one numbered first
+	pending [][]byte
+	first   uint64
+}
+
+// OpenJournal opens or creates the journal in dir for the named sinks, delivering the records they missed before
+// the last shutdown. Sinks new to the journal start at the current sequence number
+func OpenJournal(dir string, names []string, sinks []Sink, maxPending int) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	j := &Journal{dir: dir, names: names, sinks: make(map[string]Sink, len(sinks)), max: maxPending, offsets: make(map[string]uint64)}
+	for i, name := range names {
+		j.sinks[name] = sinks[i]
+	}
+
+	var state journalOffsets
+	data, err := os.ReadFile(filepath.Join(dir, offsetsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", offsetsFile, err)
+		}
+	}
+	j.seq, j.first = state.Sequence, state.Sequence+1
+	for _, name := range names {
+		offset, ok := state.Offsets[name]
+		if !ok || offset > j.seq {
+			offset = j.seq
+		}
+		j.offsets[name] = offset
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	if j.file, err = os.OpenFile(filepath.Join(dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	if len(j.pending) > 0 {
+		log.Printf("Delivering %d journaled records", len(j.pending))
+	}
+	if err := j.deliver(); err != nil {
+		log.Printf("Error delivering journaled records: %v", err)
+	}
+	return j, nil
+}
+
+// load reads the records of the journal file that some sink has not committed
+func (j *Journal) load() error {
+	f, err := os.Open(filepath.Join(j.dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	low := j.lowest()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e journalEntry
+		// A line cut short by a crash is the last one and was never delivered
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Seq <= low {
+			continue
+		}
+		// Journaled just before a crash that kept the offsets from being saved, delivered again to be safe
+		j.seq = max(j.seq, e.Seq)
+		if len(j.pending) == 0 {
+			j.first = e.Seq
+		}
+		j.pending = append(j.pending, []byte(e.Record))
+		j.lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// Records lost from the file cannot be delivered, the sinks resume after them
+	if len(j.pending) == 0 {
+		j.first = j.seq + 1
+	}
+	for name, offset := range j.offsets {
+		if offset < j.first-1 {
+			j.offsets[name] = j.first - 1
+		}
+	}
+	return nil
+}
+
+// Sequence is the number of the last record journaled, records are numbered from 1 across restarts
+func (j *Journal) Sequence() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// Write journals the record and delivers it, with what the sinks missed before
+func (j *Journal) Write(record []byte) error {
+	return j.WriteBatch([][]byte{record})
+}
+
+// WriteBatch journals the records and delivers them to each sink in one batch, with what the sink missed before
+func (j *Journal) WriteBatch(records [][]byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]byte, 0, 64*len(records))
+	for i, r := range records {
+		line, err := json.Marshal(journalEntry{Seq: j.seq + uint64(i) + 1, Record: string(r)})
+		if err != nil {
+			return err
+		}
+		entries = append(append(entries, line...), '\n')
+	}
+	// Records that could not be journaled are not numbered or delivered
+	if _, err := j.file.Write(entries); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if len(j.pending) == 0 {
+		j.first = j.seq + 1
+	}
+	j.seq += uint64(len(records))
+	j.pending = append(j.pending, records...)
+	j.lines += len(records)
+	return j.deliver()
+}
+
+// deliver writes each sink the pending records after its offset and commits the offsets of those that succeeded,
+// then drops the records every sink committed. The caller holds mu
+func (j *Journal) deliver() error {
+	var errs []error
+	for _, name := range j.names {
+		offset := j.offsets[name]
+		if offset < j.seq {
+			if err := WriteBatch(j.sinks[name], j.pending[offset+1-j.first:]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			} else {
+				j.offsets[name] = j.seq
+			}
+		}
+	}
+	j.trim()
+	for _, name := range j.names {
+		journalPending.WithLabelValues(name).Set(float64(j.seq - j.offsets[name]))
+	}
+	if err := j.saveOffsets(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := j.compact(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// trim drops the records every sink committed, and beyond max the oldest ones a lagging sink has not
+func (j *Journal) trim() {
+	if over := len(j.pending) - j.max; j.max > 0 && over > 0 {
+		last := j.first + uint64(over) - 1
+		for _, name := range j.names {
+			if offset := j.offsets[name]; offset < last {
+				journalDropped.WithLabelValues(name).Add(float64(last - offset))
+				log.Printf("Journal full, dropping %d records not delivered to sink %s", last-offset, name)
+				j.offsets[name] = last
+			}
+		}
+	}
+	if done := j.lowest() + 1 - j.first; done > 0 {
+		j.pending = j.pending[done:]
+		j.first += done
+	}
+}
+
+// lowest is the lowest committed offset
+func (j *Journal) lowest() uint64 {
+	low := j.seq
+	for _, offset := range j.offsets {
+		low = min(low, offset)
+	}
+	return low
+}
+
+// compact empties the journal file once every record was delivered, or rewrites it with the pending records once
+// they are less than half of it
+func (j *Journal) compact() error {
+	if len(j.pending) > 0 && j.lines <= 2*len(j.pending) {
+		return nil
+	}
+	if len(j.pending) == 0 {
+		if j.lines == 0 {
+			return nil
+		}
+		j.lines = 0
+		return j.file.Truncate(0)
+	}
+
+	path := filepath.Join(j.dir, journalFile)
+	tmp, err := os.CreateTemp(j.dir, journalFile+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for i, r := range j.pending {
+		line, _ := json.Marshal(journalEntry{Seq: j.first + uint64(i), Record: string(r)})
+		w.Write(append(line, '\n'))
+	}
+	if err := errors.Join(w.Flush(), tmp.Sync(), tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	j.file.Close()
+	if j.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return err
+	}
+	j.lines = len(j.pending)
+	return nil
+}
+
+// saveOffsets replaces the offsets file
+func (j *Journal) saveOffsets() error {
+	data, err := json.Marshal(journalOffsets{Sequence: j.seq, Offsets: j.offsets})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(j.dir, offsetsFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(j.dir, offsetsFile))
+}
+
+// Close saves the offsets and closes the journal and every sink
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	errs := []error{j.saveOffsets(), j.file.Close()}
+	for _, name := range j.names {
+		errs = append(errs, j.sinks[name].Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,388 @@
+//go:build !minimal && !nocloud
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+func init() {
+	openers["archive"] = func(opts Options) (Sink, error) {
+		if opts.Archive.URL == "" {
+			return nil, errors.New("archive sink requires a bucket URL")
+		}
+		return NewArchive(opts.Archive)
+	}
+}
+
+// OpenObjectStore opens the bucket of an s3://bucket/prefix or gs://bucket/prefix URL and returns it with the
+// prefix, credentials are taken from the environment
+func OpenObjectStore(rawURL string) (ObjectStore, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid bucket URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("bucket URL %q names no bucket", rawURL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	client := &http.Client{Timeout: time.Minute}
+	switch u.Scheme {
+	case "s3":
+		store, err := newS3(u.Host, client)
+		return store, prefix, err
+	case "gs":
+		store, err := newGCS(u.Host, client)
+		return store, prefix, err
+	default:
+		return nil, "", fmt.Errorf("unsupported bucket URL %q, expected s3:// or gs://", rawURL)
+	}
+}
+
+// awsCredentials sign S3 requests
+type awsCredentials struct {
+	accessKey, secretKey, sessionToken string
+	expires                            time.Time
+}
+
+// s3 puts objects with Signature Version 4 signed requests
+type s3 struct {
+	bucket   string
+	region   string
+	endpoint string
+	client   *http.Client
+
+	mu          sync.Mutex
+	credentials awsCredentials
+	// roleARN and tokenFile assume a role with a web identity token (IRSA) when there are no static keys
+	roleARN, tokenFile string
+}
+
+// newS3 reads the region, endpoint and credentials from the usual AWS environment variables: AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY with an optional AWS_SESSION_TOKEN, or AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE.
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL point at S3 compatible stores such as MinIO
+func newS3(bucket string, client *http.Client) (*s3, error) {
+	s := &s3{
+		bucket: bucket,
+		region: firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		client: client,
+		credentials: awsCredentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		roleARN:   os.Getenv("AWS_ROLE_ARN"),
+		tokenFile: os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"),
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.credentials.accessKey == "" && (s.roleARN == "" || s.tokenFile == "") {
+		return nil, errors.New("s3 needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		// Compatible stores are addressed by path, the bucket may not resolve as a host name
+		s.endpoint = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+	} else {
+		s.endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, s.region)
+	}
+	return s, nil
+}
+
+// Put uploads the object with a single signed PUT
+func (s *s3) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	creds, err := s.currentCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.endpoint+"/"+escapeKey(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	signV4(req, body, creds, s.region, "s3", time.Now())
+	return do(s.client, req, "s3://"+s.bucket+"/"+key)
+}
+
+// currentCredentials returns the static keys, or the role's temporary ones, assuming it again shortly before they
+// expire
+func (s *s3) currentCredentials(ctx context.Context) (awsCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roleARN == "" || s.tokenFile == "" || (s.credentials.accessKey != "" && s.credentials.expires.IsZero()) {
+		return s.credentials, nil
+	}
+	if time.Until(s.credentials.expires) > 5*time.Minute {
+		return s.credentials, nil
+	}
+	creds, err := s.assumeRole(ctx)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("assuming %s: %w", s.roleARN, err)
+	}
+	s.credentials = creds
+	return creds, nil
+}
+
+// assumeRole exchanges the web identity token for temporary credentials of the role, the request is not signed
+func (s *s3) assumeRole(ctx context.Context) (awsCredentials, error) {
+	token, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {s.roleARN},
+		"RoleSessionName":  {"pod-logger"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/", s.region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(query.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, responseError(resp, endpoint)
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return awsCredentials{}, err
+	}
+	c := result.Credentials
+	return awsCredentials{accessKey: c.AccessKeyID, secretKey: c.SecretAccessKey, sessionToken: c.SessionToken, expires: c.Expiration}, nil
+}
+
+// signV4 adds the Signature Version 4 headers to the request, signing its host, payload hash, date and session
+// token
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if creds.sessionToken != "" {
+		headers["x-amz-security-token"] = creds.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+		if name != "host" {
+			req.Header.Set(name, headers[name])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapeKey escapes an object key for a URL path the way Signature Version 4 expects, everything but unreserved
+// characters and the slashes
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// gcsScope lets a token write objects
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// metadataTokenURL serves the access tokens of the workload's service account on GKE and GCE
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcs uploads objects through the Cloud Storage JSON API
+type gcs struct {
+	bucket string
+	client *http.Client
+	tokens oauth2.TokenSource
+}
+
+// newGCS authenticates with the service account key in GOOGLE_APPLICATION_CREDENTIALS, or else the metadata
+// server, which serves Workload Identity tokens
+func newGCS(bucket string, client *http.Client) (*gcs, error) {
+	g := &gcs{bucket: bucket, client: client}
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var key struct {
+			Type         string `json:"type"`
+			ClientEmail  string `json:"client_email"`
+			PrivateKey   string `json:"private_key"`
+			PrivateKeyID string `json:"private_key_id"`
+			TokenURI     string `json:"token_uri"`
+		}
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, fmt.Errorf("invalid credentials in %s: %w", path, err)
+		}
+		if key.Type != "service_account" {
+			return nil, fmt.Errorf("credentials in %s are of type %q, only service account keys are supported", path, key.Type)
+		}
+		conf := &jwt.Config{
+			Email:        key.ClientEmail,
+			PrivateKey:   []byte(key.PrivateKey),
+			PrivateKeyID: key.PrivateKeyID,
+			Scopes:       []string{gcsScope},
+			TokenURL:     key.TokenURI,
+		}
+		g.tokens = conf.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, client))
+		return g, nil
+	}
+	g.tokens = oauth2.ReuseTokenSource(nil, metadataTokens{client: client})
+	return g, nil
+}
+
+// Put uploads the object with a simple media upload
+func (g *gcs) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	token, err := g.tokens.Token()
+	if err != nil {
+		return fmt.Errorf("gcs token: %w", err)
+	}
+	endpoint := "https://storage.googleapis.com/upload/storage/v1/b/" + url.PathEscape(g.bucket) +
+		"/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	token.SetAuthHeader(req)
+	return do(g.client, req, "gs://"+g.bucket+"/"+key)
+}
+
+// metadataTokens fetches access tokens from the metadata server
+type metadataTokens struct {
+	client *http.Client
+}
+
+// Token requests a new access token
+func (m metadataTokens) Token() (*oauth2.Token, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("metadata server: %w, set GOOGLE_APPLICATION_CREDENTIALS outside GKE and GCE", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp, metadataTokenURL)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// do sends the request, turning responses other than 2xx into errors
+func do(client *http.Client, req *http.Request, target string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp, target)
+	}
+	return nil
+}
+
+// responseError reports the status and the start of the body, which names the reason
+func responseError(resp *http.Response, target string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s returned %s: %s", target, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
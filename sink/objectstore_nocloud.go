@@ -0,0 +1,14 @@
+//go:build minimal || nocloud
+
+package sink
+
+import "errors"
+
+func init() {
+	excluded["archive"] = "nocloud"
+}
+
+// OpenObjectStore fails, the binary was built without the S3 and GCS clients
+func OpenObjectStore(rawURL string) (ObjectStore, string, error) {
+	return nil, "", errors.New("s3 and gcs are not built into this binary, rebuild without -tags nocloud or minimal")
+}
@@ -0,0 +1,400 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Built-in stage types. StageIDs and StageRoute are placed by the pipeline itself, the others are record
+// transformations registered like user-defined ones
+const (
+	StageFilter = "filter"
+	StageEnrich = "enrich"
+	StageRedact = "redact"
+	StageIDs    = "ids"
+	StageRoute  = "route"
+)
+
+// redacted replaces the values masked by redact stages
+const redacted = "[REDACTED]"
+
+// StageSpec configures a stage of the record pipeline
+type StageSpec struct {
+	Name string
+	Type string
+	// Match selects the records of filter and route stages: every field must have a value matching its regexp,
+	// the empty field matching the whole record
+	Match map[string]string
+	// Keep makes a filter stage keep only the matching records instead of dropping them
+	Keep bool
+	// Set are the fields an enrich stage adds, or replaces when the record has them
+	Set map[string]string
+	// Fields are masked whole and Patterns wherever they match by a redact stage
+	Fields   []string
+	Patterns []string
+	// Sinks receive the records a route stage matches, instead of every sink
+	Sinks []string
+}
+
+// Stage transforms the records on their way to the sinks, returning them changed, fewer or none
+type Stage interface {
+	Process(records [][]byte) [][]byte
+}
+
+// StageFunc adapts a function to a Stage
+type StageFunc func(records [][]byte) [][]byte
+
+// Process calls f
+func (f StageFunc) Process(records [][]byte) [][]byte {
+	return f(records)
+}
+
+// stageTypes are the registered stage builders by type
+var (
+	stageTypes   = make(map[string]func(StageSpec) (Stage, error))
+	stageTypesMu sync.RWMutex
+)
+
+func init() {
+	RegisterStage(StageFilter, newFilter)
+	RegisterStage(StageEnrich, newEnrich)
+	RegisterStage(StageRedact, newRedact)
+}
+
+// RegisterStage adds a stage type the pipeline config can name, builders get the stage's spec
+func RegisterStage(typ string, build func(StageSpec) (Stage, error)) {
+	stageTypesMu.Lock()
+	defer stageTypesMu.Unlock()
+	stageTypes[typ] = build
+}
+
+// buildStage creates a stage of a registered type
+func buildStage(spec StageSpec) (Stage, error) {
+	stageTypesMu.RLock()
+	build, ok := stageTypes[spec.Type]
+	stageTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("stage %q has unknown type %q", spec.Name, spec.Type)
+	}
+	stage, err := build(spec)
+	if err != nil {
+		return nil, fmt.Errorf("stage %q: %w", spec.Name, err)
+	}
+	return stage, nil
+}
+
+// staged runs a stage over the records before passing them on
+type staged struct {
+	Sink
+	stage Stage
+}
+
+// Write writes what the stage makes of the record
+func (s *staged) Write(record []byte) error {
+	return s.WriteBatch([][]byte{record})
+}
+
+// WriteBatch writes what the stage makes of the records, nothing when it drops them all
+func (s *staged) WriteBatch(records [][]byte) error {
+	records = s.stage.Process(records)
+	if len(records) == 0 {
+		return nil
+	}
+	return WriteBatch(s.Sink, records)
+}
+
+// Unwrap returns the sink the stage writes to
+func (s *staged) Unwrap() Sink {
+	return s.Sink
+}
+
+// pipeline wraps the sink in the stages of the specs before the route stages, in order, labeling the records with
+// the IDs where the ids stage is, or after the other stages when there is none
+func pipeline(s Sink, specs []StageSpec, ids []string) (Sink, error) {
+	var stages []StageSpec
+	placed := false
+	for _, spec := range specs {
+		switch spec.Type {
+		case StageRoute:
+			continue
+		case StageIDs:
+			placed = true
+		case StageFilter:
+			// A record dropped after labeling would leave a gap in the sequence
+			if placed && len(ids) > 0 {
+				return nil, fmt.Errorf("filter stage %q must come before the ids stage", spec.Name)
+			}
+		}
+		stages = append(stages, spec)
+	}
+	if !placed {
+		stages = append(stages, StageSpec{Name: StageIDs, Type: StageIDs})
+	}
+
+	for i := len(stages) - 1; i >= 0; i-- {
+		spec := stages[i]
+		if spec.Type == StageIDs {
+			labeled, err := Label(s, ids)
+			if err != nil {
+				return nil, err
+			}
+			s = labeled
+			continue
+		}
+		stage, err := buildStage(spec)
+		if err != nil {
+			return nil, err
+		}
+		s = &staged{Sink: s, stage: stage}
+	}
+	return s, nil
+}
+
+// checkStages validates the stage names and that the route stages come last, naming configured sinks
+func checkStages(specs []StageSpec, names []string) error {
+	seen := make(map[string]bool, len(specs))
+	routing := false
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return fmt.Errorf("a %s stage has no name", spec.Type)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("stage %q is defined twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		if spec.Type != StageRoute {
+			if routing {
+				return fmt.Errorf("stage %q follows a route stage, routes come last", spec.Name)
+			}
+			continue
+		}
+		routing = true
+		if len(spec.Sinks) == 0 {
+			return fmt.Errorf("route stage %q names no sinks", spec.Name)
+		}
+		for _, name := range spec.Sinks {
+			if !contains(names, name) {
+				return fmt.Errorf("route stage %q names sink %q, which is not configured", spec.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// matcher matches records by the values of their fields
+type matcher map[string]*regexp.Regexp
+
+// newMatcher compiles the field regexps of a spec
+func newMatcher(match map[string]string) (matcher, error) {
+	m := make(matcher, len(match))
+	for field, expr := range match {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match of %q: %w", field, err)
+		}
+		m[field] = re
+	}
+	return m, nil
+}
+
+// matches reports whether every field of the record matches, a record without one of the fields does not
+func (m matcher) matches(record []byte) bool {
+	if len(m) == 0 {
+		return true
+	}
+	text := string(record)
+	var values map[string]string
+	for field, re := range m {
+		if field == "" {
+			if !re.MatchString(text) {
+				return false
+			}
+			continue
+		}
+		if values == nil {
+			values = fieldValues(text)
+		}
+		value, ok := values[field]
+		if !ok || !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldValues maps the record's field keys to their values
+func fieldValues(record string) map[string]string {
+	fields := splitFields(record)
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		if key, value, ok := strings.Cut(f, ": "); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// newFilter builds a filter stage, which drops the matching records or keeps only them
+func newFilter(spec StageSpec) (Stage, error) {
+	if len(spec.Match) == 0 {
+		return nil, errors.New("a filter needs a match")
+	}
+	m, err := newMatcher(spec.Match)
+	if err != nil {
+		return nil, err
+	}
+	return StageFunc(func(records [][]byte) [][]byte {
+		kept := make([][]byte, 0, len(records))
+		for _, r := range records {
+			if m.matches(r) == spec.Keep {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	}), nil
+}
+
+// newEnrich builds an enrich stage, which sets fields on every record
+func newEnrich(spec StageSpec) (Stage, error) {
+	if len(spec.Set) == 0 {
+		return nil, errors.New("an enrich stage needs fields to set")
+	}
+	keys := make([]string, 0, len(spec.Set))
+	for key := range spec.Set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return StageFunc(func(records [][]byte) [][]byte {
+		out := make([][]byte, len(records))
+		for i, r := range records {
+			fields := splitFields(string(r))
+			for _, key := range keys {
+				fields = setField(fields, key, spec.Set[key])
+			}
+			out[i] = []byte(strings.Join(fields, ", "))
+		}
+		return out
+	}), nil
+}
+
+// setField replaces the value of the field, or appends the field when the record has none by that key
+func setField(fields []string, key, value string) []string {
+	for i, f := range fields {
+		if k, _, ok := strings.Cut(f, ": "); ok && k == key {
+			fields[i] = key + ": " + value
+			return fields
+		}
+	}
+	return append(fields, key+": "+value)
+}
+
+// newRedact builds a redact stage, which masks the values of fields and whatever the patterns match
+func newRedact(spec StageSpec) (Stage, error) {
+	if len(spec.Fields) == 0 && len(spec.Patterns) == 0 {
+		return nil, errors.New("a redact stage needs fields or patterns")
+	}
+	patterns := make([]*regexp.Regexp, len(spec.Patterns))
+	for i, p := range spec.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		patterns[i] = re
+	}
+	return StageFunc(func(records [][]byte) [][]byte {
+		out := make([][]byte, len(records))
+		for i, r := range records {
+			if len(spec.Fields) > 0 {
+				fields := splitFields(string(r))
+				for j, f := range fields {
+					if key, _, ok := strings.Cut(f, ": "); ok && contains(spec.Fields, key) {
+						fields[j] = key + ": " + redacted
+					}
+				}
+				r = []byte(strings.Join(fields, ", "))
+			}
+			for _, re := range patterns {
+				r = re.ReplaceAll(r, []byte(redacted))
+			}
+			out[i] = r
+		}
+		return out
+	}), nil
+}
+
+// route is a compiled route stage
+type route struct {
+	match matcher
+	sinks []string
+}
+
+// router decides which sinks receive a record: those of the first route stage matching it, every sink when none
+// does
+type router []route
+
+// newRouter compiles the route stages of the specs, nil when there are none
+func newRouter(specs []StageSpec) (router, error) {
+	var r router
+	for _, spec := range specs {
+		if spec.Type != StageRoute {
+			continue
+		}
+		m, err := newMatcher(spec.Match)
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %w", spec.Name, err)
+		}
+		r = append(r, route{match: m, sinks: spec.Sinks})
+	}
+	return r, nil
+}
+
+// sends reports whether the record goes to the named sink
+func (r router) sends(record []byte, name string) bool {
+	for _, rt := range r {
+		if rt.match.matches(record) {
+			return contains(rt.sinks, name)
+		}
+	}
+	return true
+}
+
+// routed passes on the records routed to the named sink and drops the others
+type routed struct {
+	Sink
+	name   string
+	router router
+}
+
+// wrap wraps the named sink so it only receives the records the router sends to it
+func (r router) wrap(s Sink, name string) Sink {
+	if len(r) == 0 {
+		return s
+	}
+	return &routed{Sink: s, name: name, router: r}
+}
+
+// Write writes the record when it is routed to the sink
+func (r *routed) Write(record []byte) error {
+	if !r.router.sends(record, r.name) {
+		return nil
+	}
+	return r.Sink.Write(record)
+}
+
+// WriteBatch writes the records routed to the sink
+func (r *routed) WriteBatch(records [][]byte) error {
+	kept := make([][]byte, 0, len(records))
+	for _, rec := range records {
+		if r.router.sends(rec, r.name) {
+			kept = append(kept, rec)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return WriteBatch(r.Sink, kept)
+}
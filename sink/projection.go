@@ -0,0 +1,104 @@
+package sink
+
+import "strings"
+
+// Projection selects the fields of "Key: value, Key: value" records a sink receives.
+// Records without any of the included fields, such as events, pass through unchanged
+type Projection struct {
+	// Include keeps only these fields when set
+	Include []string
+	// Exclude drops these fields
+	Exclude []string
+}
+
+// empty reports whether the projection keeps every field
+func (p Projection) empty() bool {
+	return len(p.Include) == 0 && len(p.Exclude) == 0
+}
+
+// Apply returns the record reduced to the selected fields
+func (p Projection) Apply(record []byte) []byte {
+	if p.empty() {
+		return record
+	}
+	fields := splitFields(string(record))
+	kept := make([]string, 0, len(fields))
+	matched := len(p.Include) == 0
+	for _, f := range fields {
+		key, _, _ := strings.Cut(f, ": ")
+		if contains(p.Exclude, key) {
+			continue
+		}
+		if len(p.Include) > 0 {
+			if !contains(p.Include, key) {
+				continue
+			}
+			matched = true
+		}
+		kept = append(kept, f)
+	}
+	if !matched {
+		return record
+	}
+	return []byte(strings.Join(kept, ", "))
+}
+
+// splitFields splits a record on the ", " separators that are not inside brackets
+func splitFields(record string) []string {
+	var fields []string
+	depth, start := 0, 0
+	for i := 0; i < len(record); i++ {
+		switch record[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 && i+1 < len(record) && record[i+1] == ' ' {
+				fields = append(fields, record[start:i])
+				start = i + 2
+				i++
+			}
+		}
+	}
+	return append(fields, record[start:])
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// projected applies a projection to every record before passing it on
+type projected struct {
+	Sink
+	projection Projection
+}
+
+// Project wraps the sink so it only receives the fields selected by the projection
+func Project(s Sink, p Projection) Sink {
+	if p.empty() {
+		return s
+	}
+	return &projected{Sink: s, projection: p}
+}
+
+// Write writes the projected record
+func (p *projected) Write(record []byte) error {
+	return p.Sink.Write(p.projection.Apply(record))
+}
+
+// WriteBatch writes the projected records in one batch when the wrapped sink supports it
+func (p *projected) WriteBatch(records [][]byte) error {
+	out := make([][]byte, len(records))
+	for i, r := range records {
+		out[i] = p.projection.Apply(r)
+	}
+	return WriteBatch(p.Sink, out)
+}
@@ -0,0 +1,178 @@
+package sink
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Priority ranks records for load shedding, a full queue drops the lowest first
+type Priority int
+
+const (
+	// PriorityEnrichment is for context around the cluster state, such as volume and autoscaler reports
+	PriorityEnrichment Priority = iota
+	// PriorityHeartbeat is for records restating an unchanged state, such as pod records without a transition and
+	// workload rollups
+	PriorityHeartbeat
+	// PriorityTransition is for changes: pod transitions, events, finished jobs, rollouts
+	PriorityTransition
+	// PriorityAlert is for alerts, shed last
+	PriorityAlert
+)
+
+// closeTimeout bounds how long Close waits for the queue to drain
+const closeTimeout = 10 * time.Second
+
+// priorityNames are the metric labels of the priorities
+var priorityNames = [...]string{"enrichment", "heartbeat", "transition", "alert"}
+
+// String names the priority
+func (p Priority) String() string {
+	if p < 0 || int(p) >= len(priorityNames) {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+var (
+	// shedRecords counts the records dropped by a full queue
+	shedRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "podlogger_records_shed_total",
+		Help: "Number of records dropped because the sink queue was full, by priority.",
+	}, []string{"priority"})
+
+	// queuedRecords is the number of records waiting for the sinks
+	queuedRecords = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "podlogger_record_queue_depth",
+		Help: "Number of records queued for the sinks.",
+	})
+)
+
+// PriorityWriter is implemented by sinks that shed records by priority under backpressure
+type PriorityWriter interface {
+	WritePriority(p Priority, records [][]byte) error
+}
+
+// WritePriority writes the records with their priority when the sink sheds load, as a batch otherwise
+func WritePriority(s Sink, p Priority, records ...[]byte) error {
+	if pw, ok := s.(PriorityWriter); ok {
+		return pw.WritePriority(p, records)
+	}
+	return WriteBatch(s, records)
+}
+
+// queuedRecord is a record waiting in a Shedder's queue
+type queuedRecord struct {
+	priority Priority
+	record   []byte
+}
+
+// Shedder queues records for the sink it wraps and writes them in the background, so a slow sink does not hold up
+// the reporting passes. Once the queue is full a new record replaces the oldest record of a lower priority, or is
+// dropped when none is queued. Write errors are logged rather than returned
+type Shedder struct {
+	next     Sink
+	capacity int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []queuedRecord
+	closing bool
+	done    chan struct{}
+}
+
+// Shed wraps the sink with a queue of capacity records
+func Shed(s Sink, capacity int) *Shedder {
+	sh := &Shedder{next: s, capacity: capacity, done: make(chan struct{})}
+	sh.cond = sync.NewCond(&sh.mu)
+	go sh.run()
+	return sh
+}
+
+// Write queues the record as a transition, records written without a priority are kept over restated state
+func (sh *Shedder) Write(record []byte) error {
+	return sh.WritePriority(PriorityTransition, [][]byte{record})
+}
+
+// WriteBatch queues the records as transitions
+func (sh *Shedder) WriteBatch(records [][]byte) error {
+	return sh.WritePriority(PriorityTransition, records)
+}
+
+// WritePriority queues the records, shedding the lowest priority ones when the queue is full
+func (sh *Shedder) WritePriority(p Priority, records [][]byte) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for _, r := range records {
+		if len(sh.queue) >= sh.capacity && !sh.evict(p) {
+			shedRecords.WithLabelValues(p.String()).Inc()
+			continue
+		}
+		sh.queue = append(sh.queue, queuedRecord{p, r})
+	}
+	queuedRecords.Set(float64(len(sh.queue)))
+	sh.cond.Signal()
+	return nil
+}
+
+// evict drops the oldest queued record of the lowest priority below p, reporting whether there was one. The caller
+// holds mu
+func (sh *Shedder) evict(p Priority) bool {
+	victim := -1
+	for i, q := range sh.queue {
+		if q.priority < p && (victim < 0 || q.priority < sh.queue[victim].priority) {
+			victim = i
+		}
+	}
+	if victim < 0 {
+		return false
+	}
+	shedRecords.WithLabelValues(sh.queue[victim].priority.String()).Inc()
+	sh.queue = append(sh.queue[:victim], sh.queue[victim+1:]...)
+	return true
+}
+
+// run writes the queued records to the sink in batches until the Shedder is closed and its queue drained
+func (sh *Shedder) run() {
+	defer close(sh.done)
+	for {
+		sh.mu.Lock()
+		for len(sh.queue) == 0 && !sh.closing {
+			sh.cond.Wait()
+		}
+		if len(sh.queue) == 0 {
+			sh.mu.Unlock()
+			return
+		}
+		queued := sh.queue
+		sh.queue = nil
+		queuedRecords.Set(0)
+		sh.mu.Unlock()
+
+		records := make([][]byte, len(queued))
+		for i, q := range queued {
+			records[i] = q.record
+		}
+		if err := WriteBatch(sh.next, records); err != nil {
+			log.Printf("Error writing %d queued records: %v", len(records), err)
+		}
+	}
+}
+
+// Close writes what is queued, waiting up to closeTimeout, and closes the sink
+func (sh *Shedder) Close() error {
+	sh.mu.Lock()
+	sh.closing = true
+	sh.cond.Signal()
+	sh.mu.Unlock()
+	select {
+	case <-sh.done:
+	case <-time.After(closeTimeout):
+		log.Printf("Sink queue not drained after %s, closing anyway", closeTimeout)
+	}
+	return sh.next.Close()
+}
@@ -0,0 +1,232 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sink is a destination for log records. Implementations must be safe for concurrent use
+type Sink interface {
+	// Write emits a single record
+	Write(record []byte) error
+	// Close flushes and releases the sink
+	Close() error
+}
+
+// BatchWriter is implemented by sinks that can write several records more cheaply than one at a time
+type BatchWriter interface {
+	WriteBatch(records [][]byte) error
+}
+
+// WriteBatch writes the records with a single WriteBatch call when the sink supports it
+func WriteBatch(s Sink, records [][]byte) error {
+	if bw, ok := s.(BatchWriter); ok {
+		return bw.WriteBatch(records)
+	}
+	var errs []error
+	for _, r := range records {
+		if err := s.Write(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Options configures the built-in sinks
+type Options struct {
+	FilePath     string
+	FileRotation Rotation
+	HTTP         HTTPOptions
+	SyslogAddr   string
+	SyslogTag    string
+	Archive      ArchiveOptions
+	// Projections select the fields written to each sink, keyed by sink name
+	Projections map[string]Projection
+	// IDs are the IDs every record is labeled with before it fans out to the sinks, see IDTypes
+	IDs []string
+	// Journal is the directory of a Journal in front of the sinks, empty for none
+	Journal string
+	// JournalMax bounds the records journaled for a lagging sink, 0 keeps all of them
+	JournalMax int
+	// QueueSize queues records for the sinks in a Shedder of this capacity, 0 writes them synchronously
+	QueueSize int
+	// Buffers put a Buffered in front of the sinks with a buffer size, keyed by sink name
+	Buffers map[string]BufferOptions
+	// Pipeline are the stages records pass on their way to the sinks, in order, see StageSpec
+	Pipeline []StageSpec
+	// Delivered is called with the records each sink wrote, after they were written, nil for none
+	Delivered func(sink string, records [][]byte)
+}
+
+// Open creates the named sinks, fanning out to all of them, each behind its buffer when one is configured. Records
+// pass the pipeline first: a queue when QueueSize is set, the stages in order with the IDs labeled at the ids stage
+// or after the others, and the route stages deciding which sinks get each record. A Journal sits in front of the
+// sinks when configured
+func Open(names []string, opts Options) (Sink, error) {
+	if len(names) == 0 {
+		return nil, errors.New("no sinks configured")
+	}
+	if err := checkStages(opts.Pipeline, names); err != nil {
+		return nil, err
+	}
+	routes, err := newRouter(opts.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []Sink
+	for _, name := range names {
+		s, err := open(name, opts)
+		if err != nil {
+			for _, opened := range sinks {
+				opened.Close()
+			}
+			return nil, err
+		}
+		if opts.Delivered != nil {
+			s = &observed{Sink: s, name: name, delivered: opts.Delivered}
+		}
+		// Behind the buffer, so a record counts as delivered once the sink wrote it
+		if b := opts.Buffers[name]; b.Size > 0 {
+			s = Buffer(name, s, b)
+		}
+		sinks = append(sinks, routes.wrap(Project(s, opts.Projections[name]), name))
+	}
+	var s Sink = Multi(sinks)
+	switch {
+	case opts.Journal != "":
+		j, err := OpenJournal(opts.Journal, names, sinks, opts.JournalMax)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s = j
+	case len(sinks) == 1:
+		s = sinks[0]
+	}
+	// Labeled once, so every sink receives the same IDs for a record
+	staged, err := pipeline(s, opts.Pipeline, opts.IDs)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	// Shed before labeling, so a record dropped on purpose leaves no gap in the sequence
+	if opts.QueueSize > 0 {
+		return Shed(staged, opts.QueueSize), nil
+	}
+	return staged, nil
+}
+
+// openers create the sinks by name, only the named sinks are opened. Optional sinks register theirs from files
+// a build tag leaves out, see excluded
+var openers = map[string]func(Options) (Sink, error){
+	"file": func(opts Options) (Sink, error) {
+		return NewFile(opts.FilePath, opts.FileRotation)
+	},
+	"stdout": func(Options) (Sink, error) {
+		return NewStdout(), nil
+	},
+	"syslog": func(opts Options) (Sink, error) {
+		return NewSyslog(opts.SyslogAddr, opts.SyslogTag)
+	},
+	"http": func(opts Options) (Sink, error) {
+		if opts.HTTP.URL == "" {
+			return nil, errors.New("http sink requires a URL")
+		}
+		return NewHTTP(opts.HTTP), nil
+	},
+}
+
+// excluded maps the optional sinks left out of this binary to the build tag leaving them out
+var excluded = make(map[string]string)
+
+func open(name string, opts Options) (Sink, error) {
+	if tag, ok := excluded[name]; ok {
+		return nil, fmt.Errorf("%s sink is not built into this binary, rebuild without -tags %s or minimal", name, tag)
+	}
+	o, ok := openers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q", name)
+	}
+	return o(opts)
+}
+
+// observed reports the records its sink wrote
+type observed struct {
+	Sink
+	name      string
+	delivered func(sink string, records [][]byte)
+}
+
+// Write writes the record and reports it once written
+func (o *observed) Write(record []byte) error {
+	if err := o.Sink.Write(record); err != nil {
+		return err
+	}
+	o.delivered(o.name, [][]byte{record})
+	return nil
+}
+
+// WriteBatch writes the records and reports them once all were written
+func (o *observed) WriteBatch(records [][]byte) error {
+	if err := WriteBatch(o.Sink, records); err != nil {
+		return err
+	}
+	o.delivered(o.name, records)
+	return nil
+}
+
+// Multi writes every record to all of its sinks
+type Multi []Sink
+
+// Write writes the record to every sink, returning the combined errors
+func (m Multi) Write(record []byte) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Write(record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteBatch writes the records to every sink, returning the combined errors
+func (m Multi) WriteBatch(records [][]byte) error {
+	var errs []error
+	for _, s := range m {
+		if err := WriteBatch(s, records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every sink, returning the combined errors
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lines joins the records into newline terminated lines
+func lines(records [][]byte) []byte {
+	size := 0
+	for _, r := range records {
+		size += len(r) + 1
+	}
+	l := make([]byte, 0, size)
+	for _, r := range records {
+		l = append(append(l, r...), '\n')
+	}
+	return l
+}
+
+// line copies the record with a trailing newline, leaving the caller's slice untouched
+func line(record []byte) []byte {
+	l := make([]byte, 0, len(record)+1)
+	return append(append(l, record...), '\n')
+}
@@ -0,0 +1,37 @@
+package sink
+
+import (
+	"os"
+	"sync"
+)
+
+// Stdout writes records as lines to standard output
+type Stdout struct {
+	mu sync.Mutex
+}
+
+// NewStdout creates a sink writing to standard output
+func NewStdout() *Stdout {
+	return &Stdout{}
+}
+
+// Write prints the record followed by a newline
+func (s *Stdout) Write(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := os.Stdout.Write(line(record))
+	return err
+}
+
+// WriteBatch prints all records with a single write
+func (s *Stdout) WriteBatch(records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := os.Stdout.Write(lines(records))
+	return err
+}
+
+// Close is a no-op, standard output is left open
+func (s *Stdout) Close() error {
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+
+package sink
+
+import (
+	"log/syslog"
+	"strings"
+)
+
+// Syslog sends records to a local or remote syslog daemon
+type Syslog struct {
+	writer *syslog.Writer
+}
+
+// NewSyslog connects to the syslog daemon at addr (e.g. "udp://host:514"), or the local one when addr is empty
+func NewSyslog(addr, tag string) (*Syslog, error) {
+	network, raddr := splitAddr(addr)
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Syslog{writer: w}, nil
+}
+
+// Write sends the record as a single syslog message
+func (s *Syslog) Write(record []byte) error {
+	_, err := s.writer.Write(record)
+	return err
+}
+
+// Close closes the connection to the daemon
+func (s *Syslog) Close() error {
+	return s.writer.Close()
+}
+
+// splitAddr splits "network://address" into its parts, defaulting to an empty network
+func splitAddr(addr string) (string, string) {
+	if network, raddr, ok := strings.Cut(addr, "://"); ok {
+		return network, raddr
+	}
+	return "", addr
+}
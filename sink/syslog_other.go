@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package sink
+
+import "errors"
+
+// NewSyslog is not supported on this platform
+func NewSyslog(addr, tag string) (Sink, error) {
+	return nil, errors.New("syslog sink is not supported on this platform")
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidSource generates ULIDs that sort in the order they were generated: within the same millisecond the random part
+// of the previous one is incremented rather than drawn again. It is not safe for concurrent use
+type ulidSource struct {
+	ms uint64
+	// hi and lo are the 80 random bits, 16 in hi and 64 in lo
+	hi uint16
+	lo uint64
+}
+
+// next returns the ULID for t
+func (u *ulidSource) next(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+	if ms <= u.ms {
+		// Same millisecond, or the clock went back: stay monotonic
+		ms = u.ms
+		u.lo++
+		if u.lo == 0 {
+			u.hi++
+		}
+	} else {
+		var random [10]byte
+		rand.Read(random[:])
+		u.hi = binary.BigEndian.Uint16(random[:2])
+		u.lo = binary.BigEndian.Uint64(random[2:])
+	}
+	u.ms = ms
+
+	// 48 bits of time and 80 random bits are encoded as 26 characters of 5 bits, the first holding the top 3 bits
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	binary.BigEndian.PutUint16(id[6:8], u.hi)
+	binary.BigEndian.PutUint64(id[8:16], u.lo)
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		bit := 128 - 5*(26-i)
+		out[i] = crockford[bits5(id[:], bit)]
+	}
+	return string(out)
+}
+
+// bits5 reads the 5 bits of id starting at bit, counted from the most significant one. Bits before the 128 of id,
+// which only the first character covers, read as zero
+func bits5(id []byte, bit int) byte {
+	var v byte
+	for i := 0; i < 5; i++ {
+		v <<= 1
+		if b := bit + i; b >= 0 && id[b/8]&(0x80>>(b%8)) != 0 {
+			v |= 1
+		}
+	}
+	return v
+}
@@ -0,0 +1,181 @@
+// Package store keeps the watched pods in memory, indexed by node and namespace, so the APIs and reporters read them
+// without listing pods from the apiserver
+package store
+
+import (
+	"adv-go/model"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// uidSet is a set of pod UIDs
+type uidSet map[types.UID]struct{}
+
+// Store is a concurrent map of pods keyed by UID with indexes by node and namespace, fed by pod informers
+type Store struct {
+	mu          sync.RWMutex
+	pods        map[types.UID]*model.Pod
+	byNode      map[string]uidSet
+	byNamespace map[string]uidSet
+	synced      []cache.InformerSynced
+}
+
+// New creates an empty store
+func New() *Store {
+	s := &Store{}
+	s.Reset()
+	return s
+}
+
+// Reset empties the store and forgets the informers feeding it
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pods = make(map[types.UID]*model.Pod)
+	s.byNode = make(map[string]uidSet)
+	s.byNamespace = make(map[string]uidSet)
+	s.synced = nil
+}
+
+// Handler returns the informer event handler that keeps the store in sync with the informer's cache. The informer
+// counts towards HasSynced
+func (s *Store) Handler(informer cache.SharedIndexInformer) cache.ResourceEventHandler {
+	s.mu.Lock()
+	s.synced = append(s.synced, informer.HasSynced)
+	s.mu.Unlock()
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				s.Upsert(pod)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				s.Upsert(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				s.Delete(pod.UID)
+			}
+		},
+	}
+}
+
+// HasSynced reports whether every informer feeding the store has synced, false when none feeds it
+func (s *Store) HasSynced() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.synced) == 0 {
+		return false
+	}
+	for _, synced := range s.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Upsert adds the pod or updates the stored one, moving it between the node indexes when it was scheduled
+func (s *Store) Upsert(pod *v1.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pods[pod.UID]; ok {
+		if node := p.NodeName(); node != pod.Spec.NodeName {
+			remove(s.byNode, node, pod.UID)
+			add(s.byNode, pod.Spec.NodeName, pod.UID)
+		}
+		p.Update(pod)
+		return
+	}
+	s.pods[pod.UID] = model.NewPod(pod)
+	add(s.byNode, pod.Spec.NodeName, pod.UID)
+	add(s.byNamespace, pod.Namespace, pod.UID)
+}
+
+// Delete removes the pod
+func (s *Store) Delete(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pods[uid]
+	if !ok {
+		return
+	}
+	delete(s.pods, uid)
+	remove(s.byNode, p.NodeName(), uid)
+	remove(s.byNamespace, p.Namespace(), uid)
+}
+
+// Get returns the pod with the UID
+func (s *Store) Get(uid types.UID) (*model.Pod, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.pods[uid]
+	return p, ok
+}
+
+// Len is the number of stored pods
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.pods)
+}
+
+// List returns every stored pod in no particular order
+func (s *Store) List() []*model.Pod {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pods := make([]*model.Pod, 0, len(s.pods))
+	for _, p := range s.pods {
+		pods = append(pods, p)
+	}
+	return pods
+}
+
+// ByNamespace returns the pods of the namespace in no particular order
+func (s *Store) ByNamespace(namespace string) []*model.Pod {
+	return s.indexed(s.byNamespace, namespace)
+}
+
+// ByNode returns the pods scheduled to the node in no particular order, "" returns the pods not scheduled yet
+func (s *Store) ByNode(node string) []*model.Pod {
+	return s.indexed(s.byNode, node)
+}
+
+// indexed returns the pods of an index entry
+func (s *Store) indexed(index map[string]uidSet, key string) []*model.Pod {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	uids := index[key]
+	pods := make([]*model.Pod, 0, len(uids))
+	for uid := range uids {
+		pods = append(pods, s.pods[uid])
+	}
+	return pods
+}
+
+// add puts the UID in the index entry
+func add(index map[string]uidSet, key string, uid types.UID) {
+	set, ok := index[key]
+	if !ok {
+		set = make(uidSet)
+		index[key] = set
+	}
+	set[uid] = struct{}{}
+}
+
+// remove takes the UID out of the index entry, dropping the entry once empty
+func remove(index map[string]uidSet, key string, uid types.UID) {
+	set := index[key]
+	delete(set, uid)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
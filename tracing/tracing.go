@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of the agent's own observation pipeline
+const tracerName = "adv-go"
+
+// linkTemplate renders trace IDs as links to the tracing UI, {traceID} is replaced with the ID
+var linkTemplate string
+
+// Setup installs a tracer provider exporting to the OTLP/HTTP endpoint (e.g. "tempo:4318") and
+// returns a function flushing pending spans. Tracing stays a no-op when Setup is not called
+func Setup(ctx context.Context, endpoint string, sampleRatio float64, link string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("pod-logger"))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	linkTemplate = link
+	return provider.Shutdown, nil
+}
+
+// Start starts a span of the observation pipeline
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail marks the span as failed with err, nil errors leave it untouched
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Transport wraps an HTTP transport so each request is a client span of the trace in its context, with the
+// trace context propagated to the server. The span ends with the response headers, so a watch is traced until it
+// starts streaming
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripper{next: rt}
+}
+
+// roundTripper traces the requests it forwards to next
+type roundTripper struct {
+	next http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			attribute.Bool("k8s.watch", req.URL.Query().Get("watch") == "true"),
+		))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		Fail(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+// TraceID returns the ID of the sampled trace in ctx, or an empty string when there is none
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Link returns the link to the trace in the tracing UI, or an empty string when no link template is set
+func Link(traceID string) string {
+	if linkTemplate == "" || traceID == "" {
+		return ""
+	}
+	return strings.ReplaceAll(linkTemplate, "{traceID}", traceID)
+}
+
+// Annotate appends the trace ID and link of ctx to an alert payload line
+func Annotate(ctx context.Context, line string) string {
+	traceID := TraceID(ctx)
+	if traceID == "" {
+		return line
+	}
+	line += ", TraceID: " + traceID
+	if link := Link(traceID); link != "" {
+		line += ", Trace: " + link
+	}
+	return line
+}